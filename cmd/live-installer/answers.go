@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/network"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/security"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// answersCmdlineKey is the kernel command line parameter listing answer file sources
+	answersCmdlineKey = "inst.answers="
+	// answersFileName is the answer file looked up by the default sources
+	answersFileName = "answers.yml"
+	// answersPartitionLabel is the filesystem label of the default answer partition
+	answersPartitionLabel = "OIC_ANSWERS"
+	// maxAnswersSize bounds the size of an answer file fetched from any source
+	maxAnswersSize = 1 << 20
+
+	FinishReboot   = "reboot"
+	FinishPoweroff = "poweroff"
+	FinishNone     = "none"
+)
+
+var (
+	procCmdlinePath     = "/proc/cmdline"
+	finishActionFile    = "/run/live-installer/finish"
+	answersFetchRetries = 3
+	answersFetchDelay   = 5 * time.Second
+)
+
+// partitionSourcePrefixes maps partition selectors to their udev symlink directories
+var partitionSourcePrefixes = map[string]string{
+	"LABEL=":     "/dev/disk/by-label",
+	"UUID=":      "/dev/disk/by-uuid",
+	"PARTLABEL=": "/dev/disk/by-partlabel",
+	"PARTUUID=":  "/dev/disk/by-partuuid",
+}
+
+// Answers holds the per-machine decisions of an unattended installation
+type Answers struct {
+	Disk     DiskSelector        `yaml:"disk,omitempty"`     // Disk: rules used to pick the target disk
+	Hostname string              `yaml:"hostname,omitempty"` // Hostname: overrides systemConfig.hostname
+	Users    []config.UserConfig `yaml:"users,omitempty"`    // Users: merged into systemConfig.users by name
+	Timezone string              `yaml:"timezone,omitempty"` // Timezone: overrides systemConfig.localization.timezone
+	Finish   string              `yaml:"finish,omitempty"`   // Finish: action after a successful install (reboot, poweroff, none)
+}
+
+// DiskSelector describes how the target disk is picked from the disks present on the machine
+type DiskSelector struct {
+	Path    string `yaml:"path,omitempty"`    // Path: exact device path (e.g., /dev/nvme0n1)
+	Model   string `yaml:"model,omitempty"`   // Model: shell glob matched against the disk model
+	Serial  string `yaml:"serial,omitempty"`  // Serial: exact disk serial number
+	MinSize string `yaml:"minSize,omitempty"` // MinSize: smallest acceptable disk size (e.g., 64GiB)
+	MaxSize string `yaml:"maxSize,omitempty"` // MaxSize: largest acceptable disk size
+	Largest bool   `yaml:"largest,omitempty"` // Largest: pick the largest matching disk instead of requiring a unique match
+	Wipe    bool   `yaml:"wipe,omitempty"`    // Wipe: allow erasing existing partitions and filesystems on the selected disk
+}
+
+// IsEmpty reports whether the selector sets no disk matching rule
+func (s DiskSelector) IsEmpty() bool {
+	s.Wipe = false
+	return s == DiskSelector{}
+}
+
+// parseAnswers decodes and validates an answer file
+func parseAnswers(data []byte) (*Answers, error) {
+	var answers Answers
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&answers); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("answer file is empty")
+		}
+		return nil, fmt.Errorf("failed to parse answer file: %w", err)
+	}
+	if err := answers.validate(); err != nil {
+		return nil, err
+	}
+	return &answers, nil
+}
+
+func (a *Answers) validate() error {
+	switch a.Finish {
+	case "", FinishReboot, FinishPoweroff, FinishNone:
+	default:
+		return fmt.Errorf("invalid finish action %q, expected %s, %s or %s",
+			a.Finish, FinishReboot, FinishPoweroff, FinishNone)
+	}
+	for _, size := range []string{a.Disk.MinSize, a.Disk.MaxSize} {
+		if size == "" {
+			continue
+		}
+		if _, err := imagedisc.TranslateSizeStrToBytes(size); err != nil {
+			return fmt.Errorf("invalid disk size %q in answer file: %w", size, err)
+		}
+	}
+	if a.Disk.Model != "" {
+		if _, err := filepath.Match(a.Disk.Model, ""); err != nil {
+			return fmt.Errorf("invalid disk model pattern %q: %w", a.Disk.Model, err)
+		}
+	}
+	for i, user := range a.Users {
+		if user.Name == "" {
+			return fmt.Errorf("user %d in answer file has no name", i)
+		}
+	}
+	return nil
+}
+
+// answerSources returns the answer file sources in the order they are tried.
+// Sources come from the --answers flag or, failing that, from inst.answers= on the
+// kernel command line; both accept a comma separated list. The answer partition and
+// an answers.yml next to the template are always appended as fallbacks. explicit
+// reports whether the operator asked for answers, in which case missing answers are fatal.
+func answerSources(flagValue, templateDir string) (sources []string, explicit bool) {
+	value := flagValue
+	if value == "" {
+		if cmdline, err := os.ReadFile(procCmdlinePath); err == nil {
+			value = parseCmdlineValue(string(cmdline), answersCmdlineKey)
+		}
+	}
+	for _, source := range strings.Split(value, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	explicit = len(sources) > 0
+
+	sources = append(sources,
+		"LABEL="+answersPartitionLabel+":/"+answersFileName,
+		filepath.Join(templateDir, answersFileName))
+	return sources, explicit
+}
+
+// parseCmdlineValue extracts the value of a key= parameter from a kernel command line
+func parseCmdlineValue(cmdline, key string) string {
+	var value string
+	for _, param := range strings.Fields(cmdline) {
+		if strings.HasPrefix(param, key) {
+			// The last occurrence wins, as for other kernel parameters
+			value = strings.TrimPrefix(param, key)
+		}
+	}
+	return value
+}
+
+// loadAnswers returns the answers from the first readable source
+func loadAnswers(sources []string, explicit bool) (*Answers, error) {
+	for _, source := range sources {
+		data, err := readAnswerSource(source)
+		if err != nil {
+			if explicit {
+				log.Warnf("Answer file source %s unavailable: %v", source, err)
+			} else {
+				log.Debugf("Answer file source %s unavailable: %v", source, err)
+			}
+			continue
+		}
+		answers, err := parseAnswers(data)
+		if err != nil {
+			log.Errorf("Invalid answer file from %s: %v", source, err)
+			return nil, fmt.Errorf("invalid answer file from %s: %w", source, err)
+		}
+		log.Infof("Loaded answer file from %s", source)
+		return answers, nil
+	}
+	if explicit {
+		log.Errorf("No answer file could be read from: %s", strings.Join(sources, ", "))
+		return nil, fmt.Errorf("no answer file could be read from: %s", strings.Join(sources, ", "))
+	}
+	log.Debugf("No answer file found, installing with template settings")
+	return nil, nil
+}
+
+// readAnswerSource reads an answer file from a URL, a partition or a local path
+func readAnswerSource(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return fetchAnswers(source)
+	}
+	if device, path, ok := splitPartitionSource(source); ok {
+		return readAnswersFromPartition(device, path)
+	}
+	return readAnswersFile(source)
+}
+
+func readAnswersFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxAnswersSize {
+		return nil, fmt.Errorf("answer file %s exceeds %d bytes", path, maxAnswersSize)
+	}
+	return security.SafeReadFile(path, security.RejectSymlinks)
+}
+
+// fetchAnswers downloads an answer file, retrying while the network comes up
+func fetchAnswers(url string) ([]byte, error) {
+	client := network.GetSecureHTTPClient()
+	var lastErr error
+	for attempt := 1; attempt <= answersFetchRetries; attempt++ {
+		if attempt > 1 {
+			time.Sleep(answersFetchDelay)
+		}
+		resp, err := client.Get(url)
+		if err != nil {
+			lastErr = err
+			log.Debugf("Fetching answer file %s failed (attempt %d/%d): %v", url, attempt, answersFetchRetries, err)
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxAnswersSize+1))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("unexpected HTTP status %s", resp.Status)
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(data) > maxAnswersSize {
+			return nil, fmt.Errorf("answer file %s exceeds %d bytes", url, maxAnswersSize)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("failed to fetch %s: %w", url, lastErr)
+}
+
+// splitPartitionSource splits "LABEL=name:/path" or "/dev/sdb2:/path" into a device and a file path
+func splitPartitionSource(source string) (device, path string, ok bool) {
+	idx := strings.Index(source, ":/")
+	if idx <= 0 {
+		return "", "", false
+	}
+	spec, path := source[:idx], source[idx+1:]
+	if strings.HasPrefix(spec, "/dev/") {
+		return spec, path, true
+	}
+	for prefix, dir := range partitionSourcePrefixes {
+		if strings.HasPrefix(spec, prefix) {
+			return filepath.Join(dir, strings.TrimPrefix(spec, prefix)), path, true
+		}
+	}
+	return "", "", false
+}
+
+// readAnswersFromPartition mounts the partition read-only and reads the answer file from it
+func readAnswersFromPartition(device, path string) ([]byte, error) {
+	if _, err := os.Stat(device); err != nil {
+		return nil, fmt.Errorf("answer partition %s not present: %w", device, err)
+	}
+	mountDir, err := os.MkdirTemp(config.TempDir(), "answers-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create answer partition mount point: %w", err)
+	}
+	defer os.RemoveAll(mountDir)
+
+	if _, err := shell.ExecCmd(fmt.Sprintf("mount -o ro %s %s", device, mountDir), true, shell.HostPath, nil); err != nil {
+		return nil, fmt.Errorf("failed to mount answer partition %s: %w", device, err)
+	}
+	defer func() {
+		if _, err := shell.ExecCmd("umount "+mountDir, true, shell.HostPath, nil); err != nil {
+			log.Warnf("Failed to unmount answer partition %s: %v", device, err)
+		}
+	}()
+
+	filePath := filepath.Join(mountDir, path)
+	if !strings.HasPrefix(filePath, mountDir+string(filepath.Separator)) {
+		return nil, fmt.Errorf("answer file path %s escapes the partition", path)
+	}
+	return readAnswersFile(filePath)
+}
+
+// selectDisk returns the device path of the disk matching the selector
+func selectDisk(selector DiskSelector, devices []imagedisc.SystemBlockDevice) (string, error) {
+	var minSize, maxSize uint64
+	var err error
+	if selector.MinSize != "" {
+		if minSize, err = imagedisc.TranslateSizeStrToBytes(selector.MinSize); err != nil {
+			return "", fmt.Errorf("invalid minimum disk size %s: %w", selector.MinSize, err)
+		}
+	}
+	if selector.MaxSize != "" {
+		if maxSize, err = imagedisc.TranslateSizeStrToBytes(selector.MaxSize); err != nil {
+			return "", fmt.Errorf("invalid maximum disk size %s: %w", selector.MaxSize, err)
+		}
+	}
+
+	var candidates []imagedisc.SystemBlockDevice
+	for _, device := range devices {
+		if selector.Path != "" {
+			if device.DevicePath != selector.Path {
+				continue
+			}
+		} else if device.Removable {
+			// Removable media is only ever picked when named explicitly
+			continue
+		}
+		if selector.Serial != "" && device.Serial != selector.Serial {
+			continue
+		}
+		if selector.Model != "" {
+			if matched, _ := filepath.Match(selector.Model, device.Model); !matched {
+				continue
+			}
+		}
+		if minSize != 0 && device.RawDiskSize < minSize {
+			continue
+		}
+		if maxSize != 0 && device.RawDiskSize > maxSize {
+			continue
+		}
+		candidates = append(candidates, device)
+	}
+
+	switch {
+	case len(candidates) == 0:
+		return "", fmt.Errorf("no disk matches the answer file disk selector %+v", selector)
+	case len(candidates) == 1:
+		return candidates[0].DevicePath, nil
+	case selector.Largest:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].RawDiskSize > candidates[j].RawDiskSize
+		})
+		return candidates[0].DevicePath, nil
+	default:
+		var paths []string
+		for _, candidate := range candidates {
+			paths = append(paths, candidate.DevicePath)
+		}
+		return "", fmt.Errorf("disk selector %+v matches several disks (%s), narrow it down or set largest: true",
+			selector, strings.Join(paths, ", "))
+	}
+}
+
+// applyAnswers overrides the template with the answer file settings
+func applyAnswers(template *config.ImageTemplate, answers *Answers) error {
+	if !answers.Disk.IsEmpty() {
+		devices, err := imagedisc.SystemBlockDevices()
+		if err != nil {
+			return fmt.Errorf("failed to list block devices: %w", err)
+		}
+		diskPath, err := selectDisk(answers.Disk, devices)
+		if err != nil {
+			return err
+		}
+		log.Infof("Answer file selected target disk %s", diskPath)
+		template.Disk.Path = diskPath
+	}
+	if answers.Disk.Wipe {
+		template.Disk.Wipe = true
+	}
+	if answers.Hostname != "" {
+		template.SystemConfig.HostName = answers.Hostname
+	}
+	if len(answers.Users) > 0 {
+		template.SystemConfig.Users = config.MergeUsers(template.SystemConfig.Users, answers.Users)
+	}
+	if answers.Timezone != "" {
+		template.SystemConfig.Localization.Timezone = answers.Timezone
+	}
+	return nil
+}
+
+// writeFinishAction records the requested power action for the installer wrapper script
+func writeFinishAction(action string) error {
+	if err := os.MkdirAll(filepath.Dir(finishActionFile), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", finishActionFile, err)
+	}
+	if err := os.WriteFile(finishActionFile, []byte(action+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write finish action to %s: %w", finishActionFile, err)
+	}
+	return nil
+}
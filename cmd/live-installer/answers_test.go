@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const gib = 1 << 30
+
+var testDevices = []imagedisc.SystemBlockDevice{
+	{DevicePath: "/dev/sda", RawDiskSize: 256 * gib, Model: "SAMSUNG MZ7LH256", Serial: "S1"},
+	{DevicePath: "/dev/nvme0n1", RawDiskSize: 512 * gib, Model: "SAMSUNG MZVL2512", Serial: "S2"},
+	{DevicePath: "/dev/sdb", RawDiskSize: 1024 * gib, Model: "Ultra USB 3.0", Serial: "U1", Removable: true},
+}
+
+func TestParseAnswers(t *testing.T) {
+	data := []byte(`
+disk:
+  model: "SAMSUNG*"
+  minSize: 300GiB
+hostname: edge-017
+users:
+  - name: admin
+    sudo: true
+    sshKeys:
+      - "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample admin@example.com"
+timezone: Asia/Singapore
+finish: poweroff
+`)
+	answers, err := parseAnswers(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if answers.Hostname != "edge-017" || answers.Timezone != "Asia/Singapore" || answers.Finish != FinishPoweroff {
+		t.Errorf("unexpected answers: %+v", answers)
+	}
+	if len(answers.Users) != 1 || len(answers.Users[0].SSHKeys) != 1 {
+		t.Errorf("expected one user with one SSH key, got %+v", answers.Users)
+	}
+	if answers.Disk.Model != "SAMSUNG*" || answers.Disk.MinSize != "300GiB" {
+		t.Errorf("unexpected disk selector: %+v", answers.Disk)
+	}
+}
+
+func TestParseAnswers_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"unknown field", "hostnme: edge"},
+		{"bad finish", "finish: halt"},
+		{"bad size", "disk:\n  minSize: 64 gigs"},
+		{"bad model glob", "disk:\n  model: \"[\""},
+		{"user without name", "users:\n  - sudo: true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAnswers([]byte(tt.data)); err == nil {
+				t.Errorf("expected error for %q", tt.data)
+			}
+		})
+	}
+}
+
+func TestParseCmdlineValue(t *testing.T) {
+	tests := []struct {
+		cmdline  string
+		expected string
+	}{
+		{"BOOT_IMAGE=/vmlinuz root=live:LABEL=OIC quiet", ""},
+		{"quiet inst.answers=https://srv/a.yml console=ttyS0", "https://srv/a.yml"},
+		{"inst.answers=/a.yml inst.answers=LABEL=X:/b.yml\n", "LABEL=X:/b.yml"},
+	}
+	for _, tt := range tests {
+		if got := parseCmdlineValue(tt.cmdline, answersCmdlineKey); got != tt.expected {
+			t.Errorf("parseCmdlineValue(%q) = %q, want %q", tt.cmdline, got, tt.expected)
+		}
+	}
+}
+
+func TestAnswerSources(t *testing.T) {
+	originalCmdline := procCmdlinePath
+	defer func() { procCmdlinePath = originalCmdline }()
+
+	cmdlineFile := filepath.Join(t.TempDir(), "cmdline")
+	if err := os.WriteFile(cmdlineFile, []byte("quiet inst.answers=http://srv/a.yml,/b.yml\n"), 0644); err != nil {
+		t.Fatalf("failed to write cmdline: %v", err)
+	}
+	procCmdlinePath = cmdlineFile
+
+	sources, explicit := answerSources("", "/cdrom/config")
+	expected := []string{"http://srv/a.yml", "/b.yml", "LABEL=OIC_ANSWERS:/answers.yml", "/cdrom/config/answers.yml"}
+	if !explicit || strings.Join(sources, " ") != strings.Join(expected, " ") {
+		t.Errorf("answerSources() = %v (explicit %v), want %v", sources, explicit, expected)
+	}
+
+	// The flag takes precedence over the kernel command line
+	sources, _ = answerSources("/flag.yml", "/cdrom/config")
+	if sources[0] != "/flag.yml" || len(sources) != 3 {
+		t.Errorf("expected flag source first, got %v", sources)
+	}
+
+	procCmdlinePath = filepath.Join(t.TempDir(), "missing")
+	sources, explicit = answerSources("", "/cdrom/config")
+	if explicit || len(sources) != 2 {
+		t.Errorf("expected only default sources, got %v (explicit %v)", sources, explicit)
+	}
+}
+
+func TestSplitPartitionSource(t *testing.T) {
+	tests := []struct {
+		source string
+		device string
+		path   string
+		ok     bool
+	}{
+		{"LABEL=PROVISION:/edge/answers.yml", "/dev/disk/by-label/PROVISION", "/edge/answers.yml", true},
+		{"UUID=1234-ABCD:/answers.yml", "/dev/disk/by-uuid/1234-ABCD", "/answers.yml", true},
+		{"/dev/sdb2:/answers.yml", "/dev/sdb2", "/answers.yml", true},
+		{"/cdrom/answers.yml", "", "", false},
+		{"FOO=bar:/answers.yml", "", "", false},
+	}
+	for _, tt := range tests {
+		device, path, ok := splitPartitionSource(tt.source)
+		if device != tt.device || path != tt.path || ok != tt.ok {
+			t.Errorf("splitPartitionSource(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.source, device, path, ok, tt.device, tt.path, tt.ok)
+		}
+	}
+}
+
+func TestSelectDisk(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector DiskSelector
+		expected string
+		wantErr  bool
+	}{
+		{"by path", DiskSelector{Path: "/dev/sda"}, "/dev/sda", false},
+		{"removable by path", DiskSelector{Path: "/dev/sdb"}, "/dev/sdb", false},
+		{"by serial", DiskSelector{Serial: "S2"}, "/dev/nvme0n1", false},
+		{"by model glob", DiskSelector{Model: "*MZ7LH*"}, "/dev/sda", false},
+		{"by min size", DiskSelector{MinSize: "300GiB"}, "/dev/nvme0n1", false},
+		{"by max size", DiskSelector{MaxSize: "300GiB"}, "/dev/sda", false},
+		{"largest non-removable", DiskSelector{Largest: true}, "/dev/nvme0n1", false},
+		{"ambiguous", DiskSelector{Model: "SAMSUNG*"}, "", true},
+		{"no match", DiskSelector{Serial: "missing"}, "", true},
+		{"removable never implicit", DiskSelector{Serial: "U1"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectDisk(tt.selector, testDevices)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("selectDisk() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyAnswers(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "lsblk", Output: `{"blockdevices":[
+			{"name":"sda","size":"256060514304","model":"SSD","serial":"S1","rm":false},
+			{"name":"sdb","size":"1024209543168","model":"USB","serial":"U1","rm":"1"}]}`},
+	})
+
+	template := &config.ImageTemplate{}
+	template.Disk.Path = "/dev/vda"
+	template.SystemConfig.HostName = "template-host"
+	template.SystemConfig.Users = []config.UserConfig{{Name: "admin", Password: "x", Groups: []string{"adm"}}}
+
+	answers := &Answers{
+		Disk:     DiskSelector{Largest: true},
+		Hostname: "edge-017",
+		Users: []config.UserConfig{
+			{Name: "admin", Groups: []string{"sudo"}, SSHKeys: []string{"ssh-ed25519 AAAA admin"}},
+			{Name: "ops"},
+		},
+		Timezone: "Asia/Singapore",
+	}
+	if err := applyAnswers(template, answers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if template.Disk.Path != "/dev/sda" {
+		t.Errorf("expected removable disk to be skipped, got %s", template.Disk.Path)
+	}
+	if template.SystemConfig.HostName != "edge-017" {
+		t.Errorf("expected hostname override, got %s", template.SystemConfig.HostName)
+	}
+	if template.SystemConfig.Localization.Timezone != "Asia/Singapore" {
+		t.Errorf("expected timezone override, got %s", template.SystemConfig.Localization.Timezone)
+	}
+	if len(template.SystemConfig.Users) != 2 {
+		t.Fatalf("expected 2 users, got %+v", template.SystemConfig.Users)
+	}
+	admin := template.SystemConfig.Users[0]
+	if admin.Password != "x" || len(admin.Groups) != 2 || len(admin.SSHKeys) != 1 {
+		t.Errorf("expected answer user merged into template user, got %+v", admin)
+	}
+}
+
+func TestLoadAnswers(t *testing.T) {
+	dir := t.TempDir()
+	localFile := filepath.Join(dir, "answers.yml")
+	if err := os.WriteFile(localFile, []byte("hostname: local\n"), 0644); err != nil {
+		t.Fatalf("failed to write answer file: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/edge.yml" {
+			fmt.Fprint(w, "hostname: remote\n")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	originalRetries := answersFetchRetries
+	defer func() { answersFetchRetries = originalRetries }()
+	answersFetchRetries = 1
+
+	answers, err := loadAnswers([]string{server.URL + "/edge.yml", localFile}, true)
+	if err != nil || answers.Hostname != "remote" {
+		t.Errorf("expected remote answers, got %+v, %v", answers, err)
+	}
+
+	// A failing URL falls back to the local file
+	answers, err = loadAnswers([]string{server.URL + "/missing.yml", localFile}, true)
+	if err != nil || answers.Hostname != "local" {
+		t.Errorf("expected local fallback answers, got %+v, %v", answers, err)
+	}
+
+	missing := filepath.Join(dir, "missing.yml")
+	if _, err := loadAnswers([]string{missing}, true); err == nil {
+		t.Error("expected error when explicit answers cannot be read")
+	}
+	answers, err = loadAnswers([]string{missing}, false)
+	if err != nil || answers != nil {
+		t.Errorf("expected no answers without explicit sources, got %+v, %v", answers, err)
+	}
+
+	invalidFile := filepath.Join(dir, "invalid.yml")
+	if err := os.WriteFile(invalidFile, []byte("finish: halt\n"), 0644); err != nil {
+		t.Fatalf("failed to write answer file: %v", err)
+	}
+	if _, err := loadAnswers([]string{invalidFile, localFile}, false); err == nil {
+		t.Error("expected error for an invalid answer file")
+	}
+}
+
+func TestWriteFinishAction(t *testing.T) {
+	originalFile := finishActionFile
+	defer func() { finishActionFile = originalFile }()
+	finishActionFile = filepath.Join(t.TempDir(), "run", "finish")
+
+	if err := writeFinishAction(FinishPoweroff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(finishActionFile)
+	if err != nil {
+		t.Fatalf("failed to read finish action: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != FinishPoweroff {
+		t.Errorf("expected %s, got %q", FinishPoweroff, data)
+	}
+}
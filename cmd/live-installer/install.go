@@ -122,31 +122,73 @@ func dependencyCheck(targetOs string) error {
 	return nil
 }
 
-func install(template *config.ImageTemplate, configDir, localRepo string) error {
-	if err := dependencyCheck(template.Target.OS); err != nil {
+func install(template *config.ImageTemplate, configDir, localRepo string) (err error) {
+	report := newInstallReport(template)
+	defer func() {
+		report.finish(err)
+		writeInstallReport(report, reportTargets(reportExport))
+	}()
+
+	if err := report.stage("dependency-check", func() error {
+		return dependencyCheck(template.Target.OS)
+	}); err != nil {
 		return fmt.Errorf("dependency check failed: %w", err)
 	}
 
 	globalConfig.ConfigDir = configDir
 
-	hostAsChrootEnv, err := newChrootEnv(configDir,
-		localRepo,
-		template.Target.OS,
-		template.Target.Dist,
-		template.Target.Arch)
-	if err != nil {
-		return fmt.Errorf("failed to create chroot environment: %w", err)
+	if err := report.stage("preflight", func() error {
+		return preflightCheck(template)
+	}); err != nil {
+		return err
 	}
 
-	if err := hostAsChrootEnv.InitChrootEnv(
-		template.Target.OS,
-		template.Target.Dist,
-		template.Target.Arch); err != nil {
-		return fmt.Errorf("failed to initialize chroot environment: %w", err)
+	// Network installs download the packages into a local cache repo first
+	remoteRepo := ""
+	if isRemoteRepo(localRepo) {
+		remoteRepo = localRepo
+		localRepo = filepath.Join(config.TempDir(), netRepoDirName)
+		report.Repository = config.RedactURLCredentials(remoteRepo)
 	}
 
-	if err := hostAsChrootEnv.UpdateSystemPkgs(template); err != nil {
-		return fmt.Errorf("failed to update system packages: %w", err)
+	var hostAsChrootEnv *chroot.ChrootEnv
+	if err := report.stage("chroot-env", func() error {
+		var err error
+		hostAsChrootEnv, err = newChrootEnv(configDir,
+			localRepo,
+			template.Target.OS,
+			template.Target.Dist,
+			template.Target.Arch)
+		if err != nil {
+			return fmt.Errorf("failed to create chroot environment: %w", err)
+		}
+
+		if err := hostAsChrootEnv.UpdateSystemPkgs(template); err != nil {
+			return fmt.Errorf("failed to update system packages: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if remoteRepo != "" {
+		if err := report.stage("fetch-packages", func() error {
+			return fetchRemotePackages(hostAsChrootEnv, template, remoteRepo, localRepo)
+		}); err != nil {
+			return fmt.Errorf("failed to fetch packages from %s: %w", config.RedactURLCredentials(remoteRepo), err)
+		}
+	}
+
+	if err := report.stage("local-repo", func() error {
+		if err := hostAsChrootEnv.InitChrootEnv(
+			template.Target.OS,
+			template.Target.Dist,
+			template.Target.Arch); err != nil {
+			return fmt.Errorf("failed to initialize chroot environment: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
 	diskInfo := template.GetDiskConfig()
@@ -155,31 +197,63 @@ func install(template *config.ImageTemplate, configDir, localRepo string) error
 		return fmt.Errorf("no target disk path specified in the template")
 	}
 
-	diskPathIdMap, err := imagedisc.DiskPartitionsCreate(diskPath, diskInfo.Partitions, diskInfo.PartitionTableType)
-	if err != nil {
-		return fmt.Errorf("failed to create partitions on disk %s: %w", diskPath, err)
+	var diskPathIdMap map[string]string
+	if err := report.stage("partition", func() error {
+		var err error
+		diskPathIdMap, err = imagedisc.DiskPartitionsCreate(diskPath, diskInfo.Partitions, diskInfo.PartitionTableType)
+		if err != nil {
+			return fmt.Errorf("failed to create partitions on disk %s: %w", diskPath, err)
+		}
+		return nil
+	}); err != nil {
+		return err
 	}
+	report.diskPathIdMap = diskPathIdMap
 
-	// Create ImageOs with template
-	imageOs, err := imageos.NewImageOs(hostAsChrootEnv, template)
-	if err != nil {
-		return fmt.Errorf("failed to create image OS instance: %w", err)
-	}
+	if err := report.stage("install-os", func() error {
+		// Create ImageOs with template
+		imageOs, err := imageos.NewImageOs(hostAsChrootEnv, template)
+		if err != nil {
+			return fmt.Errorf("failed to create image OS instance: %w", err)
+		}
 
-	versionInfo, err := imageOs.InstallImageOs(diskPathIdMap)
-	if err != nil {
-		return fmt.Errorf("failed to install image OS: %w", err)
-	}
+		versionInfo, err := imageOs.InstallImageOs(diskPathIdMap)
+		if err != nil {
+			return fmt.Errorf("failed to install image OS: %w", err)
+		}
 
-	log.Infof("OS installation completed with version: %s", versionInfo)
+		log.Infof("OS installation completed with version: %s", versionInfo)
+		return nil
+	}); err != nil {
+		return err
+	}
 
-	if err := updateBootOrder(template, diskPathIdMap); err != nil {
+	if err := report.stage("boot-order", func() error {
+		return updateBootOrder(template, diskPathIdMap)
+	}); err != nil {
 		return fmt.Errorf("failed to update boot order: %w", err)
 	}
 
 	return nil
 }
 
+// preflightCheck refuses to touch the target disk when any preflight check blocks the install
+func preflightCheck(template *config.ImageTemplate) error {
+	diskInfo := template.GetDiskConfig()
+	if diskInfo.Path == "" {
+		return fmt.Errorf("no target disk path specified in the template")
+	}
+	report, err := imagedisc.DiskPreflight(diskInfo.Path, diskInfo, imagedisc.PreflightOptions{
+		Force: forceInstall,
+		Wipe:  diskInfo.Wipe,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run preflight checks on disk %s: %w", diskInfo.Path, err)
+	}
+	report.Log()
+	return report.Err()
+}
+
 func updateBootOrder(template *config.ImageTemplate, diskPathIdMap map[string]string) error {
 	if template.SystemConfig.Bootloader.BootType != "efi" {
 		log.Infof("Boot order update skipped: non-UEFI boot type detected")
@@ -271,7 +345,7 @@ func createNewBootEntry(template *config.ImageTemplate, diskPathIdMap map[string
 	return nil
 }
 
-func unattendedInstall(templateFile, localRepo string) error {
+func unattendedInstall(templateFile, localRepo, answersSource string) error {
 	templateDir := filepath.Dir(templateFile)
 	configDir := filepath.Join(templateDir, "..", "..", "..", "..", "..")
 	generalConfigDir := filepath.Join(configDir, "general")
@@ -285,7 +359,32 @@ func unattendedInstall(templateFile, localRepo string) error {
 	}
 	log.Infof("Loaded template: %s (type: %s)", template.Image.Name, template.Target.ImageType)
 
-	return install(template, configDir, localRepo)
+	repo, err := packageRepo(localRepo, template)
+	if err != nil {
+		return err
+	}
+
+	sources, explicit := answerSources(answersSource, templateDir)
+	answers, err := loadAnswers(sources, explicit)
+	if err != nil {
+		return fmt.Errorf("failed to load answer file: %w", err)
+	}
+	if answers != nil {
+		if err := applyAnswers(template, answers); err != nil {
+			return fmt.Errorf("failed to apply answer file: %w", err)
+		}
+	}
+
+	if err := install(template, configDir, repo); err != nil {
+		return err
+	}
+
+	if answers != nil && answers.Finish != "" {
+		if err := writeFinishAction(answers.Finish); err != nil {
+			return fmt.Errorf("failed to record finish action: %w", err)
+		}
+	}
+	return nil
 }
 
 func attendedInstall(templateFile, localRepo string) (installationQuit bool, err error) {
@@ -302,7 +401,12 @@ func attendedInstall(templateFile, localRepo string) (installationQuit bool, err
 	}
 	log.Infof("Loaded template: %s (type: %s)", template.Image.Name, template.Target.ImageType)
 
-	attendedInstaller, err := attendedinstaller.New(template, configDir, localRepo, install)
+	repo, err := packageRepo(localRepo, template)
+	if err != nil {
+		return false, err
+	}
+
+	attendedInstaller, err := attendedinstaller.New(template, configDir, repo, forceInstall, install)
 	if err != nil {
 		return false, fmt.Errorf("failed to create attended installer: %w", err)
 	}
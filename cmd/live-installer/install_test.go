@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
 )
 
 func TestNewChrootBuilder_MissingConfigDir(t *testing.T) {
@@ -151,6 +152,7 @@ func TestInstall_MissingConfigDir(t *testing.T) {
 	// Initialize global config
 	globalConfig = config.DefaultGlobalConfig()
 	config.SetGlobal(globalConfig)
+	useTestReportDir(t)
 
 	// Create a minimal template
 	template := &config.ImageTemplate{
@@ -169,6 +171,15 @@ func TestInstall_MissingConfigDir(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error when using non-existent directories")
 	}
+
+	// A failed installation still leaves a report behind
+	data, err := os.ReadFile(filepath.Join(reportStagingDir, reportFileName))
+	if err != nil {
+		t.Fatalf("expected install report to be written: %v", err)
+	}
+	if !strings.Contains(string(data), `"status": "failed"`) {
+		t.Errorf("expected failed status in report, got %s", data)
+	}
 }
 
 func TestRemoveOldBootEntries_NoEfibootmgr(t *testing.T) {
@@ -261,8 +272,46 @@ func TestUpdateBootOrder_NonEFIBoot(t *testing.T) {
 	}
 }
 
+func TestPreflightCheck_EmptyDiskPath(t *testing.T) {
+	template := &config.ImageTemplate{}
+
+	err := preflightCheck(template)
+	if err == nil || !strings.Contains(err.Error(), "no target disk") {
+		t.Errorf("expected missing disk path error, got %v", err)
+	}
+}
+
+func TestPreflightCheck_ForceAndWipe(t *testing.T) {
+	originalExecutor := shell.Default
+	originalForce := forceInstall
+	defer func() {
+		shell.Default = originalExecutor
+		forceInstall = originalForce
+	}()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "lsblk -d", Output: `{"blockdevices":[{"name":"vdz","size":"68719476736","model":"USB","rm":true}]}`},
+		{Pattern: "lsblk /dev/vdz", Output: `{"blockdevices":[{"path":"/dev/vdz1","fstype":"ext4","type":"part"}]}`},
+		{Pattern: "fdisk -l /dev/vdz", Output: "Disk /dev/vdz: 64 GiB, 68719476736 bytes, 134217728 sectors\nDisklabel type: gpt\n"},
+		{Pattern: "cat /proc/diskstats", Output: " 252 0 vdz 1 0 2 3 0 0 0 0 0 1 1\n"},
+	})
+
+	template := &config.ImageTemplate{}
+	template.Disk.Path = "/dev/vdz"
+
+	forceInstall = false
+	if err := preflightCheck(template); err == nil {
+		t.Error("expected removable disk with existing data to be blocked")
+	}
+
+	forceInstall = true
+	template.Disk.Wipe = true
+	if err := preflightCheck(template); err != nil {
+		t.Errorf("expected forced wipe to pass preflight, got %v", err)
+	}
+}
+
 func TestUnattendedInstall_InvalidTemplatePath(t *testing.T) {
-	err := unattendedInstall("/nonexistent/template.yml", "/tmp/repo")
+	err := unattendedInstall("/nonexistent/template.yml", "/tmp/repo", "")
 	if err == nil {
 		t.Fatal("expected error when template file does not exist")
 	}
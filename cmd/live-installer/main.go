@@ -14,6 +14,8 @@ import (
 var (
 	log                 = logger.Logger()
 	logLevel     string = ""
+	forceInstall bool   = false // Allow installing to removable or installer media
+	reportExport string = ""    // Install report export targets, comma separated
 	globalConfig *config.GlobalConfig
 )
 
@@ -25,8 +27,16 @@ func main() {
 	globalConfig.Logging.Level = "info"
 	config.SetGlobal(globalConfig)
 
-	// Setup logger with configured level
-	_, cleanup := logger.InitWithLevel(globalConfig.Logging.Level)
+	// Setup logger with configured level, keeping a full debug log for the install report
+	_, cleanup, err := logger.InitWithConfig(logger.Config{
+		Level:     globalConfig.Logging.Level,
+		FilePath:  installLogFile,
+		FileLevel: "debug",
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Installer log file unavailable: %v\n", err)
+		_, cleanup = logger.InitWithLevel(globalConfig.Logging.Level)
+	}
 	defer cleanup()
 
 	// Create and execute root command
@@ -40,7 +50,7 @@ func main() {
 
 // createRootCommand creates and configures the root cobra command with all subcommands
 func createRootCommand() *cobra.Command {
-	var config, repo string
+	var config, repo, answers string
 	var attendedInstaller bool
 
 	rootCmd := &cobra.Command{
@@ -52,6 +62,9 @@ The tool supports install custom os images according to the OSI image template y
 - Create partitions and format the filesystem on the target disk
 - Install OS packages from the package config list
 - Update system configuration according to the image template
+- Apply per-machine answer file overrides in unattended mode
+- Fetch packages from an HTTP(S) repository for network installs
+- Write an install report to /var/log/installer on the installed system
 
 Use 'live-installer --help' to see available params.`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
@@ -63,7 +76,7 @@ Use 'live-installer --help' to see available params.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			if !attendedInstaller {
 				logger.SetLogLevel("debug")
-				if err := unattendedInstall(config, repo); err != nil {
+				if err := unattendedInstall(config, repo, answers); err != nil {
 					fmt.Fprintf(os.Stderr, "Unattended install failed: %v\n", err)
 					os.Exit(1)
 				}
@@ -87,14 +100,18 @@ Use 'live-installer --help' to see available params.`,
 
 	rootCmd.Flags().BoolVarP(&attendedInstaller, "attended", "a", false, "Enable UI for user input during installation")
 	rootCmd.Flags().StringVarP(&config, "config", "c", "", "Template yaml file path")
-	rootCmd.Flags().StringVarP(&repo, "repo", "r", "", "Local package cache directory")
+	rootCmd.Flags().StringVarP(&repo, "repo", "r", "",
+		"Local package cache directory or HTTP(S) repository URL (default from inst.repo= or the template installRepo)")
+	rootCmd.Flags().BoolVar(&forceInstall, "force", false,
+		"Allow installing to removable or installer media")
+	rootCmd.Flags().StringVar(&answers, "answers", "",
+		"Answer file sources for unattended install, comma separated (path, URL or LABEL=<label>:/path; default from inst.answers=)")
+	rootCmd.Flags().StringVar(&reportExport, "report", "",
+		"Install report export targets, comma separated (directory, URL or LABEL=<label>:/dir; default from inst.report=)")
 
 	if err := rootCmd.MarkFlagRequired("config"); err != nil {
 		log.Fatalf("Failed to mark 'config' flag as required: %v", err)
 	}
-	if err := rootCmd.MarkFlagRequired("repo"); err != nil {
-		log.Fatalf("Failed to mark 'repo' flag as required: %v", err)
-	}
 
 	return rootCmd
 }
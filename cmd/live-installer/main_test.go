@@ -42,6 +42,16 @@ func TestCreateRootCommand(t *testing.T) {
 		t.Fatal("--attended flag is not defined")
 	}
 
+	answersFlag := rootCmd.Flags().Lookup("answers")
+	if answersFlag == nil {
+		t.Fatal("--answers flag is not defined")
+	}
+
+	reportFlag := rootCmd.Flags().Lookup("report")
+	if reportFlag == nil {
+		t.Fatal("--report flag is not defined")
+	}
+
 	// Check persistent flags
 	logLevelFlag := rootCmd.PersistentFlags().Lookup("log-level")
 	if logLevelFlag == nil {
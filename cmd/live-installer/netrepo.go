@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/chroot"
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/ospackage/debutils"
+	"github.com/open-edge-platform/os-image-composer/internal/ospackage/rpmutils"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	// repoCmdlineKey is the kernel command line parameter naming the package repository
+	repoCmdlineKey = "inst.repo="
+	// netRepoDirName is the download directory for network installs, relative to the temp dir
+	netRepoDirName = "netinstall-repo"
+)
+
+var (
+	// dhcpLeaseDir holds the DHCP leases systemd-networkd acquired for the installer
+	dhcpLeaseDir = "/run/systemd/netif/leases"
+	// dhcpWaitTimeout bounds the wait for a DHCP lease before a network install
+	dhcpWaitTimeout  = 60 * time.Second
+	dhcpPollInterval = time.Second
+)
+
+// packageRepo returns the package repository for the install: the --repo flag,
+// then inst.repo= on the kernel command line, then the root-path a DHCP server
+// offers, then the template installRepo
+func packageRepo(flagValue string, template *config.ImageTemplate) (string, error) {
+	repo := flagValue
+	if repo == "" {
+		if cmdline, err := os.ReadFile(procCmdlinePath); err == nil {
+			repo = parseCmdlineValue(string(cmdline), repoCmdlineKey)
+		}
+	}
+	if repo != "" && !isRemoteRepo(repo) {
+		return repo, nil
+	}
+
+	// Network installs need the installer network, which networkd configures
+	// with DHCP while the installer starts
+	leases := waitForDHCPLeases(dhcpWaitTimeout)
+	if repo == "" {
+		if repo = dhcpRepo(leases); repo != "" {
+			log.Infof("Using package repository %s offered by DHCP", config.RedactURLCredentials(repo))
+		}
+	}
+	if repo == "" {
+		repo = template.Target.InstallRepo
+	}
+	if repo == "" {
+		return "", fmt.Errorf("no package repository given: use --repo, %s on the kernel command line, a DHCP root-path or target.installRepo in the template",
+			repoCmdlineKey)
+	}
+	return repo, nil
+}
+
+// waitForDHCPLeases waits until the installer holds a DHCP lease with an
+// address and returns the leases; without one by the timeout, the network is
+// left to a static configuration
+func waitForDHCPLeases(timeout time.Duration) []map[string]string {
+	deadline := time.Now().Add(timeout)
+	logged := false
+	for {
+		leases := readDHCPLeases()
+		for _, lease := range leases {
+			if lease["ADDRESS"] != "" {
+				return leases
+			}
+		}
+		if time.Now().After(deadline) {
+			log.Warnf("No DHCP lease after %s, continuing with the current network configuration", timeout)
+			return leases
+		}
+		if !logged {
+			log.Infof("Waiting for a DHCP lease in %s", dhcpLeaseDir)
+			logged = true
+		}
+		time.Sleep(dhcpPollInterval)
+	}
+}
+
+// readDHCPLeases returns the KEY=VALUE fields of the networkd lease files
+func readDHCPLeases() []map[string]string {
+	entries, err := os.ReadDir(dhcpLeaseDir)
+	if err != nil {
+		return nil
+	}
+	var leases []map[string]string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dhcpLeaseDir, entry.Name()))
+		if err != nil {
+			log.Debugf("Failed to read DHCP lease %s: %v", entry.Name(), err)
+			continue
+		}
+		lease := map[string]string{}
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok && !strings.HasPrefix(key, "#") {
+				lease[key] = value
+			}
+		}
+		leases = append(leases, lease)
+	}
+	return leases
+}
+
+// dhcpRepo returns the HTTP(S) repository a DHCP server offers in the
+// root-path option (17), or "" without one
+func dhcpRepo(leases []map[string]string) string {
+	for _, lease := range leases {
+		if rootPath := lease["ROOT_PATH"]; isRemoteRepo(rootPath) {
+			return rootPath
+		}
+	}
+	return ""
+}
+
+// isRemoteRepo reports whether the package repository is an HTTP(S) URL
+func isRemoteRepo(repo string) bool {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// mirrorRepoConfigs points the provider repositories at the mirror, keeping the
+// paths the provider config derives from its base URL
+func mirrorRepoConfigs(repos []config.ProviderRepoConfig, mirror string) []config.ProviderRepoConfig {
+	mirror = strings.TrimSuffix(mirror, "/")
+	mirrored := make([]config.ProviderRepoConfig, len(repos))
+	for i, repo := range repos {
+		base := strings.TrimSuffix(repo.BaseURL, "/")
+		rewrite := func(value string) string {
+			if base != "" && strings.HasPrefix(value, base) {
+				return mirror + strings.TrimPrefix(value, base)
+			}
+			return value
+		}
+		repo.PkgPrefix = rewrite(repo.PkgPrefix)
+		repo.ReleaseFile = rewrite(repo.ReleaseFile)
+		repo.ReleaseSign = rewrite(repo.ReleaseSign)
+		repo.PbGPGKey = rewrite(repo.PbGPGKey)
+		repo.GPGKey = rewrite(repo.GPGKey)
+		repo.BaseURL = mirror
+		mirrored[i] = repo
+	}
+	return mirrored
+}
+
+// fetchRemotePackages downloads and verifies the template packages from the
+// remote repository, then generates local repo metadata for the chroot installer
+func fetchRemotePackages(chrootEnv *chroot.ChrootEnv, template *config.ImageTemplate, repoURL, destDir string) error {
+	pkgType := chrootEnv.GetTargetOsPkgType()
+	metadataCmd := map[string]string{"rpm": "createrepo_c", "deb": "dpkg-scanpackages"}[pkgType]
+	if metadataCmd == "" {
+		return fmt.Errorf("unsupported package type: %s", pkgType)
+	}
+	cmdExist, err := shell.IsCommandExist(metadataCmd, shell.HostPath)
+	if err != nil {
+		return fmt.Errorf("failed to check command %s existence: %w", metadataCmd, err)
+	}
+	if !cmdExist {
+		return fmt.Errorf("required command %s not found, network installs need it in the installer image", metadataCmd)
+	}
+
+	providerConfigs, err := config.LoadProviderRepoConfig(template.Target.OS, template.Target.Dist)
+	if err != nil {
+		return fmt.Errorf("failed to load provider repo config: %w", err)
+	}
+	repos := mirrorRepoConfigs(providerConfigs, repoURL)
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create package download directory %s: %w", destDir, err)
+	}
+
+	log.Infof("Fetching packages from %s", config.RedactURLCredentials(repoURL))
+	switch pkgType {
+	case "rpm":
+		err = fetchRpmPackages(template, repos, destDir)
+	case "deb":
+		err = fetchDebPackages(template, repos, destDir)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("Fetched %d packages to %s", len(template.FullPkgList), destDir)
+
+	if err := chrootEnv.UpdateChrootLocalRepoMetadata(destDir, template.Target.Arch, true); err != nil {
+		return fmt.Errorf("failed to generate package repository metadata in %s: %w", destDir, err)
+	}
+	return nil
+}
+
+func fetchRpmPackages(template *config.ImageTemplate, repos []config.ProviderRepoConfig, destDir string) error {
+	if len(repos) == 0 {
+		return fmt.Errorf("no repository configurations found")
+	}
+	repoType, name, repoURL, gpgKey, component, _, _, _, _, _, gpgCheck, repoGPGCheck, enabled := repos[0].ToRepoConfigData(template.Target.Arch)
+	if repoType != "rpm" {
+		return fmt.Errorf("expected RPM repository type, got: %s", repoType)
+	}
+
+	repoDataURL := repoURL + "/repodata/repomd.xml"
+	href, err := rpmutils.FetchPrimaryURL(repoDataURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch primary.xml.gz location from %s: %w", config.RedactURLCredentials(repoDataURL), err)
+	}
+
+	rpmutils.RepoCfg = rpmutils.RepoConfig{
+		Name:         name,
+		URL:          repoURL,
+		GPGKey:       gpgKey,
+		Section:      component,
+		GPGCheck:     gpgCheck,
+		RepoGPGCheck: repoGPGCheck,
+		Enabled:      enabled,
+	}
+	rpmutils.GzHref = href
+	rpmutils.Dist = template.Target.Dist
+	rpmutils.UserRepo = template.GetPackageRepositories()
+
+	fullPkgList, _, err := rpmutils.DownloadPackagesComplete(template.GetPackages(), destDir, "")
+	if err != nil {
+		return fmt.Errorf("failed to download packages: %w", err)
+	}
+	template.FullPkgList = fullPkgList
+	return nil
+}
+
+func fetchDebPackages(template *config.ImageTemplate, repos []config.ProviderRepoConfig, destDir string) error {
+	arch := template.Target.Arch
+	switch arch {
+	case "x86_64":
+		arch = "amd64"
+	case "aarch64":
+		arch = "arm64"
+	}
+
+	var repoList []debutils.Repository
+	for i, repo := range repos {
+		repoType, name, _, gpgKey, component, _, _, _, _, baseURL, _, _, _ := repo.ToRepoConfigData(arch)
+		if repoType != "deb" {
+			log.Warnf("Skipping non-DEB repository: %s (type: %s)", name, repoType)
+			continue
+		}
+		repoList = append(repoList, debutils.Repository{
+			ID:        fmt.Sprintf("netinstall%d", i+1),
+			Codename:  name,
+			URL:       baseURL,
+			PKey:      gpgKey,
+			Component: component,
+		})
+	}
+
+	repoCfgs, err := debutils.BuildRepoConfigs(repoList, arch)
+	if err != nil {
+		return fmt.Errorf("building repo configs failed: %w", err)
+	}
+	if len(repoCfgs) == 0 {
+		return fmt.Errorf("no valid DEB repositories found")
+	}
+
+	debutils.RepoCfgs = repoCfgs
+	debutils.RepoCfg = repoCfgs[0]
+	debutils.GzHref = repoCfgs[0].PkgList
+	debutils.Architecture = repoCfgs[0].Arch
+	debutils.UserRepo = template.GetPackageRepositories()
+
+	fullPkgList, _, err := debutils.DownloadPackagesComplete(template.GetPackages(), destDir, "")
+	if err != nil {
+		return fmt.Errorf("failed to download packages: %w", err)
+	}
+	template.FullPkgList = fullPkgList
+	return nil
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/mount"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/network"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// reportCmdlineKey is the kernel command line parameter listing report export targets
+	reportCmdlineKey = "inst.report="
+	// installedReportDir is the report directory relative to the installed root
+	installedReportDir = "var/log/installer"
+
+	reportFileName     = "report.json"
+	reportTemplateName = "template.yml"
+	reportLogName      = "install.log"
+
+	ReportStatusSuccess = "success"
+	ReportStatusFailed  = "failed"
+)
+
+var (
+	installLogFile   = "/run/live-installer/install.log"
+	reportStagingDir = "/run/live-installer/report"
+)
+
+// InstallReport is the structured record of one installation written to report.json
+type InstallReport struct {
+	Status          string        `json:"status"`          // Status: success or failed
+	Error           string        `json:"error,omitempty"` // Error: installation error, if any
+	Image           string        `json:"image"`
+	Target          string        `json:"target"`    // Target: os/dist/arch of the template
+	StartTime       time.Time     `json:"startTime"` // StartTime: when the installation started
+	DurationSeconds float64       `json:"durationSeconds"`
+	Stages          []StageTiming `json:"stages"`
+	Disk            DiskReport    `json:"disk"`
+	Packages        []string      `json:"packages,omitempty"`      // Packages: packages installed in the target root
+	PackageSource   string        `json:"packageSource,omitempty"` // PackageSource: installed (queried from the target) or template
+	Repository      string        `json:"repository,omitempty"`    // Repository: remote package repository of a network install
+
+	template      *config.ImageTemplate
+	diskPathIdMap map[string]string
+}
+
+// StageTiming records the duration and outcome of one installation stage
+type StageTiming struct {
+	Name            string    `json:"name"`
+	StartTime       time.Time `json:"startTime"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// DiskReport describes the target disk after partitioning
+type DiskReport struct {
+	Path       string            `json:"path"`
+	Partitions map[string]string `json:"partitions,omitempty"` // Partitions: template partition ID to device path
+	Layout     json.RawMessage   `json:"layout,omitempty"`     // Layout: lsblk output with filesystems and UUIDs
+}
+
+func newInstallReport(template *config.ImageTemplate) *InstallReport {
+	return &InstallReport{
+		Image:     template.GetImageName(),
+		Target:    fmt.Sprintf("%s/%s/%s", template.Target.OS, template.Target.Dist, template.Target.Arch),
+		StartTime: time.Now(),
+		template:  template,
+	}
+}
+
+// stage runs one installation stage and records its timing
+func (r *InstallReport) stage(name string, run func() error) error {
+	start := time.Now()
+	err := run()
+	timing := StageTiming{Name: name, StartTime: start, DurationSeconds: time.Since(start).Seconds()}
+	if err != nil {
+		timing.Error = err.Error()
+	}
+	r.Stages = append(r.Stages, timing)
+	return err
+}
+
+// finish records the overall outcome of the installation
+func (r *InstallReport) finish(err error) {
+	r.DurationSeconds = time.Since(r.StartTime).Seconds()
+	r.Status = ReportStatusSuccess
+	if err != nil {
+		r.Status = ReportStatusFailed
+		r.Error = err.Error()
+	}
+}
+
+// writeInstallReport stores the report in the staging directory, the installed system and
+// the export targets. Reporting problems are logged but never fail the installation.
+func writeInstallReport(r *InstallReport, targets []string) {
+	r.Disk.Path = r.template.Disk.Path
+	r.Disk.Partitions = r.diskPathIdMap
+	if r.Disk.Path != "" && r.diskPathIdMap != nil {
+		layout, err := diskLayout(r.Disk.Path)
+		if err != nil {
+			log.Warnf("Failed to read disk layout for the install report: %v", err)
+		}
+		r.Disk.Layout = layout
+	}
+
+	if root, ok := findInstalledMount(r.template, r.diskPathIdMap, "/"); ok {
+		if err := withInstalledMount(root, true, r.collectPackages); err != nil {
+			log.Warnf("Failed to list installed packages for the install report: %v", err)
+		}
+	}
+	if err := r.save(); err != nil {
+		log.Warnf("Failed to write install report to %s: %v", reportStagingDir, err)
+		return
+	}
+	r.copyToInstalledSystem()
+
+	for _, target := range targets {
+		if err := exportReport(reportStagingDir, target); err != nil {
+			log.Warnf("Failed to export install report to %s: %v", target, err)
+			continue
+		}
+		log.Infof("Install report exported to %s", target)
+	}
+}
+
+// save writes the report to the staging directory, falling back to the template
+// package list when the installed packages could not be queried
+func (r *InstallReport) save() error {
+	if len(r.Packages) == 0 {
+		r.Packages = r.template.GetPackages()
+		r.PackageSource = "template"
+	}
+	if err := writeReportDir(r, reportStagingDir); err != nil {
+		return err
+	}
+	log.Infof("Install report written to %s", reportStagingDir)
+	return nil
+}
+
+// reportTargets returns the export targets from the --report flag, or the
+// inst.report= kernel command line parameter when the flag is not set
+func reportTargets(flagValue string) []string {
+	value := flagValue
+	if value == "" {
+		if cmdline, err := os.ReadFile(procCmdlinePath); err == nil {
+			value = parseCmdlineValue(string(cmdline), reportCmdlineKey)
+		}
+	}
+	var targets []string
+	for _, target := range strings.Split(value, ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// writeReportDir writes report.json, the redacted template and the installer log
+func writeReportDir(r *InstallReport, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory %s: %w", dir, err)
+	}
+
+	reportData, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal install report: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, reportFileName), append(reportData, '\n'), 0640); err != nil {
+		return fmt.Errorf("failed to write %s: %w", reportFileName, err)
+	}
+
+	templateData, err := yaml.Marshal(r.template.Redacted())
+	if err != nil {
+		return fmt.Errorf("failed to marshal template: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, reportTemplateName), templateData, 0640); err != nil {
+		return fmt.Errorf("failed to write %s: %w", reportTemplateName, err)
+	}
+
+	logData, err := os.ReadFile(installLogFile)
+	if err != nil {
+		log.Debugf("Installer log %s not available for the install report: %v", installLogFile, err)
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(dir, reportLogName), scrubPasswords(logData, r.template), 0640); err != nil {
+		return fmt.Errorf("failed to write %s: %w", reportLogName, err)
+	}
+	return nil
+}
+
+// scrubPasswords replaces the template user passwords in the installer log, in
+// case a hook script or tool echoed one
+func scrubPasswords(logData []byte, template *config.ImageTemplate) []byte {
+	for _, user := range template.SystemConfig.Users {
+		if user.Password != "" {
+			logData = bytes.ReplaceAll(logData, []byte(user.Password), []byte(config.RedactedValue))
+		}
+	}
+	return logData
+}
+
+// copyReportDir copies the regular files of the report directory into dst
+func copyReportDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to read report directory %s: %w", src, err)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dst, err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, entry.Name()), data, 0640); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Join(dst, entry.Name()), err)
+		}
+	}
+	return nil
+}
+
+// exportReport sends the report to an HTTP(S) endpoint as a tar.gz archive, or copies
+// it into a per-install subdirectory on a partition (LABEL=name:/dir) or a local directory
+func exportReport(reportDir, target string) error {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return postReport(reportDir, target)
+	}
+
+	name := reportArchiveName(reportDir)
+	device, path, ok := splitPartitionSource(target)
+	if !ok {
+		return copyReportDir(reportDir, filepath.Join(target, name))
+	}
+	if _, err := os.Stat(device); err != nil {
+		return fmt.Errorf("report partition %s not present: %w", device, err)
+	}
+	mountDir, err := os.MkdirTemp(config.TempDir(), "report-")
+	if err != nil {
+		return fmt.Errorf("failed to create report partition mount point: %w", err)
+	}
+	defer os.RemoveAll(mountDir)
+
+	if _, err := shell.ExecCmd(fmt.Sprintf("mount %s %s", device, mountDir), true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to mount report partition %s: %w", device, err)
+	}
+	defer func() {
+		if _, err := shell.ExecCmd("umount "+mountDir, true, shell.HostPath, nil); err != nil {
+			log.Warnf("Failed to unmount report partition %s: %v", device, err)
+		}
+	}()
+
+	dst := filepath.Join(mountDir, path)
+	if dst != mountDir && !strings.HasPrefix(dst, mountDir+string(filepath.Separator)) {
+		return fmt.Errorf("report path %s escapes the partition", path)
+	}
+	return copyReportDir(reportDir, filepath.Join(dst, name))
+}
+
+// postReport uploads the report directory as a tar.gz archive
+func postReport(reportDir, url string) error {
+	archive, err := archiveReportDir(reportDir)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("failed to create report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", reportArchiveName(reportDir)+".tar.gz"))
+
+	resp, err := network.GetSecureHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post report to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status %s from %s", resp.Status, url)
+	}
+	return nil
+}
+
+// archiveReportDir packs the regular files of the report directory into a tar.gz archive
+func archiveReportDir(reportDir string) ([]byte, error) {
+	entries, err := os.ReadDir(reportDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report directory %s: %w", reportDir, err)
+	}
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(reportDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		header := &tar.Header{Name: entry.Name(), Mode: 0640, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("failed to archive %s: %w", entry.Name(), err)
+		}
+		if _, err := tarWriter.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to archive %s: %w", entry.Name(), err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish report archive: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish report archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// reportArchiveName names an exported report after the installed host and the report time
+func reportArchiveName(reportDir string) string {
+	host := "install"
+	timestamp := time.Now().UTC().Format("20060102T150405Z")
+	if data, err := os.ReadFile(filepath.Join(reportDir, reportTemplateName)); err == nil {
+		var template config.ImageTemplate
+		if yaml.Unmarshal(data, &template) == nil && template.SystemConfig.HostName != "" {
+			host = template.SystemConfig.HostName
+		}
+	}
+	return fmt.Sprintf("%s-%s", host, timestamp)
+}
+
+// installedMount is a partition mount of the installed system
+type installedMount struct {
+	device     string
+	fsType     string
+	options    string // options: btrfs subvolume and compression the partition is mounted with
+	mountPoint string // mountPoint: where the partition is mounted in the installed system
+}
+
+// findInstalledMount returns the partition mount of the installed system that
+// holds path, the one with the longest mount point above it
+func findInstalledMount(template *config.ImageTemplate, diskPathIdMap map[string]string, path string) (installedMount, bool) {
+	var found installedMount
+	ok := false
+	for _, partition := range template.Disk.Partitions {
+		device := diskPathIdMap[partition.ID]
+		if device == "" {
+			continue
+		}
+		for _, partitionMount := range imagedisc.PartitionMounts(partition) {
+			mountPoint := partitionMount.MountPoint
+			if !isPathUnder(path, mountPoint) || (ok && len(mountPoint) <= len(found.mountPoint)) {
+				continue
+			}
+			found = installedMount{
+				device:     device,
+				fsType:     partition.FsType,
+				options:    imagedisc.BuildMountOptions(partitionMount),
+				mountPoint: mountPoint,
+			}
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+// isPathUnder returns whether path is mountPoint or below it
+func isPathUnder(path, mountPoint string) bool {
+	if mountPoint == "" {
+		return false
+	}
+	return mountPoint == "/" || path == mountPoint || strings.HasPrefix(path, mountPoint+"/")
+}
+
+// withInstalledMount mounts an installed partition on a temporary directory
+// while run executes, read-only unless the report is written to it
+func withInstalledMount(m installedMount, readOnly bool, run func(dir string) error) error {
+	mountDir, err := os.MkdirTemp(config.TempDir(), "installed-root-")
+	if err != nil {
+		return fmt.Errorf("failed to create mount point: %w", err)
+	}
+	defer os.RemoveAll(mountDir)
+
+	options := m.options
+	if readOnly {
+		options = strings.TrimPrefix(options+",ro", ",")
+	}
+	flags := "-t " + m.fsType
+	if options != "" {
+		flags += " -o " + options
+	}
+	if err := mount.MountPath(m.device, mountDir, flags); err != nil {
+		return err
+	}
+	defer func() {
+		if err := mount.UmountPath(mountDir); err != nil {
+			log.Warnf("Failed to unmount %s: %v", mountDir, err)
+		}
+	}()
+	return run(mountDir)
+}
+
+// copyToInstalledSystem copies the staged report to /var/log/installer on the
+// installed system. The dm-verity protected root of an immutable image fails
+// verification once written after its hash tree exists, so there the report
+// is only copied when /var/log/installer is on another, writable partition.
+func (r *InstallReport) copyToInstalledSystem() {
+	reportPath := "/" + installedReportDir
+	reportMount, ok := findInstalledMount(r.template, r.diskPathIdMap, reportPath)
+	if !ok {
+		return
+	}
+	if reportMount.mountPoint == "/" && r.template.IsImmutabilityEnabled() {
+		log.Infof("Install report not copied to the dm-verity protected root, it is kept in %s and the export targets", reportStagingDir)
+		return
+	}
+	relPath, err := filepath.Rel(reportMount.mountPoint, reportPath)
+	if err != nil {
+		log.Warnf("Failed to locate %s on its partition: %v", reportPath, err)
+		return
+	}
+	err = withInstalledMount(reportMount, false, func(dir string) error {
+		return copyReportDir(reportStagingDir, filepath.Join(dir, relPath))
+	})
+	if err != nil {
+		log.Warnf("Failed to copy install report to the installed system: %v", err)
+		return
+	}
+	log.Infof("Install report copied to %s on the installed system", reportPath)
+}
+
+// diskLayout lists the disk partitions with their filesystems, labels and UUIDs
+func diskLayout(diskPath string) (json.RawMessage, error) {
+	cmd := fmt.Sprintf("lsblk %s --json --bytes --output PATH,SIZE,TYPE,FSTYPE,LABEL,UUID,PARTLABEL,PARTUUID,PARTTYPE", diskPath)
+	output, err := shell.ExecCmd(cmd, true, shell.HostPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", diskPath, err)
+	}
+	if !json.Valid([]byte(output)) {
+		return nil, fmt.Errorf("unexpected lsblk output for %s", diskPath)
+	}
+	return json.RawMessage(output), nil
+}
+
+// collectPackages lists the packages installed in the target root
+func (r *InstallReport) collectPackages(root string) error {
+	var packages []string
+	var err error
+	if _, statErr := os.Stat(filepath.Join(root, "var", "lib", "dpkg", "status")); statErr == nil {
+		packages, err = dpkgInstalledPackages(filepath.Join(root, "var", "lib", "dpkg", "status"))
+	} else {
+		packages, err = rpmInstalledPackages(root)
+	}
+	if err != nil {
+		return err
+	}
+	r.Packages = packages
+	r.PackageSource = "installed"
+	return nil
+}
+
+// dpkgInstalledPackages parses a dpkg status file into "name version" entries
+func dpkgInstalledPackages(statusFile string) ([]string, error) {
+	data, err := os.ReadFile(statusFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", statusFile, err)
+	}
+	var packages []string
+	var name, version, status string
+	flush := func() {
+		if name != "" && strings.HasSuffix(status, " installed") {
+			packages = append(packages, strings.TrimSpace(name+" "+version))
+		}
+		name, version, status = "", "", ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "Package: "):
+			name = strings.TrimPrefix(line, "Package: ")
+		case strings.HasPrefix(line, "Version: "):
+			version = strings.TrimPrefix(line, "Version: ")
+		case strings.HasPrefix(line, "Status: "):
+			status = strings.TrimPrefix(line, "Status: ")
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", statusFile, err)
+	}
+	sort.Strings(packages)
+	return packages, nil
+}
+
+// rpmInstalledPackages queries the rpm database of the target root
+func rpmInstalledPackages(root string) ([]string, error) {
+	output, err := shell.ExecCmdSilent("rpm -qa --root "+root, true, shell.HostPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rpm database in %s: %w", root, err)
+	}
+	var packages []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			packages = append(packages, line)
+		}
+	}
+	sort.Strings(packages)
+	return packages, nil
+}
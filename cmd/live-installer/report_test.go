@@ -0,0 +1,344 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+// useTestReportDir keeps the install report and installer log inside the test temp directory
+func useTestReportDir(t *testing.T) {
+	t.Helper()
+	originalStaging, originalLog := reportStagingDir, installLogFile
+	t.Cleanup(func() { reportStagingDir, installLogFile = originalStaging, originalLog })
+
+	dir := t.TempDir()
+	reportStagingDir = filepath.Join(dir, "report")
+	installLogFile = filepath.Join(dir, "install.log")
+}
+
+func reportTestTemplate() *config.ImageTemplate {
+	template := &config.ImageTemplate{
+		Image:  config.ImageInfo{Name: "edge", Version: "1.0"},
+		Target: config.TargetInfo{OS: "ubuntu", Dist: "ubuntu24", Arch: "x86_64"},
+	}
+	template.SystemConfig.HostName = "edge-017"
+	template.SystemConfig.Packages = []string{"openssh-server"}
+	template.SystemConfig.Users = []config.UserConfig{{Name: "admin", Password: "secret"}}
+	return template
+}
+
+func TestInstallReportStages(t *testing.T) {
+	report := newInstallReport(reportTestTemplate())
+
+	if err := report.stage("first", func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stageErr := errors.New("disk vanished")
+	if err := report.stage("second", func() error { return stageErr }); !errors.Is(err, stageErr) {
+		t.Fatalf("expected stage error to be returned, got %v", err)
+	}
+	report.finish(stageErr)
+
+	if len(report.Stages) != 2 || report.Stages[0].Error != "" || report.Stages[1].Error != "disk vanished" {
+		t.Errorf("unexpected stages: %+v", report.Stages)
+	}
+	if report.Status != ReportStatusFailed || report.Error != "disk vanished" {
+		t.Errorf("unexpected outcome: %s %s", report.Status, report.Error)
+	}
+	if report.Target != "ubuntu/ubuntu24/x86_64" {
+		t.Errorf("unexpected target: %s", report.Target)
+	}
+}
+
+func TestWriteInstallReport(t *testing.T) {
+	useTestReportDir(t)
+	if err := os.WriteFile(installLogFile, []byte("DEBUG partitioning /dev/sda\n"), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+	exportDir := t.TempDir()
+
+	report := newInstallReport(reportTestTemplate())
+	report.finish(nil)
+	writeInstallReport(report, []string{exportDir})
+
+	data, err := os.ReadFile(filepath.Join(reportStagingDir, reportFileName))
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var written InstallReport
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("invalid report JSON: %v", err)
+	}
+	if written.Status != ReportStatusSuccess || written.PackageSource != "template" || len(written.Packages) != 1 {
+		t.Errorf("unexpected report: %+v", written)
+	}
+
+	templateData, err := os.ReadFile(filepath.Join(reportStagingDir, reportTemplateName))
+	if err != nil {
+		t.Fatalf("failed to read template: %v", err)
+	}
+	if strings.Contains(string(templateData), "secret") || !strings.Contains(string(templateData), config.RedactedValue) {
+		t.Errorf("expected redacted template, got %s", templateData)
+	}
+	if logData, err := os.ReadFile(filepath.Join(reportStagingDir, reportLogName)); err != nil || !strings.Contains(string(logData), "partitioning") {
+		t.Errorf("expected installer log in report, got %q, %v", logData, err)
+	}
+
+	exported, err := filepath.Glob(filepath.Join(exportDir, "edge-017-*", reportFileName))
+	if err != nil || len(exported) != 1 {
+		t.Errorf("expected report exported to a host directory, got %v, %v", exported, err)
+	}
+}
+
+func TestWriteInstallReportPasswordNotLogged(t *testing.T) {
+	useTestReportDir(t)
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	// The installer keeps a debug log for the report, as main does
+	_, cleanup, err := logger.InitWithConfig(logger.Config{Level: "info", FilePath: installLogFile, FileLevel: "debug"})
+	if err != nil {
+		t.Fatalf("failed to configure installer log: %v", err)
+	}
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "$6$hash"},
+	})
+	const password = "Plaintext-Secret-42"
+	if _, err := shell.ExecCmdWithInput(password+"\n", "openssl passwd -6 -stdin", true, shell.HostPath, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	log.Debugf("hook script output: password=%s", password)
+	cleanup()
+
+	template := reportTestTemplate()
+	template.SystemConfig.Users[0].Password = password
+	report := newInstallReport(template)
+	report.finish(nil)
+	writeInstallReport(report, nil)
+
+	logData, err := os.ReadFile(filepath.Join(reportStagingDir, reportLogName))
+	if err != nil || !strings.Contains(string(logData), "openssl passwd") {
+		t.Fatalf("expected installer debug log in report, got %q, %v", logData, err)
+	}
+	entries, err := os.ReadDir(reportStagingDir)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(reportStagingDir, entry.Name()))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", entry.Name(), err)
+		}
+		if strings.Contains(string(data), password) {
+			t.Errorf("password leaked into %s:\n%s", entry.Name(), data)
+		}
+	}
+}
+
+func TestReportTargets(t *testing.T) {
+	originalCmdline := procCmdlinePath
+	defer func() { procCmdlinePath = originalCmdline }()
+
+	cmdlineFile := filepath.Join(t.TempDir(), "cmdline")
+	if err := os.WriteFile(cmdlineFile, []byte("quiet inst.report=http://srv/upload,LABEL=LOGS:/reports\n"), 0644); err != nil {
+		t.Fatalf("failed to write cmdline: %v", err)
+	}
+	procCmdlinePath = cmdlineFile
+
+	if targets := reportTargets(""); strings.Join(targets, " ") != "http://srv/upload LABEL=LOGS:/reports" {
+		t.Errorf("unexpected cmdline targets: %v", targets)
+	}
+	if targets := reportTargets("/mnt/usb"); len(targets) != 1 || targets[0] != "/mnt/usb" {
+		t.Errorf("expected flag to override cmdline, got %v", targets)
+	}
+}
+
+func TestPostReport(t *testing.T) {
+	reportDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(reportDir, reportFileName), []byte(`{"status":"success"}`), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/gzip" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			received = append(received, header.Name)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	if err := exportReport(reportDir, server.URL+"/upload"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 1 || received[0] != reportFileName {
+		t.Errorf("expected archive with %s, got %v", reportFileName, received)
+	}
+}
+
+func TestDpkgInstalledPackages(t *testing.T) {
+	statusFile := filepath.Join(t.TempDir(), "status")
+	status := `Package: zlib1g
+Status: install ok installed
+Version: 1:1.3.dfsg-3
+
+Package: removed-pkg
+Status: deinstall ok config-files
+Version: 1.0
+
+Package: bash
+Status: install ok installed
+Architecture: amd64
+Version: 5.2.21-2
+`
+	if err := os.WriteFile(statusFile, []byte(status), 0644); err != nil {
+		t.Fatalf("failed to write status: %v", err)
+	}
+
+	packages, err := dpkgInstalledPackages(statusFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"bash 5.2.21-2", "zlib1g 1:1.3.dfsg-3"}
+	if strings.Join(packages, ",") != strings.Join(expected, ",") {
+		t.Errorf("dpkgInstalledPackages() = %v, want %v", packages, expected)
+	}
+}
+
+func TestFindInstalledMount(t *testing.T) {
+	template := &config.ImageTemplate{}
+	template.Disk.Partitions = []config.PartitionInfo{
+		{ID: "boot", MountPoint: "/boot/efi", FsType: "fat32"},
+		{ID: "rootfs", MountPoint: "/", FsType: "ext4"},
+	}
+	diskPathIdMap := map[string]string{"boot": "/dev/sda1", "rootfs": "/dev/sda2", "data": "/dev/sda3"}
+
+	root, ok := findInstalledMount(template, diskPathIdMap, "/")
+	if !ok || root.device != "/dev/sda2" || root.fsType != "ext4" || root.options != "" {
+		t.Errorf("findInstalledMount(/) = %+v, %v", root, ok)
+	}
+	if _, ok := findInstalledMount(template, nil, "/"); ok {
+		t.Error("expected no root mount before partitioning")
+	}
+
+	t.Run("BtrfsSubvolumes", func(t *testing.T) {
+		template := &config.ImageTemplate{}
+		template.Disk.Partitions = []config.PartitionInfo{{
+			ID: "rootfs", MountPoint: "/", FsType: "btrfs", MountOptions: "compress=zstd",
+			Subvolumes: []config.BtrfsSubvolume{{Name: "@", MountPoint: "/"}, {Name: "@log", MountPoint: "/var/log"}},
+		}}
+		root, ok := findInstalledMount(template, diskPathIdMap, "/")
+		if !ok || root.options != "compress=zstd,subvol=@" || root.mountPoint != "/" {
+			t.Errorf("expected the @ subvolume for /, got %+v", root)
+		}
+		reportMount, ok := findInstalledMount(template, diskPathIdMap, "/"+installedReportDir)
+		if !ok || reportMount.options != "compress=zstd,subvol=@log" || reportMount.mountPoint != "/var/log" {
+			t.Errorf("expected the @log subvolume for the report, got %+v", reportMount)
+		}
+	})
+
+	t.Run("DataPartition", func(t *testing.T) {
+		template.Disk.Partitions = append(template.Disk.Partitions, config.PartitionInfo{ID: "data", MountPoint: "/var", FsType: "ext4"})
+		reportMount, ok := findInstalledMount(template, diskPathIdMap, "/"+installedReportDir)
+		if !ok || reportMount.device != "/dev/sda3" || reportMount.mountPoint != "/var" {
+			t.Errorf("expected the /var partition for the report, got %+v", reportMount)
+		}
+	})
+}
+
+func TestWriteInstallReportMounts(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	tests := []struct {
+		name       string
+		immutable  bool
+		expectCopy bool
+	}{
+		{name: "Mutable", expectCopy: true},
+		{name: "Immutable", immutable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestReportDir(t)
+			tempDir := t.TempDir()
+			originalGlobal := config.Global()
+			globalConfig := config.DefaultGlobalConfig()
+			globalConfig.TempDir = tempDir
+			config.SetGlobal(globalConfig)
+			defer config.SetGlobal(originalGlobal)
+
+			// Packages are read from a read-only root, the report is written
+			// through a read-write mount only
+			commands := []shell.MockCommand{
+				{Pattern: `^mount$`},
+				{Pattern: `mount -t ext4 -o ro /dev/sda2 \S+$`},
+				{Pattern: `umount`},
+				{Pattern: `rpm -qa`, Output: "openssh-server-9.6\nbash-5.2\n"},
+			}
+			if tt.expectCopy {
+				commands = append(commands, shell.MockCommand{Pattern: `mount -t ext4 /dev/sda2 \S+$`})
+			}
+			shell.Default = shell.NewMockExecutor(append(commands, shell.MockCommand{Pattern: ".*", Error: fmt.Errorf("unexpected command")}))
+
+			logPath := filepath.Join(tempDir, "report-test.log")
+			_, cleanup, err := logger.InitWithConfig(logger.Config{Level: "info", FilePath: logPath, FileLevel: "debug"})
+			if err != nil {
+				t.Fatalf("failed to configure log: %v", err)
+			}
+
+			template := reportTestTemplate()
+			template.Disk.Partitions = []config.PartitionInfo{{ID: "rootfs", MountPoint: "/", FsType: "ext4"}}
+			template.SystemConfig.Immutability.Enabled = tt.immutable
+			report := newInstallReport(template)
+			report.diskPathIdMap = map[string]string{"rootfs": "/dev/sda2"}
+			report.finish(nil)
+			writeInstallReport(report, nil)
+			cleanup()
+
+			if report.PackageSource != "installed" || len(report.Packages) != 2 {
+				t.Errorf("expected packages from the installed root, got %s %v", report.PackageSource, report.Packages)
+			}
+			logData, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatalf("failed to read log: %v", err)
+			}
+			copied := strings.Contains(string(logData), "Install report copied to")
+			if copied != tt.expectCopy {
+				t.Errorf("report copied to the installed root = %v, want %v:\n%s", copied, tt.expectCopy, logData)
+			}
+		})
+	}
+}
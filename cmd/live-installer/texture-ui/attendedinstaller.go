@@ -18,6 +18,7 @@ import (
 	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/views/finishview"
 	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/views/hostnameview"
 	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/views/installerview"
+	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/views/localizationview"
 	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/views/progressview"
 	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/views/userview"
 	"github.com/open-edge-platform/os-image-composer/internal/config"
@@ -78,19 +79,22 @@ type AttendedInstaller struct {
 	template  *config.ImageTemplate
 	configDir string
 	localRepo string
+	force     bool
 }
 
 var log = logger.Logger()
 
-// New creates and returns a new AttendedInstaller.
+// New creates and returns a new AttendedInstaller. With force, the target disk
+// may be removable or boot media.
 func New(template *config.ImageTemplate,
-	configDir, localRepo string,
+	configDir, localRepo string, force bool,
 	installationFunc func(template *config.ImageTemplate, configDir, localRepo string) error) (*AttendedInstaller, error) {
 
 	attendedInstaller := &AttendedInstaller{
 		template:         template,
 		configDir:        configDir,
 		localRepo:        localRepo,
+		force:            force,
 		installationFunc: installationFunc,
 	}
 
@@ -353,8 +357,9 @@ func (ai *AttendedInstaller) initializeViews() (err error) {
 	ai.allViews = append(ai.allViews, installerview.New())
 	ai.allViews = append(ai.allViews, diskview.New())
 	ai.allViews = append(ai.allViews, hostnameview.New())
+	ai.allViews = append(ai.allViews, localizationview.New())
 	ai.allViews = append(ai.allViews, userview.New())
-	ai.allViews = append(ai.allViews, confirmview.New())
+	ai.allViews = append(ai.allViews, confirmview.New(ai.force))
 	ai.allViews = append(ai.allViews, progressview.New(ai.installationWrapper))
 	ai.allViews = append(ai.allViews, finishview.New(ai.recordedInstallationTime))
 
@@ -53,7 +53,7 @@ func TestNew(t *testing.T) {
 		return nil
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
@@ -117,7 +117,7 @@ func TestNew_WithNilTemplate(t *testing.T) {
 		}
 	}()
 
-	_, _ = New(nil, "/tmp/config", "/tmp/repo", installFunc)
+	_, _ = New(nil, "/tmp/config", "/tmp/repo", false, installFunc)
 }
 
 func TestNew_InitializesViews(t *testing.T) {
@@ -145,7 +145,7 @@ func TestNew_InitializesViews(t *testing.T) {
 		return nil
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
@@ -188,7 +188,7 @@ func TestAttendedInstaller_RecordedInstallationTime(t *testing.T) {
 		return nil
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
 	}
@@ -235,7 +235,7 @@ func TestAttendedInstaller_InstallationWrapper_Success(t *testing.T) {
 		return nil
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
 	}
@@ -290,7 +290,7 @@ func TestAttendedInstaller_InstallationWrapper_Error(t *testing.T) {
 		return expectedError
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
 	}
@@ -490,7 +490,7 @@ func TestAttendedInstaller_RefreshTitle(t *testing.T) {
 		return nil
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
 	}
@@ -569,7 +569,7 @@ func TestAttendedInstaller_UIInitialization(t *testing.T) {
 		return nil
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
 	}
@@ -635,7 +635,7 @@ func TestAttendedInstaller_InstallationError(t *testing.T) {
 		return testError
 	}
 
-	ai, err := New(template, "/tmp/config", "/tmp/repo", installFunc)
+	ai, err := New(template, "/tmp/config", "/tmp/repo", false, installFunc)
 	if err != nil {
 		t.Fatalf("New() returned error: %v", err)
 	}
@@ -37,6 +37,8 @@ const (
 	ConfirmTitle  = "Confirm"
 	ConfirmPrompt = `Start installation?
 All data on the selected disk will be lost.`
+	ConfirmPreflightHeader  = "Target disk checks:"
+	ConfirmPreflightBlocked = "Installation blocked by the target disk checks"
 )
 
 // DiskView text.
@@ -120,6 +122,18 @@ const (
 	FQDNInvalidLengthErrorFmt = "host name must be <= %d characters"
 )
 
+// LocalizationView text.
+const (
+	LocalizationTitle  = "Choose Regional Settings"
+	TimezoneInputLabel = "Time Zone"
+	LocaleInputLabel   = "Language (LANG)"
+	KeymapInputLabel   = "Keyboard Layout"
+
+	TimezoneInvalidErrorFmt = "unknown time zone '%s', use a name such as Asia/Singapore"
+	LocaleInvalidErrorFmt   = "invalid language '%s', use a locale such as en_US.UTF-8"
+	KeymapInvalidErrorFmt   = "invalid keyboard layout '%s', use a name such as us or de"
+)
+
 // InstallationView text.
 const (
 	InstallationTitle = "Select Installation Type"
@@ -4,6 +4,8 @@
 package confirmview
 
 import (
+	"strings"
+
 	"github.com/gdamore/tcell"
 	"github.com/rivo/tview"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/uitext"
 	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/uiutils"
 	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
 )
 
 // UI constants.
@@ -29,36 +32,43 @@ const (
 	navBarProportion = 1
 )
 
+// diskPreflight runs the target disk checks, replaced in tests
+var diskPreflight = imagedisc.DiskPreflight
+
 // ConfirmView contains the confirmation UI
 type ConfirmView struct {
 	text         *tview.TextView
 	navBar       *navigationbar.NavigationBar
 	flex         *tview.Flex
 	centeredFlex *tview.Flex
+	template     *config.ImageTemplate
+	nextPage     func()
+	preflightErr error
+	force        bool
 }
 
-// New creates and returns a new ConfirmView.
-func New() *ConfirmView {
-	return &ConfirmView{}
+// New creates and returns a new ConfirmView. With force, the preflight allows
+// removable and boot media as the target disk.
+func New(force bool) *ConfirmView {
+	return &ConfirmView{force: force}
 }
 
 // Initialize initializes the view.
 func (cv *ConfirmView) Initialize(backButtonText string, template *config.ImageTemplate, app *tview.Application, nextPage, previousPage, quit, refreshTitle func()) (err error) {
-	cv.text = tview.NewTextView().
-		SetText(uitext.ConfirmPrompt)
+	cv.template = template
+	cv.nextPage = nextPage
+	cv.preflightErr = nil
+
+	cv.text = tview.NewTextView()
 
 	cv.navBar = navigationbar.NewNavigationBar().
 		AddButton(backButtonText, previousPage).
-		AddButton(uitext.ButtonYes, nextPage).
+		AddButton(uitext.ButtonYes, cv.onConfirm).
 		SetAlign(tview.AlignCenter)
 
-	textWidth, textHeight := uiutils.MinTextViewWithNoWrapSize(cv.text)
-	centeredText := uiutils.Center(textWidth, textHeight, cv.text)
-
 	cv.flex = tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(centeredText, textHeight, textProportion, false).
-		AddItem(cv.navBar, navBarHeight, navBarProportion, true)
+		SetDirection(tview.FlexRow)
+	cv.setText(uitext.ConfirmPrompt)
 
 	cv.centeredFlex = uiutils.CenterVerticallyDynamically(cv.flex)
 
@@ -101,4 +111,58 @@ func (cv *ConfirmView) Primitive() tview.Primitive {
 
 // OnShow gets called when the view is shown to the user
 func (cv *ConfirmView) OnShow() {
+	cv.preflightErr = nil
+	if cv.template == nil {
+		return
+	}
+	if cv.template.Disk.Path == "" {
+		cv.setText(uitext.ConfirmPrompt)
+		return
+	}
+
+	// Confirming this page is the operator's consent to erase the disk
+	report, err := diskPreflight(cv.template.Disk.Path, cv.template.Disk, imagedisc.PreflightOptions{Wipe: true, Force: cv.force})
+	if err != nil {
+		cv.preflightErr = err
+		cv.setText(uitext.ConfirmPrompt)
+		cv.navBar.SetUserFeedback(uiutils.ErrorToUserFeedback(err), tview.Styles.TertiaryTextColor)
+		return
+	}
+	report.Log()
+
+	// Findings are not rendered with Lines() since the text view would measure brackets as color tags
+	lines := []string{uitext.ConfirmPrompt, "", uitext.ConfirmPreflightHeader}
+	for _, finding := range report.Findings {
+		lines = append(lines, "  "+finding.Severity+": "+finding.Message)
+	}
+	cv.setText(strings.Join(lines, "\n"))
+
+	if cv.preflightErr = report.Err(); cv.preflightErr != nil {
+		cv.navBar.SetUserFeedback(uitext.ConfirmPreflightBlocked, tview.Styles.TertiaryTextColor)
+	}
+}
+
+func (cv *ConfirmView) onConfirm() {
+	if cv.preflightErr != nil {
+		cv.navBar.SetUserFeedback(uitext.ConfirmPreflightBlocked, tview.Styles.TertiaryTextColor)
+		return
+	}
+	if cv.template != nil {
+		cv.template.Disk.Wipe = true
+	}
+	if cv.nextPage != nil {
+		cv.nextPage()
+	}
+}
+
+// setText replaces the page text and resizes the layout to fit it
+func (cv *ConfirmView) setText(text string) {
+	cv.text.SetText(text)
+
+	textWidth, textHeight := uiutils.MinTextViewWithNoWrapSize(cv.text)
+	centeredText := uiutils.Center(textWidth, textHeight, cv.text)
+
+	cv.flex.Clear().
+		AddItem(centeredText, textHeight, textProportion, false).
+		AddItem(cv.navBar, navBarHeight, navBarProportion, true)
 }
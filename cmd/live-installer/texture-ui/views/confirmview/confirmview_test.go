@@ -4,15 +4,17 @@
 package confirmview
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/gdamore/tcell"
 	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
 	"github.com/rivo/tview"
 )
 
 func TestNew(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	if cv == nil {
 		t.Fatal("New() returned nil")
@@ -37,7 +39,7 @@ func TestNew(t *testing.T) {
 }
 
 func TestInitialize(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -88,7 +90,7 @@ func TestInitialize_WithDifferentBackButtonText(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cv := New()
+			cv := New(false)
 
 			template := &config.ImageTemplate{
 				Target: config.TargetInfo{
@@ -115,7 +117,7 @@ func TestInitialize_WithDifferentBackButtonText(t *testing.T) {
 }
 
 func TestHandleInput(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -181,7 +183,7 @@ func TestHandleInput(t *testing.T) {
 }
 
 func TestHandleInput_NilEvent(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -207,7 +209,7 @@ func TestHandleInput_NilEvent(t *testing.T) {
 }
 
 func TestReset(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -239,7 +241,7 @@ func TestReset(t *testing.T) {
 }
 
 func TestReset_Uninitialized(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	// Reset on uninitialized view should panic
 	defer func() {
@@ -252,7 +254,7 @@ func TestReset_Uninitialized(t *testing.T) {
 }
 
 func TestName(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	name := cv.Name()
 
@@ -266,7 +268,7 @@ func TestName(t *testing.T) {
 }
 
 func TestTitle(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	title := cv.Title()
 
@@ -279,7 +281,7 @@ func TestTitle(t *testing.T) {
 }
 
 func TestPrimitive(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	// Before initialization, Primitive() returns the centeredFlex field (which is nil)
 	primitive := cv.Primitive()
@@ -315,7 +317,7 @@ func TestPrimitive(t *testing.T) {
 }
 
 func TestOnShow(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	// OnShow should not panic on uninitialized view
 	cv.OnShow()
@@ -362,7 +364,7 @@ func TestConstants(t *testing.T) {
 }
 
 func TestInitialize_CallbacksWork(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -394,7 +396,7 @@ func TestInitialize_CallbacksWork(t *testing.T) {
 }
 
 func TestInitialize_WithNilCallbacks(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -420,7 +422,7 @@ func TestInitialize_WithNilCallbacks(t *testing.T) {
 }
 
 func TestInitialize_MultipleInitializations(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -454,7 +456,7 @@ func TestInitialize_MultipleInitializations(t *testing.T) {
 }
 
 func TestConfirmView_TextContent(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -484,7 +486,7 @@ func TestConfirmView_TextContent(t *testing.T) {
 }
 
 func TestConfirmView_NavBarButtons(t *testing.T) {
-	cv := New()
+	cv := New(false)
 
 	template := &config.ImageTemplate{
 		Target: config.TargetInfo{
@@ -510,3 +512,68 @@ func TestConfirmView_NavBarButtons(t *testing.T) {
 	// We can't directly test button configuration without accessing internals,
 	// but we can verify it was created
 }
+
+func TestOnShow_Preflight(t *testing.T) {
+	originalPreflight := diskPreflight
+	defer func() { diskPreflight = originalPreflight }()
+
+	tests := []struct {
+		name       string
+		force      bool
+		findings   []imagedisc.PreflightFinding
+		expectNext bool
+	}{
+		{
+			name:       "warnings only",
+			findings:   []imagedisc.PreflightFinding{{Severity: imagedisc.PreflightWarning, Message: "existing data will be erased"}},
+			expectNext: true,
+		},
+		{
+			name:       "blocker",
+			findings:   []imagedisc.PreflightFinding{{Severity: imagedisc.PreflightBlocker, Message: "/dev/sda1 is mounted at /mnt"}},
+			expectNext: false,
+		},
+		{
+			name:       "forced",
+			force:      true,
+			findings:   []imagedisc.PreflightFinding{{Severity: imagedisc.PreflightWarning, Message: "/dev/sda is removable media"}},
+			expectNext: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotOpts imagedisc.PreflightOptions
+			diskPreflight = func(diskPath string, diskConfig config.DiskConfig, opts imagedisc.PreflightOptions) (*imagedisc.PreflightReport, error) {
+				gotOpts = opts
+				return &imagedisc.PreflightReport{DiskPath: diskPath, Findings: tt.findings}, nil
+			}
+
+			template := &config.ImageTemplate{}
+			template.Disk.Path = "/dev/sda"
+
+			nextCalled := false
+			cv := New(tt.force)
+			err := cv.Initialize("Back", template, tview.NewApplication(), func() { nextCalled = true }, func() {}, func() {}, func() {})
+			if err != nil {
+				t.Fatalf("Initialize() failed: %v", err)
+			}
+			cv.OnShow()
+
+			if !gotOpts.Wipe || gotOpts.Force != tt.force {
+				t.Errorf("expected wipe consent with force %v, got %+v", tt.force, gotOpts)
+			}
+			if !strings.Contains(cv.text.GetText(false), tt.findings[0].Message) {
+				t.Errorf("expected findings in text, got %q", cv.text.GetText(false))
+			}
+
+			cv.onConfirm()
+			if nextCalled != tt.expectNext {
+				t.Errorf("nextPage called = %v, want %v", nextCalled, tt.expectNext)
+			}
+			if template.Disk.Wipe != tt.expectNext {
+				t.Errorf("template wipe = %v, want %v", template.Disk.Wipe, tt.expectNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package localizationview
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+
+	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/primitives/navigationbar"
+	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/uitext"
+	"github.com/open-edge-platform/os-image-composer/cmd/live-installer/texture-ui/uiutils"
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+)
+
+// Input defaults, used when the template does not set them.
+const (
+	defaultTimezone = "UTC"
+	defaultLang     = "C.UTF-8"
+	defaultKeymap   = "us"
+)
+
+// UI constants.
+const (
+	navButtonNext = 1
+	noSelection   = -1
+
+	formProportion = 0
+
+	fieldWidth = 40
+)
+
+// Input validation patterns, matching the template schema.
+var (
+	timezoneRe = regexp.MustCompile(`^[A-Za-z0-9_+-]+(/[A-Za-z0-9_+-]+)*$`)
+	localeRe   = regexp.MustCompile(`^(C|POSIX|[A-Za-z]+(_[A-Za-z0-9]+)?\.[A-Za-z0-9-]+(@[A-Za-z0-9]+)?)$`)
+	keymapRe   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// zoneInfoDir is checked for the entered time zone when it exists on the installer
+var zoneInfoDir = "/usr/share/zoneinfo"
+
+// LocalizationView contains the time zone, language and keyboard layout UI
+type LocalizationView struct {
+	form          *tview.Form
+	timezoneField *tview.InputField
+	langField     *tview.InputField
+	keymapField   *tview.InputField
+	navBar        *navigationbar.NavigationBar
+	flex          *tview.Flex
+	centeredFlex  *tview.Flex
+
+	localization    *config.LocalizationConfig
+	defaultTimezone string
+	defaultLang     string
+	defaultKeymap   string
+}
+
+// New creates and returns a new LocalizationView.
+func New() *LocalizationView {
+	return &LocalizationView{}
+}
+
+// Initialize initializes the view.
+func (lv *LocalizationView) Initialize(backButtonText string, template *config.ImageTemplate, app *tview.Application, nextPage, previousPage, quit, refreshTitle func()) (err error) {
+	lv.localization = &template.SystemConfig.Localization
+	lv.defaultTimezone = valueOrDefault(lv.localization.Timezone, defaultTimezone)
+	lv.defaultLang = valueOrDefault(lv.localization.Lang, defaultLang)
+	lv.defaultKeymap = valueOrDefault(lv.localization.Keymap, defaultKeymap)
+
+	lv.timezoneField = tview.NewInputField().
+		SetLabel(uitext.TimezoneInputLabel).
+		SetFieldWidth(fieldWidth)
+
+	lv.langField = tview.NewInputField().
+		SetLabel(uitext.LocaleInputLabel).
+		SetFieldWidth(fieldWidth)
+
+	lv.keymapField = tview.NewInputField().
+		SetLabel(uitext.KeymapInputLabel).
+		SetFieldWidth(fieldWidth)
+
+	lv.navBar = navigationbar.NewNavigationBar().
+		AddButton(backButtonText, previousPage).
+		AddButton(uitext.ButtonNext, func() {
+			lv.onNextButton(nextPage)
+		}).
+		SetAlign(tview.AlignCenter).
+		SetOnFocusFunc(func() {
+			lv.navBar.SetSelectedButton(navButtonNext)
+		}).
+		SetOnBlurFunc(func() {
+			lv.navBar.SetSelectedButton(noSelection)
+		})
+
+	lv.form = tview.NewForm().
+		SetButtonsAlign(tview.AlignCenter).
+		AddFormItem(lv.timezoneField).
+		AddFormItem(lv.langField).
+		AddFormItem(lv.keymapField).
+		AddFormItem(lv.navBar)
+
+	lv.flex = tview.NewFlex().
+		SetDirection(tview.FlexRow)
+
+	formWidth, formHeight := uiutils.MinFormSize(lv.form)
+	centeredForm := uiutils.CenterHorizontally(formWidth, lv.form)
+
+	lv.flex.AddItem(centeredForm, formHeight+lv.navBar.GetHeight(), formProportion, true)
+	lv.centeredFlex = uiutils.CenterVerticallyDynamically(lv.flex)
+
+	// Box styling
+	lv.centeredFlex.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+
+	err = lv.Reset()
+	return
+}
+
+// HandleInput handles custom input.
+func (lv *LocalizationView) HandleInput(event *tcell.EventKey) *tcell.EventKey {
+	// Allow Up-Down to navigate the form
+	switch event.Key() {
+	case tcell.KeyUp:
+		return tcell.NewEventKey(tcell.KeyBacktab, 0, tcell.ModNone)
+	case tcell.KeyDown:
+		return tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone)
+	}
+
+	return event
+}
+
+// Reset resets the page, undoing any user input.
+func (lv *LocalizationView) Reset() (err error) {
+	lv.navBar.ClearUserFeedback()
+	lv.navBar.SetSelectedButton(noSelection)
+	lv.form.SetFocus(0)
+
+	lv.timezoneField.SetText(lv.defaultTimezone)
+	lv.langField.SetText(lv.defaultLang)
+	lv.keymapField.SetText(lv.defaultKeymap)
+
+	return
+}
+
+// Name returns the friendly name of the view.
+func (lv *LocalizationView) Name() string {
+	return "LOCALIZATION"
+}
+
+// Title returns the title of the view.
+func (lv *LocalizationView) Title() string {
+	return uitext.LocalizationTitle
+}
+
+// Primitive returns the primary primitive to be rendered for the view.
+func (lv *LocalizationView) Primitive() tview.Primitive {
+	return lv.centeredFlex
+}
+
+// OnShow gets called when the view is shown to the user
+func (lv *LocalizationView) OnShow() {
+}
+
+func (lv *LocalizationView) onNextButton(nextPage func()) {
+	timezone := lv.timezoneField.GetText()
+	lang := lv.langField.GetText()
+	keymap := lv.keymapField.GetText()
+	lv.navBar.ClearUserFeedback()
+
+	if err := validateLocalization(timezone, lang, keymap); err != nil {
+		lv.navBar.SetUserFeedback(uiutils.ErrorToUserFeedback(err), tview.Styles.TertiaryTextColor)
+		return
+	}
+
+	lv.localization.Timezone = enteredValue(lv.localization.Timezone, timezone, defaultTimezone)
+	lv.localization.Lang = enteredValue(lv.localization.Lang, lang, defaultLang)
+	lv.localization.Keymap = enteredValue(lv.localization.Keymap, keymap, defaultKeymap)
+	nextPage()
+}
+
+// validateLocalization checks the entered values; an empty value keeps the image default
+func validateLocalization(timezone, lang, keymap string) error {
+	if timezone != "" {
+		if !timezoneRe.MatchString(timezone) {
+			return fmt.Errorf(uitext.TimezoneInvalidErrorFmt, timezone)
+		}
+		if _, err := os.Stat(zoneInfoDir); err == nil {
+			if _, err := os.Stat(filepath.Join(zoneInfoDir, timezone)); err != nil {
+				return fmt.Errorf(uitext.TimezoneInvalidErrorFmt, timezone)
+			}
+		}
+	}
+	if lang != "" && !localeRe.MatchString(lang) {
+		return fmt.Errorf(uitext.LocaleInvalidErrorFmt, lang)
+	}
+	if keymap != "" && !keymapRe.MatchString(keymap) {
+		return fmt.Errorf(uitext.KeymapInvalidErrorFmt, keymap)
+	}
+	return nil
+}
+
+// enteredValue returns the value to store in the template. The view default
+// shown for an unset template value is not stored, so the image keeps its own
+func enteredValue(templateValue, entered, viewDefault string) string {
+	if templateValue == "" && entered == viewDefault {
+		return ""
+	}
+	return entered
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
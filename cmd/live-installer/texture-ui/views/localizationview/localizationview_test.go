@@ -0,0 +1,134 @@
+package localizationview
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell"
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/rivo/tview"
+)
+
+func TestLocalizationView_Name(t *testing.T) {
+	if name := New().Name(); name != "LOCALIZATION" {
+		t.Errorf("expected name to be %q, got %q", "LOCALIZATION", name)
+	}
+}
+
+func TestLocalizationView_Initialize(t *testing.T) {
+	template := &config.ImageTemplate{
+		SystemConfig: config.SystemConfig{
+			Localization: config.LocalizationConfig{Timezone: "Asia/Singapore"},
+		},
+	}
+	mockFunc := func() {}
+
+	lv := New()
+	if err := lv.Initialize("Back", template, tview.NewApplication(), mockFunc, mockFunc, mockFunc, mockFunc); err != nil {
+		t.Fatalf("Initialize() returned error: %v", err)
+	}
+	if lv.Primitive() != lv.centeredFlex {
+		t.Error("expected Primitive() to return centeredFlex")
+	}
+
+	// Template values are shown, view defaults fill the unset ones
+	if got := lv.timezoneField.GetText(); got != "Asia/Singapore" {
+		t.Errorf("expected the template time zone, got %q", got)
+	}
+	if got := lv.langField.GetText(); got != defaultLang {
+		t.Errorf("expected the default language, got %q", got)
+	}
+	if got := lv.keymapField.GetText(); got != defaultKeymap {
+		t.Errorf("expected the default keyboard layout, got %q", got)
+	}
+
+	lv.timezoneField.SetText("Asia/Tokyo")
+	if err := lv.Reset(); err != nil {
+		t.Fatalf("Reset() returned error: %v", err)
+	}
+	if got := lv.timezoneField.GetText(); got != "Asia/Singapore" {
+		t.Errorf("expected Reset() to restore the time zone, got %q", got)
+	}
+}
+
+func TestLocalizationView_OnNextButton(t *testing.T) {
+	originalZoneInfoDir := zoneInfoDir
+	defer func() { zoneInfoDir = originalZoneInfoDir }()
+	zoneInfoDir = filepath.Join(t.TempDir(), "missing")
+
+	template := &config.ImageTemplate{}
+	mockFunc := func() {}
+	lv := New()
+	if err := lv.Initialize("Back", template, tview.NewApplication(), mockFunc, mockFunc, mockFunc, mockFunc); err != nil {
+		t.Fatalf("Initialize() returned error: %v", err)
+	}
+
+	nextCalled := false
+	nextPage := func() { nextCalled = true }
+
+	lv.langField.SetText("english")
+	lv.onNextButton(nextPage)
+	if nextCalled {
+		t.Error("expected an invalid language to block the next page")
+	}
+
+	lv.timezoneField.SetText("Asia/Singapore")
+	lv.langField.SetText("en_SG.UTF-8")
+	lv.onNextButton(nextPage)
+	if !nextCalled {
+		t.Fatal("expected valid input to go to the next page")
+	}
+	expected := config.LocalizationConfig{Timezone: "Asia/Singapore", Lang: "en_SG.UTF-8"}
+	if template.SystemConfig.Localization.Timezone != expected.Timezone ||
+		template.SystemConfig.Localization.Lang != expected.Lang ||
+		template.SystemConfig.Localization.Keymap != "" {
+		t.Errorf("expected %+v with the untouched keymap left unset, got %+v", expected, template.SystemConfig.Localization)
+	}
+}
+
+func TestValidateLocalization(t *testing.T) {
+	originalZoneInfoDir := zoneInfoDir
+	defer func() { zoneInfoDir = originalZoneInfoDir }()
+	zoneInfoDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(zoneInfoDir, "Asia"), 0755); err != nil {
+		t.Fatalf("failed to create zoneinfo directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(zoneInfoDir, "Asia", "Singapore"), nil, 0644); err != nil {
+		t.Fatalf("failed to write zoneinfo file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		timezone string
+		lang     string
+		keymap   string
+		wantErr  bool
+	}{
+		{"valid", "Asia/Singapore", "en_SG.UTF-8", "us", false},
+		{"all empty keeps image defaults", "", "", "", false},
+		{"builtin locale", "Asia/Singapore", "C.UTF-8", "", false},
+		{"unknown time zone", "Asia/Atlantis", "", "", true},
+		{"bad time zone", "../etc/passwd", "", "", true},
+		{"locale without charset", "", "en_US", "", true},
+		{"bad keymap", "", "", "us; rm", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLocalization(tt.timezone, tt.lang, tt.keymap)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateLocalization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalizationView_HandleInput(t *testing.T) {
+	lv := New()
+	if event := lv.HandleInput(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)); event.Key() != tcell.KeyTab {
+		t.Errorf("expected KeyDown to move to the next field, got %v", event.Key())
+	}
+	if event := lv.HandleInput(tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)); event.Key() != tcell.KeyBacktab {
+		t.Errorf("expected KeyUp to move to the previous field, got %v", event.Key())
+	}
+}
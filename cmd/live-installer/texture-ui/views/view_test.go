@@ -70,7 +70,7 @@ func TestViewImplementations(t *testing.T) {
 		name     string
 		viewImpl View
 	}{
-		{"ConfirmView", confirmview.New()},
+		{"ConfirmView", confirmview.New(false)},
 		{"DiskView", diskview.New()},
 		{"FinishView", finishview.New(mockInstallationTime)},
 		{"HostnameView", hostnameview.New()},
@@ -160,7 +160,7 @@ func TestViewInterfaceMethodSignatures(t *testing.T) {
 		name string
 		view View
 	}{
-		{"ConfirmView", confirmview.New()},
+		{"ConfirmView", confirmview.New(false)},
 		{"FinishView", finishview.New(mockInstallationTime)},
 		{"HostnameView", hostnameview.New()},
 		{"InstallerView", installerview.New()},
@@ -221,7 +221,7 @@ func TestViewHandleInput(t *testing.T) {
 		name string
 		view View
 	}{
-		{"ConfirmView", confirmview.New()},
+		{"ConfirmView", confirmview.New(false)},
 		{"DiskView", diskview.New()},
 		{"FinishView", finishview.New(mockInstallationTime)},
 		{"HostnameView", hostnameview.New()},
@@ -260,7 +260,7 @@ func TestViewReset(t *testing.T) {
 		name string
 		view View
 	}{
-		{"ConfirmView", confirmview.New()},
+		{"ConfirmView", confirmview.New(false)},
 		{"DiskView", diskview.New()},
 		{"FinishView", finishview.New(mockInstallationTime)},
 		{"HostnameView", hostnameview.New()},
@@ -295,7 +295,7 @@ func TestViewOnShow(t *testing.T) {
 		name string
 		view View
 	}{
-		{"ConfirmView", confirmview.New()},
+		{"ConfirmView", confirmview.New(false)},
 		{"DiskView", diskview.New()},
 		{"FinishView", finishview.New(mockInstallationTime)},
 		{"HostnameView", hostnameview.New()},
@@ -329,7 +329,7 @@ func TestViewPrimitive(t *testing.T) {
 		name string
 		view View
 	}{
-		{"ConfirmView", confirmview.New()},
+		{"ConfirmView", confirmview.New(false)},
 		{"DiskView", diskview.New()},
 		{"FinishView", finishview.New(mockInstallationTime)},
 		{"HostnameView", hostnameview.New()},
@@ -5,6 +5,7 @@ import (
 
 	"github.com/open-edge-platform/os-image-composer/internal/config"
 	"github.com/open-edge-platform/os-image-composer/internal/hook"
+	"github.com/open-edge-platform/os-image-composer/internal/image/boottest"
 	"github.com/open-edge-platform/os-image-composer/internal/provider"
 	"github.com/open-edge-platform/os-image-composer/internal/provider/azl"
 	"github.com/open-edge-platform/os-image-composer/internal/provider/elxr"
@@ -21,6 +22,7 @@ var (
 	workers  int    = -1 // -1 means use config file value
 	cacheDir string = "" // Empty means use config file value
 	workDir  string = "" // Empty means use config file value
+	bootTest bool   = false
 )
 
 // createBuildCommand creates the build subcommand
@@ -42,6 +44,9 @@ The template file must be in YAML format following the image template schema.`,
 		"Package cache directory")
 	buildCmd.Flags().StringVar(&workDir, "work-dir", "",
 		"Working directory for builds")
+	buildCmd.Flags().BoolVar(&bootTest, "boot-test", false,
+		"Boot the produced raw/qcow2/ISO artifacts in QEMU after a successful build")
+	addBootTestFlags(buildCmd, "boot-")
 
 	return buildCmd
 }
@@ -120,6 +125,16 @@ post:
 		}
 	}
 
+	if buildErr == nil && bootTest {
+		firmware := bootFirmware
+		if !cmd.Flags().Changed("boot-firmware") {
+			firmware = boottest.FirmwareForBootType(template.GetBootloaderConfig().BootType)
+		}
+		if err := runBuildBootTest(template, firmware); err != nil {
+			buildErr = fmt.Errorf("boot test failed: %v", err)
+		}
+	}
+
 	if buildErr == nil {
 		log.Info("image build completed successfully")
 	} else {
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/boottest"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/system"
+	"github.com/spf13/cobra"
+)
+
+// Test command flags
+var (
+	bootFirmware string        = boottest.FirmwareUEFI   // Firmware used to boot the image
+	bootMarker   string        = boottest.MarkerLogin    // Console marker that signals a successful boot
+	bootTimeout  time.Duration = boottest.DefaultTimeout // Maximum time to wait for the marker
+	bootMemory   string        = boottest.DefaultMemory  // Guest memory in MiB
+	bootLogFile  string        = ""                      // Console log override
+)
+
+// createTestCommand creates the test subcommand
+func createTestCommand() *cobra.Command {
+	testCmd := &cobra.Command{
+		Use:   "test [flags] IMAGE_FILE",
+		Short: "Boot smoke test a built image in QEMU",
+		Long: `Boot a raw, qcow2 or ISO image (optionally compressed) in QEMU using TCG
+emulation and watch the serial console for a marker. The test passes when the
+marker appears before the timeout. The full console log is saved next to the
+image as <image>.boot.log unless --log is given.
+
+Markers:
+  login      a login prompt on the serial console (default)
+  systemd    systemd reached multi-user or graphical target
+  <regex>    any other value is used as a regular expression`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              executeTest,
+		ValidArgsFunction: imageFileCompletion,
+	}
+
+	addBootTestFlags(testCmd, "")
+	testCmd.Flags().StringVar(&bootLogFile, "log", "",
+		"Serial console log file (default <image>.boot.log)")
+
+	return testCmd
+}
+
+// addBootTestFlags registers the boot test tuning flags, optionally with a name prefix
+func addBootTestFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().StringVar(&bootFirmware, prefix+"firmware", boottest.FirmwareUEFI,
+		"Firmware used to boot the image (uefi, bios)")
+	cmd.Flags().StringVar(&bootMarker, prefix+"marker", boottest.MarkerLogin,
+		"Console marker that signals a successful boot (login, systemd, or a regex)")
+	cmd.Flags().DurationVar(&bootTimeout, prefix+"timeout", boottest.DefaultTimeout,
+		"Maximum time to wait for the boot marker")
+	cmd.Flags().StringVar(&bootMemory, prefix+"memory", boottest.DefaultMemory,
+		"Guest memory in MiB")
+}
+
+// executeTest handles the test command execution logic
+func executeTest(cmd *cobra.Command, args []string) error {
+	log := logger.Logger()
+	imageFile := args[0]
+
+	result, err := boottest.Run(imageFile, boottest.Options{
+		Firmware: bootFirmware,
+		Marker:   bootMarker,
+		Timeout:  bootTimeout,
+		Memory:   bootMemory,
+		LogFile:  bootLogFile,
+	})
+	if err != nil {
+		return fmt.Errorf("boot test failed: %v", err)
+	}
+
+	log.Infof("✓ Boot test passed for %s (%s), console log: %s",
+		result.ImagePath, result.Duration.Round(time.Second), result.LogFile)
+	return nil
+}
+
+// runBuildBootTest boots every bootable artifact produced by a build
+func runBuildBootTest(template *config.ImageTemplate, firmware string) error {
+	log := logger.Logger()
+
+	if !boottest.IsBootTestable(template.Target.ImageType) {
+		log.Warnf("Image type %s does not boot to a login prompt, skipping boot test", template.Target.ImageType)
+		return nil
+	}
+
+	globalWorkDir, err := config.WorkDir()
+	if err != nil {
+		return fmt.Errorf("failed to get work directory: %w", err)
+	}
+	providerId := system.GetProviderId(template.Target.OS, template.Target.Dist, template.Target.Arch)
+	imageBuildDir := filepath.Join(globalWorkDir, providerId, "imagebuild", template.GetSystemConfigName())
+
+	artifacts, err := boottest.FindBootableArtifacts(imageBuildDir)
+	if err != nil {
+		return err
+	}
+	if len(artifacts) == 0 {
+		log.Warnf("No bootable artifacts found in %s, skipping boot test", imageBuildDir)
+		return nil
+	}
+
+	for _, artifact := range artifacts {
+		if _, err := boottest.Run(artifact, boottest.Options{
+			Firmware: firmware,
+			Marker:   bootMarker,
+			Timeout:  bootTimeout,
+			Memory:   bootMemory,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageFileCompletion helps with suggesting image files for the image file argument
+func imageFileCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"raw", "qcow2", "iso", "gz", "xz", "zstd"}, cobra.ShellCompDirectiveFilterFileExt
+}
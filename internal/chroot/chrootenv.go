@@ -570,10 +570,10 @@ func (chrootEnv *ChrootEnv) UpdateSystemPkgs(template *config.ImageTemplate) err
 	kernelConfig := template.GetKernel()
 	if kernelConfig.Version == "" {
 		// Get the latest kernel version package by default
-		template.KernelPkgList = kernelConfig.Packages
+		template.KernelPkgList = kernelConfig.GetPackages()
 	} else {
 		// To do: search for exact kernel version package name
-		template.KernelPkgList = kernelConfig.Packages
+		template.KernelPkgList = kernelConfig.GetPackages()
 	}
 
 	return nil
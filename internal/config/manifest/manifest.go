@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -31,6 +32,10 @@ const (
 
 var DefaultSPDXFile = "spdx_manifest.json"
 
+// ContainerSPDXFile is the SBOM of the container artifacts, which leave out
+// the kernel and bootloader packages of the image SBOM
+var ContainerSPDXFile = "spdx_manifest_container.json"
+
 // SoftwarePackageManifest represents the structure of the manifest file.
 type SoftwarePackageManifest struct {
 	SchemaVersion     string `json:"schema_version"`
@@ -75,6 +80,7 @@ type SPDXPackage struct {
 	Supplier         string         `json:"supplier,omitempty"`
 	Checksum         []SPDXChecksum `json:"checksum,omitempty"`
 	Description      string         `json:"description,omitempty"`
+	Comment          string         `json:"comment,omitempty"`
 }
 
 // Holds the checksum value for an SBOM instance item
@@ -120,6 +126,28 @@ func WriteManifestToFile(manifest SoftwarePackageManifest, outputFile string) er
 	return nil
 }
 
+// ManifestSigAlg is the signature algorithm recorded in signed manifests
+const ManifestSigAlg = "sha256"
+
+// SignManifest signs the manifest with sign, which returns a detached SHA-256
+// signature such as the one of an image signer. The signature covers the JSON
+// encoding of the manifest with an empty signature field.
+func SignManifest(manifest *SoftwarePackageManifest, sign func(data []byte) ([]byte, error)) error {
+	manifest.SigAlg = ManifestSigAlg
+	manifest.Signature = ""
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("error marshaling manifest to sign: %w", err)
+	}
+	signature, err := sign(payload)
+	if err != nil {
+		log.Errorf("Failed to sign the image manifest: %v", err)
+		return fmt.Errorf("failed to sign the image manifest: %w", err)
+	}
+	manifest.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
 func WriteSPDXToFile(pkgs []ospackage.PackageInfo, outFile string) error {
 
 	log.Infof("Generating SPDX manifest for %d packages", len(pkgs))
@@ -299,3 +327,146 @@ func CopySBOMToChroot(chrootPath string) error {
 	log.Infof("Successfully copied SBOM into image filesystem at: %s", dstSBOM)
 	return nil
 }
+
+// WriteContainerSBOM stages the SBOM of a container root file system in the
+// temp directory, with the packages of the image SBOM that are installed in
+// the container, and copies it into the container
+func WriteContainerSBOM(chrootPath string, pkgNames []string) error {
+	spdxFile := filepath.Join(config.TempDir(), DefaultSPDXFile)
+	if _, err := os.Stat(spdxFile); os.IsNotExist(err) {
+		log.Warnf("SBOM file not found at %s, skipping container SBOM", spdxFile)
+		return nil
+	}
+
+	spdx, err := readSPDXFile(spdxFile)
+	if err != nil {
+		return err
+	}
+	installed := make(map[string]bool, len(pkgNames))
+	for _, name := range pkgNames {
+		installed[name] = true
+	}
+	kept := make([]SPDXPackage, 0, len(pkgNames))
+	for _, pkg := range spdx.Packages {
+		if installed[pkg.Name] {
+			kept = append(kept, pkg)
+		}
+	}
+	log.Infof("Container SBOM lists %d of %d image packages", len(kept), len(spdx.Packages))
+	spdx.Packages = kept
+
+	containerFile := filepath.Join(config.TempDir(), ContainerSPDXFile)
+	if err := writeSPDXFile(spdx, containerFile); err != nil {
+		return err
+	}
+	dstSBOM := filepath.Join(chrootPath, ImageSBOMPath, DefaultSPDXFile)
+	if err := file.CopyFile(containerFile, dstSBOM, "--preserve=mode", true); err != nil {
+		log.Errorf("Failed to copy container SBOM into the root file system: %v", err)
+		return fmt.Errorf("failed to copy container SBOM into the root file system: %w", err)
+	}
+	return nil
+}
+
+// CopyContainerSBOMToImageBuildDir copies the container SBOM from the temp
+// directory next to the container artifacts
+func CopyContainerSBOMToImageBuildDir(imageBuildDir string) error {
+	srcSBOM := filepath.Join(config.TempDir(), ContainerSPDXFile)
+	if _, err := os.Stat(srcSBOM); os.IsNotExist(err) {
+		log.Warnf("Container SBOM not found at %s, skipping copy", srcSBOM)
+		return nil
+	}
+	data, err := security.SafeReadFile(srcSBOM, security.RejectSymlinks)
+	if err != nil {
+		log.Errorf("Failed to read container SBOM: %v", err)
+		return fmt.Errorf("failed to read container SBOM: %w", err)
+	}
+	dstSBOM := filepath.Join(imageBuildDir, ContainerSPDXFile)
+	if err := security.SafeWriteFile(dstSBOM, data, 0644, security.RejectSymlinks); err != nil {
+		log.Errorf("Failed to write container SBOM to image build directory: %v", err)
+		return fmt.Errorf("failed to write container SBOM to image build directory: %w", err)
+	}
+	return nil
+}
+
+// RemovePackagesFromSPDX drops the named packages from the SBOM in the temp
+// directory, so the SBOM lists the packages left in the image after removal
+func RemovePackagesFromSPDX(pkgNames []string) error {
+	if len(pkgNames) == 0 {
+		return nil
+	}
+	spdxFile := filepath.Join(config.TempDir(), DefaultSPDXFile)
+	if _, err := os.Stat(spdxFile); os.IsNotExist(err) {
+		log.Warnf("SBOM file not found at %s, skipping package removal", spdxFile)
+		return nil
+	}
+
+	spdx, err := readSPDXFile(spdxFile)
+	if err != nil {
+		return err
+	}
+
+	removed := make(map[string]bool, len(pkgNames))
+	for _, name := range pkgNames {
+		removed[name] = true
+	}
+	kept := make([]SPDXPackage, 0, len(spdx.Packages))
+	for _, pkg := range spdx.Packages {
+		if !removed[pkg.Name] {
+			kept = append(kept, pkg)
+		}
+	}
+	log.Infof("Removing %d packages from SPDX manifest", len(spdx.Packages)-len(kept))
+	spdx.Packages = kept
+
+	return writeSPDXFile(spdx, spdxFile)
+}
+
+// AnnotateSPDXPackages sets the comment of the named packages in the SBOM in
+// the temp directory, for example to record which boot entry a kernel serves
+func AnnotateSPDXPackages(comments map[string]string) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	spdxFile := filepath.Join(config.TempDir(), DefaultSPDXFile)
+	if _, err := os.Stat(spdxFile); os.IsNotExist(err) {
+		log.Warnf("SBOM file not found at %s, skipping package annotation", spdxFile)
+		return nil
+	}
+
+	spdx, err := readSPDXFile(spdxFile)
+	if err != nil {
+		return err
+	}
+	for i := range spdx.Packages {
+		if comment, ok := comments[spdx.Packages[i].Name]; ok {
+			spdx.Packages[i].Comment = comment
+		}
+	}
+	return writeSPDXFile(spdx, spdxFile)
+}
+
+func readSPDXFile(spdxFile string) (SPDXDocument, error) {
+	var spdx SPDXDocument
+	data, err := security.SafeReadFile(spdxFile, security.RejectSymlinks)
+	if err != nil {
+		log.Errorf("Failed to read SBOM file: %v", err)
+		return spdx, fmt.Errorf("failed to read SBOM file: %w", err)
+	}
+	if err := json.Unmarshal(data, &spdx); err != nil {
+		log.Errorf("Failed to parse SBOM file: %v", err)
+		return spdx, fmt.Errorf("failed to parse SBOM file: %w", err)
+	}
+	return spdx, nil
+}
+
+func writeSPDXFile(spdx SPDXDocument, spdxFile string) error {
+	jsonData, err := json.MarshalIndent(spdx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SPDX JSON: %w", err)
+	}
+	if err := security.SafeWriteFile(spdxFile, jsonData, 0600, security.RejectSymlinks); err != nil {
+		log.Errorf("Failed to write SPDX file: %v", err)
+		return fmt.Errorf("failed to write SPDX file: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package manifest
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -435,3 +436,158 @@ func TestCopySBOMToImageBuildDir(t *testing.T) {
 	}
 	// Should just log warning and return nil
 }
+
+func TestRemovePackagesFromSPDX(t *testing.T) {
+	tempDir := t.TempDir()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = tempDir
+	config.SetGlobal(newGlobal)
+
+	// A missing SBOM is skipped
+	if err := RemovePackagesFromSPDX([]string{"man-db"}); err != nil {
+		t.Fatalf("RemovePackagesFromSPDX failed without an SBOM: %v", err)
+	}
+
+	pkgs := []ospackage.PackageInfo{
+		{Name: "bash", Version: "5.2"},
+		{Name: "man-db", Version: "2.11"},
+		{Name: "groff-base", Version: "1.22"},
+	}
+	if err := WriteSPDXToFile(pkgs, filepath.Join(tempDir, DefaultSPDXFile)); err != nil {
+		t.Fatalf("WriteSPDXToFile failed: %v", err)
+	}
+	if err := RemovePackagesFromSPDX([]string{"man-db", "groff-base"}); err != nil {
+		t.Fatalf("RemovePackagesFromSPDX failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, DefaultSPDXFile))
+	if err != nil {
+		t.Fatalf("failed to read SPDX file: %v", err)
+	}
+	var doc SPDXDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("failed to parse SPDX file: %v", err)
+	}
+	if len(doc.Packages) != 1 || doc.Packages[0].Name != "bash" {
+		t.Errorf("expected only bash to remain, got %+v", doc.Packages)
+	}
+}
+
+func TestWriteContainerSBOM(t *testing.T) {
+	tempDir := t.TempDir()
+	buildDir := t.TempDir()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = tempDir
+	config.SetGlobal(newGlobal)
+
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "mkdir", Output: "", Error: nil},
+		{Pattern: "cp", Output: "", Error: nil},
+		{Pattern: ".*", Output: "", Error: fmt.Errorf("unexpected command")},
+	})
+
+	pkgs := []ospackage.PackageInfo{
+		{Name: "bash", Version: "5.2"},
+		{Name: "linux-image-generic", Version: "6.8"},
+		{Name: "grub-efi-amd64-bin", Version: "2.12"},
+	}
+	if err := WriteSPDXToFile(pkgs, filepath.Join(tempDir, DefaultSPDXFile)); err != nil {
+		t.Fatalf("WriteSPDXToFile failed: %v", err)
+	}
+	if err := WriteContainerSBOM(t.TempDir(), []string{"bash", "base-files"}); err != nil {
+		t.Fatalf("WriteContainerSBOM failed: %v", err)
+	}
+	if err := CopyContainerSBOMToImageBuildDir(buildDir); err != nil {
+		t.Fatalf("CopyContainerSBOMToImageBuildDir failed: %v", err)
+	}
+
+	for _, spdxFile := range []string{filepath.Join(tempDir, ContainerSPDXFile), filepath.Join(buildDir, ContainerSPDXFile)} {
+		data, err := os.ReadFile(spdxFile)
+		if err != nil {
+			t.Fatalf("failed to read container SBOM: %v", err)
+		}
+		var doc SPDXDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("failed to parse container SBOM: %v", err)
+		}
+		if len(doc.Packages) != 1 || doc.Packages[0].Name != "bash" {
+			t.Errorf("expected only bash in %s, got %+v", spdxFile, doc.Packages)
+		}
+	}
+
+	// The image SBOM keeps the kernel and bootloader for the disk image
+	if doc, err := readSPDXFile(filepath.Join(tempDir, DefaultSPDXFile)); err != nil || len(doc.Packages) != 3 {
+		t.Errorf("expected the image SBOM to be left alone, got %+v, %v", doc.Packages, err)
+	}
+}
+
+func TestSignManifest(t *testing.T) {
+	manifest := SoftwarePackageManifest{ImageVersion: "1.0.0", Hash: "abc", HashAlg: "sha256", Signature: "stale"}
+	var signed []byte
+	err := SignManifest(&manifest, func(data []byte) ([]byte, error) {
+		signed = data
+		return []byte("signature"), nil
+	})
+	if err != nil {
+		t.Fatalf("SignManifest failed: %v", err)
+	}
+	if manifest.Signature != "c2lnbmF0dXJl" || manifest.SigAlg != ManifestSigAlg {
+		t.Errorf("unexpected signature fields %q %q", manifest.Signature, manifest.SigAlg)
+	}
+	if !strings.Contains(string(signed), `"signature":""`) || !strings.Contains(string(signed), `"sig_alg":"sha256"`) {
+		t.Errorf("expected the signed payload to have an empty signature, got %s", signed)
+	}
+
+	err = SignManifest(&manifest, func(data []byte) ([]byte, error) { return nil, os.ErrPermission })
+	if err == nil || !strings.Contains(err.Error(), "failed to sign the image manifest") {
+		t.Errorf("expected a signing error, got: %v", err)
+	}
+}
+
+func TestAnnotateSPDXPackages(t *testing.T) {
+	tempDir := t.TempDir()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = tempDir
+	config.SetGlobal(newGlobal)
+
+	pkgs := []ospackage.PackageInfo{
+		{Name: "kernel", Version: "6.12.8"},
+		{Name: "kernel-rt", Version: "6.12.8"},
+		{Name: "bash", Version: "5.2"},
+	}
+	if err := WriteSPDXToFile(pkgs, filepath.Join(tempDir, DefaultSPDXFile)); err != nil {
+		t.Fatalf("WriteSPDXToFile failed: %v", err)
+	}
+	comments := map[string]string{
+		"kernel":    "Kernel of boot entries: generic",
+		"kernel-rt": "Kernel of boot entries: rt (default)",
+	}
+	if err := AnnotateSPDXPackages(comments); err != nil {
+		t.Fatalf("AnnotateSPDXPackages failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, DefaultSPDXFile))
+	if err != nil {
+		t.Fatalf("failed to read SPDX file: %v", err)
+	}
+	var doc SPDXDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("failed to parse SPDX file: %v", err)
+	}
+	for _, pkg := range doc.Packages {
+		if pkg.Comment != comments[pkg.Name] {
+			t.Errorf("expected comment %q for %s, got %q", comments[pkg.Name], pkg.Name, pkg.Comment)
+		}
+	}
+}
@@ -38,6 +38,10 @@ func (d *DefaultConfigLoader) LoadDefaultConfig(imageType string) (*ImageTemplat
 		defaultConfigFile = fmt.Sprintf("default-initrd-%s.yml", d.targetArch)
 	case "iso":
 		defaultConfigFile = fmt.Sprintf("default-iso-%s.yml", d.targetArch)
+	case "live-iso":
+		defaultConfigFile = fmt.Sprintf("default-live-iso-%s.yml", d.targetArch)
+	case "netboot":
+		defaultConfigFile = fmt.Sprintf("default-netboot-%s.yml", d.targetArch)
 	default:
 		log.Errorf("Unsupported image type: %s", imageType)
 		return nil, fmt.Errorf("unsupported image type: %s", imageType)
@@ -109,7 +113,11 @@ func MergeConfigurations(userTemplate, defaultTemplate *ImageTemplate) (*ImageTe
 	mergedTemplate.Target = userTemplate.Target
 
 	// Disk configuration - user override if provided
-	if !isEmptyDiskConfig(userTemplate.Disk) {
+	if isArtifactsOnlyDiskConfig(userTemplate.Disk) {
+		// Artifacts alone keep the default disk layout
+		mergedTemplate.Disk.Artifacts = userTemplate.Disk.Artifacts
+		log.Debugf("User artifacts override default disk artifacts")
+	} else if !isEmptyDiskConfig(userTemplate.Disk) {
 		mergedTemplate.Disk = userTemplate.Disk
 		log.Debugf("User disk config overrides default")
 	}
@@ -184,7 +192,7 @@ func mergeSystemConfig(defaultConfig, userConfig SystemConfig) SystemConfig {
 
 	// Merge users config
 	if len(userConfig.Users) > 0 {
-		merged.Users = mergeUsers(defaultConfig.Users, userConfig.Users)
+		merged.Users = MergeUsers(defaultConfig.Users, userConfig.Users)
 	}
 
 	if len(userConfig.AdditionalFiles) > 0 {
@@ -205,9 +213,286 @@ func mergeSystemConfig(defaultConfig, userConfig SystemConfig) SystemConfig {
 		merged.Packages = mergePackages(defaultConfig.Packages, userConfig.Packages)
 	}
 
+	// Packages removed by the user are not installed explicitly, and packages
+	// the user installs are no longer removed
+	if len(userConfig.Remove) > 0 {
+		merged.Packages = removeStringItems(merged.Packages, userConfig.Remove)
+	}
+	merged.Remove = mergeStringSlices(removeStringItems(defaultConfig.Remove, userConfig.Packages), userConfig.Remove)
+	merged.Prune = mergePruneConfig(defaultConfig.Prune, userConfig.Prune)
+	if userConfig.MaxRootfsSize != "" {
+		merged.MaxRootfsSize = userConfig.MaxRootfsSize
+	}
+
 	// Merge kernel config
 	merged.Kernel = mergeKernelConfig(defaultConfig.Kernel, userConfig.Kernel)
 
+	merged.Localization = mergeLocalizationConfig(defaultConfig.Localization, userConfig.Localization)
+	merged.Time = mergeTimeConfig(defaultConfig.Time, userConfig.Time)
+	merged.Sysctl = mergeStringMaps(defaultConfig.Sysctl, userConfig.Sysctl)
+	merged.Memory = mergeMemoryConfig(defaultConfig.Memory, userConfig.Memory)
+
+	merged.SSH = mergeSSHConfig(defaultConfig.SSH, userConfig.SSH)
+	merged.Services = mergeServicesConfig(defaultConfig.Services, userConfig.Services)
+	merged.Network = mergeNetworkConfig(defaultConfig.Network, userConfig.Network)
+	merged.Live = mergeLiveConfig(defaultConfig.Live, userConfig.Live)
+	merged.Netboot = mergeNetbootConfig(defaultConfig.Netboot, userConfig.Netboot)
+	merged.Container = mergeContainerConfig(defaultConfig.Container, userConfig.Container)
+	merged.CloudInit = mergeCloudInitConfig(defaultConfig.CloudInit, userConfig.CloudInit)
+
+	return merged
+}
+
+// mergeLiveConfig overlays the user live ISO settings onto the defaults
+func mergeLiveConfig(defaultLive, userLive LiveConfig) LiveConfig {
+	merged := defaultLive
+	if userLive.Compression != "" {
+		merged.Compression = userLive.Compression
+	}
+	if userLive.Persistence.Enabled {
+		merged.Persistence.Enabled = true
+	}
+	if userLive.Persistence.Label != "" {
+		merged.Persistence.Label = userLive.Persistence.Label
+	}
+	if userLive.Persistence.Size != "" {
+		merged.Persistence.Size = userLive.Persistence.Size
+	}
+	return merged
+}
+
+// mergeNetbootConfig overlays the user netboot bundle settings onto the defaults
+func mergeNetbootConfig(defaultNetboot, userNetboot NetbootConfig) NetbootConfig {
+	merged := defaultNetboot
+	if userNetboot.BaseURL != "" {
+		merged.BaseURL = userNetboot.BaseURL
+	}
+	if userNetboot.UKI {
+		merged.UKI = true
+	}
+	return merged
+}
+
+// mergeContainerConfig overlays the user container image config onto the
+// defaults; user labels are added to the default labels
+func mergeContainerConfig(defaultContainer, userContainer ContainerConfig) ContainerConfig {
+	merged := defaultContainer
+	if len(userContainer.Entrypoint) > 0 {
+		merged.Entrypoint = userContainer.Entrypoint
+	}
+	if len(userContainer.Cmd) > 0 {
+		merged.Cmd = userContainer.Cmd
+	}
+	if len(userContainer.Env) > 0 {
+		merged.Env = userContainer.Env
+	}
+	if userContainer.WorkingDir != "" {
+		merged.WorkingDir = userContainer.WorkingDir
+	}
+	if userContainer.User != "" {
+		merged.User = userContainer.User
+	}
+	if len(userContainer.Labels) > 0 {
+		merged.Labels = make(map[string]string, len(defaultContainer.Labels)+len(userContainer.Labels))
+		for key, value := range defaultContainer.Labels {
+			merged.Labels[key] = value
+		}
+		for key, value := range userContainer.Labels {
+			merged.Labels[key] = value
+		}
+	}
+	return merged
+}
+
+// mergeCloudInitConfig overlays the user cloud-init config onto the defaults;
+// a user document replaces the default one whether inline or from a file
+func mergeCloudInitConfig(defaultCloudInit, userCloudInit CloudInitConfig) CloudInitConfig {
+	merged := defaultCloudInit
+	if userCloudInit.UserData != "" || userCloudInit.UserDataFile != "" {
+		merged.UserData = userCloudInit.UserData
+		merged.UserDataFile = userCloudInit.UserDataFile
+	}
+	if userCloudInit.MetaData != "" || userCloudInit.MetaDataFile != "" {
+		merged.MetaData = userCloudInit.MetaData
+		merged.MetaDataFile = userCloudInit.MetaDataFile
+	}
+	if userCloudInit.NetworkConfig != "" || userCloudInit.NetworkConfigFile != "" {
+		merged.NetworkConfig = userCloudInit.NetworkConfig
+		merged.NetworkConfigFile = userCloudInit.NetworkConfigFile
+	}
+	if userCloudInit.Seed != "" {
+		merged.Seed = userCloudInit.Seed
+	}
+	if len(userCloudInit.Datasources) > 0 {
+		merged.Datasources = userCloudInit.Datasources
+	}
+	return merged
+}
+
+// mergePruneConfig adds the user prune paths to the defaults
+func mergePruneConfig(defaultPrune, userPrune PruneConfig) PruneConfig {
+	merged := defaultPrune
+	merged.Paths = mergeStringSlices(defaultPrune.Paths, userPrune.Paths)
+	if userPrune.Docs {
+		merged.Docs = true
+	}
+	return merged
+}
+
+// mergeLocalizationConfig overlays the user regional settings onto the defaults;
+// user locales are generated in addition to the default ones
+func mergeLocalizationConfig(defaultLocalization, userLocalization LocalizationConfig) LocalizationConfig {
+	merged := defaultLocalization
+	if userLocalization.Timezone != "" {
+		merged.Timezone = userLocalization.Timezone
+	}
+	if len(userLocalization.Locales) > 0 {
+		merged.Locales = mergeStringSlices(defaultLocalization.Locales, userLocalization.Locales)
+	}
+	if userLocalization.Lang != "" {
+		merged.Lang = userLocalization.Lang
+	}
+	if userLocalization.Keymap != "" {
+		merged.Keymap = userLocalization.Keymap
+	}
+	return merged
+}
+
+// mergeTimeConfig overlays the user clock settings onto the defaults; user time
+// servers replace the default ones
+func mergeTimeConfig(defaultTime, userTime TimeConfig) TimeConfig {
+	merged := defaultTime
+	if len(userTime.NTP) > 0 {
+		merged.NTP = userTime.NTP
+	}
+	if userTime.RTC != "" {
+		merged.RTC = userTime.RTC
+	}
+	return merged
+}
+
+// mergeMemoryConfig overlays the user swap and tmpfs settings onto the defaults
+func mergeMemoryConfig(defaultMemory, userMemory MemoryConfig) MemoryConfig {
+	merged := defaultMemory
+	if userMemory.SwapFile.Size != "" {
+		merged.SwapFile.Size = userMemory.SwapFile.Size
+	}
+	if userMemory.SwapFile.Path != "" {
+		merged.SwapFile.Path = userMemory.SwapFile.Path
+	}
+	if userMemory.SwapFile.CreateAt != "" {
+		merged.SwapFile.CreateAt = userMemory.SwapFile.CreateAt
+	}
+	if userMemory.Zram.Enabled {
+		merged.Zram.Enabled = true
+	}
+	if userMemory.Zram.Size != "" {
+		merged.Zram.Size = userMemory.Zram.Size
+	}
+	if userMemory.Zram.Compression != "" {
+		merged.Zram.Compression = userMemory.Zram.Compression
+	}
+	if userMemory.Tmpfs.Enabled {
+		merged.Tmpfs.Enabled = true
+	}
+	if userMemory.Tmpfs.Size != "" {
+		merged.Tmpfs.Size = userMemory.Tmpfs.Size
+	}
+	return merged
+}
+
+// mergeNetworkConfig adds the user interfaces to the defaults. A user interface
+// replaces the default interface with the same name
+func mergeNetworkConfig(defaultNetwork, userNetwork NetworkConfig) NetworkConfig {
+	merged := defaultNetwork
+	if userNetwork.Backend != "" {
+		merged.Backend = userNetwork.Backend
+	}
+
+	if len(userNetwork.Interfaces) > 0 {
+		merged.Interfaces = nil
+		for _, iface := range defaultNetwork.Interfaces {
+			overridden := false
+			for _, userIface := range userNetwork.Interfaces {
+				if userIface.Name == iface.Name {
+					overridden = true
+					break
+				}
+			}
+			if !overridden {
+				merged.Interfaces = append(merged.Interfaces, iface)
+			}
+		}
+		merged.Interfaces = append(merged.Interfaces, userNetwork.Interfaces...)
+	}
+	return merged
+}
+
+// mergeServicesConfig adds the user unit lists to the defaults. A unit the user
+// enables, disables or masks is dropped from the other default lists
+func mergeServicesConfig(defaultServices, userServices ServicesConfig) ServicesConfig {
+	merged := defaultServices
+	merged.Enable = mergeStringSlices(
+		removeStringItems(defaultServices.Enable, userServices.Disable, userServices.Mask), userServices.Enable)
+	merged.Disable = mergeStringSlices(
+		removeStringItems(defaultServices.Disable, userServices.Enable, userServices.Mask), userServices.Disable)
+	merged.Mask = mergeStringSlices(
+		removeStringItems(defaultServices.Mask, userServices.Enable, userServices.Disable), userServices.Mask)
+
+	// User units replace default units with the same name and drop-in
+	if len(userServices.Units) > 0 {
+		merged.Units = nil
+		for _, unit := range defaultServices.Units {
+			overridden := false
+			for _, userUnit := range userServices.Units {
+				if userUnit.Name == unit.Name && userUnit.DropIn == unit.DropIn {
+					overridden = true
+					break
+				}
+			}
+			if !overridden {
+				merged.Units = append(merged.Units, unit)
+			}
+		}
+		merged.Units = append(merged.Units, userServices.Units...)
+	}
+
+	if userServices.DefaultTarget != "" {
+		merged.DefaultTarget = userServices.DefaultTarget
+	}
+	return merged
+}
+
+// removeStringItems returns the items not present in any of the remove lists
+func removeStringItems(items []string, removeLists ...[]string) []string {
+	var kept []string
+	for _, item := range items {
+		removed := false
+		for _, removeList := range removeLists {
+			if slice.Contains(removeList, item) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// mergeSSHConfig overlays the user sshd settings onto the defaults; hardening
+// enabled by the defaults cannot be turned off by a user template
+func mergeSSHConfig(defaultSSH, userSSH SSHConfig) SSHConfig {
+	merged := defaultSSH
+	merged.DisablePasswordAuth = defaultSSH.DisablePasswordAuth || userSSH.DisablePasswordAuth
+	merged.DisableRootLogin = defaultSSH.DisableRootLogin || userSSH.DisableRootLogin
+	if len(userSSH.Ciphers) > 0 {
+		merged.Ciphers = userSSH.Ciphers
+	}
+	if userSSH.Port != 0 {
+		merged.Port = userSSH.Port
+	}
 	return merged
 }
 
@@ -222,6 +507,10 @@ func isEmptyImmutabilityConfig(config ImmutabilityConfig) bool {
 		return false
 	}
 
+	if config.SecureBoot || config.SecureBootMode != "" {
+		return false
+	}
+
 	// If enabled is true, they explicitly set it
 	if config.Enabled {
 		return false
@@ -253,6 +542,14 @@ func mergeImmutabilityConfig(defaultImmutability, userImmutability ImmutabilityC
 		merged.SecureBootDBCer = userImmutability.SecureBootDBCer
 	}
 
+	if userImmutability.SecureBoot {
+		merged.SecureBoot = true
+	}
+
+	if userImmutability.SecureBootMode != "" {
+		merged.SecureBootMode = userImmutability.SecureBootMode
+	}
+
 	return merged
 }
 
@@ -279,8 +576,9 @@ func mergeAdditionalFiles(defaultFiles, userFiles []AdditionalFileInfo) []Additi
 	return mergedFiles
 }
 
-// mergeUsers merges user configurations
-func mergeUsers(defaultUsers, userUsers []UserConfig) []UserConfig {
+// MergeUsers merges user configurations, overriding default users with the
+// user entries of the same name
+func MergeUsers(defaultUsers, userUsers []UserConfig) []UserConfig {
 	merged := make([]UserConfig, 0, len(defaultUsers)+len(userUsers))
 	userMap := make(map[string]UserConfig)
 
@@ -355,6 +653,14 @@ func mergeUserConfig(defaultUser, userUser UserConfig) UserConfig {
 		merged.Groups = mergeStringSlices(defaultUser.Groups, userUser.Groups)
 	}
 
+	// Merge SSH keys
+	if len(userUser.SSHKeys) > 0 {
+		merged.SSHKeys = mergeStringSlices(defaultUser.SSHKeys, userUser.SSHKeys)
+	}
+	if len(userUser.SSHKeyFiles) > 0 {
+		merged.SSHKeyFiles = mergeStringSlices(defaultUser.SSHKeyFiles, userUser.SSHKeyFiles)
+	}
+
 	// Override sudo setting
 	merged.Sudo = userUser.Sudo
 
@@ -395,6 +701,10 @@ func mergeBootloader(defaultBootloader, userBootloader Bootloader) Bootloader {
 	if userBootloader.Provider != "" {
 		merged.Provider = userBootloader.Provider
 	}
+	// A pointer, so that a user template can set the timeout back to 0
+	if userBootloader.Timeout != nil {
+		merged.Timeout = userBootloader.Timeout
+	}
 
 	return merged
 }
@@ -447,11 +757,76 @@ func mergeKernelConfig(defaultKernel, userKernel KernelConfig) KernelConfig {
 		merged.EnableExtraModules = userKernel.EnableExtraModules
 	}
 
+	if userKernel.Lockdown != "" {
+		merged.Lockdown = userKernel.Lockdown
+	}
+
+	if userKernel.PCRSigning.IsEnabled() {
+		merged.PCRSigning = userKernel.PCRSigning
+	}
+
+	if len(userKernel.Entries) > 0 {
+		merged.Entries = userKernel.Entries
+	}
+
+	merged.Modules = mergeKernelModules(defaultKernel.Modules, userKernel.Modules)
+
 	// Note: name and uki fields come from defaults and are preserved
 
 	return merged
 }
 
+// mergeKernelModules adds the user module lists to the defaults, deduplicated by
+// normalized module name. A module the user loads is dropped from the default
+// blacklist and the other way round; user module options replace the defaults
+func mergeKernelModules(defaultModules, userModules KernelModules) KernelModules {
+	var merged KernelModules
+	merged.Load = mergeStringSlices(
+		removeStringItems(normalizeModuleNames(defaultModules.Load), normalizeModuleNames(userModules.Blacklist)),
+		normalizeModuleNames(userModules.Load))
+	merged.Initramfs = mergeStringSlices(
+		removeStringItems(normalizeModuleNames(defaultModules.Initramfs), normalizeModuleNames(userModules.Blacklist)),
+		normalizeModuleNames(userModules.Initramfs))
+	merged.Blacklist = mergeStringSlices(
+		removeStringItems(normalizeModuleNames(defaultModules.Blacklist),
+			normalizeModuleNames(userModules.Load), normalizeModuleNames(userModules.Initramfs)),
+		normalizeModuleNames(userModules.Blacklist))
+
+	for _, options := range []map[string]string{defaultModules.Options, userModules.Options} {
+		for module, value := range options {
+			if merged.Options == nil {
+				merged.Options = make(map[string]string)
+			}
+			merged.Options[NormalizeModuleName(module)] = value
+		}
+	}
+	return merged
+}
+
+// normalizeModuleNames returns the normalized module names
+func normalizeModuleNames(modules []string) []string {
+	var normalized []string
+	for _, module := range modules {
+		normalized = append(normalized, NormalizeModuleName(module))
+	}
+	return normalized
+}
+
+// mergeStringMaps returns the default entries overlaid with the user entries
+func mergeStringMaps(defaultMap, userMap map[string]string) map[string]string {
+	if len(defaultMap) == 0 && len(userMap) == 0 {
+		return defaultMap
+	}
+	merged := make(map[string]string, len(defaultMap)+len(userMap))
+	for key, value := range defaultMap {
+		merged[key] = value
+	}
+	for key, value := range userMap {
+		merged[key] = value
+	}
+	return merged
+}
+
 func mergePackageRepositories(defaultRepos, userRepos []PackageRepository) []PackageRepository {
 	if len(userRepos) == 0 {
 		return defaultRepos
@@ -468,12 +843,18 @@ func isEmptyDiskConfig(disk DiskConfig) bool {
 	return disk.Name == "" && disk.Size == "" && len(disk.Partitions) == 0
 }
 
+// isArtifactsOnlyDiskConfig reports whether a disk config only selects the
+// output artifacts, without a size or partitions of its own
+func isArtifactsOnlyDiskConfig(disk DiskConfig) bool {
+	return disk.Size == "" && len(disk.Partitions) == 0 && len(disk.Artifacts) > 0
+}
+
 func isEmptySystemConfig(config SystemConfig) bool {
 	return config.Name == ""
 }
 
 func isEmptyBootloader(bootloader Bootloader) bool {
-	return bootloader.BootType == "" && bootloader.Provider == ""
+	return bootloader.BootType == "" && bootloader.Provider == "" && bootloader.Timeout == nil
 }
 
 // validateAndFixImmutabilityConfig checks if immutability is enabled but hash partition is missing
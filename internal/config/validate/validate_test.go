@@ -277,6 +277,150 @@ func TestImageTemplateValidation(t *testing.T) {
 			shouldPass:  false,
 			description: "template with incorrect field types",
 		},
+		{
+			name:        "ValidNetinstallIso",
+			file:        "/testdata/netinstall-iso-template.yml",
+			shouldPass:  true,
+			description: "iso template with an install repository",
+		},
+		{
+			name:        "InvalidNetinstallRaw",
+			file:        "/testdata/netinstall-raw-template.yml",
+			shouldPass:  false,
+			description: "install repository on a non-iso template",
+		},
+		{
+			name:        "InvalidNetworkVlan",
+			file:        "/testdata/network-vlan-without-link.yml",
+			shouldPass:  false,
+			description: "vlan interface without a link",
+		},
+		{
+			name:        "InvalidSysctlValue",
+			file:        "/testdata/sysctl-multiline-value.yml",
+			shouldPass:  false,
+			description: "sysctl value spanning two lines",
+		},
+		{
+			name:        "InvalidAutoSize",
+			file:        "/testdata/auto-size-invalid.yml",
+			shouldPass:  false,
+			description: "partition size other than auto",
+		},
+		{
+			name:        "ValidBtrfsSubvolumes",
+			file:        "/testdata/btrfs-subvolumes.yml",
+			shouldPass:  true,
+			description: "btrfs partition with subvolumes and a snapshot",
+		},
+		{
+			name:        "InvalidBtrfsSnapshot",
+			file:        "/testdata/btrfs-subvolume-invalid.yml",
+			shouldPass:  false,
+			description: "absolute btrfs snapshot path",
+		},
+		{
+			name:        "InvalidMemory",
+			file:        "/testdata/memory-invalid.yml",
+			shouldPass:  false,
+			description: "zram size that is not a percentage of RAM",
+		},
+		{
+			name:        "ValidSecureBootGrub",
+			file:        "/testdata/secure-boot-grub.yml",
+			shouldPass:  true,
+			description: "secure boot through shim and MOK without immutability",
+		},
+		{
+			name:        "InvalidSecureBoot",
+			file:        "/testdata/secure-boot-invalid.yml",
+			shouldPass:  false,
+			description: "secure boot without the signing keys",
+		},
+		{
+			name:        "ValidKernelEntries",
+			file:        "/testdata/kernel-entries.yml",
+			shouldPass:  true,
+			description: "generic, realtime and recovery boot entries with a menu timeout",
+		},
+		{
+			name:        "ValidLiveIso",
+			file:        "/testdata/live-iso.yml",
+			shouldPass:  true,
+			description: "live ISO with zstd squashfs and a persistence partition",
+		},
+		{
+			name:        "ValidNetboot",
+			file:        "/testdata/netboot.yml",
+			shouldPass:  true,
+			description: "netboot bundle served over HTTP with a UKI",
+		},
+		{
+			name:        "InvalidLiveIsoOS",
+			file:        "/testdata/live-iso-unsupported-os.yml",
+			shouldPass:  false,
+			description: "live ISO for an OS whose provider does not build it",
+		},
+		{
+			name:        "InvalidNetbootUKI",
+			file:        "/testdata/netboot-uki-invalid.yml",
+			shouldPass:  false,
+			description: "netboot UKI without a base URL",
+		},
+		{
+			name:        "ValidContainer",
+			file:        "/testdata/container.yml",
+			shouldPass:  true,
+			description: "rootfs tarball, OCI and Docker archives with an image config",
+		},
+		{
+			name:        "InvalidContainerEnv",
+			file:        "/testdata/container-env-invalid.yml",
+			shouldPass:  false,
+			description: "container environment variable without a value assignment",
+		},
+		{
+			name:        "ValidCloudProfiles",
+			file:        "/testdata/cloud-profiles.yml",
+			shouldPass:  true,
+			description: "Azure fixed VHD, AWS raw and GCE tarball next to a qcow2",
+		},
+		{
+			name:        "InvalidCloudProfile",
+			file:        "/testdata/cloud-profile-invalid.yml",
+			shouldPass:  false,
+			description: "Azure profile on a qcow2 artifact",
+		},
+		{
+			name:        "InvalidCloudProfileCompression",
+			file:        "/testdata/cloud-profile-gce-compression.yml",
+			shouldPass:  false,
+			description: "compression on a GCE tarball that is already gzipped",
+		},
+		{
+			name:        "ValidCloudInit",
+			file:        "/testdata/cloud-init.yml",
+			shouldPass:  true,
+			description: "inline user-data and a network-config file on a cidata ISO",
+		},
+		{
+			name:        "InvalidCloudInitIsoSeedLiveIso",
+			file:        "/testdata/cloud-init-iso-seed-live.yml",
+			shouldPass:  false,
+			description: "cidata ISO seed on a live ISO, which has no disk artifacts",
+		},
+		{
+			name:        "InvalidCloudInitIsoSeedContainer",
+			file:        "/testdata/cloud-init-iso-seed-container.yml",
+			shouldPass:  false,
+			description: "cidata ISO seed with only a container artifact",
+		},
+		{
+			name:        "InvalidCloudInitUserData",
+			file:        "/testdata/cloud-init-invalid.yml",
+			shouldPass:  false,
+			description: "user-data both inline and from a file",
+		},
 	}
 
 	for _, tt := range tests {
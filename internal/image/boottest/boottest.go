@@ -0,0 +1,369 @@
+package boottest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/utils/compression"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	FirmwareUEFI = "uefi"
+	FirmwareBIOS = "bios"
+
+	MarkerLogin   = "login"
+	MarkerSystemd = "systemd"
+
+	DefaultTimeout = 10 * time.Minute
+	DefaultMemory  = "2048"
+
+	// consoleTailSize bounds the amount of console output kept in memory for
+	// marker matching; markers are expected to fit on a single screen line.
+	consoleTailSize = 4096
+
+	// cloudInitSeedSuffix names the cloud-init seed ISO written next to raw images
+	cloudInitSeedSuffix = "-cidata.iso"
+)
+
+var log = logger.Logger()
+
+// builtinMarkers maps the well-known marker names to console regexes
+var builtinMarkers = map[string]string{
+	MarkerLogin:   `(?m)login:\s*$`,
+	MarkerSystemd: `Reached target .*(Multi-User System|Graphical Interface)`,
+}
+
+// ovmfCodePaths lists the OVMF firmware locations used by common host distributions
+var ovmfCodePaths = []string{
+	"/usr/share/OVMF/OVMF_CODE_4M.fd",
+	"/usr/share/OVMF/OVMF_CODE.fd",
+	"/usr/share/edk2/ovmf/OVMF_CODE.fd",
+	"/usr/share/edk2/x64/OVMF_CODE.fd",
+	"/usr/share/qemu/ovmf-x86_64-code.bin",
+}
+
+// ovmfVarsPaths lists the OVMF variable store templates matching ovmfCodePaths
+var ovmfVarsPaths = []string{
+	"/usr/share/OVMF/OVMF_VARS_4M.fd",
+	"/usr/share/OVMF/OVMF_VARS.fd",
+	"/usr/share/edk2/ovmf/OVMF_VARS.fd",
+	"/usr/share/edk2/x64/OVMF_VARS.fd",
+	"/usr/share/qemu/ovmf-x86_64-vars.bin",
+}
+
+// bootableExtensions maps artifact extensions to the QEMU disk format used to attach them
+var bootableExtensions = map[string]string{
+	".raw":   "raw",
+	".qcow2": "qcow2",
+	".iso":   "iso",
+}
+
+// compressionExtensions maps compressed artifact suffixes to the compression type
+var compressionExtensions = map[string]string{
+	".gz":   "gz",
+	".xz":   "xz",
+	".zstd": "zstd",
+}
+
+// Options configures a boot smoke test run
+type Options struct {
+	Firmware string        // Firmware: "uefi" (OVMF) or "bios" (SeaBIOS)
+	Marker   string        // Marker: "login", "systemd" or a custom regular expression
+	Timeout  time.Duration // Timeout: maximum time to wait for the marker
+	Memory   string        // Memory: guest memory size in MiB
+	OvmfCode string        // OvmfCode: optional override for the OVMF code image
+	OvmfVars string        // OvmfVars: optional override for the OVMF variable store template
+	LogFile  string        // LogFile: serial console log path, defaults to <image>.boot.log
+}
+
+// Result holds the outcome of a boot smoke test run
+type Result struct {
+	ImagePath string
+	LogFile   string
+	Passed    bool
+	Duration  time.Duration
+}
+
+// ResolveMarker returns the regular expression for a marker name or custom regex
+func ResolveMarker(marker string) (*regexp.Regexp, error) {
+	if marker == "" {
+		marker = MarkerLogin
+	}
+	pattern, ok := builtinMarkers[marker]
+	if !ok {
+		pattern = marker
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boot marker %q: %w", marker, err)
+	}
+	return re, nil
+}
+
+// FirmwareForBootType maps a template bootloader bootType to the test firmware
+func FirmwareForBootType(bootType string) string {
+	if strings.ToLower(bootType) == "legacy" {
+		return FirmwareBIOS
+	}
+	return FirmwareUEFI
+}
+
+// IsBootTestable returns whether images of a template image type boot to a
+// login prompt. Installer ISOs stop in the installer, so they never reach the
+// boot marker.
+func IsBootTestable(imageType string) bool {
+	return imageType != "iso"
+}
+
+// FindBootableArtifacts returns the bootable raw, qcow2 and ISO artifacts in a
+// build directory. cloud-init seed ISOs are data disks and are skipped.
+func FindBootableArtifacts(imageBuildDir string) ([]string, error) {
+	entries, err := os.ReadDir(imageBuildDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image build directory %s: %w", imageBuildDir, err)
+	}
+	var artifacts []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), cloudInitSeedSuffix) {
+			continue
+		}
+		if _, _, ok := artifactFormat(entry.Name()); ok {
+			artifacts = append(artifacts, filepath.Join(imageBuildDir, entry.Name()))
+		}
+	}
+	return artifacts, nil
+}
+
+// artifactFormat returns the QEMU format and compression type of an artifact file name
+func artifactFormat(name string) (format, compressionType string, ok bool) {
+	ext := filepath.Ext(name)
+	if ctype, found := compressionExtensions[ext]; found {
+		compressionType = ctype
+		name = strings.TrimSuffix(name, ext)
+		ext = filepath.Ext(name)
+	}
+	format, ok = bootableExtensions[ext]
+	return format, compressionType, ok
+}
+
+// Run boots the image in QEMU using TCG and waits for the marker on the serial console
+func Run(imagePath string, opts Options) (*Result, error) {
+	if _, err := os.Stat(imagePath); err != nil {
+		return nil, fmt.Errorf("image file %s not accessible: %w", imagePath, err)
+	}
+	format, compressionType, ok := artifactFormat(filepath.Base(imagePath))
+	if !ok {
+		return nil, fmt.Errorf("unsupported boot test artifact: %s", imagePath)
+	}
+	if opts.Firmware == "" {
+		opts.Firmware = FirmwareUEFI
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Memory == "" {
+		opts.Memory = DefaultMemory
+	}
+	if opts.LogFile == "" {
+		opts.LogFile = imagePath + ".boot.log"
+	}
+	markerRe, err := ResolveMarker(opts.Marker)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir, err := os.MkdirTemp(filepath.Dir(opts.LogFile), ".boottest-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create boot test work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	bootImage := imagePath
+	if compressionType != "" {
+		bootImage = filepath.Join(workDir, strings.TrimSuffix(filepath.Base(imagePath), filepath.Ext(imagePath)))
+		log.Infof("Decompressing %s for boot test", imagePath)
+		if err := compression.DecompressFile(imagePath, bootImage, compressionType, false); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", imagePath, err)
+		}
+	}
+
+	args, err := buildQemuArgs(bootImage, format, workDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Boot testing %s (firmware: %s, marker: %s, timeout: %s)",
+		imagePath, opts.Firmware, markerRe.String(), opts.Timeout)
+
+	start := time.Now()
+	passed, err := runQemu(args, markerRe, opts.Timeout, opts.LogFile)
+	result := &Result{
+		ImagePath: imagePath,
+		LogFile:   opts.LogFile,
+		Passed:    passed,
+		Duration:  time.Since(start),
+	}
+	if err != nil {
+		return result, err
+	}
+	if !passed {
+		log.Errorf("Boot test failed for %s, console log: %s", imagePath, opts.LogFile)
+		return result, fmt.Errorf("boot marker %q not seen within %s, console log: %s",
+			markerRe.String(), opts.Timeout, opts.LogFile)
+	}
+	log.Infof("Boot test passed for %s in %s", imagePath, result.Duration.Round(time.Second))
+	return result, nil
+}
+
+// buildQemuArgs assembles the qemu-system-x86_64 command line for the boot test
+func buildQemuArgs(imagePath, format, workDir string, opts Options) ([]string, error) {
+	args := []string{
+		"qemu-system-x86_64",
+		"-machine", "q35",
+		"-accel", "tcg",
+		"-m", opts.Memory,
+		"-smp", "2",
+		"-nographic",
+		"-serial", "mon:stdio",
+		"-no-reboot",
+	}
+
+	switch opts.Firmware {
+	case FirmwareUEFI:
+		code, vars, err := locateOvmf(opts)
+		if err != nil {
+			return nil, err
+		}
+		// The variable store is writable, so boot from a private copy
+		varsCopy := filepath.Join(workDir, "OVMF_VARS.fd")
+		if err := file.CopyFile(vars, varsCopy, "", false); err != nil {
+			return nil, fmt.Errorf("failed to copy OVMF variable store: %w", err)
+		}
+		args = append(args,
+			"-drive", fmt.Sprintf("if=pflash,format=raw,readonly=on,file=%s", code),
+			"-drive", fmt.Sprintf("if=pflash,format=raw,file=%s", varsCopy))
+	case FirmwareBIOS:
+		// SeaBIOS is the QEMU default firmware
+	default:
+		return nil, fmt.Errorf("unsupported boot test firmware: %s", opts.Firmware)
+	}
+
+	if format == "iso" {
+		args = append(args, "-cdrom", imagePath, "-boot", "d")
+	} else {
+		args = append(args, "-drive", fmt.Sprintf("file=%s,format=%s,if=virtio,snapshot=on", imagePath, format))
+	}
+	return args, nil
+}
+
+// locateOvmf returns the OVMF code and variable store images to use
+func locateOvmf(opts Options) (code, vars string, err error) {
+	code = opts.OvmfCode
+	vars = opts.OvmfVars
+	if code == "" {
+		code = firstExisting(ovmfCodePaths)
+	}
+	if vars == "" {
+		vars = firstExisting(ovmfVarsPaths)
+	}
+	if code == "" || vars == "" {
+		return "", "", fmt.Errorf("OVMF firmware not found, install the ovmf package or use BIOS firmware")
+	}
+	return code, vars, nil
+}
+
+func firstExisting(paths []string) string {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// runQemu runs the command, tees the serial console into logFile and stops QEMU
+// as soon as the marker is seen or the timeout expires. The arguments are
+// passed to QEMU as they are, without a shell, so paths may hold spaces.
+func runQemu(args []string, markerRe *regexp.Regexp, timeout time.Duration, logFile string) (bool, error) {
+	qemuPath, err := shell.GetFullCmdStr(args[0], false, shell.HostPath, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to prepare qemu command: %w", err)
+	}
+
+	logWriter, err := os.Create(logFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to create console log %s: %w", logFile, err)
+	}
+	defer logWriter.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, qemuPath, args[1:]...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, fmt.Errorf("failed to get qemu stdout pipe: %w", err)
+	}
+	cmd.Stderr = logWriter
+
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("failed to start qemu: %w", err)
+	}
+
+	passed := watchConsole(stdout, logWriter, markerRe)
+	if passed {
+		cancel()
+	}
+	waitErr := cmd.Wait()
+
+	if passed {
+		return true, nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false, nil
+	}
+	if waitErr != nil {
+		return false, fmt.Errorf("qemu exited before boot marker was seen: %w", waitErr)
+	}
+	return false, nil
+}
+
+// watchConsole copies the console stream to the log and reports whether the marker appeared
+func watchConsole(console io.Reader, logWriter io.Writer, markerRe *regexp.Regexp) bool {
+	var tail []byte
+	buf := make([]byte, 1024)
+	for {
+		n, err := console.Read(buf)
+		if n > 0 {
+			if _, werr := logWriter.Write(buf[:n]); werr != nil {
+				log.Warnf("Failed to write console log: %v", werr)
+			}
+			tail = append(tail, buf[:n]...)
+			// Serial consoles emit CRLF line endings
+			if markerRe.Match(consoleText(tail)) {
+				return true
+			}
+			if len(tail) > consoleTailSize {
+				tail = tail[len(tail)-consoleTailSize:]
+			}
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+func consoleText(data []byte) []byte {
+	return []byte(strings.ReplaceAll(string(data), "\r", ""))
+}
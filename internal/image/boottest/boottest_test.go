@@ -0,0 +1,203 @@
+package boottest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveMarker(t *testing.T) {
+	tests := []struct {
+		name    string
+		marker  string
+		console string
+		match   bool
+		wantErr bool
+	}{
+		{"default login", "", "Ubuntu 24.04 host ttyS0\n\nhost login: ", true, false},
+		{"login no prompt", MarkerLogin, "Last login: yesterday\nWelcome", false, false},
+		{"systemd multi-user", MarkerSystemd, "[  OK  ] Reached target Multi-User System.", true, false},
+		{"systemd graphical", MarkerSystemd, "[  OK  ] Reached target Graphical Interface.", true, false},
+		{"custom regex", `edge-node-\d+ ready`, "edge-node-42 ready", true, false},
+		{"invalid regex", `([`, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := ResolveMarker(tt.marker)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for marker %q", tt.marker)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := re.MatchString(tt.console); got != tt.match {
+				t.Errorf("match = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
+
+func TestFirmwareForBootType(t *testing.T) {
+	if got := FirmwareForBootType("legacy"); got != FirmwareBIOS {
+		t.Errorf("legacy boot type should map to %s, got %s", FirmwareBIOS, got)
+	}
+	if got := FirmwareForBootType("efi"); got != FirmwareUEFI {
+		t.Errorf("efi boot type should map to %s, got %s", FirmwareUEFI, got)
+	}
+	if got := FirmwareForBootType(""); got != FirmwareUEFI {
+		t.Errorf("empty boot type should default to %s, got %s", FirmwareUEFI, got)
+	}
+}
+
+func TestArtifactFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		compression string
+		ok          bool
+	}{
+		{"image-1.0.raw", "raw", "", true},
+		{"image-1.0.raw.gz", "raw", "gz", true},
+		{"image-1.0.qcow2.zstd", "qcow2", "zstd", true},
+		{"installer.iso", "iso", "", true},
+		{"image-1.0.vhdx", "", "", false},
+		{"image.spdx.json", "", "", false},
+		{"image-1.0.raw.boot.log", "", "", false},
+	}
+
+	for _, tt := range tests {
+		format, compressionType, ok := artifactFormat(tt.name)
+		if ok != tt.ok || format != tt.format || compressionType != tt.compression {
+			t.Errorf("artifactFormat(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.name, format, compressionType, ok, tt.format, tt.compression, tt.ok)
+		}
+	}
+}
+
+func TestFindBootableArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"img.raw.gz", "img.vhd", "img.qcow2", "img-cidata.iso", "spdx_manifest.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.raw"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+
+	artifacts, err := FindBootableArtifacts(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("expected 2 bootable artifacts, got %v", artifacts)
+	}
+
+	if _, err := FindBootableArtifacts(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestIsBootTestable(t *testing.T) {
+	for imageType, expected := range map[string]bool{"raw": true, "live-iso": true, "netboot": true, "iso": false} {
+		if got := IsBootTestable(imageType); got != expected {
+			t.Errorf("IsBootTestable(%q) = %v, want %v", imageType, got, expected)
+		}
+	}
+}
+
+func TestBuildQemuArgs(t *testing.T) {
+	workDir := t.TempDir()
+
+	t.Run("bios raw", func(t *testing.T) {
+		args, err := buildQemuArgs("/images/a.raw", "raw", workDir, Options{Firmware: FirmwareBIOS, Memory: "1024"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cmd := strings.Join(args, " ")
+		for _, want := range []string{"-accel tcg", "-m 1024", "-serial mon:stdio",
+			"file=/images/a.raw,format=raw,if=virtio,snapshot=on"} {
+			if !strings.Contains(cmd, want) {
+				t.Errorf("command %q missing %q", cmd, want)
+			}
+		}
+		if strings.Contains(cmd, "pflash") {
+			t.Errorf("BIOS boot should not attach OVMF: %s", cmd)
+		}
+		if strings.Contains(cmd, "kvm") {
+			t.Errorf("boot test must not require KVM: %s", cmd)
+		}
+	})
+
+	t.Run("uefi iso", func(t *testing.T) {
+		code := filepath.Join(workDir, "code.fd")
+		vars := filepath.Join(workDir, "vars.fd")
+		for _, f := range []string{code, vars} {
+			if err := os.WriteFile(f, []byte("fw"), 0644); err != nil {
+				t.Fatalf("failed to create %s: %v", f, err)
+			}
+		}
+		args, err := buildQemuArgs("/images/a.iso", "iso", workDir,
+			Options{Firmware: FirmwareUEFI, Memory: "2048", OvmfCode: code, OvmfVars: vars})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cmd := strings.Join(args, " ")
+		if !strings.Contains(cmd, "readonly=on,file="+code) {
+			t.Errorf("command %q missing OVMF code", cmd)
+		}
+		if !strings.Contains(cmd, "file="+filepath.Join(workDir, "OVMF_VARS.fd")) {
+			t.Errorf("command %q should use a private OVMF vars copy", cmd)
+		}
+		if !strings.Contains(cmd, "-cdrom /images/a.iso -boot d") {
+			t.Errorf("command %q missing cdrom boot", cmd)
+		}
+	})
+
+	t.Run("unknown firmware", func(t *testing.T) {
+		if _, err := buildQemuArgs("/images/a.raw", "raw", workDir, Options{Firmware: "coreboot"}); err == nil {
+			t.Error("expected error for unsupported firmware")
+		}
+	})
+}
+
+func TestWatchConsole(t *testing.T) {
+	re, err := ResolveMarker(MarkerLogin)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var logBuf bytes.Buffer
+	console := strings.NewReader("BdsDxe: loading Boot0001\r\n[  OK  ] Started getty.\r\n\r\nedge login: ")
+	if !watchConsole(console, &logBuf, re) {
+		t.Error("expected login marker to be detected across CRLF output")
+	}
+	if !strings.Contains(logBuf.String(), "Started getty") {
+		t.Error("console output should be written to the log")
+	}
+
+	logBuf.Reset()
+	console = strings.NewReader("Kernel panic - not syncing: VFS: Unable to mount root fs\r\n")
+	if watchConsole(console, &logBuf, re) {
+		t.Error("marker should not be detected for a panicking boot")
+	}
+}
+
+func TestRunRejectsUnsupportedArtifact(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "image.vhdx")
+	if err := os.WriteFile(image, []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if _, err := Run(image, Options{}); err == nil {
+		t.Error("expected error for unsupported artifact type")
+	}
+	if _, err := Run(filepath.Join(t.TempDir(), "missing.raw"), Options{}); err == nil {
+		t.Error("expected error for missing image")
+	}
+}
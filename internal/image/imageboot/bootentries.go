@@ -0,0 +1,209 @@
+package imageboot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+// grubEntriesScript runs before 10_linux, so the boot entries of the template
+// come first in the GRUB menu
+const grubEntriesScript = "/etc/grub.d/09_os_image_composer"
+
+// BootEntry is a kernel entry of the template resolved against the kernels
+// installed in the image
+type BootEntry struct {
+	Name          string // Name: entry identifier
+	KernelVersion string // KernelVersion: version of the installed kernel it boots
+	Cmdline       string // Cmdline: parameters appended to the kernel command line
+	Default       bool   // Default: whether the entry boots by default
+}
+
+// ResolveBootEntries matches the boot entries of the template with the kernels
+// installed in /boot. It returns no entries when the template has none.
+func ResolveBootEntries(installRoot string, template *config.ImageTemplate) ([]BootEntry, error) {
+	kernelConfig := template.GetKernel()
+	if len(kernelConfig.Entries) == 0 {
+		return nil, nil
+	}
+
+	kernelVersions, err := getInstalledKernelVersions(installRoot)
+	if err != nil {
+		return nil, err
+	}
+	defaultEntry := kernelConfig.GetDefaultEntry()
+
+	var entries []BootEntry
+	names := make(map[string]bool)
+	var defaults int
+	for _, entry := range kernelConfig.Entries {
+		if names[entry.Name] {
+			log.Errorf("Duplicate boot entry %s", entry.Name)
+			return nil, fmt.Errorf("duplicate boot entry %s", entry.Name)
+		}
+		names[entry.Name] = true
+		if entry.Default {
+			defaults++
+		}
+
+		kernelVersion := matchKernelVersion(kernelVersions, entry.Version)
+		if kernelVersion == "" {
+			log.Errorf("No installed kernel matches version %s of boot entry %s, installed: %s",
+				entry.Version, entry.Name, strings.Join(kernelVersions, ", "))
+			return nil, fmt.Errorf("no installed kernel matches version %s of boot entry %s", entry.Version, entry.Name)
+		}
+		entries = append(entries, BootEntry{
+			Name:          entry.Name,
+			KernelVersion: kernelVersion,
+			Cmdline:       strings.TrimSpace(entry.Cmdline),
+			Default:       entry.Name == defaultEntry.Name,
+		})
+	}
+	if defaults > 1 {
+		log.Errorf("More than one boot entry is marked as default")
+		return nil, fmt.Errorf("more than one boot entry is marked as default")
+	}
+	return entries, nil
+}
+
+// getInstalledKernelVersions returns the versions of the kernels in /boot
+func getInstalledKernelVersions(installRoot string) ([]string, error) {
+	kernelDir := filepath.Join(installRoot, "boot")
+	fileList, err := file.GetFileList(kernelDir)
+	if err != nil {
+		log.Errorf("Failed to list kernel directory %s: %v", kernelDir, err)
+		return nil, fmt.Errorf("failed to list kernel directory %s: %w", kernelDir, err)
+	}
+	var versions []string
+	for _, f := range fileList {
+		if version, ok := strings.CutPrefix(f, "vmlinuz-"); ok && version != "" {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		log.Errorf("Kernel image not found in %s", kernelDir)
+		return nil, fmt.Errorf("kernel image not found in %s", kernelDir)
+	}
+	return versions, nil
+}
+
+// matchKernelVersion returns the installed kernel version equal to, or else
+// starting with, the requested version; the first kernel when none is requested
+func matchKernelVersion(kernelVersions []string, version string) string {
+	if version == "" {
+		return kernelVersions[0]
+	}
+	for _, kernelVersion := range kernelVersions {
+		if kernelVersion == version {
+			return kernelVersion
+		}
+	}
+	for _, kernelVersion := range kernelVersions {
+		if strings.HasPrefix(kernelVersion, version) {
+			return kernelVersion
+		}
+	}
+	return ""
+}
+
+// getGrubDefault returns the GRUB_DEFAULT value: the id of the default boot
+// entry, or the first menu entry without boot entries
+func getGrubDefault(template *config.ImageTemplate) string {
+	if defaultEntry := template.GetKernel().GetDefaultEntry(); defaultEntry != nil {
+		return defaultEntry.Name
+	}
+	return "0"
+}
+
+// writeGrubBootEntries writes the grub.d script that adds a menu entry for
+// each boot entry; grub-mkconfig still lists every installed kernel after them
+func writeGrubBootEntries(installRoot, bootPrefix string, entries []BootEntry, template *config.ImageTemplate) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	bootFiles, err := file.GetFileList(filepath.Join(installRoot, "boot"))
+	if err != nil {
+		return fmt.Errorf("failed to list boot directory: %w", err)
+	}
+	script, err := renderGrubBootEntries(bootPrefix, getRootSubvolumeArg(template), entries, bootFiles)
+	if err != nil {
+		return err
+	}
+
+	scriptPath := filepath.Join(installRoot, grubEntriesScript)
+	if err := file.Write(script, scriptPath); err != nil {
+		log.Errorf("Failed to write GRUB boot entries: %v", err)
+		return fmt.Errorf("failed to write GRUB boot entries: %w", err)
+	}
+	if _, err := shell.ExecCmd("chmod 755 "+scriptPath, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to make GRUB boot entries executable: %v", err)
+		return fmt.Errorf("failed to make GRUB boot entries executable: %w", err)
+	}
+	return nil
+}
+
+// renderGrubBootEntries returns the grub.d script for the boot entries. Like
+// 10_linux, it boots the root device grub-mkconfig detects, with the command
+// lines of /etc/default/grub.
+func renderGrubBootEntries(bootPrefix, rootSubvolumeArg string, entries []BootEntry, bootFiles []string) (string, error) {
+	var script strings.Builder
+	script.WriteString(`#!/bin/sh
+set -e
+# Boot entries of the image template, written by os-image-composer
+
+. "$pkgdatadir/grub-mkconfig_lib"
+
+if [ "x${GRUB_DEVICE_UUID}" = "x" ] || [ "x${GRUB_DISABLE_LINUX_UUID}" = "xtrue" ]; then
+  LINUX_ROOT_DEVICE=${GRUB_DEVICE}
+else
+  LINUX_ROOT_DEVICE=UUID=${GRUB_DEVICE_UUID}
+fi
+prepare_boot="$(prepare_grub_to_access_device ${GRUB_DEVICE_BOOT} | grub_add_tab)"
+`)
+	for _, entry := range entries {
+		initrd := getInitrdName(bootFiles, entry.KernelVersion)
+		if initrd == "" {
+			log.Errorf("Initramfs of kernel %s not found for boot entry %s", entry.KernelVersion, entry.Name)
+			return "", fmt.Errorf("initramfs of kernel %s not found for boot entry %s", entry.KernelVersion, entry.Name)
+		}
+		cmdline := strings.TrimSpace(rootSubvolumeArg + " " + shellDoubleQuoteEscape(entry.Cmdline))
+		fmt.Fprintf(&script, `
+echo "menuentry '%s (%s)' --id '%s' {"
+echo "${prepare_boot}"
+echo "	linux %s/vmlinuz-%s root=${LINUX_ROOT_DEVICE} ro ${GRUB_CMDLINE_LINUX} ${GRUB_CMDLINE_LINUX_DEFAULT} %s"
+echo "	initrd %s/%s"
+echo "}"
+`, entry.Name, entry.KernelVersion, entry.Name, bootPrefix, entry.KernelVersion, cmdline, bootPrefix, initrd)
+	}
+	return script.String(), nil
+}
+
+// getInitrdName returns the initramfs file of a kernel version, named the
+// Debian or the dracut way
+func getInitrdName(bootFiles []string, kernelVersion string) string {
+	for _, name := range []string{"initrd.img-" + kernelVersion, "initramfs-" + kernelVersion + ".img"} {
+		for _, bootFile := range bootFiles {
+			if bootFile == name {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// shellDoubleQuoteEscape escapes a string for use in double quotes in sh
+func shellDoubleQuoteEscape(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return replacer.Replace(s)
+}
+
+// getBootTimeout returns the boot menu timeout of the template in seconds
+func getBootTimeout(template *config.ImageTemplate) string {
+	return strconv.Itoa(template.GetBootloaderConfig().GetTimeout())
+}
@@ -0,0 +1,102 @@
+package imageboot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func bootEntriesTemplate(entries ...config.KernelEntry) *config.ImageTemplate {
+	return &config.ImageTemplate{
+		SystemConfig: config.SystemConfig{
+			Kernel: config.KernelConfig{Entries: entries},
+		},
+	}
+}
+
+func TestResolveBootEntries(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "ls ", Output: "config-6.12.8-1.emt3\nvmlinuz-6.12.8-1.emt3\nvmlinuz-6.12.8-rt-1.emt3\n", Error: nil},
+	})
+
+	// Templates without entries keep the single kernel flow
+	if entries, err := ResolveBootEntries("/install", bootEntriesTemplate()); err != nil || entries != nil {
+		t.Errorf("expected no entries, got %v, %v", entries, err)
+	}
+
+	template := bootEntriesTemplate(
+		config.KernelEntry{Name: "generic"},
+		config.KernelEntry{Name: "rt", Version: "6.12.8-rt", Cmdline: " isolcpus=2-3 ", Default: true},
+		config.KernelEntry{Name: "debug", Version: "6.12.8-1.emt3", Cmdline: "debug"},
+	)
+	entries, err := ResolveBootEntries("/install", template)
+	if err != nil {
+		t.Fatalf("ResolveBootEntries failed: %v", err)
+	}
+	expected := []BootEntry{
+		{Name: "generic", KernelVersion: "6.12.8-1.emt3"},
+		{Name: "rt", KernelVersion: "6.12.8-rt-1.emt3", Cmdline: "isolcpus=2-3", Default: true},
+		{Name: "debug", KernelVersion: "6.12.8-1.emt3", Cmdline: "debug"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %+v", len(expected), entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("expected entry %+v, got %+v", expected[i], entries[i])
+		}
+	}
+	if grubDefault := getGrubDefault(template); grubDefault != "rt" {
+		t.Errorf("expected GRUB default rt, got %q", grubDefault)
+	}
+
+	for want, template := range map[string]*config.ImageTemplate{
+		"duplicate boot entry generic": bootEntriesTemplate(config.KernelEntry{Name: "generic"}, config.KernelEntry{Name: "generic"}),
+		"more than one boot entry":     bootEntriesTemplate(config.KernelEntry{Name: "a", Default: true}, config.KernelEntry{Name: "b", Default: true}),
+		"no installed kernel matches":  bootEntriesTemplate(config.KernelEntry{Name: "lts", Version: "6.6"}),
+	} {
+		if _, err := ResolveBootEntries("/install", template); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error containing %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestRenderGrubBootEntries(t *testing.T) {
+	entries := []BootEntry{
+		{Name: "generic", KernelVersion: "6.8.0-45-generic", Default: true},
+		{Name: "debug", KernelVersion: "6.8.0-45-generic", Cmdline: `debug dyndbg="file $x +p"`},
+	}
+	bootFiles := []string{"initrd.img-6.8.0-45-generic", "vmlinuz-6.8.0-45-generic"}
+
+	script, err := renderGrubBootEntries("/boot", "", entries, bootFiles)
+	if err != nil {
+		t.Fatalf("renderGrubBootEntries failed: %v", err)
+	}
+	for _, want := range []string{
+		`. "$pkgdatadir/grub-mkconfig_lib"`,
+		`echo "menuentry 'generic (6.8.0-45-generic)' --id 'generic' {"`,
+		`echo "	linux /boot/vmlinuz-6.8.0-45-generic root=${LINUX_ROOT_DEVICE} ro ${GRUB_CMDLINE_LINUX} ${GRUB_CMDLINE_LINUX_DEFAULT} "`,
+		`${GRUB_CMDLINE_LINUX_DEFAULT} debug dyndbg=\"file \$x +p\""`,
+		`echo "	initrd /boot/initrd.img-6.8.0-45-generic"`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected the script to contain %q, got:\n%s", want, script)
+		}
+	}
+
+	script, err = renderGrubBootEntries("/@/boot", "rootflags=subvol=@", entries[:1], []string{"initramfs-6.8.0-45-generic.img"})
+	if err != nil {
+		t.Fatalf("renderGrubBootEntries failed: %v", err)
+	}
+	if !strings.Contains(script, "rootflags=subvol=@\"") || !strings.Contains(script, "initrd /@/boot/initramfs-6.8.0-45-generic.img") {
+		t.Errorf("expected the subvolume and the dracut initramfs, got:\n%s", script)
+	}
+
+	if _, err := renderGrubBootEntries("/boot", "", entries, []string{"vmlinuz-6.8.0-45-generic"}); err == nil {
+		t.Error("expected a kernel without initramfs to fail")
+	}
+}
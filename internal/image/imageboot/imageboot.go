@@ -7,6 +7,7 @@ import (
 
 	"github.com/open-edge-platform/os-image-composer/internal/config"
 	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
@@ -29,10 +30,40 @@ func getDiskPartDevByMountPoint(mountPoint string, diskPathIdMap map[string]stri
 	partions := diskInfo.Partitions
 	for diskId, diskPath := range diskPathIdMap {
 		for _, partition := range partions {
-			if partition.ID == diskId && partition.MountPoint == mountPoint {
-				return diskPath
+			if partition.ID != diskId {
+				continue
 			}
+			for _, partitionMount := range imagedisc.PartitionMounts(partition) {
+				if partitionMount.MountPoint == mountPoint {
+					return diskPath
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// getBootPrefix returns the path of /boot from the top of the filesystem it is on,
+// which includes the btrfs subvolume when /boot is on one
+func getBootPrefix(template *config.ImageTemplate) string {
+	diskInfo := template.GetDiskConfig()
+	if _, bootMount, ok := imagedisc.FindPartitionMount(diskInfo, "/boot"); ok {
+		if bootMount.Subvolume != "" {
+			return "/" + bootMount.Subvolume
 		}
+		return ""
+	}
+	if _, rootMount, ok := imagedisc.FindPartitionMount(diskInfo, "/"); ok && rootMount.Subvolume != "" {
+		return "/" + rootMount.Subvolume + "/boot"
+	}
+	return "/boot"
+}
+
+// getRootSubvolumeArg returns the kernel argument that mounts the root btrfs
+// subvolume, or an empty string when root is not on a subvolume
+func getRootSubvolumeArg(template *config.ImageTemplate) string {
+	if _, rootMount, ok := imagedisc.FindPartitionMount(template.GetDiskConfig(), "/"); ok && rootMount.Subvolume != "" {
+		return "rootflags=subvol=" + rootMount.Subvolume
 	}
 	return ""
 }
@@ -118,6 +149,10 @@ func installGrubWithEfiMode(installRoot, bootUUID, bootPrefix, pkgType, grubVers
 	if pkgType == "deb" {
 		// Generate bootx64.efi for debian based systems at /EFI/BOOT/bootx64.efi
 		installCmd := fmt.Sprintf("grub-install --target=x86_64-efi --efi-directory=%s --removable", efiDir)
+		if template.IsSecureBootEnabled() {
+			// GRUB is locked down under Secure Boot and cannot load modules from disk
+			installCmd += fmt.Sprintf(" --modules=\"%s\"", strings.Join(imagesign.GrubLockdownModules, " "))
+		}
 		if _, err = shell.ExecCmd(installCmd, true, installRoot, nil); err != nil {
 			log.Errorf("Failed to install bootx64.efi for GRUB EFI bootloader: %v", err)
 			return fmt.Errorf("failed to install bootx64.efi for GRUB EFI bootloader: %w", err)
@@ -176,6 +211,14 @@ func updateBootConfigTemplate(installRoot, rootDevID, bootUUID, bootPrefix, hash
 			log.Errorf("Failed to replace Hostname in boot configuration: %v", err)
 			return fmt.Errorf("failed to replace Hostname in boot configuration: %w", err)
 		}
+		if err := file.ReplacePlaceholdersInFile("{{.DefaultEntry}}", getGrubDefault(template), configFinalPath); err != nil {
+			log.Errorf("Failed to replace DefaultEntry in boot configuration: %v", err)
+			return fmt.Errorf("failed to replace DefaultEntry in boot configuration: %w", err)
+		}
+		if err := file.ReplacePlaceholdersInFile("{{.Timeout}}", getBootTimeout(template), configFinalPath); err != nil {
+			log.Errorf("Failed to replace Timeout in boot configuration: %v", err)
+			return fmt.Errorf("failed to replace Timeout in boot configuration: %w", err)
+		}
 	case "systemd-boot":
 		configAssetPath = filepath.Join(configDir, "image", "efi", "bootParams.conf")
 		configFinalPath = filepath.Join(installRoot, "boot", "cmdline.conf")
@@ -307,6 +350,17 @@ func updateBootConfigTemplate(installRoot, rootDevID, bootUUID, bootPrefix, hash
 		trimRootArgfromCmdLine = strings.Join(filteredFields, " ")
 	}
 
+	// grub-mkconfig adds rootflags for a btrfs root subvolume itself
+	rootSubvolumeArg := getRootSubvolumeArg(template)
+	if bootloaderConfig.Provider == "systemd-boot" && rootSubvolumeArg != "" && !strings.Contains(trimRootArgfromCmdLine, "rootflags=") {
+		trimRootArgfromCmdLine = strings.TrimSpace(trimRootArgfromCmdLine + " " + rootSubvolumeArg)
+	}
+
+	// Kernel lockdown only loads the modules signed for Secure Boot
+	if kernelConfig.Lockdown != "" && !strings.Contains(trimRootArgfromCmdLine, "lockdown=") {
+		trimRootArgfromCmdLine = strings.TrimSpace(trimRootArgfromCmdLine + " lockdown=" + kernelConfig.Lockdown)
+	}
+
 	if err := file.ReplacePlaceholdersInFile("{{.ExtraCommandLine}}", trimRootArgfromCmdLine, configFinalPath); err != nil {
 		log.Errorf("Failed to replace ExtraCommandLine in boot configuration: %v", err)
 		return fmt.Errorf("failed to replace ExtraCommandLine in boot configuration: %w", err)
@@ -328,7 +382,7 @@ func updateBootConfigTemplate(installRoot, rootDevID, bootUUID, bootPrefix, hash
 
 func (imageBoot *ImageBoot) InstallImageBoot(installRoot string, diskPathIdMap map[string]string, template *config.ImageTemplate, pkgType string) error {
 	var bootUUID string
-	var bootPrefix string = ""
+	var bootPrefix string = getBootPrefix(template)
 	var rootDev string
 	var hashDev string
 	var err error
@@ -338,7 +392,6 @@ func (imageBoot *ImageBoot) InstallImageBoot(installRoot string, diskPathIdMap m
 	bootPartDev := getDiskPartDevByMountPoint("/boot", diskPathIdMap, template)
 	if bootPartDev == "" {
 		// /boot is not a separate partition, use root partition instead
-		bootPrefix = "/boot"
 		rootDev = getDiskPartDevByMountPoint("/", diskPathIdMap, template)
 		if rootDev == "" {
 			return fmt.Errorf("failed to find root partition for mount point '/'")
@@ -389,6 +442,14 @@ func (imageBoot *ImageBoot) InstallImageBoot(installRoot string, diskPathIdMap m
 			return fmt.Errorf("failed to update boot configuration: %w", err)
 		}
 
+		entries, err := ResolveBootEntries(installRoot, template)
+		if err != nil {
+			return fmt.Errorf("failed to resolve boot entries: %w", err)
+		}
+		if err := writeGrubBootEntries(installRoot, bootPrefix, entries, template); err != nil {
+			return fmt.Errorf("failed to write GRUB boot entries: %w", err)
+		}
+
 		if err := copyGrubEnvFile(installRoot, grubVersion); err != nil {
 			return fmt.Errorf("failed to copy grubenv file: %w", err)
 		}
@@ -723,6 +723,27 @@ func TestGetDiskPartDevByMountPoint(t *testing.T) {
 			},
 			expected: "",
 		},
+		{
+			name:       "btrfs_subvolume_mount_point",
+			mountPoint: "/home",
+			diskPathIdMap: map[string]string{
+				"disk1": "/dev/sda",
+			},
+			template: &config.ImageTemplate{
+				Image: config.ImageInfo{
+					Name: "test-image",
+				},
+				Disk: config.DiskConfig{
+					Partitions: []config.PartitionInfo{
+						{ID: "disk1", FsType: "btrfs", MountPoint: "/", Subvolumes: []config.BtrfsSubvolume{
+							{Name: "@", MountPoint: "/"},
+							{Name: "@home", MountPoint: "/home"},
+						}},
+					},
+				},
+			},
+			expected: "/dev/sda",
+		},
 	}
 
 	for _, tt := range tests {
@@ -735,6 +756,37 @@ func TestGetDiskPartDevByMountPoint(t *testing.T) {
 	}
 }
 
+func TestGetBootPrefix(t *testing.T) {
+	btrfsRoot := config.PartitionInfo{ID: "rootfs", FsType: "btrfs", MountPoint: "/", Subvolumes: []config.BtrfsSubvolume{
+		{Name: "@", MountPoint: "/"},
+	}}
+	tests := []struct {
+		name       string
+		partitions []config.PartitionInfo
+		prefix     string
+		rootArg    string
+	}{
+		{"boot_in_root", []config.PartitionInfo{{ID: "rootfs", FsType: "ext4", MountPoint: "/"}}, "/boot", ""},
+		{"separate_boot", []config.PartitionInfo{{ID: "boot", FsType: "ext4", MountPoint: "/boot"}, {ID: "rootfs", FsType: "ext4", MountPoint: "/"}}, "", ""},
+		{"boot_in_root_subvolume", []config.PartitionInfo{btrfsRoot}, "/@/boot", "rootflags=subvol=@"},
+		{"boot_subvolume", []config.PartitionInfo{{ID: "rootfs", FsType: "btrfs", MountPoint: "/", Subvolumes: []config.BtrfsSubvolume{
+			{Name: "@", MountPoint: "/"},
+			{Name: "@boot", MountPoint: "/boot"},
+		}}}, "/@boot", "rootflags=subvol=@"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &config.ImageTemplate{Disk: config.DiskConfig{Partitions: tt.partitions}}
+			if prefix := getBootPrefix(template); prefix != tt.prefix {
+				t.Errorf("getBootPrefix() = %q, expected %q", prefix, tt.prefix)
+			}
+			if rootArg := getRootSubvolumeArg(template); rootArg != tt.rootArg {
+				t.Errorf("getRootSubvolumeArg() = %q, expected %q", rootArg, tt.rootArg)
+			}
+		})
+	}
+}
+
 func TestInstallGrubWithLegacyMode(t *testing.T) {
 	err := installGrubWithLegacyMode("/tmp", "uuid", "/boot", nil)
 	if err == nil {
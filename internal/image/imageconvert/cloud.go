@@ -0,0 +1,222 @@
+package imageconvert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+)
+
+// CloudProfile describes what a cloud expects of an imported disk image
+type CloudProfile struct {
+	Name          string
+	ArtifactType  string   // disk artifact type the cloud imports
+	Alignment     uint64   // virtual disk size multiple, in bytes
+	Datasource    string   // cloud-init datasource of the cloud
+	GuestAgents   []string // packages of the cloud guest agent, any one of them
+	SerialConsole string   // console the cloud serial console and boot diagnostics read
+}
+
+const (
+	mib = 1024 * 1024
+	gib = 1024 * mib
+
+	cloudInitPkg = "cloud-init"
+)
+
+var cloudProfiles = map[string]CloudProfile{
+	// Azure needs a fixed VHD with a virtual size in whole MiB
+	"azure": {
+		Name:          "azure",
+		ArtifactType:  "vhd",
+		Alignment:     mib,
+		Datasource:    "Azure",
+		GuestAgents:   []string{"walinuxagent", "WALinuxAgent"},
+		SerialConsole: "ttyS0",
+	},
+	// EBS snapshots and volumes are sized in whole GiB
+	"aws": {
+		Name:          "aws",
+		ArtifactType:  "raw",
+		Alignment:     gib,
+		Datasource:    "Ec2",
+		GuestAgents:   []string{"amazon-ssm-agent"},
+		SerialConsole: "ttyS0",
+	},
+	// GCE imports a disk.raw in a gzip tarball, sized in whole GiB
+	"gce": {
+		Name:          "gce",
+		ArtifactType:  "raw",
+		Alignment:     gib,
+		Datasource:    "GCE",
+		GuestAgents:   []string{"google-guest-agent", "google-compute-engine"},
+		SerialConsole: "ttyS0",
+	},
+}
+
+// GetCloudProfile returns the cloud profile of an artifact profile name
+func GetCloudProfile(name string) (CloudProfile, bool) {
+	profile, ok := cloudProfiles[name]
+	return profile, ok
+}
+
+// CloudDatasources returns the cloud-init datasources of the clouds the
+// template's artifacts are prepared for
+func CloudDatasources(template *config.ImageTemplate) []string {
+	var datasources []string
+	for _, artifact := range template.GetDiskConfig().Artifacts {
+		profile, ok := GetCloudProfile(artifact.Profile)
+		if ok && !slice.Contains(datasources, profile.Datasource) {
+			datasources = append(datasources, profile.Datasource)
+		}
+	}
+	return datasources
+}
+
+// CheckCloudProfiles checks the template against the clouds its artifacts are
+// prepared for before the image is built: the image must be provisioned by
+// cloud-init or the cloud guest agent, and the kernel must log to the serial
+// console the cloud reads
+func CheckCloudProfiles(template *config.ImageTemplate) error {
+	packages := template.GetPackages()
+	cmdline := template.GetKernel().Cmdline
+
+	for _, artifact := range template.GetDiskConfig().Artifacts {
+		if artifact.Profile == "" {
+			continue
+		}
+		profile, ok := GetCloudProfile(artifact.Profile)
+		if !ok {
+			return fmt.Errorf("unknown cloud profile %q", artifact.Profile)
+		}
+		if artifact.Type != profile.ArtifactType {
+			return fmt.Errorf("cloud profile %s needs a %s artifact, not %s", profile.Name, profile.ArtifactType, artifact.Type)
+		}
+		if profile.Name == "gce" && artifact.Compression != "" {
+			return fmt.Errorf("cloud profile gce writes a gzip tarball and cannot set compression %s", artifact.Compression)
+		}
+
+		hasCloudInit := slice.Contains(packages, cloudInitPkg)
+		hasGuestAgent := false
+		for _, agent := range profile.GuestAgents {
+			if slice.Contains(packages, agent) {
+				hasGuestAgent = true
+			}
+		}
+		if !hasCloudInit && !hasGuestAgent {
+			return fmt.Errorf("cloud profile %s needs %s or %s in the packages to provision the image",
+				profile.Name, cloudInitPkg, strings.Join(profile.GuestAgents, " or "))
+		}
+		if !hasGuestAgent {
+			log.Warnf("Cloud profile %s: the image has no guest agent (%s)", profile.Name, strings.Join(profile.GuestAgents, " or "))
+		}
+
+		if !hasConsoleArg(cmdline, profile.SerialConsole) {
+			return fmt.Errorf("cloud profile %s needs console=%s in the kernel cmdline for the serial console", profile.Name, profile.SerialConsole)
+		}
+	}
+	return nil
+}
+
+// hasConsoleArg returns whether the cmdline sends the console to a device
+func hasConsoleArg(cmdline, device string) bool {
+	for _, arg := range strings.Fields(cmdline) {
+		if arg == "console="+device || strings.HasPrefix(arg, "console="+device+",") {
+			return true
+		}
+	}
+	return false
+}
+
+// copyAlignedRawImage copies the raw image to outputPath and grows the copy to
+// a multiple of alignment. The backup GPT header is moved to the new end of
+// the disk.
+func copyAlignedRawImage(filePath, outputPath string, alignment uint64, partitionTableType string) error {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat image file: %w", err)
+	}
+	cmdStr := fmt.Sprintf("cp --sparse=always %s %s", filePath, outputPath)
+	if _, err := shell.ExecCmd(cmdStr, false, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to copy image file %s to %s: %v", filePath, outputPath, err)
+		return fmt.Errorf("failed to copy image file to %s: %w", outputPath, err)
+	}
+	size := uint64(fileInfo.Size())
+	aligned := (size + alignment - 1) / alignment * alignment
+	if aligned == size {
+		return nil
+	}
+	log.Infof("Aligning image file %s to %s for cloud import", outputPath, imagedisc.TranslateBytesToSizeStr(alignment))
+	return imagedisc.TruncateRawFile(outputPath, aligned, partitionTableType)
+}
+
+// convertCloudImageFile writes the cloud artifact of a profile from the raw
+// image and returns its path. Clouds only import disks sized in whole MiB or
+// GiB, so each profile converts its own aligned copy of the raw image and
+// the raw image itself is left as built.
+func convertCloudImageFile(filePath string, profile CloudProfile, partitionTableType string) (string, error) {
+	basePath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + "-" + profile.Name
+	alignedPath := basePath + ".raw"
+	var outputFilePath string
+	var cmdStr string
+
+	switch profile.Name {
+	case "azure":
+		outputFilePath = basePath + ".vhd"
+		cmdStr = fmt.Sprintf("qemu-img convert -f raw -O vpc -o subformat=fixed,force_size=on %s %s", alignedPath, outputFilePath)
+	case "aws":
+		outputFilePath = alignedPath
+	case "gce":
+		outputFilePath = basePath + ".tar.gz"
+	default:
+		return "", fmt.Errorf("unsupported cloud profile: %s", profile.Name)
+	}
+
+	log.Infof("Converting image file %s for %s", filePath, profile.Name)
+	if err := copyAlignedRawImage(filePath, alignedPath, profile.Alignment, partitionTableType); err != nil {
+		return outputFilePath, fmt.Errorf("failed to align image file for %s: %w", profile.Name, err)
+	}
+	if alignedPath == outputFilePath {
+		return outputFilePath, nil
+	}
+	defer os.Remove(alignedPath)
+
+	if profile.Name == "gce" {
+		return writeGceTarball(alignedPath, outputFilePath)
+	}
+	if _, err := shell.ExecCmd(cmdStr, false, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to convert image file for %s: %v", profile.Name, err)
+		return outputFilePath, fmt.Errorf("failed to convert image file for %s: %w", profile.Name, err)
+	}
+	return outputFilePath, nil
+}
+
+// writeGceTarball packs the raw image as disk.raw in a sparse gzip tarball,
+// the format GCE imports images from
+func writeGceTarball(filePath, outputFilePath string) (string, error) {
+	log.Infof("Creating GCE image tarball %s", outputFilePath)
+
+	stagingDir := outputFilePath + ".staging"
+	if err := os.MkdirAll(stagingDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create GCE staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	diskRaw := filepath.Join(stagingDir, "disk.raw")
+	if _, err := shell.ExecCmd(fmt.Sprintf("ln %s %s", filePath, diskRaw), true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to link %s to %s: %v", filePath, diskRaw, err)
+		return "", fmt.Errorf("failed to stage GCE disk image: %w", err)
+	}
+
+	cmdStr := fmt.Sprintf("tar --format=oldgnu --sparse --gzip --create --file=%s --directory=%s disk.raw", outputFilePath, stagingDir)
+	if _, err := shell.ExecCmd(cmdStr, false, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create GCE image tarball: %v", err)
+		return "", fmt.Errorf("failed to create GCE image tarball: %w", err)
+	}
+	return outputFilePath, nil
+}
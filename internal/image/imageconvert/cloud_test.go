@@ -0,0 +1,193 @@
+package imageconvert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func createCloudTemplate(artifacts ...config.ArtifactInfo) *config.ImageTemplate {
+	return &config.ImageTemplate{
+		Image: config.ImageInfo{Name: "elxr-cloud-amd64", Version: "12.12.0"},
+		SystemConfig: config.SystemConfig{
+			Name:     "elxr-cloud-amd64",
+			Packages: []string{"cloud-init", "qemu-guest-agent"},
+			Kernel:   config.KernelConfig{Cmdline: "console=tty0 console=ttyS0,115200"},
+		},
+		Disk: config.DiskConfig{PartitionTableType: "gpt", Artifacts: artifacts},
+	}
+}
+
+func TestCheckCloudProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    func() *config.ImageTemplate
+		expectError string
+	}{
+		{
+			name: "NoProfiles",
+			template: func() *config.ImageTemplate {
+				template := createCloudTemplate(config.ArtifactInfo{Type: "qcow2"})
+				template.SystemConfig.Packages = nil
+				return template
+			},
+		},
+		{
+			name: "CloudInitProvisioning",
+			template: func() *config.ImageTemplate {
+				return createCloudTemplate(config.ArtifactInfo{Type: "vhd", Profile: "azure"}, config.ArtifactInfo{Type: "raw", Profile: "gce"})
+			},
+		},
+		{
+			name: "GuestAgentProvisioning",
+			template: func() *config.ImageTemplate {
+				template := createCloudTemplate(config.ArtifactInfo{Type: "vhd", Profile: "azure"})
+				template.SystemConfig.Packages = []string{"walinuxagent"}
+				return template
+			},
+		},
+		{
+			name: "WrongArtifactType",
+			template: func() *config.ImageTemplate {
+				return createCloudTemplate(config.ArtifactInfo{Type: "qcow2", Profile: "azure"})
+			},
+			expectError: "needs a vhd artifact",
+		},
+		{
+			name: "UnknownProfile",
+			template: func() *config.ImageTemplate {
+				return createCloudTemplate(config.ArtifactInfo{Type: "raw", Profile: "oci"})
+			},
+			expectError: "unknown cloud profile",
+		},
+		{
+			name: "CompressedGceTarball",
+			template: func() *config.ImageTemplate {
+				return createCloudTemplate(config.ArtifactInfo{Type: "raw", Profile: "gce", Compression: "gzip"})
+			},
+			expectError: "cannot set compression",
+		},
+		{
+			name: "NoProvisioningAgent",
+			template: func() *config.ImageTemplate {
+				template := createCloudTemplate(config.ArtifactInfo{Type: "raw", Profile: "aws"})
+				template.SystemConfig.Packages = []string{"openssh-server"}
+				return template
+			},
+			expectError: "needs cloud-init or amazon-ssm-agent",
+		},
+		{
+			name: "NoSerialConsole",
+			template: func() *config.ImageTemplate {
+				template := createCloudTemplate(config.ArtifactInfo{Type: "raw", Profile: "gce"})
+				template.SystemConfig.Kernel.Cmdline = "console=tty0 console=ttyS01"
+				return template
+			},
+			expectError: "console=ttyS0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCloudProfiles(tt.template())
+			if tt.expectError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestCloudDatasources(t *testing.T) {
+	template := createCloudTemplate(
+		config.ArtifactInfo{Type: "qcow2"},
+		config.ArtifactInfo{Type: "vhd", Profile: "azure"},
+		config.ArtifactInfo{Type: "raw", Profile: "gce"},
+		config.ArtifactInfo{Type: "vhd", Profile: "azure", Compression: "zstd"},
+	)
+	datasources := CloudDatasources(template)
+	if strings.Join(datasources, ",") != "Azure,GCE" {
+		t.Errorf("expected Azure and GCE datasources, got %v", datasources)
+	}
+}
+
+func TestCopyAlignedRawImage(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	tempDir := t.TempDir()
+
+	t.Run("Unaligned", func(t *testing.T) {
+		filePath := filepath.Join(tempDir, "unaligned.raw")
+		if err := os.WriteFile(filePath, make([]byte, mib+512), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: `cp --sparse=always \S+/unaligned\.raw \S+/unaligned-aws\.raw$`},
+			{Pattern: fmt.Sprintf(`truncate -s %d \S+/unaligned-aws\.raw$`, 2*mib)},
+			{Pattern: `sfdisk --relocate gpt-bak-std \S+/unaligned-aws\.raw$`},
+			{Pattern: "cp|truncate|sfdisk", Error: fmt.Errorf("unexpected command")},
+		})
+		if err := copyAlignedRawImage(filePath, filepath.Join(tempDir, "unaligned-aws.raw"), mib, "gpt"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Aligned", func(t *testing.T) {
+		filePath := filepath.Join(tempDir, "aligned.raw")
+		if err := os.WriteFile(filePath, make([]byte, mib), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: `cp --sparse=always \S+/aligned\.raw \S+/aligned-aws\.raw$`},
+			{Pattern: "cp|truncate|sfdisk", Error: fmt.Errorf("unexpected command")},
+		})
+		if err := copyAlignedRawImage(filePath, filepath.Join(tempDir, "aligned-aws.raw"), mib, "gpt"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestConvertImageFile_CloudProfiles(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		// Only the per-profile copies are aligned, never the raw image itself
+		{Pattern: `cp --sparse=always \S+/elxr-cloud\.raw \S+/elxr-cloud-(azure|aws|gce)\.raw$`},
+		{Pattern: `truncate -s 1048576 \S+/elxr-cloud-azure\.raw$`},
+		{Pattern: `truncate -s 1073741824 \S+/elxr-cloud-(aws|gce)\.raw$`},
+		{Pattern: `sfdisk --relocate gpt-bak-std \S+/elxr-cloud-(azure|aws|gce)\.raw$`},
+		{Pattern: `qemu-img convert -f raw -O vpc -o subformat=fixed,force_size=on \S+/elxr-cloud-azure\.raw \S+/elxr-cloud-azure\.vhd$`},
+		{Pattern: `ln \S+/elxr-cloud-gce\.raw \S+/elxr-cloud-gce\.tar\.gz\.staging/disk\.raw$`},
+		{Pattern: `tar --format=oldgnu --sparse --gzip --create --file=\S+/elxr-cloud-gce\.tar\.gz --directory=\S+ disk\.raw$`},
+		{Pattern: "truncate|sfdisk|qemu-img|cp|ln|tar", Error: fmt.Errorf("unexpected command")},
+	})
+
+	filePath := filepath.Join(t.TempDir(), "elxr-cloud.raw")
+	if err := os.WriteFile(filePath, []byte("raw image"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	template := createCloudTemplate(
+		config.ArtifactInfo{Type: "vhd", Profile: "azure"},
+		config.ArtifactInfo{Type: "raw", Profile: "aws"},
+		config.ArtifactInfo{Type: "raw", Profile: "gce"},
+	)
+
+	if err := NewImageConvert().ConvertImageFile(filePath, template); err != nil {
+		t.Fatalf("ConvertImageFile failed: %v", err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Error("expected the raw image to be removed without a plain raw artifact")
+	}
+}
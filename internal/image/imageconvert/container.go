@@ -0,0 +1,490 @@
+package imageconvert
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	ociLayoutVersion      = "1.0.0"
+	ociIndexMediaType     = "application/vnd.oci.image.index.v1+json"
+	ociManifestMediaType  = "application/vnd.oci.image.manifest.v1+json"
+	ociConfigMediaType    = "application/vnd.oci.image.config.v1+json"
+	ociLayerGzipMediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+	// defaultContainerPath is the PATH of the container when the template sets none
+	defaultContainerPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// containerRepoInvalidChars are the characters an image reference name cannot have
+var containerRepoInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// containerTagInvalidChars are the characters an image reference tag cannot have
+var containerTagInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// ociDescriptor references a blob of an OCI image
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociManifest is the OCI image manifest of a single layer image
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// ociIndex is the index.json of an OCI image layout
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// ociImageConfig is the image config shared by OCI and Docker images
+type ociImageConfig struct {
+	Created      string           `json:"created"`
+	Architecture string           `json:"architecture"`
+	OS           string           `json:"os"`
+	Config       ociRuntimeConfig `json:"config"`
+	RootFS       ociRootFS        `json:"rootfs"`
+	History      []ociHistory     `json:"history"`
+}
+
+type ociRuntimeConfig struct {
+	User       string            `json:"User,omitempty"`
+	Env        []string          `json:"Env,omitempty"`
+	Entrypoint []string          `json:"Entrypoint,omitempty"`
+	Cmd        []string          `json:"Cmd,omitempty"`
+	WorkingDir string            `json:"WorkingDir,omitempty"`
+	Labels     map[string]string `json:"Labels,omitempty"`
+}
+
+type ociRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type ociHistory struct {
+	Created   string `json:"created"`
+	CreatedBy string `json:"created_by"`
+}
+
+// dockerManifestEntry is an image of the manifest.json of a docker-archive
+type dockerManifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// ConvertRootfs writes the container artifacts of the template from the
+// populated install root: a rootfs tarball, an OCI image archive and a
+// docker-archive, next to imageBasePath
+func (imageConvert *ImageConvert) ConvertRootfs(rootfsPath, imageBasePath string, template *config.ImageTemplate) error {
+	if template == nil {
+		return fmt.Errorf("image template is nil")
+	}
+
+	rootfsTarPath := imageBasePath + ".tar"
+	if err := writeRootfsTar(rootfsPath, rootfsTarPath); err != nil {
+		return err
+	}
+
+	var keepRootfsTar bool
+	for _, artifact := range template.GetDiskConfig().Artifacts {
+		var outputFilePath string
+		switch artifact.Type {
+		case "tar":
+			keepRootfsTar = true
+			continue
+		case "oci":
+			outputFilePath = imageBasePath + ".oci.tar"
+			if err := writeOciArchive(rootfsTarPath, outputFilePath, template); err != nil {
+				return err
+			}
+		case "docker":
+			outputFilePath = imageBasePath + ".docker.tar"
+			if err := writeDockerArchive(rootfsTarPath, outputFilePath, template); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+		if artifact.Compression != "" {
+			if err := compressImageFile(outputFilePath, artifact.Compression); err != nil {
+				return fmt.Errorf("failed to compress container image: %w", err)
+			}
+		}
+	}
+
+	if !keepRootfsTar {
+		if err := os.Remove(rootfsTarPath); err != nil {
+			log.Warnf("Failed to remove rootfs tarball: %v", err)
+		}
+		return nil
+	}
+	for _, artifact := range template.GetDiskConfig().Artifacts {
+		if artifact.Type == "tar" && artifact.Compression != "" {
+			if err := compressImageFile(rootfsTarPath, artifact.Compression); err != nil {
+				return fmt.Errorf("failed to compress rootfs tarball: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeRootfsTar archives the install root with its ownership, permissions,
+// extended attributes and ACLs. It runs as root to read every file.
+func writeRootfsTar(rootfsPath, tarPath string) error {
+	log.Infof("Creating rootfs tarball %s", tarPath)
+	cmdStr := fmt.Sprintf("tar --create --file=%s --numeric-owner --xattrs --xattrs-include='*' --acls --directory=%s .",
+		tarPath, rootfsPath)
+	if _, err := shell.ExecCmd(cmdStr, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create rootfs tarball: %v", err)
+		return fmt.Errorf("failed to create rootfs tarball: %w", err)
+	}
+	return nil
+}
+
+// writeOciArchive writes an OCI image layout archive with the rootfs tarball
+// as its gzip compressed layer
+func writeOciArchive(rootfsTarPath, outputPath string, template *config.ImageTemplate) error {
+	log.Infof("Creating OCI image archive %s", outputPath)
+
+	layerPath := outputPath + ".layer"
+	defer os.Remove(layerPath)
+	diffID, layerDigest, layerSize, err := writeGzipLayer(rootfsTarPath, layerPath)
+	if err != nil {
+		return err
+	}
+
+	configJSON, err := json.Marshal(containerImageConfig(template, diffID))
+	if err != nil {
+		return fmt.Errorf("failed to encode container image config: %w", err)
+	}
+	manifestJSON, err := json.Marshal(ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        ociDescriptor{MediaType: ociConfigMediaType, Digest: sha256Digest(configJSON), Size: int64(len(configJSON))},
+		Layers:        []ociDescriptor{{MediaType: ociLayerGzipMediaType, Digest: layerDigest, Size: layerSize}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode OCI image manifest: %w", err)
+	}
+	repo, tag := containerImageRef(template)
+	indexJSON, err := json.Marshal(ociIndex{
+		SchemaVersion: 2,
+		MediaType:     ociIndexMediaType,
+		Manifests: []ociDescriptor{{
+			MediaType: ociManifestMediaType,
+			Digest:    sha256Digest(manifestJSON),
+			Size:      int64(len(manifestJSON)),
+			Annotations: map[string]string{
+				"org.opencontainers.image.ref.name": tag,
+				"io.containerd.image.name":          repo + ":" + tag,
+			},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode OCI image index: %w", err)
+	}
+
+	return writeArchive(outputPath, func(archive *imageArchive) error {
+		files := []struct {
+			name    string
+			content []byte
+		}{
+			{"oci-layout", []byte(fmt.Sprintf(`{"imageLayoutVersion":"%s"}`, ociLayoutVersion))},
+			{"index.json", indexJSON},
+			{blobPath(sha256Digest(manifestJSON)), manifestJSON},
+			{blobPath(sha256Digest(configJSON)), configJSON},
+		}
+		for _, f := range files {
+			if err := addArchiveBytes(archive, f.name, f.content); err != nil {
+				return err
+			}
+		}
+		return addArchiveFile(archive, blobPath(layerDigest), layerPath)
+	})
+}
+
+// writeDockerArchive writes a docker-archive, as docker save does, with the
+// rootfs tarball as its layer
+func writeDockerArchive(rootfsTarPath, outputPath string, template *config.ImageTemplate) error {
+	log.Infof("Creating Docker image archive %s", outputPath)
+
+	diffID, err := fileDigest(rootfsTarPath)
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.Marshal(containerImageConfig(template, diffID))
+	if err != nil {
+		return fmt.Errorf("failed to encode container image config: %w", err)
+	}
+	configName := strings.TrimPrefix(sha256Digest(configJSON), "sha256:") + ".json"
+	layerName := strings.TrimPrefix(diffID, "sha256:") + "/layer.tar"
+	repo, tag := containerImageRef(template)
+	manifestJSON, err := json.Marshal([]dockerManifestEntry{{
+		Config:   configName,
+		RepoTags: []string{repo + ":" + tag},
+		Layers:   []string{layerName},
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to encode Docker image manifest: %w", err)
+	}
+
+	return writeArchive(outputPath, func(archive *imageArchive) error {
+		if err := addArchiveBytes(archive, configName, configJSON); err != nil {
+			return err
+		}
+		if err := addArchiveFile(archive, layerName, rootfsTarPath); err != nil {
+			return err
+		}
+		return addArchiveBytes(archive, "manifest.json", manifestJSON)
+	})
+}
+
+// containerImageConfig returns the image config of the template's container
+// image with a single layer
+func containerImageConfig(template *config.ImageTemplate, diffID string) ociImageConfig {
+	container := template.SystemConfig.Container
+	created := time.Now().UTC().Format(time.RFC3339)
+
+	env := container.Env
+	hasPath := false
+	for _, variable := range env {
+		if strings.HasPrefix(variable, "PATH=") {
+			hasPath = true
+		}
+	}
+	if !hasPath {
+		env = append([]string{defaultContainerPath}, env...)
+	}
+
+	cmd := container.Cmd
+	if len(container.Entrypoint) == 0 && len(cmd) == 0 {
+		cmd = []string{"/bin/sh"}
+	}
+
+	labels := map[string]string{"org.opencontainers.image.title": template.GetImageName()}
+	if template.Image.Version != "" {
+		labels["org.opencontainers.image.version"] = template.Image.Version
+	}
+	if template.SystemConfig.Description != "" {
+		labels["org.opencontainers.image.description"] = template.SystemConfig.Description
+	}
+	for key, value := range container.Labels {
+		labels[key] = value
+	}
+
+	return ociImageConfig{
+		Created:      created,
+		Architecture: containerArch(template.Target.Arch),
+		OS:           "linux",
+		Config: ociRuntimeConfig{
+			User:       container.User,
+			Env:        env,
+			Entrypoint: container.Entrypoint,
+			Cmd:        cmd,
+			WorkingDir: container.WorkingDir,
+			Labels:     labels,
+		},
+		RootFS:  ociRootFS{Type: "layers", DiffIDs: []string{diffID}},
+		History: []ociHistory{{Created: created, CreatedBy: "os-image-composer"}},
+	}
+}
+
+// containerArch returns the OCI architecture of a target architecture
+func containerArch(arch string) string {
+	switch arch {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	}
+	return arch
+}
+
+// containerImageRef returns the repository and tag the image is loaded as,
+// from the image name and version
+func containerImageRef(template *config.ImageTemplate) (repo, tag string) {
+	repo = containerRepoInvalidChars.ReplaceAllString(strings.ToLower(template.GetImageName()), "-")
+	repo = strings.Trim(repo, "._-")
+	if repo == "" {
+		repo = "image"
+	}
+	tag = strings.TrimLeft(containerTagInvalidChars.ReplaceAllString(template.Image.Version, "-"), ".-")
+	if tag == "" {
+		tag = "latest"
+	}
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return repo, tag
+}
+
+// writeGzipLayer compresses the rootfs tarball into an image layer. It returns
+// the digests of the uncompressed and the compressed layer, and its size.
+func writeGzipLayer(rootfsTarPath, layerPath string) (diffID, digest string, size int64, err error) {
+	src, err := os.Open(rootfsTarPath)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to open rootfs tarball: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(layerPath)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to create image layer: %w", err)
+	}
+	defer dst.Close()
+
+	diffHash := sha256.New()
+	layerHash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(dst, layerHash)}
+	gz := gzip.NewWriter(counter)
+	if _, err := io.Copy(io.MultiWriter(gz, diffHash), src); err != nil {
+		return "", "", 0, fmt.Errorf("failed to compress image layer: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", "", 0, fmt.Errorf("failed to compress image layer: %w", err)
+	}
+	return hashDigest(diffHash), hashDigest(layerHash), counter.n, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// fileDigest returns the sha256 digest of a file
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hashDigest(h), nil
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func hashDigest(h hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// blobPath returns the path of a blob in an OCI image layout
+func blobPath(digest string) string {
+	return filepath.Join("blobs", "sha256", strings.TrimPrefix(digest, "sha256:"))
+}
+
+// imageArchive is a tar archive that remembers the directories written to it
+type imageArchive struct {
+	*tar.Writer
+	dirs map[string]bool
+}
+
+// writeArchive creates a tar archive at outputPath with the entries added by
+// addEntries
+func writeArchive(outputPath string, addEntries func(archive *imageArchive) error) error {
+	out, err := os.Create(outputPath)
+	if err != nil {
+		log.Errorf("Failed to create image archive %s: %v", outputPath, err)
+		return fmt.Errorf("failed to create image archive %s: %w", outputPath, err)
+	}
+	archive := &imageArchive{Writer: tar.NewWriter(out), dirs: map[string]bool{}}
+	if err := addEntries(archive); err != nil {
+		out.Close()
+		os.Remove(outputPath)
+		return fmt.Errorf("failed to write image archive %s: %w", outputPath, err)
+	}
+	if err := archive.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write image archive %s: %w", outputPath, err)
+	}
+	return out.Close()
+}
+
+// addArchiveBytes adds a file with content to a tar archive, creating its
+// parent directories
+func addArchiveBytes(archive *imageArchive, name string, content []byte) error {
+	if err := addArchiveDirs(archive, name); err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := archive.Write(content)
+	return err
+}
+
+// addArchiveFile adds the file at path to a tar archive as name
+func addArchiveFile(archive *imageArchive, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := addArchiveDirs(archive, name); err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(archive, f)
+	return err
+}
+
+// addArchiveDirs adds the parent directories of name to a tar archive, once
+// per directory
+func addArchiveDirs(archive *imageArchive, name string) error {
+	var dirs []string
+	for dir := filepath.Dir(name); dir != "." && !archive.dirs[dir]; dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, d := range dirs {
+		archive.dirs[d] = true
+		header := &tar.Header{Name: d + "/", Mode: 0755, ModTime: time.Now(), Typeflag: tar.TypeDir}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package imageconvert
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func createContainerTemplate(artifacts ...config.ArtifactInfo) *config.ImageTemplate {
+	return &config.ImageTemplate{
+		Image:  config.ImageInfo{Name: "Edge_Base", Version: "1.2.0"},
+		Target: config.TargetInfo{OS: "ubuntu", Dist: "ubuntu24", Arch: "x86_64"},
+		SystemConfig: config.SystemConfig{
+			Name:        "edge-base",
+			Description: "Edge base container",
+			Container: config.ContainerConfig{
+				Entrypoint: []string{"/usr/bin/app"},
+				Env:        []string{"APP_MODE=edge"},
+				WorkingDir: "/srv",
+				Labels:     map[string]string{"org.opencontainers.image.vendor": "Example"},
+			},
+		},
+		Disk: config.DiskConfig{Artifacts: artifacts},
+	}
+}
+
+// writeTestRootfsTar stands in for the tar command and writes a rootfs
+// tarball with a single file
+func writeTestRootfsTar(t *testing.T, path string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	content := []byte("NAME=Edge\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "etc/os-release", Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatalf("Failed to write tar header: %v", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatalf("Failed to write tar content: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write rootfs tarball: %v", err)
+	}
+}
+
+func readArchive(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer f.Close()
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		if _, ok := entries[header.Name]; ok {
+			t.Errorf("duplicate archive entry %s", header.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("Failed to read archive entry %s: %v", header.Name, err)
+		}
+		entries[header.Name] = content
+	}
+	return entries
+}
+
+func TestContainerImageRef(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		wantRepo string
+		wantTag  string
+	}{
+		{"Edge_Base", "1.2.0", "edge_base", "1.2.0"},
+		{"My Image!", "", "my-image", "latest"},
+		{"--", "+build.1", "image", "build.1"},
+	}
+	for _, tt := range tests {
+		template := &config.ImageTemplate{Image: config.ImageInfo{Name: tt.name, Version: tt.version}}
+		repo, tag := containerImageRef(template)
+		if repo != tt.wantRepo || tag != tt.wantTag {
+			t.Errorf("containerImageRef(%q, %q) = %s:%s, want %s:%s", tt.name, tt.version, repo, tag, tt.wantRepo, tt.wantTag)
+		}
+	}
+}
+
+func TestContainerImageConfig(t *testing.T) {
+	template := createContainerTemplate()
+	template.Target.Arch = "aarch64"
+
+	imageConfig := containerImageConfig(template, "sha256:abc")
+	if imageConfig.Architecture != "arm64" || imageConfig.OS != "linux" {
+		t.Errorf("unexpected platform %s/%s", imageConfig.OS, imageConfig.Architecture)
+	}
+	if len(imageConfig.Config.Env) != 2 || imageConfig.Config.Env[0] != defaultContainerPath {
+		t.Errorf("expected default PATH before the template env, got %v", imageConfig.Config.Env)
+	}
+	if imageConfig.Config.Cmd != nil {
+		t.Errorf("expected no default command with an entrypoint, got %v", imageConfig.Config.Cmd)
+	}
+	if imageConfig.Config.WorkingDir != "/srv" {
+		t.Errorf("expected working directory /srv, got %s", imageConfig.Config.WorkingDir)
+	}
+	for key, want := range map[string]string{
+		"org.opencontainers.image.title":       "Edge_Base",
+		"org.opencontainers.image.version":     "1.2.0",
+		"org.opencontainers.image.description": "Edge base container",
+		"org.opencontainers.image.vendor":      "Example",
+	} {
+		if got := imageConfig.Config.Labels[key]; got != want {
+			t.Errorf("expected label %s=%q, got %q", key, want, got)
+		}
+	}
+	if len(imageConfig.RootFS.DiffIDs) != 1 || imageConfig.RootFS.DiffIDs[0] != "sha256:abc" {
+		t.Errorf("unexpected diff IDs %v", imageConfig.RootFS.DiffIDs)
+	}
+
+	template.SystemConfig.Container = config.ContainerConfig{Env: []string{"PATH=/opt/bin"}}
+	imageConfig = containerImageConfig(template, "sha256:abc")
+	if len(imageConfig.Config.Env) != 1 || imageConfig.Config.Env[0] != "PATH=/opt/bin" {
+		t.Errorf("expected the template PATH only, got %v", imageConfig.Config.Env)
+	}
+	if len(imageConfig.Config.Cmd) != 1 || imageConfig.Config.Cmd[0] != "/bin/sh" {
+		t.Errorf("expected default shell command, got %v", imageConfig.Config.Cmd)
+	}
+}
+
+func TestConvertRootfs(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: `tar --create --file=\S+\.tar --numeric-owner --xattrs --xattrs-include='\*' --acls --directory=/rootfs \.$`},
+		{Pattern: "tar", Error: fmt.Errorf("unexpected command")},
+	})
+
+	t.Run("OciArchive", func(t *testing.T) {
+		imageBasePath := filepath.Join(t.TempDir(), "edge-1.2.0")
+		writeTestRootfsTar(t, imageBasePath+".tar")
+
+		template := createContainerTemplate(config.ArtifactInfo{Type: "oci"})
+		if err := NewImageConvert().ConvertRootfs("/rootfs", imageBasePath, template); err != nil {
+			t.Fatalf("ConvertRootfs failed: %v", err)
+		}
+		if _, err := os.Stat(imageBasePath + ".tar"); !os.IsNotExist(err) {
+			t.Error("expected the rootfs tarball to be removed")
+		}
+
+		entries := readArchive(t, imageBasePath+".oci.tar")
+		if string(entries["oci-layout"]) != `{"imageLayoutVersion":"1.0.0"}` {
+			t.Errorf("unexpected oci-layout %s", entries["oci-layout"])
+		}
+		var index ociIndex
+		if err := json.Unmarshal(entries["index.json"], &index); err != nil {
+			t.Fatalf("Failed to decode index.json: %v", err)
+		}
+		if len(index.Manifests) != 1 || index.Manifests[0].Annotations["org.opencontainers.image.ref.name"] != "1.2.0" {
+			t.Fatalf("unexpected index %+v", index)
+		}
+
+		var manifest ociManifest
+		manifestJSON := entries[blobPath(index.Manifests[0].Digest)]
+		if sha256Digest(manifestJSON) != index.Manifests[0].Digest {
+			t.Fatalf("manifest blob does not match its digest")
+		}
+		if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+			t.Fatalf("Failed to decode manifest: %v", err)
+		}
+		layer := entries[blobPath(manifest.Layers[0].Digest)]
+		if sha256Digest(layer) != manifest.Layers[0].Digest || int64(len(layer)) != manifest.Layers[0].Size {
+			t.Fatalf("layer blob does not match its descriptor")
+		}
+
+		var imageConfig ociImageConfig
+		if err := json.Unmarshal(entries[blobPath(manifest.Config.Digest)], &imageConfig); err != nil {
+			t.Fatalf("Failed to decode image config: %v", err)
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(layer))
+		if err != nil {
+			t.Fatalf("Failed to decompress layer: %v", err)
+		}
+		uncompressed, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("Failed to decompress layer: %v", err)
+		}
+		if sha256Digest(uncompressed) != imageConfig.RootFS.DiffIDs[0] {
+			t.Errorf("diff ID does not match the uncompressed layer")
+		}
+	})
+
+	t.Run("DockerArchiveAndTarball", func(t *testing.T) {
+		imageBasePath := filepath.Join(t.TempDir(), "edge-1.2.0")
+		writeTestRootfsTar(t, imageBasePath+".tar")
+
+		template := createContainerTemplate(config.ArtifactInfo{Type: "docker"}, config.ArtifactInfo{Type: "tar"})
+		if err := NewImageConvert().ConvertRootfs("/rootfs", imageBasePath, template); err != nil {
+			t.Fatalf("ConvertRootfs failed: %v", err)
+		}
+		if _, err := os.Stat(imageBasePath + ".tar"); err != nil {
+			t.Errorf("expected the rootfs tarball to be kept: %v", err)
+		}
+
+		entries := readArchive(t, imageBasePath+".docker.tar")
+		var manifest []dockerManifestEntry
+		if err := json.Unmarshal(entries["manifest.json"], &manifest); err != nil {
+			t.Fatalf("Failed to decode manifest.json: %v", err)
+		}
+		if len(manifest) != 1 || len(manifest[0].RepoTags) != 1 || manifest[0].RepoTags[0] != "edge_base:1.2.0" {
+			t.Fatalf("unexpected manifest %+v", manifest)
+		}
+		if _, ok := entries[manifest[0].Config]; !ok {
+			t.Errorf("expected config %s in the archive", manifest[0].Config)
+		}
+		rootfsTar, err := os.ReadFile(imageBasePath + ".tar")
+		if err != nil {
+			t.Fatalf("Failed to read rootfs tarball: %v", err)
+		}
+		if !bytes.Equal(entries[manifest[0].Layers[0]], rootfsTar) {
+			t.Errorf("expected the rootfs tarball as the image layer")
+		}
+	})
+
+	t.Run("TarFailure", func(t *testing.T) {
+		template := createContainerTemplate(config.ArtifactInfo{Type: "tar"})
+		err := NewImageConvert().ConvertRootfs("/other", filepath.Join(t.TempDir(), "edge"), template)
+		if err == nil {
+			t.Error("expected tar error")
+		}
+	})
+}
+
+func TestConvertImageFile_SkipsContainerArtifacts(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test-image.raw")
+	if err := os.WriteFile(filePath, []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	template := createContainerTemplate(config.ArtifactInfo{Type: "raw"}, config.ArtifactInfo{Type: "oci"})
+	if err := NewImageConvert().ConvertImageFile(filePath, template); err != nil {
+		t.Fatalf("ConvertImageFile failed: %v", err)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Errorf("expected the raw image to be kept: %v", err)
+	}
+}
@@ -16,6 +16,7 @@ var log = logger.Logger()
 
 type ImageConvertInterface interface {
 	ConvertImageFile(filePath string, template *config.ImageTemplate) error
+	ConvertRootfs(rootfsPath, imageBasePath string, template *config.ImageTemplate) error
 }
 
 type ImageConvert struct{}
@@ -36,6 +37,22 @@ func (imageConvert *ImageConvert) ConvertImageFile(filePath string, template *co
 	if diskConfig.Artifacts != nil {
 		if len(diskConfig.Artifacts) > 0 {
 			for _, artifact := range diskConfig.Artifacts {
+				if artifact.IsContainer() {
+					// Container artifacts are written from the install root
+					continue
+				}
+				if profile, ok := GetCloudProfile(artifact.Profile); ok {
+					outputFilePath, err := convertCloudImageFile(filePath, profile, diskConfig.PartitionTableType)
+					if err != nil {
+						return fmt.Errorf("failed to convert image file: %w", err)
+					}
+					if artifact.Compression != "" {
+						if err = compressImageFile(outputFilePath, artifact.Compression); err != nil {
+							return fmt.Errorf("failed to compress image file: %w", err)
+						}
+					}
+					continue
+				}
 				if artifact.Type != "raw" {
 					outputFilePath, err := convertImageFile(filePath, artifact.Type)
 					if err != nil {
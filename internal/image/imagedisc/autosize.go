@@ -0,0 +1,297 @@
+package imagedisc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	// SizeAuto sizes the disk and its last partition to the image content
+	SizeAuto = "auto"
+
+	// defaultHeadroom is kept free on an auto-sized partition when the template sets none
+	defaultHeadroom = "20%"
+	// provisionalAutoSizeBytes is the sparse space given to an auto-sized partition while the image is installed
+	provisionalAutoSizeBytes = 32 * 1073741824
+	// autoSizeAlignBytes aligns the shrunk partition and disk sizes
+	autoSizeAlignBytes = 1048576
+)
+
+var resizeMinimumPattern = regexp.MustCompile(`Estimated minimum size of the filesystem:\s*(\d+)`)
+var blockSizePattern = regexp.MustCompile(`(?m)^Block size:\s*(\d+)`)
+
+type sfdiskOutput struct {
+	PartitionTable sfdiskTable `json:"partitiontable"`
+}
+
+type sfdiskTable struct {
+	SectorSize uint64            `json:"sectorsize"`
+	Partitions []sfdiskPartition `json:"partitions"`
+}
+
+type sfdiskPartition struct {
+	Node  string `json:"node"`
+	Start uint64 `json:"start"`
+	Size  uint64 `json:"size"`
+}
+
+// IsDiskSizeAuto reports whether the raw image is shrunk to fit its content
+func IsDiskSizeAuto(diskConfig config.DiskConfig) bool {
+	return diskConfig.Size == SizeAuto
+}
+
+// GrowPartition returns the partition expanded to the end of the disk at first boot
+func GrowPartition(diskConfig config.DiskConfig) (config.PartitionInfo, bool) {
+	for _, partition := range diskConfig.Partitions {
+		if partition.Grow {
+			return partition, true
+		}
+	}
+	return config.PartitionInfo{}, false
+}
+
+func isExtFsType(fsType string) bool {
+	return fsType == "ext2" || fsType == "ext3" || fsType == "ext4"
+}
+
+// CheckPartitionSizing validates the auto-sized and growing partitions of the template disk layout
+func CheckPartitionSizing(template *config.ImageTemplate) error {
+	diskConfig := template.GetDiskConfig()
+	partitions := diskConfig.Partitions
+	last := len(partitions) - 1
+	_, grows := GrowPartition(diskConfig)
+
+	for i, partition := range partitions {
+		if partition.Size != "" && partition.Size != SizeAuto {
+			return fmt.Errorf("invalid size %q for partition %s: only %q is supported", partition.Size, partition.ID, SizeAuto)
+		}
+		if partition.Size == SizeAuto {
+			if i != last {
+				return fmt.Errorf("partition %s is auto-sized but only the last partition can be", partition.ID)
+			}
+			if !IsDiskSizeAuto(diskConfig) {
+				return fmt.Errorf("partition %s is auto-sized but the disk size is not %q", partition.ID, SizeAuto)
+			}
+			if partition.End != "" && partition.End != "0" {
+				return fmt.Errorf("auto-sized partition %s cannot set an end offset", partition.ID)
+			}
+			if !isExtFsType(partition.FsType) {
+				return fmt.Errorf("auto-sized partition %s must use an ext2, ext3 or ext4 filesystem, not %q", partition.ID, partition.FsType)
+			}
+		}
+		if partition.Grow {
+			if i != last {
+				return fmt.Errorf("partition %s grows but only the last partition can", partition.ID)
+			}
+			if partition.MountPoint == "" {
+				return fmt.Errorf("growing partition %s needs a mount point", partition.ID)
+			}
+			if !isExtFsType(partition.FsType) && partition.FsType != "xfs" && partition.FsType != FsTypeBtrfs {
+				return fmt.Errorf("growing partition %s must use an ext2, ext3, ext4, xfs or btrfs filesystem, not %q", partition.ID, partition.FsType)
+			}
+		}
+	}
+
+	if IsDiskSizeAuto(diskConfig) {
+		if last < 0 || partitions[last].Size != SizeAuto {
+			return fmt.Errorf("disk size %q requires the last partition to be auto-sized", SizeAuto)
+		}
+		if diskConfig.PartitionTableType == PartitionTableTypeMbr && len(partitions) > 4 {
+			return fmt.Errorf("disk size %q does not support logical MBR partitions", SizeAuto)
+		}
+		if _, err := headroomBytes(diskConfig.Headroom, 0); err != nil {
+			return err
+		}
+	}
+
+	if (IsDiskSizeAuto(diskConfig) || grows) && template.IsImmutabilityEnabled() {
+		return fmt.Errorf("auto-sized and growing partitions are not supported on immutable images")
+	}
+	return nil
+}
+
+// headroomBytes returns the free space kept next to contentBytes of an auto-sized partition
+func headroomBytes(headroom string, contentBytes uint64) (uint64, error) {
+	if headroom == "" {
+		headroom = defaultHeadroom
+	}
+	if percent, ok := strings.CutSuffix(headroom, "%"); ok {
+		value, err := strconv.ParseUint(percent, 10, 64)
+		if err != nil || value == 0 || value >= 100 {
+			return 0, fmt.Errorf("invalid disk headroom %s", headroom)
+		}
+		return contentBytes * value / 100, nil
+	}
+	bytes, err := TranslateSizeStrToBytes(headroom)
+	if err != nil {
+		return 0, fmt.Errorf("invalid disk headroom %s: %w", headroom, err)
+	}
+	return bytes, nil
+}
+
+func alignUp(bytes, align uint64) uint64 {
+	return (bytes + align - 1) / align * align
+}
+
+// provisionalDiskBytes returns the size of the sparse raw file an auto-sized image is installed into
+func provisionalDiskBytes(diskConfig config.DiskConfig) (uint64, error) {
+	required, err := requiredDiskBytes(diskConfig)
+	if err != nil {
+		return 0, err
+	}
+	return alignUp(required+provisionalAutoSizeBytes, autoSizeAlignBytes), nil
+}
+
+// createSparseRawFile creates a raw file without allocating its blocks
+func createSparseRawFile(filePath string, sizeBytes uint64) error {
+	cmd := fmt.Sprintf("truncate -s %d %s", sizeBytes, filePath)
+	if _, err := shell.ExecCmd(cmd, false, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create sparse raw file %s: %v", filePath, err)
+		return fmt.Errorf("failed to create sparse raw file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// extFsMinimumBytes returns the smallest size the ext filesystem on partDev can be shrunk to
+func extFsMinimumBytes(partDev string) (uint64, error) {
+	output, err := shell.ExecCmd("tune2fs -l "+partDev, true, shell.HostPath, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read filesystem information of %s: %w", partDev, err)
+	}
+	match := blockSizePattern.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("failed to find the block size of %s", partDev)
+	}
+	blockSize, _ := strconv.ParseUint(match[1], 10, 64)
+
+	output, err = shell.ExecCmd("resize2fs -P "+partDev, true, shell.HostPath, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate the minimum size of %s: %w", partDev, err)
+	}
+	match = resizeMinimumPattern.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("failed to find the minimum size of %s", partDev)
+	}
+	blocks, _ := strconv.ParseUint(match[1], 10, 64)
+	return blocks * blockSize, nil
+}
+
+// readPartitionTable returns the partition table of diskPath
+func readPartitionTable(diskPath string) (sfdiskTable, error) {
+	output, err := shell.ExecCmd("sfdisk --json "+diskPath, true, shell.HostPath, nil)
+	if err != nil {
+		return sfdiskTable{}, fmt.Errorf("failed to read partition table of %s: %w", diskPath, err)
+	}
+	var table sfdiskOutput
+	if err := json.Unmarshal([]byte(output), &table); err != nil {
+		return sfdiskTable{}, fmt.Errorf("failed to parse partition table of %s: %w", diskPath, err)
+	}
+	if table.PartitionTable.SectorSize == 0 {
+		table.PartitionTable.SectorSize = 512
+	}
+	return table.PartitionTable, nil
+}
+
+// ShrinkDiskToFit shrinks the filesystem and the auto-sized last partition of
+// diskPath to the image content plus headroom, and returns the disk size that
+// holds the shrunk layout. The partitions must not be mounted.
+func ShrinkDiskToFit(diskPath string, diskPathIdMap map[string]string, diskConfig config.DiskConfig) (uint64, error) {
+	partitions := diskConfig.Partitions
+	if len(partitions) == 0 || partitions[len(partitions)-1].Size != SizeAuto {
+		return 0, fmt.Errorf("disk %s has no auto-sized partition", diskConfig.Name)
+	}
+	partNum := len(partitions)
+	partition := partitions[partNum-1]
+	partDev, ok := diskPathIdMap[partition.ID]
+	if !ok {
+		return 0, fmt.Errorf("no device found for partition %s", partition.ID)
+	}
+
+	log.Infof("Shrinking partition %s to fit its content", partition.ID)
+	if _, err := shell.ExecCmd("e2fsck -fy "+partDev, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Filesystem check of %s failed: %v", partDev, err)
+		return 0, fmt.Errorf("filesystem check of %s failed: %w", partDev, err)
+	}
+
+	contentBytes, err := extFsMinimumBytes(partDev)
+	if err != nil {
+		return 0, err
+	}
+	headroom, err := headroomBytes(diskConfig.Headroom, contentBytes)
+	if err != nil {
+		return 0, err
+	}
+	fsBytes := alignUp(contentBytes+headroom, autoSizeAlignBytes)
+
+	table, err := readPartitionTable(diskPath)
+	if err != nil {
+		return 0, err
+	}
+	if len(table.Partitions) < partNum {
+		return 0, fmt.Errorf("partition %d not found on %s", partNum, diskPath)
+	}
+	current := table.Partitions[partNum-1]
+	sizeSectors := fsBytes / table.SectorSize
+	if sizeSectors > current.Size {
+		return 0, fmt.Errorf("partition %s needs %s, more than the provisional %s",
+			partition.ID, TranslateBytesToSizeStr(fsBytes), TranslateBytesToSizeStr(current.Size*table.SectorSize))
+	}
+
+	log.Infof("Partition %s content is %s, shrinking it to %s", partition.ID,
+		TranslateBytesToSizeStr(contentBytes), TranslateBytesToSizeStr(fsBytes))
+	cmd := fmt.Sprintf("resize2fs %s %dK", partDev, fsBytes/1024)
+	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to shrink filesystem on %s: %v", partDev, err)
+		return 0, fmt.Errorf("failed to shrink filesystem on %s: %w", partDev, err)
+	}
+	cmd = fmt.Sprintf("echo ',%d' | sudo sfdisk --no-reread -N %d %s", sizeSectors, partNum, diskPath)
+	if _, err := shell.ExecCmd(cmd, false, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to shrink partition %d on %s: %v", partNum, diskPath, err)
+		return 0, fmt.Errorf("failed to shrink partition %d on %s: %w", partNum, diskPath, err)
+	}
+
+	diskBytes := (current.Start + sizeSectors) * table.SectorSize
+	if diskConfig.PartitionTableType == PartitionTableTypeGpt {
+		diskBytes += gptBackupHeaderBytes
+	}
+	return alignUp(diskBytes, autoSizeAlignBytes), nil
+}
+
+// TruncateRawFile cuts a raw image file down to sizeBytes and moves the backup
+// GPT header to the new end of the disk. The file must not be attached to a
+// loop device.
+func TruncateRawFile(filePath string, sizeBytes uint64, partitionTableType string) error {
+	log.Infof("Truncating raw image file %s to %s", filePath, TranslateBytesToSizeStr(sizeBytes))
+	cmd := fmt.Sprintf("truncate -s %d %s", sizeBytes, filePath)
+	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to truncate raw file %s: %v", filePath, err)
+		return fmt.Errorf("failed to truncate raw file %s: %w", filePath, err)
+	}
+	if partitionTableType == PartitionTableTypeGpt {
+		cmd = fmt.Sprintf("sfdisk --relocate gpt-bak-std %s", filePath)
+		if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to relocate backup GPT header of %s: %v", filePath, err)
+			return fmt.Errorf("failed to relocate backup GPT header of %s: %w", filePath, err)
+		}
+	}
+	return nil
+}
+
+// fillAutoPartitions lets auto-sized partitions fill the rest of the disk when
+// the partitions are created
+func fillAutoPartitions(partitionsList []config.PartitionInfo) []config.PartitionInfo {
+	filled := make([]config.PartitionInfo, len(partitionsList))
+	for i, partition := range partitionsList {
+		if partition.Size == SizeAuto {
+			partition.End = "0"
+		}
+		filled[i] = partition
+	}
+	return filled
+}
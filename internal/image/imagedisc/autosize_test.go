@@ -0,0 +1,141 @@
+package imagedisc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func autoSizeTemplate() *config.ImageTemplate {
+	return &config.ImageTemplate{
+		Disk: config.DiskConfig{
+			Name:               "auto",
+			Size:               SizeAuto,
+			PartitionTableType: PartitionTableTypeGpt,
+			Partitions: []config.PartitionInfo{
+				{ID: "boot", FsType: "vfat", Start: "1MiB", End: "513MiB", MountPoint: "/boot/efi"},
+				{ID: "rootfs", FsType: "ext4", Start: "513MiB", Size: SizeAuto, Grow: true, MountPoint: "/"},
+			},
+		},
+	}
+}
+
+func TestCheckPartitionSizing(t *testing.T) {
+	template := autoSizeTemplate()
+	if err := CheckPartitionSizing(template); err != nil {
+		t.Fatalf("expected the auto-sized layout to be valid, got: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(template *config.ImageTemplate)
+		want   string
+	}{
+		{"fixed disk size", func(t *config.ImageTemplate) { t.Disk.Size = "8GiB" }, "disk size is not"},
+		{"auto partition not last", func(t *config.ImageTemplate) { t.Disk.Partitions[0].Size = SizeAuto }, "only the last partition"},
+		{"auto partition with end", func(t *config.ImageTemplate) { t.Disk.Partitions[1].End = "4GiB" }, "end offset"},
+		{"auto partition on xfs", func(t *config.ImageTemplate) { t.Disk.Partitions[1].FsType = "xfs" }, "ext2, ext3 or ext4"},
+		{"auto disk without auto partition", func(t *config.ImageTemplate) { t.Disk.Partitions[1].Size = "" }, "requires the last partition"},
+		{"unknown size", func(t *config.ImageTemplate) { t.Disk.Partitions[1].Size = "4GiB" }, "only \"auto\""},
+		{"grow not last", func(t *config.ImageTemplate) { t.Disk.Partitions[0].Grow = true }, "only the last partition can"},
+		{"grow without mount point", func(t *config.ImageTemplate) { t.Disk.Partitions[1].MountPoint = "" }, "mount point"},
+		{"invalid headroom", func(t *config.ImageTemplate) { t.Disk.Headroom = "150%" }, "headroom"},
+		{"immutable image", func(t *config.ImageTemplate) { t.SystemConfig.Immutability.Enabled = true }, "immutable"},
+	}
+	for _, tt := range tests {
+		template := autoSizeTemplate()
+		tt.modify(template)
+		if err := CheckPartitionSizing(template); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got: %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestHeadroomBytes(t *testing.T) {
+	tests := []struct {
+		headroom string
+		expected uint64
+		wantErr  bool
+	}{
+		{"", 200 * 1048576, false},
+		{"10%", 100 * 1048576, false},
+		{"512MiB", 512 * 1048576, false},
+		{"0%", 0, true},
+		{"lots", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := headroomBytes(tt.headroom, 1000*1048576)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("headroomBytes(%q) error = %v, wantErr %v", tt.headroom, err, tt.wantErr)
+		}
+		if !tt.wantErr && got != tt.expected {
+			t.Errorf("headroomBytes(%q) = %d, expected %d", tt.headroom, got, tt.expected)
+		}
+	}
+}
+
+func TestFillAutoPartitions(t *testing.T) {
+	partitions := autoSizeTemplate().Disk.Partitions
+	filled := fillAutoPartitions(partitions)
+	if filled[0].End != "513MiB" || filled[1].End != "0" {
+		t.Errorf("expected only the auto-sized partition to fill the disk, got %+v", filled)
+	}
+	if partitions[1].End != "" {
+		t.Error("expected the template partitions to be left unchanged")
+	}
+}
+
+func TestShrinkDiskToFit(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	diskPathIdMap := map[string]string{"boot": "/dev/loop0p1", "rootfs": "/dev/loop0p2"}
+	partitionTable := `{"partitiontable": {"label": "gpt", "sectorsize": 512, "partitions": [
+		{"node": "/dev/loop0p1", "start": 2048, "size": 1048576},
+		{"node": "/dev/loop0p2", "start": 1050624, "size": 67108864}]}}`
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "e2fsck", Output: "", Error: nil},
+		{Pattern: "tune2fs -l", Output: "Block count:              16777216\nBlock size:               4096\n", Error: nil},
+		{Pattern: "resize2fs -P", Output: "Estimated minimum size of the filesystem: 262144\n", Error: nil},
+		{Pattern: "sfdisk --json", Output: partitionTable, Error: nil},
+		{Pattern: "resize2fs", Output: "", Error: nil},
+		{Pattern: "sfdisk --no-reread -N 2", Output: "", Error: nil},
+	})
+
+	diskConfig := autoSizeTemplate().Disk
+	diskConfig.Headroom = "256MiB"
+	diskSize, err := ShrinkDiskToFit("/dev/loop0", diskPathIdMap, diskConfig)
+	if err != nil {
+		t.Fatalf("ShrinkDiskToFit failed: %v", err)
+	}
+	// 513MiB start, 1GiB content, 256MiB headroom and the backup GPT header
+	expected := uint64(513+1024+256+1) * 1048576
+	if diskSize != expected {
+		t.Errorf("expected disk size %d, got %d", expected, diskSize)
+	}
+
+	diskConfig.Headroom = "40GiB"
+	if _, err := ShrinkDiskToFit("/dev/loop0", diskPathIdMap, diskConfig); err == nil || !strings.Contains(err.Error(), "provisional") {
+		t.Errorf("expected an error when the content does not fit, got: %v", err)
+	}
+
+	diskConfig.Partitions[1].Size = ""
+	if _, err := ShrinkDiskToFit("/dev/loop0", diskPathIdMap, diskConfig); err == nil {
+		t.Error("expected an error without an auto-sized partition")
+	}
+}
+
+func TestTruncateRawFile(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "truncate -s 1048576", Output: "", Error: nil},
+		{Pattern: "sfdisk --relocate gpt-bak-std", Output: "", Error: nil},
+	})
+
+	if err := TruncateRawFile("/tmp/image.raw", 1048576, PartitionTableTypeGpt); err != nil {
+		t.Errorf("TruncateRawFile failed: %v", err)
+	}
+}
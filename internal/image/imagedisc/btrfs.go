@@ -0,0 +1,222 @@
+package imagedisc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/mount"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+// FsTypeBtrfs is the btrfs partition filesystem type
+const FsTypeBtrfs = "btrfs"
+
+// PartitionMount is a mount of a partition, or of one of its btrfs subvolumes
+type PartitionMount struct {
+	MountPoint string
+	Subvolume  string // Subvolume: btrfs subvolume mounted, empty for the whole partition
+	Options    string // Options: fstab mount options
+}
+
+// PartitionMounts returns where a partition is mounted in the image. A btrfs
+// partition with subvolumes is mounted once per subvolume with a mount point.
+func PartitionMounts(partition config.PartitionInfo) []PartitionMount {
+	if partition.FsType != FsTypeBtrfs || len(partition.Subvolumes) == 0 {
+		options := partition.MountOptions
+		if options == "" {
+			options = "defaults"
+		}
+		return []PartitionMount{{MountPoint: partition.MountPoint, Options: options}}
+	}
+	var mounts []PartitionMount
+	for _, subvolume := range partition.Subvolumes {
+		if subvolume.MountPoint == "" {
+			continue
+		}
+		mounts = append(mounts, PartitionMount{
+			MountPoint: subvolume.MountPoint,
+			Subvolume:  subvolume.Name,
+			Options:    joinMountOptions(partition.MountOptions, "subvol="+subvolume.Name, subvolume.MountOptions),
+		})
+	}
+	return mounts
+}
+
+// FindPartitionMount returns the partition and the mount that mountPoint is mounted from
+func FindPartitionMount(diskConfig config.DiskConfig, mountPoint string) (config.PartitionInfo, PartitionMount, bool) {
+	for _, partition := range diskConfig.Partitions {
+		for _, partitionMount := range PartitionMounts(partition) {
+			if partitionMount.MountPoint == mountPoint {
+				return partition, partitionMount, true
+			}
+		}
+	}
+	return config.PartitionInfo{}, PartitionMount{}, false
+}
+
+// joinMountOptions joins comma separated option lists, dropping "defaults"
+// and options set twice
+func joinMountOptions(optionLists ...string) string {
+	var options []string
+	seen := make(map[string]bool)
+	for _, list := range optionLists {
+		for _, option := range strings.Split(list, ",") {
+			option = strings.TrimSpace(option)
+			if option == "" || option == "defaults" || seen[option] {
+				continue
+			}
+			seen[option] = true
+			options = append(options, option)
+		}
+	}
+	if len(options) == 0 {
+		return "defaults"
+	}
+	return strings.Join(options, ",")
+}
+
+// BuildMountOptions returns the options a partition mount is mounted with
+// while the image is built: the btrfs subvolume and the compression, so that
+// installed files are compressed like files written on the device later
+func BuildMountOptions(partitionMount PartitionMount) string {
+	var options []string
+	for _, option := range strings.Split(partitionMount.Options, ",") {
+		if strings.HasPrefix(option, "subvol=") || strings.HasPrefix(option, "compress") {
+			options = append(options, option)
+		}
+	}
+	return strings.Join(options, ",")
+}
+
+// checkBtrfsSubvolumes validates the subvolumes and snapshot of a partition
+func checkBtrfsSubvolumes(partition config.PartitionInfo) error {
+	if partition.FsType != FsTypeBtrfs {
+		if len(partition.Subvolumes) > 0 || partition.Snapshot != "" {
+			return fmt.Errorf("partition %s sets btrfs subvolumes but uses %q", partition.ID, partition.FsType)
+		}
+		return nil
+	}
+	if len(partition.Subvolumes) == 0 {
+		if partition.Snapshot != "" {
+			return fmt.Errorf("partition %s needs a subvolume to snapshot", partition.ID)
+		}
+		return nil
+	}
+
+	names := make(map[string]bool)
+	mountPoints := make(map[string]bool)
+	for _, subvolume := range partition.Subvolumes {
+		if err := checkSubvolumeName(subvolume.Name); err != nil {
+			return fmt.Errorf("partition %s: %w", partition.ID, err)
+		}
+		if names[subvolume.Name] {
+			return fmt.Errorf("partition %s lists subvolume %s twice", partition.ID, subvolume.Name)
+		}
+		names[subvolume.Name] = true
+		if subvolume.MountPoint == "" {
+			continue
+		}
+		if !strings.HasPrefix(subvolume.MountPoint, "/") {
+			return fmt.Errorf("subvolume %s mount point %s is not absolute", subvolume.Name, subvolume.MountPoint)
+		}
+		if mountPoints[subvolume.MountPoint] {
+			return fmt.Errorf("partition %s mounts two subvolumes at %s", partition.ID, subvolume.MountPoint)
+		}
+		mountPoints[subvolume.MountPoint] = true
+	}
+	if !mountPoints[partition.MountPoint] {
+		return fmt.Errorf("partition %s has no subvolume mounted at its mount point %s", partition.ID, partition.MountPoint)
+	}
+	if partition.Snapshot != "" {
+		if err := checkSubvolumeName(partition.Snapshot); err != nil {
+			return fmt.Errorf("partition %s snapshot: %w", partition.ID, err)
+		}
+		if names[partition.Snapshot] {
+			return fmt.Errorf("partition %s snapshot %s is also a subvolume", partition.ID, partition.Snapshot)
+		}
+	}
+	return nil
+}
+
+func checkSubvolumeName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsAny(name, " \t\n,'\"") {
+		return fmt.Errorf("invalid subvolume name %q", name)
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == "" || element == "." || element == ".." {
+			return fmt.Errorf("invalid subvolume name %q", name)
+		}
+	}
+	return nil
+}
+
+// withBtrfsTopLevel mounts the top level of the btrfs filesystem on diskPartDev
+// to a temporary directory while fn runs
+func withBtrfsTopLevel(diskPartDev, partitionID string, fn func(topLevel string) error) error {
+	topLevel := filepath.Join(config.TempDir(), "btrfs-"+partitionID)
+	if err := mount.MountPath(diskPartDev, topLevel, "-t btrfs -o subvolid=5"); err != nil {
+		log.Errorf("Failed to mount btrfs filesystem %s: %v", diskPartDev, err)
+		return fmt.Errorf("failed to mount btrfs filesystem %s: %w", diskPartDev, err)
+	}
+	fnErr := fn(topLevel)
+	if err := mount.UmountAndDeletePath(topLevel); err != nil {
+		if fnErr != nil {
+			return fmt.Errorf("%w, cleanup errors: %v", fnErr, err)
+		}
+		return fmt.Errorf("failed to unmount btrfs filesystem %s: %w", diskPartDev, err)
+	}
+	return fnErr
+}
+
+// createBtrfsSubvolumes creates the subvolumes of a freshly formatted btrfs partition
+func createBtrfsSubvolumes(diskPartDev string, partition config.PartitionInfo) error {
+	if len(partition.Subvolumes) == 0 {
+		return nil
+	}
+	return withBtrfsTopLevel(diskPartDev, partition.ID, func(topLevel string) error {
+		for _, subvolume := range partition.Subvolumes {
+			subvolumePath := filepath.Join(topLevel, subvolume.Name)
+			if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(subvolumePath), true, shell.HostPath, nil); err != nil {
+				return fmt.Errorf("failed to create parent directory of subvolume %s: %w", subvolume.Name, err)
+			}
+			if _, err := shell.ExecCmd("btrfs subvolume create "+subvolumePath, true, shell.HostPath, nil); err != nil {
+				log.Errorf("Failed to create btrfs subvolume %s: %v", subvolume.Name, err)
+				return fmt.Errorf("failed to create btrfs subvolume %s: %w", subvolume.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SnapshotBtrfsPartition takes the read-only snapshot of the subvolume mounted
+// at the partition mount point, when the partition sets one
+func SnapshotBtrfsPartition(diskPartDev string, partition config.PartitionInfo) error {
+	if partition.Snapshot == "" {
+		return nil
+	}
+	var source string
+	for _, subvolume := range partition.Subvolumes {
+		if subvolume.MountPoint == partition.MountPoint {
+			source = subvolume.Name
+		}
+	}
+	if source == "" {
+		return fmt.Errorf("partition %s has no subvolume mounted at %s to snapshot", partition.ID, partition.MountPoint)
+	}
+
+	log.Infof("Taking read-only snapshot %s of subvolume %s", partition.Snapshot, source)
+	return withBtrfsTopLevel(diskPartDev, partition.ID, func(topLevel string) error {
+		snapshotPath := filepath.Join(topLevel, partition.Snapshot)
+		if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(snapshotPath), true, shell.HostPath, nil); err != nil {
+			return fmt.Errorf("failed to create parent directory of snapshot %s: %w", partition.Snapshot, err)
+		}
+		cmd := fmt.Sprintf("btrfs subvolume snapshot -r %s %s", filepath.Join(topLevel, source), snapshotPath)
+		if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to snapshot btrfs subvolume %s: %v", source, err)
+			return fmt.Errorf("failed to snapshot btrfs subvolume %s: %w", source, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,143 @@
+package imagedisc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func btrfsPartition() config.PartitionInfo {
+	return config.PartitionInfo{
+		ID:           "rootfs",
+		FsType:       FsTypeBtrfs,
+		MountPoint:   "/",
+		MountOptions: "defaults,noatime",
+		Subvolumes: []config.BtrfsSubvolume{
+			{Name: "@", MountPoint: "/", MountOptions: "compress=zstd"},
+			{Name: "@home", MountPoint: "/home", MountOptions: "compress=zstd:3"},
+			{Name: "@var", MountPoint: "/var"},
+			{Name: "@snapshots"},
+		},
+		Snapshot: "@snapshots/root-initial",
+	}
+}
+
+func TestPartitionMounts(t *testing.T) {
+	expected := []PartitionMount{
+		{MountPoint: "/", Subvolume: "@", Options: "noatime,subvol=@,compress=zstd"},
+		{MountPoint: "/home", Subvolume: "@home", Options: "noatime,subvol=@home,compress=zstd:3"},
+		{MountPoint: "/var", Subvolume: "@var", Options: "noatime,subvol=@var"},
+	}
+	if mounts := PartitionMounts(btrfsPartition()); !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("expected subvolume mounts %+v, got %+v", expected, mounts)
+	}
+
+	ext4 := config.PartitionInfo{ID: "boot", FsType: "ext4", MountPoint: "/boot"}
+	expected = []PartitionMount{{MountPoint: "/boot", Options: "defaults"}}
+	if mounts := PartitionMounts(ext4); !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("expected a single partition mount %+v, got %+v", expected, mounts)
+	}
+}
+
+func TestFindPartitionMount(t *testing.T) {
+	diskConfig := config.DiskConfig{Partitions: []config.PartitionInfo{
+		{ID: "efi", FsType: "vfat", MountPoint: "/boot/efi"},
+		btrfsPartition(),
+	}}
+	partition, partitionMount, ok := FindPartitionMount(diskConfig, "/home")
+	if !ok || partition.ID != "rootfs" || partitionMount.Subvolume != "@home" {
+		t.Errorf("expected /home on subvolume @home of rootfs, got %s %+v", partition.ID, partitionMount)
+	}
+	if _, _, ok := FindPartitionMount(diskConfig, "/srv"); ok {
+		t.Error("expected no mount for /srv")
+	}
+}
+
+func TestBuildMountOptions(t *testing.T) {
+	partitionMount := PartitionMount{Options: "noatime,subvol=@,compress=zstd"}
+	if options := BuildMountOptions(partitionMount); options != "subvol=@,compress=zstd" {
+		t.Errorf("expected the subvolume and compression options, got %q", options)
+	}
+	if options := BuildMountOptions(PartitionMount{Options: "defaults"}); options != "" {
+		t.Errorf("expected no build mount options, got %q", options)
+	}
+}
+
+func TestCheckBtrfsSubvolumes(t *testing.T) {
+	if err := checkBtrfsSubvolumes(btrfsPartition()); err != nil {
+		t.Fatalf("expected the subvolume layout to be valid, got: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(partition *config.PartitionInfo)
+		want   string
+	}{
+		{"subvolumes on ext4", func(p *config.PartitionInfo) { p.FsType = "ext4" }, "btrfs subvolumes"},
+		{"duplicate name", func(p *config.PartitionInfo) { p.Subvolumes[2].Name = "@home" }, "twice"},
+		{"duplicate mount point", func(p *config.PartitionInfo) { p.Subvolumes[2].MountPoint = "/home" }, "two subvolumes"},
+		{"relative mount point", func(p *config.PartitionInfo) { p.Subvolumes[2].MountPoint = "var" }, "not absolute"},
+		{"invalid name", func(p *config.PartitionInfo) { p.Subvolumes[1].Name = "../home" }, "invalid subvolume name"},
+		{"no subvolume at mount point", func(p *config.PartitionInfo) { p.Subvolumes[0].MountPoint = "" }, "no subvolume mounted"},
+		{"snapshot is a subvolume", func(p *config.PartitionInfo) { p.Snapshot = "@var" }, "also a subvolume"},
+		{"snapshot without subvolumes", func(p *config.PartitionInfo) { p.Subvolumes = nil }, "needs a subvolume"},
+	}
+	for _, tt := range tests {
+		partition := btrfsPartition()
+		tt.modify(&partition)
+		if err := checkBtrfsSubvolumes(partition); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got: %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestCreateBtrfsSubvolumes(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "^mount$", Output: "", Error: nil},
+		{Pattern: "mount -t btrfs -o subvolid=5 /dev/loop0p2", Output: "", Error: nil},
+		{Pattern: "mkdir -p", Output: "", Error: nil},
+		{Pattern: "btrfs subvolume create .*/@home$", Output: "", Error: fmt.Errorf("no space left")},
+		{Pattern: "btrfs subvolume create", Output: "", Error: nil},
+		{Pattern: "rm -rf", Output: "", Error: nil},
+	})
+
+	partition := btrfsPartition()
+	err := createBtrfsSubvolumes("/dev/loop0p2", partition)
+	if err == nil || !strings.Contains(err.Error(), "@home") {
+		t.Errorf("expected the @home subvolume creation to fail, got: %v", err)
+	}
+
+	partition.Subvolumes = partition.Subvolumes[:1]
+	if err := createBtrfsSubvolumes("/dev/loop0p2", partition); err != nil {
+		t.Errorf("createBtrfsSubvolumes failed: %v", err)
+	}
+}
+
+func TestSnapshotBtrfsPartition(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "^mount$", Output: "", Error: nil},
+		{Pattern: "mount -t btrfs -o subvolid=5", Output: "", Error: nil},
+		{Pattern: "mkdir -p", Output: "", Error: nil},
+		{Pattern: "btrfs subvolume snapshot -r .*/@ .*/@snapshots/root-initial$", Output: "", Error: nil},
+		{Pattern: "^sudo btrfs", Output: "", Error: fmt.Errorf("unexpected btrfs command")},
+		{Pattern: "rm -rf", Output: "", Error: nil},
+	})
+
+	if err := SnapshotBtrfsPartition("/dev/loop0p2", btrfsPartition()); err != nil {
+		t.Errorf("SnapshotBtrfsPartition failed: %v", err)
+	}
+
+	partition := btrfsPartition()
+	partition.Snapshot = ""
+	if err := SnapshotBtrfsPartition("/dev/loop0p2", partition); err != nil {
+		t.Errorf("expected no snapshot without a snapshot name, got: %v", err)
+	}
+}
@@ -26,12 +26,31 @@ type blockDeviceInfo struct {
 	MajMin string      `json:"maj:min"` // Example: 1:2
 	Size   json.Number `json:"size"`    // Number of bytes. Can be a quoted string or a JSON number, depending on the util-linux version
 	Model  string      `json:"model"`   // Example: 'Virtual Disk'
+	Serial string      `json:"serial"`  // Example: 'S4EVNX0N123456'
+	Rm     lsblkBool   `json:"rm"`      // Removable flag. Can be a boolean or a "0"/"1" string, depending on the util-linux version
+}
+
+// lsblkBool decodes lsblk flag columns reported either as JSON booleans or as "0"/"1" strings
+type lsblkBool bool
+
+func (b *lsblkBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true", "1":
+		*b = true
+	case "false", "0", "", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid lsblk flag value: %s", data)
+	}
+	return nil
 }
 
 type SystemBlockDevice struct {
 	DevicePath  string // Example: /dev/sda
 	RawDiskSize uint64 // Size in bytes
 	Model       string // Example: Virtual Disk
+	Serial      string // Example: S4EVNX0N123456
+	Removable   bool   // Removable media such as USB sticks and SD cards
 }
 
 const (
@@ -436,7 +455,7 @@ func diskPartitionCreate(
 	partitionType string) (string, error) {
 
 	partitionTypeList := []string{"primary", "extended", "logical"}
-	fsTypeList := []string{"fat32", "fat16", "vfat", "ext2", "ext3", "ext4", "xfs", "linux-swap"}
+	fsTypeList := []string{"fat32", "fat16", "vfat", "ext2", "ext3", "ext4", "xfs", "btrfs", "linux-swap"}
 
 	// Partition info
 	partitionName := partitionInfo.Name
@@ -475,6 +494,10 @@ func diskPartitionCreate(
 		log.Errorf("Unknown fs type for partition %d: %s", partitionNum, partitionInfo.FsType)
 		return "", fmt.Errorf("unknown fs type for partition %d: %s", partitionNum, partitionInfo.FsType)
 	}
+	if err := checkBtrfsSubvolumes(partitionInfo); err != nil {
+		log.Errorf("Invalid btrfs subvolumes for partition %d: %v", partitionNum, err)
+		return "", fmt.Errorf("invalid btrfs subvolumes for partition %d: %w", partitionNum, err)
+	}
 
 	diskName, err := GetDiskNameFromDiskPath(diskPath)
 	if err != nil {
@@ -599,6 +622,16 @@ func diskPartitionCreate(
 			log.Errorf("Failed to format partition %d with fs type %s: %v", partitionNum, partitionInfo.FsType, err)
 			return "", fmt.Errorf("failed to format partition %d with fs type %s: %w", partitionNum, partitionInfo.FsType, err)
 		}
+	} else if partitionInfo.FsType == FsTypeBtrfs {
+		cmdStr = fmt.Sprintf("mkfs -t btrfs -f %s", diskPartDev)
+		_, err := shell.ExecCmd(cmdStr, true, shell.HostPath, nil)
+		if err != nil {
+			log.Errorf("Failed to format partition %d with fs type %s: %v", partitionNum, partitionInfo.FsType, err)
+			return "", fmt.Errorf("failed to format partition %d with fs type %s: %w", partitionNum, partitionInfo.FsType, err)
+		}
+		if err := createBtrfsSubvolumes(diskPartDev, partitionInfo); err != nil {
+			return "", fmt.Errorf("failed to create subvolumes on partition %d: %w", partitionNum, err)
+		}
 	} else if partitionInfo.FsType == "linux-swap" {
 		cmdStr = fmt.Sprintf("mkswap %s", diskPartDev)
 		_, err := shell.ExecCmd(cmdStr, true, shell.HostPath, nil)
@@ -642,6 +675,7 @@ func diskPartitionDelete(diskPath string, partitionNum int) error {
 
 func DiskPartitionsCreate(diskPath string, partitionsList []config.PartitionInfo, partitionTableType string) (map[string]string, error) {
 	partIDDiskDevMap := make(map[string]string)
+	partitionsList = fillAutoPartitions(partitionsList)
 
 	partitionExist, err := IsDiskPartitionExist(diskPath)
 	if err != nil {
@@ -793,7 +827,7 @@ func SystemBlockDevices() (systemDevices []SystemBlockDevice, err error) {
 	)
 
 	blockDeviceMajorNumbers := []string{scsiDiskMajorNumber, mmcBlockMajorNumber, virtualDiskMajorNumber, blockExtendedMajorNumber}
-	cmd := fmt.Sprintf("lsblk -d --bytes -I %s -n --json --output NAME,SIZE,MODEL", strings.Join(blockDeviceMajorNumbers, ","))
+	cmd := fmt.Sprintf("lsblk -d --bytes -I %s -n --json --output NAME,SIZE,MODEL,SERIAL,RM", strings.Join(blockDeviceMajorNumbers, ","))
 	rawDiskOutput, err := shell.ExecCmd(cmd, true, shell.HostPath, nil)
 	if err != nil {
 		log.Errorf("Failed to execute lsblk command: %v", err)
@@ -832,6 +866,8 @@ func SystemBlockDevices() (systemDevices []SystemBlockDevice, err error) {
 				DevicePath:  devicePath,
 				RawDiskSize: rawSize,
 				Model:       strings.TrimSpace(device.Model),
+				Serial:      strings.TrimSpace(device.Serial),
+				Removable:   bool(device.Rm),
 			})
 		} else {
 			log.Debugf("Excluded removable installer device: %s", devicePath)
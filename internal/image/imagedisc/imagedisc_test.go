@@ -1195,6 +1195,33 @@ func TestSystemBlockDevices(t *testing.T) {
 	}
 }
 
+func TestSystemBlockDevicesSerialAndRemovable(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	// util-linux reports the rm column as a boolean or as a "0"/"1" string depending on version
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "lsblk", Output: `{"blockdevices":[
+			{"name":"sda","size":"256060514304","model":"SSD ","serial":" S1 ","rm":false},
+			{"name":"sdb","size":1024209543168,"model":"USB","serial":"U1","rm":"1"},
+			{"name":"sdc","size":1024,"model":"Card","serial":null,"rm":true}]}`},
+	})
+
+	devices, err := SystemBlockDevices()
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("Expected 3 devices, but got %d", len(devices))
+	}
+	if devices[0].Serial != "S1" || devices[0].Model != "SSD" || devices[0].Removable {
+		t.Errorf("Unexpected device: %+v", devices[0])
+	}
+	if !devices[1].Removable || !devices[2].Removable {
+		t.Errorf("Expected removable devices, got %+v", devices[1:])
+	}
+}
+
 func TestBootPartitionConfig(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -53,6 +53,19 @@ func loopSetupCreateEmptyRawDisk(filePath, fileSize string) (string, error) {
 	return "", fmt.Errorf("can't find %s", filePath)
 }
 
+// loopSetupCreateSparseRawDisk attaches a sparse raw file large enough to
+// install an auto-sized image into; the file is shrunk after the install
+func loopSetupCreateSparseRawDisk(filePath string, diskInfo config.DiskConfig) (string, error) {
+	sizeBytes, err := provisionalDiskBytes(diskInfo)
+	if err != nil {
+		return "", err
+	}
+	if err := createSparseRawFile(filePath, sizeBytes); err != nil {
+		return "", err
+	}
+	return loopSetupCreate(filePath)
+}
+
 func (loopDev *LoopDev) LoopSetupDelete(loopDevPath string) error {
 	cmd := fmt.Sprintf("losetup -d %s", loopDevPath)
 	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
@@ -139,7 +152,15 @@ func (loopDev *LoopDev) CreateRawImageLoopDev(filePath string, template *config.
 	var loopDevPath string
 
 	diskInfo := template.GetDiskConfig()
-	loopDevPath, err := loopSetupCreateEmptyRawDisk(filePath, diskInfo.Size)
+	if err := CheckPartitionSizing(template); err != nil {
+		return loopDevPath, diskPathIdMap, fmt.Errorf("invalid disk layout: %w", err)
+	}
+	var err error
+	if IsDiskSizeAuto(diskInfo) {
+		loopDevPath, err = loopSetupCreateSparseRawDisk(filePath, diskInfo)
+	} else {
+		loopDevPath, err = loopSetupCreateEmptyRawDisk(filePath, diskInfo.Size)
+	}
 	if err != nil {
 		return loopDevPath, diskPathIdMap, fmt.Errorf("failed to create loop device: %w", err)
 	}
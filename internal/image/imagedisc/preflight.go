@@ -0,0 +1,261 @@
+package imagedisc
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	PreflightInfo    = "info"
+	PreflightWarning = "warning"
+	PreflightBlocker = "blocker"
+
+	// InstallerMediaLabel is the filesystem label of the installer ISO
+	InstallerMediaLabel = "OIC_CDROM"
+
+	// gptBackupHeaderBytes is reserved at the end of the disk for the backup GPT header
+	gptBackupHeaderBytes = 1048576
+	// minFillPartitionBytes is the smallest size accepted for a partition that fills the rest of the disk
+	minFillPartitionBytes = 1048576
+)
+
+// PreflightOptions carries the operator consent that downgrades blockers
+type PreflightOptions struct {
+	Force bool // Force: allow installing to removable or installer media
+	Wipe  bool // Wipe: allow erasing an existing partition table and filesystems
+}
+
+// PreflightFinding is a single preflight check result
+type PreflightFinding struct {
+	Severity string // Severity: info, warning or blocker
+	Message  string
+}
+
+// PreflightReport collects the results of the checks run against a target disk
+type PreflightReport struct {
+	DiskPath     string
+	DiskSize     uint64 // DiskSize: raw disk size in bytes
+	RequiredSize uint64 // RequiredSize: bytes needed by the partition layout
+	Findings     []PreflightFinding
+}
+
+type lsblkEntriesOutput struct {
+	Entries []lsblkEntry `json:"blockdevices"`
+}
+
+type lsblkEntry struct {
+	Path       string `json:"path"`
+	FsType     string `json:"fstype"`
+	Label      string `json:"label"`
+	MountPoint string `json:"mountpoint"`
+	Type       string `json:"type"`
+}
+
+func (r *PreflightReport) add(severity, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, PreflightFinding{Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// Blockers returns the findings that prevent the installation
+func (r *PreflightReport) Blockers() []PreflightFinding {
+	var blockers []PreflightFinding
+	for _, finding := range r.Findings {
+		if finding.Severity == PreflightBlocker {
+			blockers = append(blockers, finding)
+		}
+	}
+	return blockers
+}
+
+// Err returns an error describing all blockers, or nil if the installation may proceed
+func (r *PreflightReport) Err() error {
+	blockers := r.Blockers()
+	if len(blockers) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(blockers))
+	for _, blocker := range blockers {
+		messages = append(messages, blocker.Message)
+	}
+	return fmt.Errorf("preflight checks failed for disk %s: %s", r.DiskPath, strings.Join(messages, "; "))
+}
+
+// Lines renders the findings one per line for display
+func (r *PreflightReport) Lines() []string {
+	lines := make([]string, 0, len(r.Findings))
+	for _, finding := range r.Findings {
+		lines = append(lines, fmt.Sprintf("[%s] %s", finding.Severity, finding.Message))
+	}
+	return lines
+}
+
+// Log writes the findings to the installer log
+func (r *PreflightReport) Log() {
+	for _, finding := range r.Findings {
+		switch finding.Severity {
+		case PreflightBlocker:
+			log.Errorf("Preflight %s: %s", r.DiskPath, finding.Message)
+		case PreflightWarning:
+			log.Warnf("Preflight %s: %s", r.DiskPath, finding.Message)
+		default:
+			log.Infof("Preflight %s: %s", r.DiskPath, finding.Message)
+		}
+	}
+}
+
+// DiskPreflight checks that the target disk can safely receive the partition layout.
+// Missing disks, disks that are too small and mounted disks always block the installation.
+// Removable and installer media block unless forced, existing data blocks unless wipe is allowed.
+func DiskPreflight(diskPath string, diskConfig config.DiskConfig, opts PreflightOptions) (*PreflightReport, error) {
+	if resolved, err := filepath.EvalSymlinks(diskPath); err == nil {
+		diskPath = resolved
+	}
+	report := &PreflightReport{DiskPath: diskPath}
+
+	requiredSize, err := requiredDiskBytes(diskConfig)
+	if err != nil {
+		return nil, err
+	}
+	report.RequiredSize = requiredSize
+
+	if isReadOnlyISO(diskPath) {
+		report.add(PreflightBlocker, "disk is the mounted installer medium")
+		return report, nil
+	}
+
+	devices, err := SystemBlockDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list block devices: %w", err)
+	}
+	var device *SystemBlockDevice
+	for i := range devices {
+		if devices[i].DevicePath == diskPath {
+			device = &devices[i]
+			break
+		}
+	}
+	if device == nil {
+		report.add(PreflightBlocker, "disk not found among the system block devices")
+		return report, nil
+	}
+	report.DiskSize = device.RawDiskSize
+	report.add(PreflightInfo, "found %s disk %s", TranslateBytesToSizeStr(device.RawDiskSize),
+		strings.TrimSpace(device.Model+" "+device.Serial))
+
+	if device.RawDiskSize < requiredSize {
+		report.add(PreflightBlocker, "disk size %s is smaller than the %s needed by the partition layout",
+			TranslateBytesToSizeStr(device.RawDiskSize), TranslateBytesToSizeStr(requiredSize))
+	}
+
+	if device.Removable {
+		if opts.Force {
+			report.add(PreflightWarning, "disk is removable media, continuing because install is forced")
+		} else {
+			report.add(PreflightBlocker, "disk is removable media, force the install to use it")
+		}
+	}
+
+	entries, err := diskBlockEntries(diskPath)
+	if err != nil {
+		return nil, err
+	}
+	var filesystems []string
+	installerMedia := false
+	for _, entry := range entries {
+		if entry.MountPoint != "" {
+			report.add(PreflightBlocker, "%s is mounted at %s", entry.Path, entry.MountPoint)
+		}
+		if entry.Label == InstallerMediaLabel || entry.FsType == "iso9660" {
+			installerMedia = true
+		}
+		if entry.FsType != "" {
+			filesystems = append(filesystems, fmt.Sprintf("%s (%s)", entry.Path, entry.FsType))
+		}
+	}
+	if installerMedia {
+		if opts.Force {
+			report.add(PreflightWarning, "disk holds installer media, continuing because install is forced")
+		} else {
+			report.add(PreflightBlocker, "disk holds installer media, force the install to use it")
+		}
+	}
+
+	diskInfo, err := DiskGetInfo(diskPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk info for %s: %w", diskPath, err)
+	}
+	var existing []string
+	if tableType, ok := diskInfo["part_table_type"].(string); ok && tableType != "" {
+		partNum, _ := diskInfo["part_num"].(int)
+		existing = append(existing, fmt.Sprintf("%s partition table with %d partition(s)", tableType, partNum))
+	}
+	if len(filesystems) > 0 {
+		existing = append(existing, "filesystems on "+strings.Join(filesystems, ", "))
+	}
+	if len(existing) > 0 {
+		if opts.Wipe {
+			report.add(PreflightWarning, "existing data will be erased: %s", strings.Join(existing, "; "))
+		} else {
+			report.add(PreflightBlocker, "disk holds existing data (%s), set wipe: true to erase it", strings.Join(existing, "; "))
+		}
+	}
+
+	busy, err := CheckDiskIOStats(diskPath)
+	if err != nil {
+		report.add(PreflightWarning, "could not read I/O statistics: %v", err)
+	} else if busy {
+		report.add(PreflightWarning, "disk has I/O in progress")
+	}
+
+	return report, nil
+}
+
+// requiredDiskBytes returns the number of bytes spanned by the partition layout
+func requiredDiskBytes(diskConfig config.DiskConfig) (uint64, error) {
+	var required uint64
+	for _, partition := range diskConfig.Partitions {
+		start, err := partitionOffsetBytes(partition.Start)
+		if err != nil {
+			return 0, fmt.Errorf("invalid start %s for partition %s: %w", partition.Start, partition.ID, err)
+		}
+		end := start + minFillPartitionBytes
+		if partition.End != "0" && partition.End != "" {
+			if end, err = partitionOffsetBytes(partition.End); err != nil {
+				return 0, fmt.Errorf("invalid end %s for partition %s: %w", partition.End, partition.ID, err)
+			}
+		}
+		if end > required {
+			required = end
+		}
+	}
+	if required > 0 && diskConfig.PartitionTableType == PartitionTableTypeGpt {
+		required += gptBackupHeaderBytes
+	}
+	return required, nil
+}
+
+func partitionOffsetBytes(offset string) (uint64, error) {
+	if offset == "" || offset == "0" {
+		return 0, nil
+	}
+	return TranslateSizeStrToBytes(offset)
+}
+
+// diskBlockEntries lists the disk and its partitions with filesystem, label and mount information
+func diskBlockEntries(diskPath string) ([]lsblkEntry, error) {
+	cmd := fmt.Sprintf("lsblk %s --json --list --output PATH,FSTYPE,LABEL,MOUNTPOINT,TYPE", diskPath)
+	output, err := shell.ExecCmd(cmd, true, shell.HostPath, nil)
+	if err != nil {
+		log.Errorf("Failed to list block entries for disk %s: %v", diskPath, err)
+		return nil, fmt.Errorf("failed to list block entries for disk %s: %w", diskPath, err)
+	}
+	var entries lsblkEntriesOutput
+	if err := json.Unmarshal([]byte(output), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse block entries for disk %s: %w", diskPath, err)
+	}
+	return entries.Entries, nil
+}
@@ -0,0 +1,141 @@
+package imagedisc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+var preflightDiskConfig = config.DiskConfig{
+	PartitionTableType: PartitionTableTypeGpt,
+	Partitions: []config.PartitionInfo{
+		{ID: "boot", Start: "1MiB", End: "513MiB"},
+		{ID: "rootfs", Start: "513MiB", End: "0"},
+	},
+}
+
+func TestRequiredDiskBytes(t *testing.T) {
+	required, err := requiredDiskBytes(preflightDiskConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The fill partition needs at least 1MiB plus the backup GPT header
+	if expected := uint64(515 * 1048576); required != expected {
+		t.Errorf("requiredDiskBytes() = %d, want %d", required, expected)
+	}
+
+	required, err = requiredDiskBytes(config.DiskConfig{
+		PartitionTableType: "mbr",
+		Partitions:         []config.PartitionInfo{{ID: "rootfs", Start: "1MiB", End: "4GiB"}},
+	})
+	if err != nil || required != 4*1073741824 {
+		t.Errorf("requiredDiskBytes() = %d, %v, want %d", required, err, 4*1073741824)
+	}
+
+	_, err = requiredDiskBytes(config.DiskConfig{
+		Partitions: []config.PartitionInfo{{ID: "rootfs", Start: "1 megabyte", End: "0"}},
+	})
+	if err == nil {
+		t.Error("expected error for invalid partition start")
+	}
+}
+
+func TestDiskPreflight(t *testing.T) {
+	const (
+		fixedDisk     = `{"blockdevices":[{"name":"vdz","size":"68719476736","model":"QEMU","serial":"Q1","rm":false}]}`
+		removableDisk = `{"blockdevices":[{"name":"vdz","size":"68719476736","model":"USB","serial":"U1","rm":true}]}`
+		smallDisk     = `{"blockdevices":[{"name":"vdz","size":"268435456","model":"QEMU","serial":"Q1","rm":false}]}`
+		emptyEntries  = `{"blockdevices":[{"path":"/dev/vdz","fstype":null,"label":null,"mountpoint":null,"type":"disk"}]}`
+		dataEntries   = `{"blockdevices":[{"path":"/dev/vdz","type":"disk"},
+			{"path":"/dev/vdz1","fstype":"ext4","label":"data","mountpoint":null,"type":"part"}]}`
+		mountedEntries = `{"blockdevices":[{"path":"/dev/vdz","type":"disk"},
+			{"path":"/dev/vdz1","fstype":"ext4","label":"data","mountpoint":"/mnt","type":"part"}]}`
+		mediaEntries = `{"blockdevices":[{"path":"/dev/vdz","fstype":"iso9660","label":"OIC_CDROM","mountpoint":null,"type":"disk"}]}`
+		emptyFdisk   = "Disk /dev/vdz: 64 GiB, 68719476736 bytes, 134217728 sectors\n"
+		gptFdisk     = emptyFdisk + "Disklabel type: gpt\n/dev/vdz1 2048 1050623 1048576 512M Linux filesystem\n"
+		idleStats    = " 252 0 vdz 100 0 200 50 0 0 0 0 0 100 100\n"
+	)
+
+	tests := []struct {
+		name     string
+		disk     string
+		entries  string
+		fdisk    string
+		opts     PreflightOptions
+		blockers []string
+		warnings []string
+	}{
+		{name: "empty disk", disk: fixedDisk, entries: emptyEntries, fdisk: emptyFdisk},
+		{name: "too small", disk: smallDisk, entries: emptyEntries, fdisk: emptyFdisk, blockers: []string{"smaller than"}},
+		{name: "existing data", disk: fixedDisk, entries: dataEntries, fdisk: gptFdisk, blockers: []string{"wipe: true"}},
+		{name: "existing data wiped", disk: fixedDisk, entries: dataEntries, fdisk: gptFdisk,
+			opts: PreflightOptions{Wipe: true}, warnings: []string{"will be erased"}},
+		{name: "mounted", disk: fixedDisk, entries: mountedEntries, fdisk: gptFdisk,
+			opts: PreflightOptions{Wipe: true, Force: true}, blockers: []string{"mounted at /mnt"}, warnings: []string{"will be erased"}},
+		{name: "removable", disk: removableDisk, entries: emptyEntries, fdisk: emptyFdisk, blockers: []string{"removable"}},
+		{name: "removable forced", disk: removableDisk, entries: emptyEntries, fdisk: emptyFdisk,
+			opts: PreflightOptions{Force: true}, warnings: []string{"removable"}},
+		{name: "installer media", disk: fixedDisk, entries: mediaEntries, fdisk: emptyFdisk,
+			opts: PreflightOptions{Wipe: true}, blockers: []string{"installer media"}, warnings: []string{"will be erased"}},
+	}
+
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+				{Pattern: "lsblk -d", Output: tt.disk},
+				{Pattern: "lsblk /dev/vdz", Output: tt.entries},
+				{Pattern: "fdisk -l /dev/vdz", Output: tt.fdisk},
+				{Pattern: "cat /proc/diskstats", Output: idleStats},
+			})
+
+			report, err := DiskPreflight("/dev/vdz", preflightDiskConfig, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkFindings(t, report, PreflightBlocker, tt.blockers)
+			checkFindings(t, report, PreflightWarning, tt.warnings)
+			if (report.Err() != nil) != (len(tt.blockers) > 0) {
+				t.Errorf("Err() = %v, expected blockers %v", report.Err(), tt.blockers)
+			}
+		})
+	}
+}
+
+func TestDiskPreflight_MissingDisk(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "lsblk -d", Output: `{"blockdevices":[{"name":"sda","size":"68719476736","model":"QEMU","rm":false}]}`},
+	})
+
+	report, err := DiskPreflight("/dev/vdz", preflightDiskConfig, PreflightOptions{Force: true, Wipe: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected missing disk blocker, got %v", err)
+	}
+}
+
+func checkFindings(t *testing.T, report *PreflightReport, severity string, expected []string) {
+	t.Helper()
+	var messages []string
+	for _, finding := range report.Findings {
+		if finding.Severity == severity {
+			messages = append(messages, finding.Message)
+		}
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %d %s findings, got %v", len(expected), severity, messages)
+	}
+	for i, want := range expected {
+		if !strings.Contains(messages[i], want) {
+			t.Errorf("%s finding %q does not mention %q", severity, messages[i], want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package imageos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imageconvert"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+)
+
+const (
+	cloudInitConfigDir      = "/etc/cloud/cloud.cfg.d"
+	cloudInitDatasourceFile = "/etc/cloud/cloud.cfg.d/95_os-image-composer-datasource.cfg"
+	cloudInitSeedDir        = "/var/lib/cloud/seed/nocloud"
+	cloudInitStamp          = "# Generated by OS Image Composer from systemConfig.cloudInit\n"
+
+	// cloudInitSeedLabel is the volume label the NoCloud datasource looks for
+	cloudInitSeedLabel = "cidata"
+)
+
+// updateImageCloudInit embeds the NoCloud seed of the template in the image
+// and limits cloud-init to the datasources of the image, so it does not probe
+// the others at boot
+func updateImageCloudInit(installRoot string, template *config.ImageTemplate) error {
+	cloudInit := template.SystemConfig.CloudInit
+	datasources := cloudInitDatasources(template)
+	if !cloudInit.HasSeed() && len(datasources) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(installRoot, cloudInitConfigDir)); err != nil {
+		if cloudInit.HasSeed() || len(cloudInit.Datasources) > 0 {
+			return fmt.Errorf("cloudInit needs cloud-init in the image, is the cloud-init package installed")
+		}
+		log.Debugf("cloud-init is not installed, skipping datasource configuration")
+		return nil
+	}
+
+	if cloudInit.HasSeed() && !cloudInit.HasIsoSeed() {
+		seed, err := template.GetCloudInitSeed()
+		if err != nil {
+			return err
+		}
+		if err := writeCloudInitSeed(installRoot, seed); err != nil {
+			return err
+		}
+	}
+
+	if len(datasources) == 0 {
+		return nil
+	}
+	return writeImageFile(installRoot, cloudInitDatasourceFile, renderCloudInitDatasources(datasources))
+}
+
+// cloudInitDatasources returns the datasources cloud-init looks for: the
+// configured ones, or NoCloud with a seed followed by the datasources of the
+// artifact cloud profiles
+func cloudInitDatasources(template *config.ImageTemplate) []string {
+	cloudInit := template.SystemConfig.CloudInit
+	if len(cloudInit.Datasources) > 0 {
+		return cloudInit.Datasources
+	}
+	var datasources []string
+	if cloudInit.HasSeed() {
+		datasources = append(datasources, "NoCloud")
+	}
+	return append(datasources, imageconvert.CloudDatasources(template)...)
+}
+
+// renderCloudInitDatasources returns the cloud-init config with the
+// datasource list; None is kept last for when no datasource is found
+func renderCloudInitDatasources(datasources []string) string {
+	if !slice.Contains(datasources, "None") {
+		datasources = append(append([]string{}, datasources...), "None")
+	}
+	return cloudInitStamp + fmt.Sprintf("datasource_list: [ %s ]\n", strings.Join(datasources, ", "))
+}
+
+// writeCloudInitSeed writes the NoCloud seed into the image. The user-data may
+// hold credentials, so the seed is only readable by root.
+func writeCloudInitSeed(installRoot string, seed config.CloudInitSeed) error {
+	log.Infof("Embedding cloud-init NoCloud seed in %s", cloudInitSeedDir)
+	for name, content := range cloudInitSeedFiles(seed) {
+		seedFile := filepath.Join(cloudInitSeedDir, name)
+		if err := writeImageFile(installRoot, seedFile, content); err != nil {
+			return err
+		}
+		if _, err := shell.ExecCmd("chmod 0600 "+filepath.Join(installRoot, seedFile), true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to set permissions of %s: %v", seedFile, err)
+			return fmt.Errorf("failed to set permissions of %s: %w", seedFile, err)
+		}
+	}
+	if _, err := shell.ExecCmd("chmod 0700 "+filepath.Join(installRoot, cloudInitSeedDir), true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", cloudInitSeedDir, err)
+	}
+	return nil
+}
+
+// cloudInitSeedFiles returns the NoCloud seed file contents by name. The
+// user-data and meta-data files must exist for NoCloud to use the seed.
+func cloudInitSeedFiles(seed config.CloudInitSeed) map[string]string {
+	files := map[string]string{
+		"user-data": seed.UserData,
+		"meta-data": seed.MetaData,
+	}
+	if seed.NetworkConfig != "" {
+		files["network-config"] = seed.NetworkConfig
+	}
+	return files
+}
+
+// CreateCloudInitSeedIso writes the NoCloud seed of the template to a cidata
+// ISO, attached to the machine next to the disk image
+func CreateCloudInitSeedIso(template *config.ImageTemplate, isoPath string) error {
+	seed, err := template.GetCloudInitSeed()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Creating cloud-init seed ISO %s", isoPath)
+	stagingDir, err := os.MkdirTemp(filepath.Dir(isoPath), ".cidata-")
+	if err != nil {
+		return fmt.Errorf("failed to create cloud-init seed directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	for name, content := range cloudInitSeedFiles(seed) {
+		if err := os.WriteFile(filepath.Join(stagingDir, name), []byte(content), 0600); err != nil {
+			return fmt.Errorf("failed to write cloud-init %s: %w", name, err)
+		}
+	}
+
+	cmdStr := fmt.Sprintf("xorriso -as mkisofs -output %s -volid %s -joliet -rock %s", isoPath, cloudInitSeedLabel, stagingDir)
+	if _, err := shell.ExecCmd(cmdStr, false, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create cloud-init seed ISO: %v", err)
+		return fmt.Errorf("failed to create cloud-init seed ISO: %w", err)
+	}
+	return nil
+}
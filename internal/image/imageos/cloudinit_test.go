@@ -0,0 +1,167 @@
+package imageos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func TestCloudInitDatasources(t *testing.T) {
+	template := &config.ImageTemplate{
+		Disk: config.DiskConfig{Artifacts: []config.ArtifactInfo{{Type: "vhd", Profile: "azure"}, {Type: "raw", Profile: "gce"}}},
+	}
+	if got := cloudInitDatasources(template); !reflect.DeepEqual(got, []string{"Azure", "GCE"}) {
+		t.Errorf("expected the profile datasources, got %v", got)
+	}
+
+	template.SystemConfig.CloudInit.UserData = "#cloud-config\n"
+	if got := cloudInitDatasources(template); !reflect.DeepEqual(got, []string{"NoCloud", "Azure", "GCE"}) {
+		t.Errorf("expected NoCloud first with a seed, got %v", got)
+	}
+
+	template.SystemConfig.CloudInit.Datasources = []string{"ConfigDrive", "None"}
+	if got := cloudInitDatasources(template); !reflect.DeepEqual(got, []string{"ConfigDrive", "None"}) {
+		t.Errorf("expected the configured datasources, got %v", got)
+	}
+}
+
+func TestRenderCloudInitDatasources(t *testing.T) {
+	content := renderCloudInitDatasources([]string{"NoCloud", "Azure"})
+	if !strings.HasPrefix(content, cloudInitStamp) {
+		t.Errorf("expected stamp in:\n%s", content)
+	}
+	if !strings.HasSuffix(content, "datasource_list: [ NoCloud, Azure, None ]\n") {
+		t.Errorf("expected datasource list in:\n%s", content)
+	}
+
+	content = renderCloudInitDatasources([]string{"Ec2", "None"})
+	if !strings.HasSuffix(content, "datasource_list: [ Ec2, None ]\n") {
+		t.Errorf("expected a single None in:\n%s", content)
+	}
+}
+
+func TestUpdateImageCloudInit(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = t.TempDir()
+	config.SetGlobal(newGlobal)
+
+	newCloudInitRoot := func(t *testing.T) string {
+		installRoot := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(installRoot, cloudInitConfigDir), 0755); err != nil {
+			t.Fatalf("Failed to create cloud-init config directory: %v", err)
+		}
+		return installRoot
+	}
+
+	t.Run("EmbeddedSeed", func(t *testing.T) {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: `mkdir -p \S+/var/lib/cloud/seed/nocloud$`},
+			{Pattern: `mkdir -p \S+/etc/cloud/cloud\.cfg\.d$`},
+			{Pattern: "mkdir"},
+			{Pattern: `cp \S+ '\S+/var/lib/cloud/seed/nocloud/(user|meta)-data'$`},
+			{Pattern: `cp \S+ '\S+/etc/cloud/cloud\.cfg\.d/95_os-image-composer-datasource\.cfg'$`},
+			{Pattern: `chmod 0600 \S+/var/lib/cloud/seed/nocloud/(user|meta)-data$`},
+			{Pattern: `chmod 0700 \S+/var/lib/cloud/seed/nocloud$`},
+			{Pattern: ".*", Error: fmt.Errorf("unexpected command")},
+		})
+		template := &config.ImageTemplate{
+			Image: config.ImageInfo{Name: "edge", Version: "1.0.0"},
+			SystemConfig: config.SystemConfig{
+				CloudInit: config.CloudInitConfig{UserData: "#cloud-config\nruncmd:\n  - [true]\n"},
+			},
+		}
+		if err := updateImageCloudInit(newCloudInitRoot(t), template); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("IsoSeed", func(t *testing.T) {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "nocloud", Error: fmt.Errorf("unexpected seed in the image")},
+			{Pattern: "mkdir"},
+			{Pattern: `cp \S+ '\S+/95_os-image-composer-datasource\.cfg'$`},
+			{Pattern: ".*", Error: fmt.Errorf("unexpected command")},
+		})
+		template := &config.ImageTemplate{
+			SystemConfig: config.SystemConfig{
+				CloudInit: config.CloudInitConfig{MetaData: "instance-id: edge\n", Seed: "iso"},
+			},
+		}
+		if err := updateImageCloudInit(newCloudInitRoot(t), template); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("InvalidSeed", func(t *testing.T) {
+		template := &config.ImageTemplate{
+			SystemConfig: config.SystemConfig{
+				CloudInit: config.CloudInitConfig{UserData: "runcmd: []\n"},
+			},
+		}
+		err := updateImageCloudInit(newCloudInitRoot(t), template)
+		if err == nil || !strings.Contains(err.Error(), "#cloud-config") {
+			t.Errorf("expected user-data error, got %v", err)
+		}
+	})
+
+	t.Run("WithoutCloudInit", func(t *testing.T) {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: ".*", Error: fmt.Errorf("unexpected command")},
+		})
+		template := &config.ImageTemplate{
+			Disk: config.DiskConfig{Artifacts: []config.ArtifactInfo{{Type: "vhd", Profile: "azure"}}},
+		}
+		if err := updateImageCloudInit(t.TempDir(), template); err != nil {
+			t.Errorf("expected profile datasources to be skipped without cloud-init, got %v", err)
+		}
+
+		template.SystemConfig.CloudInit.UserData = "#cloud-config\n"
+		err := updateImageCloudInit(t.TempDir(), template)
+		if err == nil || !strings.Contains(err.Error(), "cloud-init package") {
+			t.Errorf("expected missing cloud-init error, got %v", err)
+		}
+	})
+}
+
+func TestCreateCloudInitSeedIso(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: `xorriso -as mkisofs -output \S+/edge-1\.0\.0-cidata\.iso -volid cidata -joliet -rock \S+/\.cidata-\S+$`},
+		{Pattern: ".*", Error: fmt.Errorf("unexpected command")},
+	})
+
+	template := &config.ImageTemplate{
+		Image: config.ImageInfo{Name: "edge", Version: "1.0.0"},
+		SystemConfig: config.SystemConfig{
+			CloudInit: config.CloudInitConfig{
+				UserData:      "#cloud-config\n",
+				NetworkConfig: "version: 2\nethernets:\n  eth0:\n    dhcp4: true\n",
+				Seed:          "iso",
+			},
+		},
+	}
+	isoPath := filepath.Join(t.TempDir(), "edge-1.0.0-cidata.iso")
+	if err := CreateCloudInitSeedIso(template, isoPath); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(isoPath))
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the seed staging directory to be removed, got %v", entries)
+	}
+}
@@ -0,0 +1,152 @@
+package imageos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
+	"github.com/open-edge-platform/os-image-composer/internal/hook"
+)
+
+// containerSkippedPkgPrefixes are the bootloader packages left out of a
+// container root file system, along with the kernel packages
+var containerSkippedPkgPrefixes = []string{"grub", "shim", "systemd-boot", "efibootmgr"}
+
+// InstallContainerOs installs the image packages into the install root
+// without a disk, kernel or bootloader, for the rootfs tarball and container
+// image artifacts
+func (imageOs *ImageOs) InstallContainerOs() (versionInfo string, err error) {
+	log.Infof("Installing container root file system for image: %s", imageOs.template.GetImageName())
+	template := containerTemplate(imageOs.template)
+
+	pkgType := imageOs.chrootEnv.GetTargetOsPkgType()
+	if pkgType == "deb" {
+		if err = imageOs.initRootfsForDeb(imageOs.installRoot); err != nil {
+			err = fmt.Errorf("failed to initialize rootfs for deb: %w", err)
+			return
+		}
+	}
+
+	if err = imageOs.mountSysfsToRootfs(imageOs.installRoot); err != nil {
+		return
+	}
+
+	defer func() {
+		if umountErr := imageOs.umountSysfsFromRootfs(imageOs.installRoot); umountErr != nil {
+			if err != nil {
+				err = fmt.Errorf("operation failed: %w, cleanup errors: %v", err, umountErr)
+			} else {
+				err = fmt.Errorf("failed to unmount sysfs from image rootfs: %w", umountErr)
+			}
+		}
+	}()
+
+	log.Infof("Image installation pre-processing...")
+	if err = preImageOsInstall(imageOs.installRoot, template); err != nil {
+		err = fmt.Errorf("pre-install failed: %w", err)
+		return
+	}
+
+	log.Infof("Image package installation...")
+	if err = imageOs.installImagePkgs(imageOs.installRoot, template); err != nil {
+		err = fmt.Errorf("failed to install image packages: %w", err)
+		return
+	}
+
+	installed, err := imageOs.packageSizes(imageOs.installRoot, pkgType)
+	if err != nil {
+		err = fmt.Errorf("failed to list installed packages: %w", err)
+		return
+	}
+	pkgNames := make([]string, 0, len(installed))
+	for _, pkg := range installed {
+		pkgNames = append(pkgNames, pkg.Name)
+	}
+
+	log.Infof("Image system configuration...")
+	if err = updateContainerConfig(imageOs.installRoot, template, pkgNames); err != nil {
+		err = fmt.Errorf("failed to update image config: %w", err)
+		return
+	}
+	if err = updateImageLocalization(imageOs.installRoot, pkgType, template); err != nil {
+		err = fmt.Errorf("failed to update image localization: %w", err)
+		return
+	}
+
+	log.Infof("Post rootfs hook execution...")
+	if err = hook.HookPostRootfs(imageOs.installRoot, template); err != nil {
+		err = fmt.Errorf("Hook post-rootfs failed: %v", err)
+		return
+	}
+
+	if err = imageOs.reportImageSize(imageOs.installRoot, pkgType, nil); err != nil {
+		err = fmt.Errorf("failed to check image size: %w", err)
+		return
+	}
+
+	log.Infof("Image installation post-processing...")
+	versionInfo, err = imageOs.postImageOsInstall(imageOs.installRoot, template)
+	if err != nil {
+		err = fmt.Errorf("post-install failed: %w", err)
+		return
+	}
+
+	return
+}
+
+// containerTemplate returns a copy of the template without the kernel and
+// bootloader packages
+func containerTemplate(template *config.ImageTemplate) *config.ImageTemplate {
+	containerTmpl := *template
+	containerTmpl.KernelPkgList = nil
+	containerTmpl.BootloaderPkgList = nil
+	containerTmpl.SystemConfig.Packages = nil
+	for _, pkg := range template.SystemConfig.Packages {
+		if isBootPackage(pkg) {
+			log.Debugf("Skipping bootloader package %s in container root file system", pkg)
+			continue
+		}
+		containerTmpl.SystemConfig.Packages = append(containerTmpl.SystemConfig.Packages, pkg)
+	}
+	return &containerTmpl
+}
+
+// isBootPackage returns whether a package installs a bootloader
+func isBootPackage(pkg string) bool {
+	for _, prefix := range containerSkippedPkgPrefixes {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateContainerConfig applies the system configuration that belongs in a
+// container. The container runtime provides the hostname, network, mounts and
+// resolv.conf, and there is no kernel or disk to configure. The SBOM lists the
+// installed packages pkgNames only.
+func updateContainerConfig(installRoot string, template *config.ImageTemplate, pkgNames []string) error {
+	if err := addImageAdditionalFiles(installRoot, template); err != nil {
+		return fmt.Errorf("failed to add additional files to image: %w", err)
+	}
+	if err := manifest.WriteContainerSBOM(installRoot, pkgNames); err != nil {
+		log.Warnf("failed to copy SBOM into image filesystem: %v", err)
+	}
+	if err := updateImageUsrGroup(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image user/group: %w", err)
+	}
+	if err := updateImageSSH(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image sshd config: %w", err)
+	}
+	if err := updateImageServices(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image services: %w", err)
+	}
+	if err := addImageIDFile(installRoot, template); err != nil {
+		return fmt.Errorf("failed to add image ID file: %w", err)
+	}
+	if err := updateImageTimezone(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image timezone: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package imageos
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+)
+
+func TestContainerTemplate(t *testing.T) {
+	template := &config.ImageTemplate{
+		SystemConfig: config.SystemConfig{
+			Name:     "container",
+			Packages: []string{"ca-certificates", "grub-efi-amd64-bin", "shim-signed", "systemd-boot", "efibootmgr", "python3"},
+		},
+		KernelPkgList:     []string{"linux-image-generic"},
+		BootloaderPkgList: []string{"grub-pc"},
+	}
+
+	containerTmpl := containerTemplate(template)
+	if containerTmpl.KernelPkgList != nil || containerTmpl.BootloaderPkgList != nil {
+		t.Errorf("expected no kernel or bootloader packages, got %v and %v",
+			containerTmpl.KernelPkgList, containerTmpl.BootloaderPkgList)
+	}
+	expected := []string{"ca-certificates", "python3"}
+	if !reflect.DeepEqual(containerTmpl.SystemConfig.Packages, expected) {
+		t.Errorf("expected packages %v, got %v", expected, containerTmpl.SystemConfig.Packages)
+	}
+	if len(template.SystemConfig.Packages) != 6 || len(template.KernelPkgList) != 1 {
+		t.Error("expected the original template to be left unchanged")
+	}
+}
@@ -0,0 +1,103 @@
+package imageos
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	growPartitionScript  = "/usr/local/sbin/grow-partition.sh"
+	growPartitionService = "grow-partition.service"
+)
+
+// updateImageGrowPartition installs a first boot service that expands the
+// growing partition and its filesystem to the end of the disk
+func updateImageGrowPartition(installRoot string, template *config.ImageTemplate) error {
+	partition, ok := imagedisc.GrowPartition(template.GetDiskConfig())
+	if !ok {
+		return nil
+	}
+	if err := imagedisc.CheckPartitionSizing(template); err != nil {
+		return err
+	}
+	log.Infof("Adding first boot growth of partition %s", partition.ID)
+
+	if err := writeImageFile(installRoot, growPartitionScript, renderGrowPartitionScript(partition)); err != nil {
+		return err
+	}
+	scriptPath := filepath.Join(installRoot, growPartitionScript)
+	if _, err := shell.ExecCmd("chmod 755 "+scriptPath, true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", scriptPath, err)
+	}
+
+	unitFile := filepath.Join("/etc/systemd/system", growPartitionService)
+	if err := writeImageFile(installRoot, unitFile, renderGrowPartitionUnit()); err != nil {
+		return err
+	}
+	cmd := "systemctl --root=\"" + installRoot + "\" enable " + growPartitionService
+	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to enable %s: %v", growPartitionService, err)
+		return fmt.Errorf("failed to enable %s: %w", growPartitionService, err)
+	}
+	return nil
+}
+
+// renderGrowPartitionScript returns a script that grows the partition mounted
+// at the partition mount point, then its filesystem, and disables itself
+func renderGrowPartitionScript(partition config.PartitionInfo) string {
+	growFs := `resize2fs "$part_dev"`
+	switch partition.FsType {
+	case "xfs":
+		growFs = `xfs_growfs "$mount_point"`
+	case imagedisc.FsTypeBtrfs:
+		growFs = `btrfs filesystem resize max "$mount_point"`
+	}
+	lines := []string{
+		"#!/bin/sh",
+		"# Generated by os-image-composer: grows partition " + partition.ID + " to the end of the disk",
+		"set -e",
+		"mount_point=" + shellQuote(partition.MountPoint),
+		`part_dev=$(findmnt -n -v -o SOURCE --target "$mount_point")`,
+		`part_name=$(basename "$part_dev")`,
+		`part_num=$(cat "/sys/class/block/$part_name/partition")`,
+		`disk_dev="/dev/$(basename "$(readlink -f "/sys/class/block/$part_name/..")")"`,
+		`if sfdisk --dump "$disk_dev" | grep -q '^label: gpt'; then`,
+		`    sfdisk --relocate gpt-bak-std "$disk_dev"`,
+		"fi",
+		`echo ', +' | sfdisk --force --no-reread -N "$part_num" "$disk_dev"`,
+		`partx --update --nr "$part_num" "$disk_dev"`,
+		growFs,
+		"systemctl disable " + growPartitionService,
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func renderGrowPartitionUnit() string {
+	lines := []string{
+		"[Unit]",
+		"Description=Grow the last partition to the end of the disk",
+		"DefaultDependencies=no",
+		"After=local-fs.target",
+		"Before=sysinit.target shutdown.target",
+		"Conflicts=shutdown.target",
+		"",
+		"[Service]",
+		"Type=oneshot",
+		"ExecStart=" + growPartitionScript,
+		"RemainAfterExit=true",
+		"",
+		"[Install]",
+		"WantedBy=sysinit.target",
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// shellQuote quotes value as a single shell word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
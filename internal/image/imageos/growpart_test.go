@@ -0,0 +1,63 @@
+package imageos
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func TestRenderGrowPartitionScript(t *testing.T) {
+	script := renderGrowPartitionScript(config.PartitionInfo{ID: "rootfs", FsType: "ext4", MountPoint: "/"})
+	for _, want := range []string{"mount_point='/'", "sfdisk --force --no-reread -N", `resize2fs "$part_dev"`, "systemctl disable grow-partition.service"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected %q in script:\n%s", want, script)
+		}
+	}
+
+	script = renderGrowPartitionScript(config.PartitionInfo{ID: "data", FsType: "xfs", MountPoint: "/srv/it's"})
+	if !strings.Contains(script, `xfs_growfs "$mount_point"`) || !strings.Contains(script, `mount_point='/srv/it'\''s'`) {
+		t.Errorf("expected an xfs grow of the quoted mount point, got:\n%s", script)
+	}
+
+	script = renderGrowPartitionScript(config.PartitionInfo{ID: "rootfs", FsType: "btrfs", MountPoint: "/"})
+	if !strings.Contains(script, `btrfs filesystem resize max "$mount_point"`) {
+		t.Errorf("expected a btrfs grow of the mount point, got:\n%s", script)
+	}
+}
+
+func TestUpdateImageGrowPartition(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No growing partition is a no-op
+	if err := updateImageGrowPartition(installRoot, template); err != nil {
+		t.Errorf("expected no error without a growing partition, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(installRoot, growPartitionScript)); !os.IsNotExist(err) {
+		t.Error("expected no grow script without a growing partition")
+	}
+
+	template.Disk.Partitions = []config.PartitionInfo{
+		{ID: "boot", FsType: "vfat", MountPoint: "/boot/efi"},
+		{ID: "rootfs", FsType: "ext4", MountPoint: "/", Grow: true},
+	}
+	if err := updateImageGrowPartition(installRoot, template); err != nil {
+		t.Fatalf("updateImageGrowPartition failed: %v", err)
+	}
+
+	template.Disk.Partitions[1].FsType = "vfat"
+	if err := updateImageGrowPartition(installRoot, template); err == nil {
+		t.Error("expected an error for a growing vfat partition")
+	}
+}
@@ -29,6 +29,8 @@ type ImageOsInterface interface {
 	GetInstallRoot() string
 	InstallInitrd() (installRoot, versionInfo string, err error)
 	InstallImageOs(diskPathIdMap map[string]string) (versionInfo string, err error)
+	InstallLiveOs() (versionInfo string, err error)
+	InstallContainerOs() (versionInfo string, err error)
 }
 
 type ImageOs struct {
@@ -40,6 +42,23 @@ type ImageOs struct {
 
 var log = logger.Logger()
 
+const (
+	// sshdDropInFile sorts early so its settings win over distribution drop-ins
+	sshdDropInFile  = "/etc/ssh/sshd_config.d/10-os-image-composer.conf"
+	sshdIncludeLine = "Include /etc/ssh/sshd_config.d/*.conf"
+
+	// defaultUKIName names the UKI of images without boot entries
+	defaultUKIName = "linux"
+
+	systemdAdminUnitDir = "/etc/systemd/system"
+	systemdPresetFile   = "/etc/systemd/system-preset/10-os-image-composer.preset"
+)
+
+// systemdUnitDirs are searched for units referenced by systemConfig.services
+var systemdUnitDirs = []string{systemdAdminUnitDir, "/usr/lib/systemd/system", "/lib/systemd/system"}
+
+var sshdIncludeRe = regexp.MustCompile(`(?m)^\s*Include\s+/etc/ssh/sshd_config\.d/`)
+
 func NewImageOs(chrootEnv chroot.ChrootEnvInterface, template *config.ImageTemplate) (*ImageOs, error) {
 	chrootImageBuildDir := chrootEnv.GetChrootImageBuildDir()
 	if _, err := os.Stat(chrootImageBuildDir); os.IsNotExist(err) {
@@ -115,6 +134,11 @@ func (imageOs *ImageOs) InstallInitrd() (installRoot, versionInfo string, err er
 		return
 	}
 
+	if err = imageOs.reportImageSize(imageOs.installRoot, pkgType, nil); err != nil {
+		err = fmt.Errorf("failed to check image size: %w", err)
+		return
+	}
+
 	log.Infof("Image installation post-processing...")
 	versionInfo, err = imageOs.postImageOsInstall(imageOs.installRoot, imageOs.template)
 	if err != nil {
@@ -180,6 +204,10 @@ func (imageOs *ImageOs) InstallImageOs(diskPathIdMap map[string]string) (version
 		err = fmt.Errorf("failed to update image config: %w", err)
 		return
 	}
+	if err = updateImageLocalization(imageOs.installRoot, pkgType, imageOs.template); err != nil {
+		err = fmt.Errorf("failed to update image localization: %w", err)
+		return
+	}
 
 	// Add post rootfs hook call here
 	log.Infof("Post rootfs hook execution...")
@@ -210,6 +238,11 @@ func (imageOs *ImageOs) InstallImageOs(diskPathIdMap map[string]string) (version
 		return
 	}
 
+	if err = imageOs.reportImageSize(imageOs.installRoot, pkgType, mountPointInfoList); err != nil {
+		err = fmt.Errorf("failed to check image size: %w", err)
+		return
+	}
+
 	log.Infof("Image installation post-processing...")
 	versionInfo, err = imageOs.postImageOsInstall(imageOs.installRoot, imageOs.template)
 	if err != nil {
@@ -217,6 +250,11 @@ func (imageOs *ImageOs) InstallImageOs(diskPathIdMap map[string]string) (version
 		return
 	}
 
+	if err = snapshotImageSubvolumes(diskPathIdMap, imageOs.template); err != nil {
+		err = fmt.Errorf("failed to snapshot image subvolumes: %w", err)
+		return
+	}
+
 	return
 }
 
@@ -288,9 +326,12 @@ func mountDiskRootToChroot(installRoot string, diskPathIdMap map[string]string,
 	for diskId, diskPath := range diskPathIdMap {
 		for _, partition := range partions {
 			if partition.ID == diskId {
-				if partition.MountPoint == "/" {
-					mountPoint := filepath.Join(installRoot, partition.MountPoint)
-					mountFlags := fmt.Sprintf("-t %s", partition.FsType)
+				for _, partitionMount := range imagedisc.PartitionMounts(partition) {
+					if partitionMount.MountPoint != "/" {
+						continue
+					}
+					mountPoint := filepath.Join(installRoot, partitionMount.MountPoint)
+					mountFlags := partitionMountFlags(partition, partitionMount)
 					if err := mount.MountPath(diskPath, mountPoint, mountFlags); err != nil {
 						log.Errorf("Failed to mount %s to %s: %v", diskPath, mountPoint, err)
 						return fmt.Errorf("failed to mount %s to %s: %w", diskPath, mountPoint, err)
@@ -311,20 +352,14 @@ func (imageOs *ImageOs) mountDiskToChroot(installRoot string, diskPathIdMap map[
 	for diskId, diskPath := range diskPathIdMap {
 		for _, partition := range partions {
 			if partition.ID == diskId {
-				mountPointInfo := make(map[string]string)
-				mountPointInfo["Id"] = diskId
-				mountPointInfo["Path"] = diskPath
-				mountPointInfo["MountPoint"] = filepath.Join(installRoot, partition.MountPoint)
-				if partition.MountPoint == "/boot/efi" {
-					if partition.FsType == "fat32" || partition.FsType == "fat16" {
-						mountPointInfo["Flags"] = fmt.Sprintf("-t %s -o umask=0077", "vfat")
-					} else {
-						mountPointInfo["Flags"] = fmt.Sprintf("-t %s -o umask=0077", partition.FsType)
-					}
-				} else {
-					mountPointInfo["Flags"] = fmt.Sprintf("-t %s", partition.FsType)
+				for _, partitionMount := range imagedisc.PartitionMounts(partition) {
+					mountPointInfo := make(map[string]string)
+					mountPointInfo["Id"] = diskId
+					mountPointInfo["Path"] = diskPath
+					mountPointInfo["MountPoint"] = filepath.Join(installRoot, partitionMount.MountPoint)
+					mountPointInfo["Flags"] = partitionMountFlags(partition, partitionMount)
+					mountPointInfoList = append(mountPointInfoList, mountPointInfo)
 				}
-				mountPointInfoList = append(mountPointInfoList, mountPointInfo)
 			}
 		}
 	}
@@ -357,6 +392,20 @@ func (imageOs *ImageOs) mountDiskToChroot(installRoot string, diskPathIdMap map[
 	return mountPointInfoList, nil
 }
 
+// partitionMountFlags returns the mount flags of a partition mount in the chroot
+func partitionMountFlags(partition config.PartitionInfo, partitionMount imagedisc.PartitionMount) string {
+	if partitionMount.MountPoint == "/boot/efi" {
+		if partition.FsType == "fat32" || partition.FsType == "fat16" {
+			return fmt.Sprintf("-t %s -o umask=0077", "vfat")
+		}
+		return fmt.Sprintf("-t %s -o umask=0077", partition.FsType)
+	}
+	if options := imagedisc.BuildMountOptions(partitionMount); options != "" {
+		return fmt.Sprintf("-t %s -o %s", partition.FsType, options)
+	}
+	return fmt.Sprintf("-t %s", partition.FsType)
+}
+
 func (imageOs *ImageOs) umountDiskFromChroot(installRoot string, mountPointInfoList []map[string]string) error {
 	if err := imageOs.umountSysfsFromRootfs(installRoot); err != nil {
 		return err
@@ -522,6 +571,9 @@ func (imageOs *ImageOs) installImagePkgs(installRoot string, template *config.Im
 		if err := imageOs.initImageRpmDb(installRoot, template); err != nil {
 			return fmt.Errorf("failed to initialize RPM database: %w", err)
 		}
+		if err := preparePkgPrune(installRoot, pkgType, template); err != nil {
+			return fmt.Errorf("failed to prepare file pruning: %w", err)
+		}
 		imagePkgOrderedList := getRpmPkgInstallList(template)
 		imagePkgNum := len(imagePkgOrderedList)
 		// Force to use the local cache repository
@@ -538,6 +590,9 @@ func (imageOs *ImageOs) installImagePkgs(installRoot string, template *config.Im
 		if err := imageOs.initDebLocalRepoWithinInstallRoot(installRoot); err != nil {
 			return fmt.Errorf("failed to initialize local repository within install root: %w", err)
 		}
+		if err := preparePkgPrune(installRoot, pkgType, template); err != nil {
+			return fmt.Errorf("failed to prepare file pruning: %w", err)
+		}
 		imagePkgNum := len(imagePkgOrderedList)
 		// Force to use the local cache repository
 		var repoSrcList []string = []string{"/etc/apt/sources.list.d/local.list"}
@@ -583,6 +638,12 @@ func (imageOs *ImageOs) installImagePkgs(installRoot string, template *config.Im
 	} else {
 		return fmt.Errorf("unsupported package type: %s", pkgType)
 	}
+	if err := imageOs.removeImagePkgs(installRoot, pkgType, template); err != nil {
+		return fmt.Errorf("failed to remove image packages: %w", err)
+	}
+	if err := pruneImageFiles(installRoot, template); err != nil {
+		return fmt.Errorf("failed to prune image files: %w", err)
+	}
 	return nil
 }
 
@@ -596,9 +657,18 @@ func updateInitrdConfig(installRoot string, template *config.ImageTemplate) erro
 	if err := updateImageUsrGroup(installRoot, template); err != nil {
 		return fmt.Errorf("failed to update image user/group: %w", err)
 	}
+	if err := updateImageSSH(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image sshd config: %w", err)
+	}
 	if err := updateImageNetwork(installRoot, template); err != nil {
 		return fmt.Errorf("failed to update image network: %w", err)
 	}
+	if err := updateImageNetworkConfig(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image network config: %w", err)
+	}
+	if err := updateImageServices(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image services: %w", err)
+	}
 	if err := addImageIDFile(installRoot, template); err != nil {
 		return fmt.Errorf("failed to add image ID file: %w", err)
 	}
@@ -615,6 +685,9 @@ func updateImageConfig(installRoot string, diskPathIdMap map[string]string, temp
 	if err := addImageAdditionalFiles(installRoot, template); err != nil {
 		return fmt.Errorf("failed to add additional files to image: %w", err)
 	}
+	if err := manifest.AnnotateSPDXPackages(getBootEntryPackageComments(template)); err != nil {
+		log.Warnf("failed to record boot entries in SBOM: %v", err)
+	}
 	if err := manifest.CopySBOMToChroot(installRoot); err != nil {
 		log.Warnf("failed to copy SBOM into image filesystem: %v", err)
 		// Don't fail the build if SBOM copy fails, just log warning
@@ -622,18 +695,49 @@ func updateImageConfig(installRoot string, diskPathIdMap map[string]string, temp
 	if err := updateImageUsrGroup(installRoot, template); err != nil {
 		return fmt.Errorf("failed to update image user/group: %w", err)
 	}
+	if err := updateImageSSH(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image sshd config: %w", err)
+	}
 	if err := updateImageNetwork(installRoot, template); err != nil {
 		return fmt.Errorf("failed to update image network: %w", err)
 	}
+	if err := updateImageNetworkConfig(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image network config: %w", err)
+	}
+	if err := updateImageServices(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image services: %w", err)
+	}
 	if err := addImageIDFile(installRoot, template); err != nil {
 		return fmt.Errorf("failed to add image ID file: %w", err)
 	}
+	if err := updateImageCloudInit(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image cloud-init: %w", err)
+	}
+	if err := updateImageMemory(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image swap and tmpfs: %w", err)
+	}
 	if err := updateImageFstab(installRoot, diskPathIdMap, template); err != nil {
 		return fmt.Errorf("failed to update image fstab: %w", err)
 	}
+	if err := updateImageGrowPartition(installRoot, template); err != nil {
+		return fmt.Errorf("failed to add partition growth: %w", err)
+	}
 	if err := createResolvConfSymlink(installRoot, template); err != nil {
 		return fmt.Errorf("failed to create resolv.conf: %w", err)
 	}
+	if err := updateImageTimezone(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image timezone: %w", err)
+	}
+	if err := updateImageTime(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image time settings: %w", err)
+	}
+	// Last, as these may regenerate the initramfs from the configuration above
+	if err := updateImageKernelModules(installRoot, template); err != nil {
+		return fmt.Errorf("failed to update image kernel modules: %w", err)
+	}
+	if err := signImageKernelModules(installRoot, template); err != nil {
+		return fmt.Errorf("failed to sign image kernel modules: %w", err)
+	}
 
 	return nil
 }
@@ -709,6 +813,74 @@ func updateImageUsrGroup(installRoot string, template *config.ImageTemplate) err
 	return nil
 }
 
+// updateImageSSH renders the systemConfig.ssh settings into an sshd_config.d drop-in
+func updateImageSSH(installRoot string, template *config.ImageTemplate) error {
+	dropIn := renderSSHDropIn(template.SystemConfig.SSH)
+	if dropIn == "" {
+		return nil
+	}
+	log.Infof("Configuring sshd...")
+
+	sshdConfigPath := filepath.Join(installRoot, "etc", "ssh", "sshd_config")
+	if _, err := os.Stat(sshdConfigPath); err != nil {
+		log.Errorf("sshd_config not found in image, is openssh-server installed?")
+		return fmt.Errorf("sshd_config not found in image: %w", err)
+	}
+	sshdConfig, err := file.Read(sshdConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", sshdConfigPath, err)
+	}
+	if updated, changed := sshdConfigWithInclude(sshdConfig); changed {
+		log.Debugf("Adding sshd_config.d include to %s", sshdConfigPath)
+		if err := file.Write(updated, sshdConfigPath); err != nil {
+			return fmt.Errorf("failed to write %s: %w", sshdConfigPath, err)
+		}
+	}
+
+	dropInPath := filepath.Join(installRoot, sshdDropInFile)
+	if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(dropInPath), true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dropInPath), err)
+	}
+	if err := file.Write(dropIn, dropInPath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dropInPath, err)
+	}
+	if _, err := shell.ExecCmd("chmod 0644 "+dropInPath, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to set permissions for sshd drop-in %s: %v", dropInPath, err)
+		return fmt.Errorf("failed to set permissions for sshd drop-in %s: %w", dropInPath, err)
+	}
+	return nil
+}
+
+// sshdConfigWithInclude prepends the sshd_config.d include when sshd_config lacks it.
+// sshd uses the first value it reads, so the drop-ins must come before the main settings
+func sshdConfigWithInclude(sshdConfig string) (string, bool) {
+	if sshdIncludeRe.MatchString(sshdConfig) {
+		return sshdConfig, false
+	}
+	return sshdIncludeLine + "\n\n" + sshdConfig, true
+}
+
+// renderSSHDropIn returns the sshd_config.d drop-in content, or "" when nothing is configured
+func renderSSHDropIn(sshConfig config.SSHConfig) string {
+	var lines []string
+	if sshConfig.Port != 0 {
+		lines = append(lines, fmt.Sprintf("Port %d", sshConfig.Port))
+	}
+	if sshConfig.DisableRootLogin {
+		lines = append(lines, "PermitRootLogin no")
+	}
+	if sshConfig.DisablePasswordAuth {
+		lines = append(lines, "PasswordAuthentication no", "KbdInteractiveAuthentication no")
+	}
+	if len(sshConfig.Ciphers) > 0 {
+		lines = append(lines, "Ciphers "+strings.Join(sshConfig.Ciphers, ","))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "# Generated by OS Image Composer from systemConfig.ssh\n" + strings.Join(lines, "\n") + "\n"
+}
+
 func updateImageNetwork(installRoot string, template *config.ImageTemplate) error {
 	unitFilePath := filepath.Join(installRoot, "lib", "systemd", "system", "systemd-networkd.service")
 	if _, err := os.Stat(unitFilePath); os.IsNotExist(err) {
@@ -722,6 +894,184 @@ func updateImageNetwork(installRoot string, template *config.ImageTemplate) erro
 	return nil
 }
 
+// updateImageServices writes the inline units, then applies the enable and disable
+// lists as a systemd preset, the masks and the default target in the install root
+func updateImageServices(installRoot string, template *config.ImageTemplate) error {
+	services := template.SystemConfig.Services
+	if len(services.Enable) == 0 && len(services.Disable) == 0 && len(services.Mask) == 0 &&
+		len(services.Units) == 0 && services.DefaultTarget == "" {
+		return nil
+	}
+	log.Infof("Configuring systemd units...")
+
+	if err := checkServiceLists(services); err != nil {
+		return err
+	}
+
+	for _, unit := range services.Units {
+		unitPath := filepath.Join(installRoot, systemdAdminUnitDir, unit.Name)
+		if unit.DropIn != "" {
+			unitPath = filepath.Join(installRoot, systemdAdminUnitDir, unit.Name+".d", unit.DropIn+".conf")
+		}
+		if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(unitPath), true, shell.HostPath, nil); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(unitPath), err)
+		}
+		if err := file.Write(unit.Content, unitPath); err != nil {
+			log.Errorf("Failed to write unit %s: %v", unitPath, err)
+			return fmt.Errorf("failed to write unit %s: %w", unitPath, err)
+		}
+		if _, err := shell.ExecCmd("chmod 0644 "+unitPath, true, shell.HostPath, nil); err != nil {
+			return fmt.Errorf("failed to set permissions for unit %s: %w", unitPath, err)
+		}
+		log.Debugf("Wrote systemd unit %s", unitPath)
+	}
+
+	var referenced []string
+	referenced = append(referenced, services.Enable...)
+	referenced = append(referenced, services.Disable...)
+	referenced = append(referenced, services.Mask...)
+	if services.DefaultTarget != "" {
+		referenced = append(referenced, services.DefaultTarget)
+	}
+	var missing []string
+	for _, unit := range referenced {
+		if !unitFileExists(installRoot, unit) {
+			missing = append(missing, unit)
+		}
+	}
+	if len(missing) > 0 {
+		log.Errorf("systemd units not found in image: %s", strings.Join(missing, ", "))
+		return fmt.Errorf("systemd units not found in image, is the providing package installed: %s",
+			strings.Join(missing, ", "))
+	}
+
+	systemctl := "systemctl --root=\"" + installRoot + "\""
+	if preset := renderServicePreset(services); preset != "" {
+		presetPath := filepath.Join(installRoot, systemdPresetFile)
+		if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(presetPath), true, shell.HostPath, nil); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(presetPath), err)
+		}
+		if err := file.Write(preset, presetPath); err != nil {
+			return fmt.Errorf("failed to write systemd preset %s: %w", presetPath, err)
+		}
+		units := append(append([]string{}, services.Enable...), services.Disable...)
+		cmd := systemctl + " preset " + strings.Join(units, " ")
+		if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to apply systemd preset: %v", err)
+			return fmt.Errorf("failed to apply systemd preset: %w", err)
+		}
+	}
+	if len(services.Mask) > 0 {
+		cmd := systemctl + " mask " + strings.Join(services.Mask, " ")
+		if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to mask systemd units: %v", err)
+			return fmt.Errorf("failed to mask systemd units: %w", err)
+		}
+	}
+	if services.DefaultTarget != "" {
+		cmd := systemctl + " set-default " + services.DefaultTarget
+		if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to set default target %s: %v", services.DefaultTarget, err)
+			return fmt.Errorf("failed to set default target %s: %w", services.DefaultTarget, err)
+		}
+	}
+	return nil
+}
+
+// checkServiceLists rejects units listed in more than one of enable, disable and mask
+func checkServiceLists(services config.ServicesConfig) error {
+	listed := make(map[string]string)
+	for _, list := range []struct {
+		name  string
+		units []string
+	}{
+		{"enable", services.Enable},
+		{"disable", services.Disable},
+		{"mask", services.Mask},
+	} {
+		for _, unit := range list.units {
+			if other, ok := listed[unit]; ok && other != list.name {
+				return fmt.Errorf("systemd unit %s is listed in both %s and %s", unit, other, list.name)
+			}
+			listed[unit] = list.name
+		}
+	}
+	return nil
+}
+
+// renderServicePreset returns the preset file for the enable and disable lists.
+// Template instances are written as "<action> name@.service instance"
+func renderServicePreset(services config.ServicesConfig) string {
+	var lines []string
+	for _, list := range []struct {
+		action string
+		units  []string
+	}{
+		{"enable", services.Enable},
+		{"disable", services.Disable},
+	} {
+		for _, unit := range list.units {
+			if template, instance := unitTemplate(unit); instance != "" {
+				lines = append(lines, fmt.Sprintf("%s %s %s", list.action, template, instance))
+			} else {
+				lines = append(lines, list.action+" "+unit)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "# Generated by OS Image Composer from systemConfig.services\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// unitTemplate returns the template unit and instance of an instantiated unit
+// such as getty@tty1.service, or the unit itself and "" otherwise
+func unitTemplate(unit string) (template, instance string) {
+	at := strings.Index(unit, "@")
+	dot := strings.LastIndex(unit, ".")
+	if at < 0 || dot < at {
+		return unit, ""
+	}
+	return unit[:at+1] + unit[dot:], unit[at+1 : dot]
+}
+
+// unitFileExists reports whether the unit, or the template of an instance, exists in the install root
+func unitFileExists(installRoot, unit string) bool {
+	template, _ := unitTemplate(unit)
+	for _, dir := range systemdUnitDirs {
+		for _, name := range []string{unit, template} {
+			if _, err := os.Lstat(filepath.Join(installRoot, dir, name)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func updateImageTimezone(installRoot string, template *config.ImageTemplate) error {
+	timezone := template.SystemConfig.Localization.Timezone
+	if timezone == "" {
+		return nil
+	}
+	log.Infof("Configuring timezone: %s", timezone)
+	zoneInfoPath := filepath.Join("/usr/share/zoneinfo", timezone)
+	if _, err := os.Stat(filepath.Join(installRoot, zoneInfoPath)); err != nil {
+		log.Errorf("Timezone %s not found in image, is tzdata installed?", timezone)
+		return fmt.Errorf("timezone %s not found in image: %w", timezone, err)
+	}
+	localtimePath := filepath.Join(installRoot, "etc", "localtime")
+	cmd := fmt.Sprintf("ln -sf ..%s %s", zoneInfoPath, localtimePath)
+	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to link %s to %s: %v", localtimePath, zoneInfoPath, err)
+		return fmt.Errorf("failed to link %s to %s: %w", localtimePath, zoneInfoPath, err)
+	}
+	timezoneFilePath := filepath.Join(installRoot, "etc", "timezone")
+	if err := file.Write(timezone+"\n", timezoneFilePath); err != nil {
+		return fmt.Errorf("failed to write timezone to %s: %w", timezoneFilePath, err)
+	}
+	return nil
+}
+
 func addImageIDFile(installRoot string, template *config.ImageTemplate) error {
 	log.Infof("Adding image ID file for image: %s", template.GetImageName())
 	imageIDFilePath := filepath.Join(installRoot, "etc", "image-id")
@@ -783,45 +1133,76 @@ func updateImageFstab(installRoot string, diskPathIdMap map[string]string, templ
 					return fmt.Errorf("failed to get partition UUID for %s: %w", diskPath, err)
 				}
 				mountId := fmt.Sprintf("PARTUUID=%s", partUUID)
-				mountPoint := partition.MountPoint
 
 				// Get the filesystem type
-				var fsType, options, pass string
+				var fsType string
 				if partition.FsType == "fat16" || partition.FsType == "fat32" {
 					fsType = "vfat"
 				} else {
 					fsType = partition.FsType
 				}
 
-				// Get the mount options
-				options = defaultOptions
-				if partition.MountOptions != "" {
-					options = partition.MountOptions
-				}
+				// A btrfs partition gets one entry per mounted subvolume
+				for _, partitionMount := range imagedisc.PartitionMounts(partition) {
+					mountPoint := partitionMount.MountPoint
+					options := partitionMount.Options
+					if options == "" {
+						options = defaultOptions
+					}
 
-				// Get the default dump and pass values
-				pass = defaultPass
-				if mountPoint == rootfsMountPoint {
-					pass = rootPass
-				}
+					// Get the default dump and pass values
+					pass := defaultPass
+					if mountPoint == rootfsMountPoint {
+						pass = rootPass
+					}
 
-				if fsType == swapFsType {
-					// For swap partitions, set the options accordingly
-					options = swapOptions
-					pass = disablePass // No pass value for swap
-				}
+					if fsType == swapFsType {
+						// For swap partitions, set the options accordingly
+						options = swapOptions
+						pass = disablePass // No pass value for swap
+					} else if fsType == imagedisc.FsTypeBtrfs {
+						pass = disablePass // btrfs is not checked by fsck at boot
+					}
 
-				newEntry := fmt.Sprintf("%v %v %v %v %v %v\n",
-					mountId, mountPoint, fsType, options, defaultDump, pass)
-				log.Debugf("Adding fstab entry: %s", newEntry)
-				err = file.Append(newEntry, fstabFullPath)
-				if err != nil {
-					log.Errorf("Failed to append fstab entry for %s: %v", mountPoint, err)
-					return fmt.Errorf("failed to append fstab entry for %s: %w", mountPoint, err)
+					newEntry := fmt.Sprintf("%v %v %v %v %v %v\n",
+						mountId, mountPoint, fsType, options, defaultDump, pass)
+					log.Debugf("Adding fstab entry: %s", newEntry)
+					err = file.Append(newEntry, fstabFullPath)
+					if err != nil {
+						log.Errorf("Failed to append fstab entry for %s: %v", mountPoint, err)
+						return fmt.Errorf("failed to append fstab entry for %s: %w", mountPoint, err)
+					}
 				}
 			}
 		}
 	}
+
+	// Swap file and /tmp tmpfs from systemConfig.memory
+	memoryEntries, err := memoryFstabEntries(template.SystemConfig.Memory)
+	if err != nil {
+		return err
+	}
+	for _, entry := range memoryEntries {
+		log.Debugf("Adding fstab entry: %s", entry)
+		if err := file.Append(entry+"\n", fstabFullPath); err != nil {
+			log.Errorf("Failed to append fstab entry %s: %v", entry, err)
+			return fmt.Errorf("failed to append fstab entry %s: %w", entry, err)
+		}
+	}
+	return nil
+}
+
+// snapshotImageSubvolumes takes the read-only snapshots of the installed btrfs subvolumes
+func snapshotImageSubvolumes(diskPathIdMap map[string]string, template *config.ImageTemplate) error {
+	for _, partition := range template.GetDiskConfig().Partitions {
+		diskPath, ok := diskPathIdMap[partition.ID]
+		if !ok || partition.Snapshot == "" {
+			continue
+		}
+		if err := imagedisc.SnapshotBtrfsPartition(diskPath, partition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -846,24 +1227,18 @@ func createResolvConfSymlink(installRoot string, template *config.ImageTemplate)
 func buildImageUKI(installRoot string, template *config.ImageTemplate) error {
 	bootloaderConfig := template.GetBootloaderConfig()
 	if bootloaderConfig.Provider == "systemd-boot" {
-		// 1. Update initramfs
-		kernelVersion, err := getKernelVersion(installRoot)
+		entries, err := imageboot.ResolveBootEntries(installRoot, template)
 		if err != nil {
-			return fmt.Errorf("failed to get kernel version: %w", err)
+			return fmt.Errorf("failed to resolve boot entries: %w", err)
 		}
-
-		log.Debugf("Kernel version:%s", kernelVersion)
-
-		if err := updateInitramfs(installRoot, kernelVersion, template); err != nil {
-			return fmt.Errorf("failed to update initramfs: %w", err)
+		if len(entries) == 0 {
+			kernelVersion, err := getKernelVersion(installRoot)
+			if err != nil {
+				return fmt.Errorf("failed to get kernel version: %w", err)
+			}
+			entries = []imageboot.BootEntry{{Name: defaultUKIName, KernelVersion: kernelVersion, Default: true}}
 		}
 
-		log.Debug("Initramfs updated successfully")
-
-		// 2. Build UKI with ukify
-		kernelPath := filepath.Join("/boot", "vmlinuz-"+kernelVersion)
-		initrdPath := fmt.Sprintf("/boot/initramfs-%s.img", kernelVersion)
-
 		espRoot := installRoot
 		espDir, err := prepareESPDir(espRoot)
 		if err != nil {
@@ -871,14 +1246,27 @@ func buildImageUKI(installRoot string, template *config.ImageTemplate) error {
 		}
 		log.Debugf("Succesfully Creating EspPath:", espDir)
 
-		outputPath := filepath.Join(espDir, "EFI", "Linux", "linux.efi")
-		log.Debugf("UKI Path:", outputPath)
+		updatedInitramfs := make(map[string]bool)
+		for _, entry := range entries {
+			// 1. Update initramfs
+			log.Debugf("Kernel version:%s", entry.KernelVersion)
+			if !updatedInitramfs[entry.KernelVersion] {
+				if err := updateInitramfs(installRoot, entry.KernelVersion, template); err != nil {
+					return fmt.Errorf("failed to update initramfs: %w", err)
+				}
+				updatedInitramfs[entry.KernelVersion] = true
+				log.Debug("Initramfs updated successfully")
+			}
 
-		cmdlineFile := filepath.Join("/boot", "cmdline.conf")
-		if err := buildUKI(installRoot, kernelPath, initrdPath, cmdlineFile, outputPath, template); err != nil {
-			return fmt.Errorf("failed to build UKI: %w", err)
+			// 2. Build UKI with ukify
+			if err := buildEntryUKI(installRoot, espDir, entry, template); err != nil {
+				return err
+			}
+		}
+
+		if err := writeLoaderConfig(installRoot, espDir, entries, template); err != nil {
+			return err
 		}
-		log.Debugf("UKI created successfully on:", outputPath)
 
 		// 3. Copy systemd-bootx64.efi to ESP/EFI/BOOT/BOOTX64.EFI
 		srcBootloader := filepath.Join("usr", "lib", "systemd", "boot", "efi", "systemd-bootx64.efi")
@@ -894,6 +1282,102 @@ func buildImageUKI(installRoot string, template *config.ImageTemplate) error {
 	return nil
 }
 
+// renderLoaderConfig returns the loader.conf content for the boot entries
+func renderLoaderConfig(entries []imageboot.BootEntry, timeout int) string {
+	var loaderConfig strings.Builder
+	for _, entry := range entries {
+		if entry.Default {
+			fmt.Fprintf(&loaderConfig, "default %s.efi\n", entry.Name)
+		}
+	}
+	fmt.Fprintf(&loaderConfig, "timeout %d\n", timeout)
+	return loaderConfig.String()
+}
+
+// getBootEntryPackageComments returns SBOM comments naming the boot entries
+// each kernel package serves; entries without packages boot the kernel packages
+func getBootEntryPackageComments(template *config.ImageTemplate) map[string]string {
+	kernelConfig := template.GetKernel()
+	defaultEntry := kernelConfig.GetDefaultEntry()
+	entryNames := make(map[string][]string)
+	var pkgs []string
+	for _, entry := range kernelConfig.Entries {
+		name := entry.Name
+		if entry.Name == defaultEntry.Name {
+			name += " (default)"
+		}
+		entryPkgs := entry.Packages
+		if len(entryPkgs) == 0 {
+			entryPkgs = kernelConfig.Packages
+		}
+		for _, pkg := range entryPkgs {
+			if _, ok := entryNames[pkg]; !ok {
+				pkgs = append(pkgs, pkg)
+			}
+			entryNames[pkg] = append(entryNames[pkg], name)
+		}
+	}
+
+	comments := make(map[string]string, len(pkgs))
+	for _, pkg := range pkgs {
+		comments[pkg] = "Kernel of boot entries: " + strings.Join(entryNames[pkg], ", ")
+	}
+	return comments
+}
+
+// buildEntryUKI builds the UKI of a boot entry, with the entry's parameters
+// appended to the kernel command line
+func buildEntryUKI(installRoot, espDir string, entry imageboot.BootEntry, template *config.ImageTemplate) error {
+	kernelPath := filepath.Join("/boot", "vmlinuz-"+entry.KernelVersion)
+	initrdPath := fmt.Sprintf("/boot/initramfs-%s.img", entry.KernelVersion)
+
+	outputPath := filepath.Join(espDir, "EFI", "Linux", entry.Name+".efi")
+	log.Debugf("UKI Path:", outputPath)
+
+	cmdlineFile := filepath.Join("/boot", "cmdline.conf")
+	if entry.Cmdline != "" {
+		cmdline, err := file.Read(filepath.Join(installRoot, cmdlineFile))
+		if err != nil {
+			log.Errorf("Failed to read cmdline file %s: %v", cmdlineFile, err)
+			return fmt.Errorf("failed to read cmdline file: %w", err)
+		}
+		cmdlineFile = filepath.Join("/boot", "cmdline-"+entry.Name+".conf")
+		if err := file.Write(strings.TrimSpace(cmdline)+" "+entry.Cmdline, filepath.Join(installRoot, cmdlineFile)); err != nil {
+			log.Errorf("Failed to write cmdline file %s: %v", cmdlineFile, err)
+			return fmt.Errorf("failed to write cmdline file: %w", err)
+		}
+	}
+
+	if err := buildUKI(installRoot, kernelPath, initrdPath, cmdlineFile, outputPath, template); err != nil {
+		return fmt.Errorf("failed to build UKI: %w", err)
+	}
+	log.Debugf("UKI created successfully on:", outputPath)
+
+	if template.GetKernel().PCRSigning.IsEnabled() {
+		if err := writePCRPrediction(filepath.Join(installRoot, outputPath), pcrPredictionFileName(entry), template); err != nil {
+			return fmt.Errorf("failed to predict UKI PCR values: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeLoaderConfig writes the systemd-boot loader.conf selecting the default
+// UKI and the menu timeout. Without boot entries or timeout, systemd-boot
+// boots the only UKI and no loader.conf is needed.
+func writeLoaderConfig(installRoot, espDir string, entries []imageboot.BootEntry, template *config.ImageTemplate) error {
+	timeout := template.GetBootloaderConfig().GetTimeout()
+	if len(template.GetKernel().Entries) == 0 && timeout == 0 {
+		return nil
+	}
+
+	loaderConfigPath := filepath.Join(installRoot, espDir, "loader", "loader.conf")
+	if err := file.Write(renderLoaderConfig(entries, timeout), loaderConfigPath); err != nil {
+		log.Errorf("Failed to write loader.conf: %v", err)
+		return fmt.Errorf("failed to write loader.conf: %w", err)
+	}
+	return nil
+}
+
 // Helper to get the current kernel version from the rootfs
 func getKernelVersion(installRoot string) (string, error) {
 	kernelDir := filepath.Join(installRoot, "boot")
@@ -1153,8 +1637,15 @@ func buildUKI(installRoot, kernelPath, initrdPath, cmdlineFile, outputPath strin
 	var cmd string
 	var backInstallRoot = installRoot
 	exists, _ := shell.IsCommandExist("ukify", installRoot)
-	if !exists {
-		log.Debugf("Ukify not found, running ukify on host")
+	// The PCR signing key stays on the host, so ukify signing with it runs there
+	pcrSigning := template.GetKernel().PCRSigning.IsEnabled()
+	if pcrSigning {
+		if hostUkify, _ := shell.IsCommandExist("ukify", shell.HostPath); !hostUkify {
+			return fmt.Errorf("PCR signing needs ukify on the build host")
+		}
+	}
+	if !exists || pcrSigning {
+		log.Debugf("Running ukify on host")
 		kernelPath = filepath.Join(installRoot, kernelPath)
 		initrdPath = filepath.Join(installRoot, initrdPath)
 		outputPath = filepath.Join(installRoot, outputPath)
@@ -1169,6 +1660,10 @@ func buildUKI(installRoot, kernelPath, initrdPath, cmdlineFile, outputPath strin
 			osRelease,
 			outputPath,
 		)
+		// Build with the stub of the image, like ukify in the chroot would
+		if stub := imageUKIStub(backInstallRoot); exists && stub != "" {
+			cmd += fmt.Sprintf(" --stub \"%s\"", stub)
+		}
 
 	} else {
 		cmd = fmt.Sprintf(
@@ -1180,6 +1675,12 @@ func buildUKI(installRoot, kernelPath, initrdPath, cmdlineFile, outputPath strin
 		)
 	}
 
+	pcrSigningArgs, err := ukiPCRSigningArgs(template)
+	if err != nil {
+		return err
+	}
+	cmd += pcrSigningArgs
+
 	log.Debugf("UKI Executing command:", cmd)
 	if template.IsImmutabilityEnabled() {
 		// Set TMPDIR environment variable to use the mounted tmpfs
@@ -1296,6 +1797,17 @@ func createUser(installRoot string, template *config.ImageTemplate) error {
 			return fmt.Errorf("user verification failed for %s: %w", user.Name, err)
 		}
 
+		sshKeys, err := template.GetUserSSHKeys(user)
+		if err != nil {
+			return fmt.Errorf("failed to collect SSH keys for user %s: %w", user.Name, err)
+		}
+		user.SSHKeys = sshKeys
+		if len(user.SSHKeys) > 0 {
+			if err := configUserSSHKeys(installRoot, user); err != nil {
+				return fmt.Errorf("failed to configure SSH keys for user %s: %w", user.Name, err)
+			}
+		}
+
 		if user.StartupScript != "" {
 			if err := configUserStartupScript(installRoot, user); err != nil {
 				return fmt.Errorf("failed to configure startup script for user %s: %w", user.Name, err)
@@ -1405,30 +1917,31 @@ func setUserPassword(installRoot string, user config.UserConfig) error {
 	return nil
 }
 
-// Helper function to hash password using specified algorithm
+// Helper function to hash password using specified algorithm. The password is
+// passed on stdin so it never shows up in a command line or the debug log.
 func hashPassword(password, hashAlgo, installRoot string) (string, error) {
 	var cmd string
 
 	switch strings.ToLower(hashAlgo) {
 	case "sha512":
 		// Use openssl to generate SHA-512 hash
-		cmd = fmt.Sprintf("openssl passwd -6 '%s'", password)
+		cmd = "openssl passwd -6 -stdin"
 	case "sha256":
 		// Use openssl to generate SHA-256 hash
-		cmd = fmt.Sprintf("openssl passwd -5 '%s'", password)
+		cmd = "openssl passwd -5 -stdin"
 	case "md5":
 		// Use openssl to generate MD5 hash (not recommended for production)
-		cmd = fmt.Sprintf("openssl passwd -1 '%s'", password)
+		cmd = "openssl passwd -1 -stdin"
 	case "bcrypt":
 		// Use python3 to generate bcrypt hash
-		pythonScript := fmt.Sprintf("import bcrypt; print(bcrypt.hashpw(b'%s', bcrypt.gensalt()).decode())", password)
+		pythonScript := "import bcrypt, sys; print(bcrypt.hashpw(sys.stdin.buffer.readline().rstrip(b'\\n'), bcrypt.gensalt()).decode())"
 		cmd = fmt.Sprintf("python3 -c \"%s\"", pythonScript)
 	default:
 		return "", fmt.Errorf("unsupported hash algorithm: %s", hashAlgo)
 	}
 
 	log.Debugf("Hashing password with algorithm %s", hashAlgo)
-	output, err := shell.ExecCmd(cmd, true, installRoot, nil)
+	output, err := shell.ExecCmdWithInput(password+"\n", cmd, true, installRoot, nil)
 	if err != nil {
 		log.Errorf("Failed to hash password with algorithm %s: %v", hashAlgo, err)
 		return "", fmt.Errorf("failed to hash password with algorithm %s: %w", hashAlgo, err)
@@ -1464,3 +1977,42 @@ func configUserStartupScript(installRoot string, user config.UserConfig) error {
 	}
 	return nil
 }
+
+// userHomeDir returns the home directory of a user within the image
+func userHomeDir(user config.UserConfig) string {
+	if user.Home != "" {
+		return user.Home
+	}
+	if user.Name == "root" {
+		return "/root"
+	}
+	return filepath.Join("/home", user.Name)
+}
+
+func configUserSSHKeys(installRoot string, user config.UserConfig) error {
+	log.Infof("Configuring %d SSH key(s) for user '%s'", len(user.SSHKeys), user.Name)
+
+	sshDir := filepath.Join(userHomeDir(user), ".ssh")
+	authorizedKeys := filepath.Join(sshDir, "authorized_keys")
+	if _, err := shell.ExecCmd("mkdir -p "+filepath.Join(installRoot, sshDir), true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create %s for user %s: %v", sshDir, user.Name, err)
+		return fmt.Errorf("failed to create %s for user %s: %w", sshDir, user.Name, err)
+	}
+	if err := file.Write(strings.Join(user.SSHKeys, "\n")+"\n", filepath.Join(installRoot, authorizedKeys)); err != nil {
+		log.Errorf("Failed to write %s for user %s: %v", authorizedKeys, user.Name, err)
+		return fmt.Errorf("failed to write %s for user %s: %w", authorizedKeys, user.Name, err)
+	}
+
+	// sshd refuses keys with loose ownership or permissions, so fix them up inside the image
+	for _, cmd := range []string{
+		fmt.Sprintf("chown -R %s: %s", user.Name, sshDir),
+		"chmod 0700 " + sshDir,
+		"chmod 0600 " + authorizedKeys,
+	} {
+		if _, err := shell.ExecCmd(cmd, true, installRoot, nil); err != nil {
+			log.Errorf("Failed to set SSH key permissions for user %s: %v", user.Name, err)
+			return fmt.Errorf("failed to set SSH key permissions for user %s: %w", user.Name, err)
+		}
+	}
+	return nil
+}
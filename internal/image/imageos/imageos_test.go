@@ -10,6 +10,9 @@ import (
 
 	"github.com/open-edge-platform/os-image-composer/internal/chroot"
 	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imageboot"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
 )
 
@@ -3102,7 +3105,7 @@ func TestSetUserPassword_HashAlgo(t *testing.T) {
 	defer func() { shell.Default = originalExecutor }()
 
 	mockCommands := []shell.MockCommand{
-		{Pattern: "openssl passwd -6 'password'", Output: "$6$generatedhash", Error: nil},
+		{Pattern: "openssl passwd -6 -stdin", Output: "$6$generatedhash", Error: nil},
 		{Pattern: "usermod -p .* testuser", Output: "", Error: nil},
 	}
 	shell.Default = shell.NewMockExecutor(mockCommands)
@@ -3124,9 +3127,9 @@ func TestHashPassword(t *testing.T) {
 	defer func() { shell.Default = originalExecutor }()
 
 	mockCommands := []shell.MockCommand{
-		{Pattern: "openssl passwd -6 'password'", Output: "$6$hash", Error: nil},
-		{Pattern: "openssl passwd -5 'password'", Output: "$5$hash", Error: nil},
-		{Pattern: "openssl passwd -1 'password'", Output: "$1$hash", Error: nil},
+		{Pattern: "openssl passwd -6 -stdin", Output: "$6$hash", Error: nil},
+		{Pattern: "openssl passwd -5 -stdin", Output: "$5$hash", Error: nil},
+		{Pattern: "openssl passwd -1 -stdin", Output: "$1$hash", Error: nil},
 		{Pattern: "python3 -c .*", Output: "$2b$hash", Error: nil},
 	}
 	shell.Default = shell.NewMockExecutor(mockCommands)
@@ -3150,6 +3153,49 @@ func TestHashPassword(t *testing.T) {
 	}
 }
 
+func TestHashPasswordNotLogged(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	logPath := filepath.Join(t.TempDir(), "install.log")
+	_, cleanup, err := logger.InitWithConfig(logger.Config{Level: "info", FilePath: logPath, FileLevel: "debug"})
+	if err != nil {
+		t.Fatalf("failed to configure log file: %v", err)
+	}
+
+	const password = "Plaintext-Secret-42"
+	installRoot := t.TempDir()
+	for _, algo := range []string{"sha512", "sha256", "md5", "bcrypt"} {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "openssl passwd -[651] -stdin$", Output: "$6$hash"},
+			{Pattern: "python3 -c .*sys.stdin", Output: "$2b$hash"},
+			{Pattern: ".*", Error: fmt.Errorf("unexpected command")},
+		})
+		if _, err := hashPassword(password, algo, installRoot); err != nil {
+			t.Errorf("hashPassword failed for %s: %v", algo, err)
+		}
+
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: ".*", Error: fmt.Errorf("exit status 1")},
+		})
+		if _, err := hashPassword(password, algo, installRoot); err == nil || strings.Contains(err.Error(), password) {
+			t.Errorf("expected error without the password for %s, got %v", algo, err)
+		}
+	}
+	cleanup()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "Hashing password") {
+		t.Fatalf("expected debug messages in the log file, got %s", data)
+	}
+	if strings.Contains(string(data), password) {
+		t.Errorf("password leaked into the log file:\n%s", data)
+	}
+}
+
 func TestConfigUserStartupScript(t *testing.T) {
 	originalExecutor := shell.Default
 	defer func() { shell.Default = originalExecutor }()
@@ -3201,3 +3247,331 @@ func TestConfigUserStartupScript(t *testing.T) {
 	// or use a real sed if available and not requiring sudo.
 	// But file.ReplaceRegexInFile forces sudo.
 }
+
+// useTestTempDir points the global temp directory used by file.Write at a test directory
+func useTestTempDir(t *testing.T) {
+	originalGlobal := config.Global()
+	testGlobal := config.DefaultGlobalConfig()
+	testGlobal.TempDir = t.TempDir()
+	config.SetGlobal(testGlobal)
+	t.Cleanup(func() { config.SetGlobal(originalGlobal) })
+}
+
+func TestConfigUserSSHKeys(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	useTestTempDir(t)
+
+	user := config.UserConfig{
+		Name:    "admin",
+		SSHKeys: []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample admin@example.com"},
+	}
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	if err := configUserSSHKeys(t.TempDir(), user); err != nil {
+		t.Errorf("configUserSSHKeys failed: %v", err)
+	}
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "chown", Output: "", Error: fmt.Errorf("invalid user")},
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	if err := configUserSSHKeys(t.TempDir(), user); err == nil {
+		t.Error("expected error when ownership cannot be set")
+	}
+}
+
+func TestUserHomeDir(t *testing.T) {
+	tests := []struct {
+		user     config.UserConfig
+		expected string
+	}{
+		{config.UserConfig{Name: "admin"}, "/home/admin"},
+		{config.UserConfig{Name: "root"}, "/root"},
+		{config.UserConfig{Name: "svc", Home: "/srv/svc"}, "/srv/svc"},
+	}
+	for _, tt := range tests {
+		if got := userHomeDir(tt.user); got != tt.expected {
+			t.Errorf("userHomeDir(%s) = %s, want %s", tt.user.Name, got, tt.expected)
+		}
+	}
+}
+
+func TestUpdateImageTimezone(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No timezone configured is a no-op
+	if err := updateImageTimezone(installRoot, template); err != nil {
+		t.Errorf("expected no error without timezone, got: %v", err)
+	}
+
+	template.SystemConfig.Localization.Timezone = "Asia/Singapore"
+	if err := updateImageTimezone(installRoot, template); err == nil {
+		t.Error("expected error when zoneinfo is missing from the image")
+	}
+
+	zoneFile := filepath.Join(installRoot, "usr", "share", "zoneinfo", "Asia", "Singapore")
+	if err := os.MkdirAll(filepath.Dir(zoneFile), 0755); err != nil {
+		t.Fatalf("failed to create zoneinfo directory: %v", err)
+	}
+	if err := os.WriteFile(zoneFile, []byte("TZif"), 0644); err != nil {
+		t.Fatalf("failed to create zoneinfo file: %v", err)
+	}
+	if err := updateImageTimezone(installRoot, template); err != nil {
+		t.Errorf("updateImageTimezone failed: %v", err)
+	}
+}
+
+func TestRenderSSHDropIn(t *testing.T) {
+	if got := renderSSHDropIn(config.SSHConfig{}); got != "" {
+		t.Errorf("expected no drop-in without settings, got %q", got)
+	}
+
+	got := renderSSHDropIn(config.SSHConfig{
+		DisablePasswordAuth: true,
+		DisableRootLogin:    true,
+		Ciphers:             []string{"chacha20-poly1305@openssh.com", "aes256-gcm@openssh.com"},
+		Port:                2222,
+	})
+	for _, line := range []string{
+		"Port 2222",
+		"PermitRootLogin no",
+		"PasswordAuthentication no",
+		"KbdInteractiveAuthentication no",
+		"Ciphers chacha20-poly1305@openssh.com,aes256-gcm@openssh.com",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("expected drop-in to contain %q, got:\n%s", line, got)
+		}
+	}
+}
+
+func TestSshdConfigWithInclude(t *testing.T) {
+	updated, changed := sshdConfigWithInclude("PasswordAuthentication yes\n")
+	if !changed || !strings.HasPrefix(updated, sshdIncludeLine+"\n") {
+		t.Errorf("expected the drop-in include first in sshd_config, got:\n%s", updated)
+	}
+
+	existing := "Include /etc/ssh/sshd_config.d/*.conf\nPasswordAuthentication yes\n"
+	if updated, changed := sshdConfigWithInclude(existing); changed || updated != existing {
+		t.Errorf("expected an existing include to be left alone, got:\n%s", updated)
+	}
+}
+
+func TestUpdateImageSSH(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No ssh settings is a no-op
+	if err := updateImageSSH(installRoot, template); err != nil {
+		t.Errorf("expected no error without ssh settings, got: %v", err)
+	}
+
+	template.SystemConfig.SSH = config.SSHConfig{DisablePasswordAuth: true}
+	if err := updateImageSSH(installRoot, template); err == nil {
+		t.Error("expected error when sshd_config is missing from the image")
+	}
+
+	sshdConfigPath := filepath.Join(installRoot, "etc", "ssh", "sshd_config")
+	if err := os.MkdirAll(filepath.Dir(sshdConfigPath), 0755); err != nil {
+		t.Fatalf("failed to create ssh directory: %v", err)
+	}
+	if err := os.WriteFile(sshdConfigPath, []byte("PasswordAuthentication yes\n"), 0644); err != nil {
+		t.Fatalf("failed to write sshd_config: %v", err)
+	}
+	if err := updateImageSSH(installRoot, template); err != nil {
+		t.Errorf("updateImageSSH failed: %v", err)
+	}
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "chmod", Output: "", Error: fmt.Errorf("permission denied")},
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	if err := updateImageSSH(installRoot, template); err == nil {
+		t.Error("expected error when drop-in permissions cannot be set")
+	}
+}
+
+func TestUnitTemplate(t *testing.T) {
+	tests := []struct {
+		unit, template, instance string
+	}{
+		{"sshd.service", "sshd.service", ""},
+		{"getty@tty1.service", "getty@.service", "tty1"},
+		{"serial-getty@ttyS0.service", "serial-getty@.service", "ttyS0"},
+		{"getty@.service", "getty@.service", ""},
+	}
+	for _, tt := range tests {
+		template, instance := unitTemplate(tt.unit)
+		if template != tt.template || instance != tt.instance {
+			t.Errorf("unitTemplate(%s) = (%s, %s), want (%s, %s)", tt.unit, template, instance, tt.template, tt.instance)
+		}
+	}
+}
+
+func TestRenderServicePreset(t *testing.T) {
+	if got := renderServicePreset(config.ServicesConfig{Mask: []string{"ctrl-alt-del.target"}}); got != "" {
+		t.Errorf("expected no preset without enable or disable lists, got %q", got)
+	}
+
+	got := renderServicePreset(config.ServicesConfig{
+		Enable:  []string{"sshd.service", "serial-getty@ttyS0.service"},
+		Disable: []string{"apt-daily.timer"},
+	})
+	for _, line := range []string{
+		"enable sshd.service",
+		"enable serial-getty@.service ttyS0",
+		"disable apt-daily.timer",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("expected preset to contain %q, got:\n%s", line, got)
+		}
+	}
+}
+
+func TestCheckServiceLists(t *testing.T) {
+	if err := checkServiceLists(config.ServicesConfig{
+		Enable: []string{"sshd.service"},
+		Mask:   []string{"ctrl-alt-del.target"},
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkServiceLists(config.ServicesConfig{
+		Enable:  []string{"sshd.service"},
+		Disable: []string{"sshd.service"},
+	}); err == nil {
+		t.Error("expected error for a unit both enabled and disabled")
+	}
+}
+
+func TestUpdateImageServices(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No services configured is a no-op
+	if err := updateImageServices(installRoot, template); err != nil {
+		t.Errorf("expected no error without services, got: %v", err)
+	}
+
+	template.SystemConfig.Services = config.ServicesConfig{
+		Enable:        []string{"sshd.service", "serial-getty@ttyS0.service"},
+		Mask:          []string{"ctrl-alt-del.target"},
+		DefaultTarget: "multi-user.target",
+	}
+	err := updateImageServices(installRoot, template)
+	if err == nil {
+		t.Fatal("expected error when referenced units are missing from the image")
+	}
+	for _, unit := range []string{"sshd.service", "serial-getty@ttyS0.service", "multi-user.target"} {
+		if !strings.Contains(err.Error(), unit) {
+			t.Errorf("expected missing unit %s in error, got: %v", unit, err)
+		}
+	}
+
+	unitDir := filepath.Join(installRoot, "usr", "lib", "systemd", "system")
+	if err := os.MkdirAll(unitDir, 0755); err != nil {
+		t.Fatalf("failed to create unit directory: %v", err)
+	}
+	for _, unit := range []string{"sshd.service", "serial-getty@.service", "ctrl-alt-del.target", "multi-user.target"} {
+		if err := os.WriteFile(filepath.Join(unitDir, unit), []byte("[Unit]\n"), 0644); err != nil {
+			t.Fatalf("failed to write unit %s: %v", unit, err)
+		}
+	}
+	if err := updateImageServices(installRoot, template); err != nil {
+		t.Errorf("updateImageServices failed: %v", err)
+	}
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "systemctl .* mask", Output: "", Error: fmt.Errorf("unit not found")},
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	if err := updateImageServices(installRoot, template); err == nil {
+		t.Error("expected error when masking fails")
+	}
+}
+
+func TestPartitionMountFlags(t *testing.T) {
+	partition := config.PartitionInfo{
+		ID:         "rootfs",
+		FsType:     "btrfs",
+		MountPoint: "/",
+		Subvolumes: []config.BtrfsSubvolume{
+			{Name: "@", MountPoint: "/", MountOptions: "compress=zstd"},
+			{Name: "@home", MountPoint: "/home", MountOptions: "noatime"},
+		},
+	}
+	mounts := imagedisc.PartitionMounts(partition)
+	if flags := partitionMountFlags(partition, mounts[0]); flags != "-t btrfs -o subvol=@,compress=zstd" {
+		t.Errorf("unexpected root subvolume mount flags: %s", flags)
+	}
+	if flags := partitionMountFlags(partition, mounts[1]); flags != "-t btrfs -o subvol=@home" {
+		t.Errorf("unexpected home subvolume mount flags: %s", flags)
+	}
+
+	efi := config.PartitionInfo{ID: "efi", FsType: "fat32", MountPoint: "/boot/efi"}
+	if flags := partitionMountFlags(efi, imagedisc.PartitionMounts(efi)[0]); flags != "-t vfat -o umask=0077" {
+		t.Errorf("unexpected ESP mount flags: %s", flags)
+	}
+	ext4 := config.PartitionInfo{ID: "data", FsType: "ext4", MountPoint: "/data", MountOptions: "noatime"}
+	if flags := partitionMountFlags(ext4, imagedisc.PartitionMounts(ext4)[0]); flags != "-t ext4" {
+		t.Errorf("unexpected ext4 mount flags: %s", flags)
+	}
+}
+
+func TestRenderLoaderConfig(t *testing.T) {
+	entries := []imageboot.BootEntry{
+		{Name: "generic", KernelVersion: "6.12.8-1.emt3"},
+		{Name: "rt", KernelVersion: "6.12.8-rt-1.emt3", Default: true},
+	}
+	if got := renderLoaderConfig(entries, 5); got != "default rt.efi\ntimeout 5\n" {
+		t.Errorf("unexpected loader.conf %q", got)
+	}
+}
+
+func TestGetBootEntryPackageComments(t *testing.T) {
+	template := createTestImageTemplate()
+	template.SystemConfig.Kernel.Packages = []string{"kernel"}
+	template.SystemConfig.Kernel.Entries = []config.KernelEntry{
+		{Name: "generic"},
+		{Name: "rt", Packages: []string{"kernel-rt"}, Default: true},
+		{Name: "debug", Cmdline: "debug"},
+	}
+
+	comments := getBootEntryPackageComments(template)
+	expected := map[string]string{
+		"kernel":    "Kernel of boot entries: generic, debug",
+		"kernel-rt": "Kernel of boot entries: rt (default)",
+	}
+	if !reflect.DeepEqual(comments, expected) {
+		t.Errorf("expected comments %v, got %v", expected, comments)
+	}
+
+	template.SystemConfig.Kernel.Entries = nil
+	if comments := getBootEntryPackageComments(template); len(comments) != 0 {
+		t.Errorf("expected no comments without boot entries, got %v", comments)
+	}
+}
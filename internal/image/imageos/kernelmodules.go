@@ -0,0 +1,255 @@
+package imageos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+)
+
+const (
+	modulesLoadFile         = "/etc/modules-load.d/10-os-image-composer.conf"
+	modprobeFile            = "/etc/modprobe.d/10-os-image-composer.conf"
+	dracutModulesFile       = "/etc/dracut.conf.d/10-os-image-composer.conf"
+	initramfsToolsModules   = "/etc/initramfs-tools/modules"
+	updateInitramfsTool     = "/usr/sbin/update-initramfs"
+	kernelModulesStamp      = "# Generated by OS Image Composer from systemConfig.kernel.modules\n"
+	initramfsToolsStamp     = "# Added by OS Image Composer from systemConfig.kernel.modules.initramfs"
+	sysctlFile              = "/etc/sysctl.d/90-os-image-composer.conf"
+	sysctlStamp             = "# Generated by OS Image Composer from systemConfig.sysctl\n"
+	systemdBootProviderName = "systemd-boot"
+)
+
+// dracutTools are the dracut paths of the supported distributions
+var dracutTools = []string{"/usr/bin/dracut", "/usr/sbin/dracut"}
+
+// updateImageKernelModules writes the module load, blacklist and option settings
+// and the sysctl parameters, and rebuilds the initramfs when early modules change
+func updateImageKernelModules(installRoot string, template *config.ImageTemplate) error {
+	modules := template.SystemConfig.Kernel.Modules
+	sysctl := template.SystemConfig.Sysctl
+	if isKernelModulesEmpty(modules) && len(sysctl) == 0 {
+		return nil
+	}
+	log.Infof("Configuring kernel modules and parameters...")
+
+	if err := checkKernelModules(modules); err != nil {
+		return err
+	}
+
+	files := make(map[string]string)
+	if content := renderModulesLoad(modules); content != "" {
+		files[modulesLoadFile] = content
+	}
+	if content := renderModprobe(modules); content != "" {
+		files[modprobeFile] = content
+	}
+	if len(sysctl) > 0 {
+		files[sysctlFile] = renderSysctl(sysctl)
+	}
+	if len(modules.Initramfs) > 0 || len(modules.Blacklist) > 0 {
+		if dracutInstalled(installRoot) {
+			files[dracutModulesFile] = renderDracutModules(modules)
+		}
+	}
+	for _, configFile := range sortedKeys(files) {
+		if err := writeImageFile(installRoot, configFile, files[configFile]); err != nil {
+			return err
+		}
+	}
+
+	if len(modules.Initramfs) > 0 {
+		if err := addInitramfsToolsModules(installRoot, modules.Initramfs); err != nil {
+			return err
+		}
+	}
+
+	if len(modules.Initramfs) > 0 || len(modules.Blacklist) > 0 || len(modules.Options) > 0 {
+		// The UKI build regenerates the initramfs with these settings
+		if template.GetBootloaderConfig().Provider != systemdBootProviderName {
+			if err := regenerateInitramfs(installRoot); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isKernelModulesEmpty(modules config.KernelModules) bool {
+	return len(modules.Load) == 0 && len(modules.Initramfs) == 0 &&
+		len(modules.Blacklist) == 0 && len(modules.Options) == 0
+}
+
+// checkKernelModules rejects modules that are both loaded and blacklisted
+func checkKernelModules(modules config.KernelModules) error {
+	var conflicts []string
+	for _, module := range modules.Blacklist {
+		module = config.NormalizeModuleName(module)
+		for _, loaded := range append(append([]string{}, modules.Load...), modules.Initramfs...) {
+			if config.NormalizeModuleName(loaded) == module && !slice.Contains(conflicts, module) {
+				conflicts = append(conflicts, module)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		log.Errorf("Kernel modules both loaded and blacklisted: %s", strings.Join(conflicts, ", "))
+		return fmt.Errorf("kernel modules both loaded and blacklisted: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// renderModulesLoad returns the modules-load.d content loading the boot and
+// initramfs modules, or an empty string when there are none
+func renderModulesLoad(modules config.KernelModules) string {
+	load := uniqueModuleNames(append(append([]string{}, modules.Load...), modules.Initramfs...))
+	if len(load) == 0 {
+		return ""
+	}
+	return kernelModulesStamp + strings.Join(load, "\n") + "\n"
+}
+
+// renderModprobe returns the modprobe.d content with the blacklist and module
+// options, or an empty string when there are none
+func renderModprobe(modules config.KernelModules) string {
+	var lines []string
+	for _, module := range uniqueModuleNames(modules.Blacklist) {
+		lines = append(lines, "blacklist "+module)
+	}
+	for _, module := range sortedKeys(modules.Options) {
+		lines = append(lines, fmt.Sprintf("options %s %s", config.NormalizeModuleName(module),
+			strings.TrimSpace(modules.Options[module])))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return kernelModulesStamp + strings.Join(lines, "\n") + "\n"
+}
+
+// renderDracutModules returns the dracut.conf.d content adding the initramfs
+// modules and leaving out the blacklisted ones
+func renderDracutModules(modules config.KernelModules) string {
+	content := kernelModulesStamp
+	if initramfs := uniqueModuleNames(modules.Initramfs); len(initramfs) > 0 {
+		content += fmt.Sprintf("add_drivers+=\" %s \"\n", strings.Join(initramfs, " "))
+	}
+	if blacklist := uniqueModuleNames(modules.Blacklist); len(blacklist) > 0 {
+		content += fmt.Sprintf("omit_drivers+=\" %s \"\n", strings.Join(blacklist, " "))
+	}
+	return content
+}
+
+// renderSysctl returns the sysctl.d content, sorted by key
+func renderSysctl(sysctl map[string]string) string {
+	content := sysctlStamp
+	for _, key := range sortedKeys(sysctl) {
+		content += fmt.Sprintf("%s = %s\n", key, strings.TrimSpace(sysctl[key]))
+	}
+	return content
+}
+
+// uniqueModuleNames returns the normalized module names without duplicates
+func uniqueModuleNames(modules []string) []string {
+	var unique []string
+	for _, module := range modules {
+		module = config.NormalizeModuleName(module)
+		if module != "" && !slice.Contains(unique, module) {
+			unique = append(unique, module)
+		}
+	}
+	return unique
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeImageFile writes a configuration file into the image, creating its directory
+func writeImageFile(installRoot, configFile, content string) error {
+	filePath := filepath.Join(installRoot, configFile)
+	if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(filePath), true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(filePath), err)
+	}
+	if err := file.Write(content, filePath); err != nil {
+		log.Errorf("Failed to write %s: %v", filePath, err)
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	log.Debugf("Wrote %s", filePath)
+	return nil
+}
+
+// addInitramfsToolsModules appends the initramfs modules missing from the
+// initramfs-tools module list, when the image uses initramfs-tools
+func addInitramfsToolsModules(installRoot string, modules []string) error {
+	modulesPath := filepath.Join(installRoot, initramfsToolsModules)
+	if _, err := os.Stat(modulesPath); err != nil {
+		return nil
+	}
+	content, err := file.Read(modulesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", modulesPath, err)
+	}
+	listed := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			listed[config.NormalizeModuleName(fields[0])] = true
+		}
+	}
+	var missing []string
+	for _, module := range uniqueModuleNames(modules) {
+		if !listed[module] {
+			missing = append(missing, module)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += initramfsToolsStamp + "\n" + strings.Join(missing, "\n") + "\n"
+	if err := file.Write(content, modulesPath); err != nil {
+		log.Errorf("Failed to write %s: %v", modulesPath, err)
+		return fmt.Errorf("failed to write %s: %w", modulesPath, err)
+	}
+	return nil
+}
+
+func dracutInstalled(installRoot string) bool {
+	for _, tool := range dracutTools {
+		if _, err := os.Stat(filepath.Join(installRoot, tool)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// regenerateInitramfs rebuilds the initramfs of every installed kernel with the
+// tool the image ships
+func regenerateInitramfs(installRoot string) error {
+	var cmd string
+	if _, err := os.Stat(filepath.Join(installRoot, updateInitramfsTool)); err == nil {
+		cmd = "update-initramfs -u -k all"
+	} else if dracutInstalled(installRoot) {
+		cmd = "dracut --force --regenerate-all"
+	} else {
+		log.Warnf("No initramfs tool found in image, kernel module settings apply after the root mount only")
+		return nil
+	}
+	log.Infof("Regenerating initramfs for kernel module settings...")
+	if _, err := shell.ExecCmd(cmd, true, installRoot, nil); err != nil {
+		log.Errorf("Failed to regenerate initramfs: %v", err)
+		return fmt.Errorf("failed to regenerate initramfs: %w", err)
+	}
+	return nil
+}
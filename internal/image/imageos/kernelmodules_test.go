@@ -0,0 +1,102 @@
+package imageos
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func TestCheckKernelModules(t *testing.T) {
+	valid := config.KernelModules{Load: []string{"br_netfilter"}, Blacklist: []string{"nouveau"}}
+	if err := checkKernelModules(valid); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	conflicting := config.KernelModules{Initramfs: []string{"br-netfilter"}, Blacklist: []string{"br_netfilter"}}
+	if err := checkKernelModules(conflicting); err == nil || !strings.Contains(err.Error(), "br_netfilter") {
+		t.Errorf("expected an error naming the conflicting module, got: %v", err)
+	}
+}
+
+func TestRenderKernelModuleFiles(t *testing.T) {
+	modules := config.KernelModules{
+		Load:      []string{"br-netfilter", "overlay"},
+		Initramfs: []string{"nvme", "overlay"},
+		Blacklist: []string{"nouveau"},
+		Options:   map[string]string{"kvm-intel": "nested=1", "bonding": "max_bonds=0"},
+	}
+
+	expected := kernelModulesStamp + "br_netfilter\noverlay\nnvme\n"
+	if got := renderModulesLoad(modules); got != expected {
+		t.Errorf("expected modules-load.d %q, got %q", expected, got)
+	}
+
+	expected = kernelModulesStamp + "blacklist nouveau\noptions bonding max_bonds=0\noptions kvm_intel nested=1\n"
+	if got := renderModprobe(modules); got != expected {
+		t.Errorf("expected modprobe.d %q, got %q", expected, got)
+	}
+
+	expected = kernelModulesStamp + "add_drivers+=\" nvme overlay \"\nomit_drivers+=\" nouveau \"\n"
+	if got := renderDracutModules(modules); got != expected {
+		t.Errorf("expected dracut.conf.d %q, got %q", expected, got)
+	}
+
+	if got := renderModulesLoad(config.KernelModules{}); got != "" {
+		t.Errorf("expected no modules-load.d content, got %q", got)
+	}
+	if got := renderModprobe(config.KernelModules{Load: []string{"overlay"}}); got != "" {
+		t.Errorf("expected no modprobe.d content, got %q", got)
+	}
+}
+
+func TestRenderSysctl(t *testing.T) {
+	sysctl := map[string]string{"vm.swappiness": "0", "net.ipv4.ip_forward": " 1 "}
+	expected := sysctlStamp + "net.ipv4.ip_forward = 1\nvm.swappiness = 0\n"
+	if got := renderSysctl(sysctl); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestUpdateImageKernelModules(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No modules or sysctl settings configured is a no-op
+	if err := updateImageKernelModules(installRoot, template); err != nil {
+		t.Errorf("expected no error without settings, got: %v", err)
+	}
+
+	template.SystemConfig.Kernel.Modules = config.KernelModules{
+		Load:      []string{"overlay"},
+		Blacklist: []string{"overlay"},
+	}
+	if err := updateImageKernelModules(installRoot, template); err == nil {
+		t.Error("expected an error for a module both loaded and blacklisted")
+	}
+
+	template.SystemConfig.Kernel.Modules = config.KernelModules{
+		Load:      []string{"br_netfilter"},
+		Initramfs: []string{"nvme"},
+	}
+	template.SystemConfig.Sysctl = map[string]string{"net.ipv4.ip_forward": "1"}
+	if err := os.MkdirAll(filepath.Join(installRoot, "usr", "sbin"), 0755); err != nil {
+		t.Fatalf("failed to create sbin directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(installRoot, "usr", "sbin", "dracut"), nil, 0755); err != nil {
+		t.Fatalf("failed to write dracut: %v", err)
+	}
+	if err := updateImageKernelModules(installRoot, template); err != nil {
+		t.Errorf("updateImageKernelModules failed: %v", err)
+	}
+}
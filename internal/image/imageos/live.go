@@ -0,0 +1,240 @@
+package imageos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/hook"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	// dracutModulesDir holds the dracut modules installed in the image
+	dracutModulesDir = "/usr/lib/dracut/modules.d"
+
+	// dracutLiveModule finds the squashfs on the boot media and mounts it as
+	// the root file system; the dracut-live package installs it
+	dracutLiveModule = "dmsquash-live"
+
+	// dracutOverlayfsModule sets up the overlayfs writable layer; older dracut
+	// versions have it in dmsquash-live
+	dracutOverlayfsModule = "overlayfs"
+
+	// dracutLivenetModule downloads the squashfs of a root=live: URL; the
+	// dracut-live package installs it
+	dracutLivenetModule = "livenet"
+)
+
+// InstallLiveOs installs the image OS into the install root without a disk,
+// for a live ISO that boots the root file system from a squashfs
+func (imageOs *ImageOs) InstallLiveOs() (versionInfo string, err error) {
+	log.Infof("Installing live OS for image: %s", imageOs.template.GetImageName())
+
+	if err = checkLiveConfig(imageOs.template); err != nil {
+		return
+	}
+
+	pkgType := imageOs.chrootEnv.GetTargetOsPkgType()
+	if pkgType == "deb" {
+		if err = imageOs.initRootfsForDeb(imageOs.installRoot); err != nil {
+			err = fmt.Errorf("failed to initialize rootfs for deb: %w", err)
+			return
+		}
+	}
+
+	if err = imageOs.mountSysfsToRootfs(imageOs.installRoot); err != nil {
+		return
+	}
+
+	defer func() {
+		if umountErr := imageOs.umountSysfsFromRootfs(imageOs.installRoot); umountErr != nil {
+			if err != nil {
+				err = fmt.Errorf("operation failed: %w, cleanup errors: %v", err, umountErr)
+			} else {
+				err = fmt.Errorf("failed to unmount sysfs from image rootfs: %w", umountErr)
+			}
+		}
+	}()
+
+	log.Infof("Image installation pre-processing...")
+	if err = preImageOsInstall(imageOs.installRoot, imageOs.template); err != nil {
+		err = fmt.Errorf("pre-install failed: %w", err)
+		return
+	}
+
+	log.Infof("Image package installation...")
+	if err = imageOs.installImagePkgs(imageOs.installRoot, imageOs.template); err != nil {
+		err = fmt.Errorf("failed to install image packages: %w", err)
+		return
+	}
+
+	log.Infof("Image system configuration...")
+	if err = updateImageConfig(imageOs.installRoot, nil, imageOs.template); err != nil {
+		err = fmt.Errorf("failed to update image config: %w", err)
+		return
+	}
+	if err = updateImageLocalization(imageOs.installRoot, pkgType, imageOs.template); err != nil {
+		err = fmt.Errorf("failed to update image localization: %w", err)
+		return
+	}
+
+	log.Infof("Post rootfs hook execution...")
+	if err = hook.HookPostRootfs(imageOs.installRoot, imageOs.template); err != nil {
+		err = fmt.Errorf("Hook post-rootfs failed: %v", err)
+		return
+	}
+
+	log.Infof("Building live initramfs...")
+	if err = buildLiveInitramfs(imageOs.installRoot, imageOs.template); err != nil {
+		err = fmt.Errorf("failed to build live initramfs: %w", err)
+		return
+	}
+
+	if err = imageOs.reportImageSize(imageOs.installRoot, pkgType, nil); err != nil {
+		err = fmt.Errorf("failed to check image size: %w", err)
+		return
+	}
+
+	log.Infof("Image installation post-processing...")
+	versionInfo, err = imageOs.postImageOsInstall(imageOs.installRoot, imageOs.template)
+	if err != nil {
+		err = fmt.Errorf("post-install failed: %w", err)
+		return
+	}
+
+	return
+}
+
+// checkLiveConfig rejects the settings a live system cannot use
+func checkLiveConfig(template *config.ImageTemplate) error {
+	if template.SystemConfig.Memory.SwapFile.Size != "" {
+		return fmt.Errorf("a live image cannot have a swap file on its overlay root, use zram instead")
+	}
+	if len(template.GetKernel().Entries) > 0 {
+		log.Warnf("Kernel boot entries are ignored in a live image, it boots the first installed kernel")
+	}
+	return nil
+}
+
+// buildLiveInitramfs regenerates the initramfs of the kernel with the dracut
+// live modules. Its output replaces the initramfs the ISO boots.
+func buildLiveInitramfs(installRoot string, template *config.ImageTemplate) error {
+	if !dracutModuleInstalled(installRoot, dracutLiveModule) {
+		log.Errorf("Dracut module %s not found in image", dracutLiveModule)
+		return fmt.Errorf("live image needs the dracut %s module, is the dracut-live package installed", dracutLiveModule)
+	}
+	modules := []string{dracutLiveModule}
+	if dracutModuleInstalled(installRoot, dracutOverlayfsModule) {
+		modules = append(modules, dracutOverlayfsModule)
+	}
+	if template.Target.ImageType == "netboot" {
+		if !dracutModuleInstalled(installRoot, dracutLivenetModule) {
+			log.Errorf("Dracut module %s not found in image", dracutLivenetModule)
+			return fmt.Errorf("netboot image needs the dracut %s module, is the dracut-live package installed", dracutLivenetModule)
+		}
+		modules = append(modules, dracutLivenetModule)
+	}
+
+	kernelVersion, err := getKernelVersion(installRoot)
+	if err != nil {
+		return err
+	}
+	cmd := liveDracutCmd(kernelVersion, modules, template.SystemConfig.Kernel.EnableExtraModules)
+	if _, err := shell.ExecCmd(cmd, true, installRoot, nil); err != nil {
+		log.Errorf("Failed to build live initramfs: %v", err)
+		return fmt.Errorf("failed to build live initramfs: %w", err)
+	}
+	return nil
+}
+
+// GetLiveBootFiles returns the paths in the install root of the kernel and the
+// live initramfs the ISO boots
+func GetLiveBootFiles(installRoot string) (kernelPath, initrdPath string, err error) {
+	kernelVersion, err := getKernelVersion(installRoot)
+	if err != nil {
+		return "", "", err
+	}
+	kernelPath = filepath.Join(installRoot, "boot", "vmlinuz-"+kernelVersion)
+	initrdPath = filepath.Join(installRoot, liveInitrdPath(kernelVersion))
+	return kernelPath, initrdPath, nil
+}
+
+// CreateLiveSquashfs compresses the image rootfs into the squashfs the live
+// system boots
+func CreateLiveSquashfs(template *config.ImageTemplate, rootfsPath, squashfsPath string) error {
+	compression := template.SystemConfig.Live.GetCompression()
+	log.Infof("Creating %s compressed squashfs of the image rootfs...", compression)
+	cmdStr := fmt.Sprintf("mksquashfs %s %s -noappend -comp %s", rootfsPath, squashfsPath, compression)
+	if _, err := shell.ExecCmdWithStream(cmdStr, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create live squashfs: %v", err)
+		return fmt.Errorf("failed to create live squashfs: %w", err)
+	}
+	return nil
+}
+
+// LiveKernelCmdline returns the kernel command line of a live system: the
+// live boot arguments, then the template command line and kernel lockdown
+func LiveKernelCmdline(template *config.ImageTemplate, liveArgs ...string) string {
+	args := append([]string{}, liveArgs...)
+	kernelConfig := template.GetKernel()
+	if cmdline := strings.TrimSpace(kernelConfig.Cmdline); cmdline != "" {
+		args = append(args, cmdline)
+	}
+	// Kernel lockdown only loads the modules signed for Secure Boot
+	if kernelConfig.Lockdown != "" && !strings.Contains(kernelConfig.Cmdline, "lockdown=") {
+		args = append(args, "lockdown="+kernelConfig.Lockdown)
+	}
+	return strings.Join(args, " ")
+}
+
+// BuildLiveUKI builds a UKI of the live kernel and initramfs with the kernel
+// command line built in, at outputPath in the install root
+func BuildLiveUKI(installRoot, cmdline, outputPath string, template *config.ImageTemplate) error {
+	kernelVersion, err := getKernelVersion(installRoot)
+	if err != nil {
+		return err
+	}
+	cmdlineFile := filepath.Join("/boot", "cmdline-live.conf")
+	if err := file.Write(cmdline, filepath.Join(installRoot, cmdlineFile)); err != nil {
+		log.Errorf("Failed to write cmdline file %s: %v", cmdlineFile, err)
+		return fmt.Errorf("failed to write cmdline file: %w", err)
+	}
+	kernelPath := filepath.Join("/boot", "vmlinuz-"+kernelVersion)
+	if err := buildUKI(installRoot, kernelPath, liveInitrdPath(kernelVersion), cmdlineFile, outputPath, template); err != nil {
+		return fmt.Errorf("failed to build live UKI: %w", err)
+	}
+	return nil
+}
+
+// liveInitrdPath returns the image path of the live initramfs of a kernel
+func liveInitrdPath(kernelVersion string) string {
+	return fmt.Sprintf("/boot/initramfs-%s.img", kernelVersion)
+}
+
+// liveDracutCmd returns the dracut command building the live initramfs of a
+// kernel
+func liveDracutCmd(kernelVersion string, modules []string, extraModules string) string {
+	cmdParts := []string{"dracut", "--force", "--no-hostonly"}
+	cmdParts = append(cmdParts, fmt.Sprintf("--add '%s'", strings.Join(modules, " ")))
+	if extraModules = strings.TrimSpace(extraModules); extraModules != "" {
+		cmdParts = append(cmdParts, fmt.Sprintf("--add-drivers '%s'", extraModules))
+	}
+	cmdParts = append(cmdParts, "--kver", kernelVersion)
+	cmdParts = append(cmdParts, liveInitrdPath(kernelVersion))
+	return strings.Join(cmdParts, " ")
+}
+
+// dracutModuleInstalled returns whether the image has a dracut module, in a
+// modules.d directory with any ordering prefix
+func dracutModuleInstalled(installRoot, module string) bool {
+	matches, err := filepath.Glob(filepath.Join(installRoot, dracutModulesDir, "[0-9][0-9]"+module))
+	if err != nil || len(matches) == 0 {
+		return false
+	}
+	info, err := os.Stat(matches[0])
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,145 @@
+package imageos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func writeDracutModule(t *testing.T, installRoot, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(installRoot, dracutModulesDir, dir), 0755); err != nil {
+		t.Fatalf("failed to create dracut module %s: %v", dir, err)
+	}
+}
+
+func TestCheckLiveConfig(t *testing.T) {
+	template := createTestImageTemplate()
+	if err := checkLiveConfig(template); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	template.SystemConfig.Memory.SwapFile.Size = "1GiB"
+	err := checkLiveConfig(template)
+	if err == nil || !strings.Contains(err.Error(), "swap file") {
+		t.Errorf("expected swap file error, got %v", err)
+	}
+}
+
+func TestLiveDracutCmd(t *testing.T) {
+	cmd := liveDracutCmd("6.8.0-45-generic", []string{"dmsquash-live", "overlayfs"}, "")
+	expected := "dracut --force --no-hostonly --add 'dmsquash-live overlayfs' --kver 6.8.0-45-generic /boot/initramfs-6.8.0-45-generic.img"
+	if cmd != expected {
+		t.Errorf("expected %q, got %q", expected, cmd)
+	}
+
+	cmd = liveDracutCmd("6.8.0", []string{"dmsquash-live"}, " usbhid xhci_pci ")
+	if !strings.Contains(cmd, "--add-drivers 'usbhid xhci_pci' --kver 6.8.0") {
+		t.Errorf("expected extra drivers in %q", cmd)
+	}
+}
+
+func TestBuildLiveInitramfs(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	t.Run("MissingLiveModule", func(t *testing.T) {
+		installRoot := t.TempDir()
+		err := buildLiveInitramfs(installRoot, createTestImageTemplate())
+		if err == nil || !strings.Contains(err.Error(), "dracut-live") {
+			t.Errorf("expected missing dracut-live error, got %v", err)
+		}
+	})
+
+	t.Run("WithOverlayfsModule", func(t *testing.T) {
+		installRoot := t.TempDir()
+		writeDracutModule(t, installRoot, "90dmsquash-live")
+		writeDracutModule(t, installRoot, "90overlayfs")
+
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "ls ", Output: "config-6.8.0\nvmlinuz-6.8.0\n"},
+			{Pattern: `dracut --force --no-hostonly --add 'dmsquash-live overlayfs' --kver 6\.8\.0 /boot/initramfs-6\.8\.0\.img$`},
+			{Pattern: "dracut", Error: fmt.Errorf("unexpected dracut command")},
+		})
+		if err := buildLiveInitramfs(installRoot, createTestImageTemplate()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("NetbootNeedsLivenet", func(t *testing.T) {
+		installRoot := t.TempDir()
+		writeDracutModule(t, installRoot, "90dmsquash-live")
+		template := createTestImageTemplate()
+		template.Target.ImageType = "netboot"
+		err := buildLiveInitramfs(installRoot, template)
+		if err == nil || !strings.Contains(err.Error(), "livenet") {
+			t.Errorf("expected missing livenet error, got %v", err)
+		}
+
+		writeDracutModule(t, installRoot, "90livenet")
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "ls ", Output: "vmlinuz-6.8.0\n"},
+			{Pattern: `dracut --force --no-hostonly --add 'dmsquash-live livenet' --kver 6\.8\.0 `},
+			{Pattern: "dracut", Error: fmt.Errorf("unexpected dracut command")},
+		})
+		if err := buildLiveInitramfs(installRoot, template); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("DracutFailure", func(t *testing.T) {
+		installRoot := t.TempDir()
+		writeDracutModule(t, installRoot, "90dmsquash-live")
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "ls ", Output: "vmlinuz-6.8.0\n"},
+			{Pattern: "dracut", Error: fmt.Errorf("dracut failed")},
+		})
+		if err := buildLiveInitramfs(installRoot, createTestImageTemplate()); err == nil {
+			t.Error("expected dracut error")
+		}
+	})
+}
+
+func TestLiveKernelCmdline(t *testing.T) {
+	template := createTestImageTemplate()
+	template.SystemConfig.Kernel.Cmdline = " console=ttyS0 "
+	template.SystemConfig.Kernel.Lockdown = "integrity"
+
+	cmdline := LiveKernelCmdline(template, "root=live:CDLABEL=TEST", "rd.live.image")
+	expected := "root=live:CDLABEL=TEST rd.live.image console=ttyS0 lockdown=integrity"
+	if cmdline != expected {
+		t.Errorf("expected %q, got %q", expected, cmdline)
+	}
+}
+
+func TestGetLiveBootFiles(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "ls ", Output: "initramfs-6.8.0.img\nvmlinuz-6.8.0\n"},
+	})
+
+	kernelPath, initrdPath, err := GetLiveBootFiles("/rootfs")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kernelPath != "/rootfs/boot/vmlinuz-6.8.0" || initrdPath != "/rootfs/boot/initramfs-6.8.0.img" {
+		t.Errorf("unexpected boot files %s, %s", kernelPath, initrdPath)
+	}
+}
+
+func TestDracutModuleInstalled(t *testing.T) {
+	installRoot := t.TempDir()
+	writeDracutModule(t, installRoot, "90dmsquash-live")
+
+	if !dracutModuleInstalled(installRoot, "dmsquash-live") {
+		t.Error("expected dmsquash-live to be installed")
+	}
+	if dracutModuleInstalled(installRoot, "overlayfs") {
+		t.Error("expected overlayfs not to be installed")
+	}
+}
@@ -0,0 +1,316 @@
+package imageos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+)
+
+const (
+	localeSourceDir = "/usr/share/i18n/locales"
+
+	// Debian derivatives keep the regional settings in /etc/default
+	debLocaleGenFile  = "/etc/locale.gen"
+	debLocaleFile     = "/etc/default/locale"
+	debKeyboardFile   = "/etc/default/keyboard"
+	rpmLocaleFile     = "/etc/locale.conf"
+	rpmVconsoleFile   = "/etc/vconsole.conf"
+	timesyncdDropIn   = "/etc/systemd/timesyncd.conf.d/10-os-image-composer.conf"
+	adjtimeFile       = "/etc/adjtime"
+	localizationStamp = "# Generated by OS Image Composer from systemConfig.localization\n"
+	timeStamp         = "# Generated by OS Image Composer from systemConfig.time\n"
+)
+
+// chronyConfigFiles are the chrony configuration paths of DEB and RPM distributions
+var chronyConfigFiles = []string{"/etc/chrony/chrony.conf", "/etc/chrony.conf"}
+
+// chronySourceRe matches the server and pool lines of a chrony configuration
+var chronySourceRe = regexp.MustCompile(`^\s*(server|pool)\s`)
+
+// xkbConsoleKeymaps maps the XKB layouts whose kbd console keymap has another
+// name. The template keymap is an XKB layout, written as is to DEB images and
+// as its console keymap to RPM images.
+var xkbConsoleKeymaps = map[string]string{
+	"gb":    "uk",
+	"jp":    "jp106",
+	"br":    "br-abnt2",
+	"latam": "la-latin1",
+}
+
+// builtinLocales are provided by glibc and need not be generated
+var builtinLocales = []string{"C", "POSIX", "C.UTF-8", "C.utf8"}
+
+// updateImageLocalization generates the locales and writes the default LANG and
+// console keymap the way the target package type expects
+func updateImageLocalization(installRoot, pkgType string, template *config.ImageTemplate) error {
+	localization := template.SystemConfig.Localization
+	locales := localesToGenerate(localization)
+	if len(locales) == 0 && localization.Lang == "" && localization.Keymap == "" {
+		return nil
+	}
+	if pkgType != "deb" && pkgType != "rpm" {
+		return fmt.Errorf("unsupported package type: %s", pkgType)
+	}
+	log.Infof("Configuring locales and keymap...")
+
+	if len(locales) > 0 {
+		if err := generateImageLocales(installRoot, pkgType, locales); err != nil {
+			return err
+		}
+	}
+
+	if localization.Lang != "" {
+		localeFile := rpmLocaleFile
+		if pkgType == "deb" {
+			localeFile = debLocaleFile
+		}
+		if err := setImageConfigVar(installRoot, localeFile, "LANG", localization.Lang); err != nil {
+			return err
+		}
+	}
+
+	if localization.Keymap != "" {
+		if pkgType == "deb" {
+			if err := setImageConfigVar(installRoot, debKeyboardFile, "XKBLAYOUT", localization.Keymap); err != nil {
+				return err
+			}
+		} else if err := setImageConfigVar(installRoot, rpmVconsoleFile, "KEYMAP", consoleKeymap(localization.Keymap)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consoleKeymap returns the kbd console keymap of an XKB layout
+func consoleKeymap(layout string) string {
+	if keymap, ok := xkbConsoleKeymaps[layout]; ok {
+		return keymap
+	}
+	return layout
+}
+
+// localesToGenerate returns the locales to generate, including the default LANG
+// and excluding the locales built into glibc
+func localesToGenerate(localization config.LocalizationConfig) []string {
+	var locales []string
+	candidates := append([]string{}, localization.Locales...)
+	if localization.Lang != "" {
+		candidates = append(candidates, localization.Lang)
+	}
+	for _, locale := range candidates {
+		if slice.Contains(builtinLocales, locale) || slice.Contains(locales, locale) {
+			continue
+		}
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+// parseLocale splits a locale name such as de_DE.UTF-8@euro into its localedef
+// input (de_DE@euro) and charmap (UTF-8)
+func parseLocale(locale string) (input, charmap string, err error) {
+	name, modifier, hasModifier := strings.Cut(locale, "@")
+	base, charset, hasCharset := strings.Cut(name, ".")
+	if base == "" || !hasCharset || charset == "" {
+		return "", "", fmt.Errorf("locale %s needs a charset, e.g. %s.UTF-8", locale, name)
+	}
+	charmap = strings.ToUpper(charset)
+	if charmap == "UTF8" {
+		charmap = "UTF-8"
+	}
+	input = base
+	if hasModifier {
+		input += "@" + modifier
+	}
+	return input, charmap, nil
+}
+
+// renderLocaleGen returns the /etc/locale.gen content enabling the locales
+func renderLocaleGen(locales []string) (string, error) {
+	var lines []string
+	for _, locale := range locales {
+		_, charmap, err := parseLocale(locale)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, locale+" "+charmap)
+	}
+	return localizationStamp + strings.Join(lines, "\n") + "\n", nil
+}
+
+// generateImageLocales compiles the locales with locale-gen on DEB targets and
+// localedef on RPM targets
+func generateImageLocales(installRoot, pkgType string, locales []string) error {
+	var missing []string
+	for _, locale := range locales {
+		input, _, err := parseLocale(locale)
+		if err != nil {
+			return err
+		}
+		source, _, _ := strings.Cut(input, "@")
+		if _, err := os.Stat(filepath.Join(installRoot, localeSourceDir, source)); err != nil {
+			missing = append(missing, locale)
+		}
+	}
+	if len(missing) > 0 {
+		log.Errorf("Locale sources not found in image: %s", strings.Join(missing, ", "))
+		return fmt.Errorf("locale sources not found in %s, are the locale packages installed: %s",
+			localeSourceDir, strings.Join(missing, ", "))
+	}
+
+	if pkgType == "deb" {
+		localeGen, err := renderLocaleGen(locales)
+		if err != nil {
+			return err
+		}
+		localeGenPath := filepath.Join(installRoot, debLocaleGenFile)
+		if err := file.Write(localeGen, localeGenPath); err != nil {
+			return fmt.Errorf("failed to write %s: %w", localeGenPath, err)
+		}
+		if _, err := shell.ExecCmd("locale-gen", true, installRoot, nil); err != nil {
+			log.Errorf("Failed to generate locales: %v", err)
+			return fmt.Errorf("failed to generate locales: %w", err)
+		}
+		return nil
+	}
+
+	for _, locale := range locales {
+		input, charmap, _ := parseLocale(locale)
+		cmd := fmt.Sprintf("localedef -i %s -f %s %s", input, charmap, locale)
+		if _, err := shell.ExecCmd(cmd, true, installRoot, nil); err != nil {
+			log.Errorf("Failed to generate locale %s: %v", locale, err)
+			return fmt.Errorf("failed to generate locale %s: %w", locale, err)
+		}
+	}
+	return nil
+}
+
+// setImageConfigVar sets a KEY=value line in a shell style configuration file of the image
+func setImageConfigVar(installRoot, configFile, key, value string) error {
+	configPath := filepath.Join(installRoot, configFile)
+	content := ""
+	if _, err := os.Stat(configPath); err == nil {
+		if content, err = file.Read(configPath); err != nil {
+			return fmt.Errorf("failed to read %s: %w", configPath, err)
+		}
+	}
+	if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(configPath), true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(configPath), err)
+	}
+	if err := file.Write(configWithVar(content, key, value), configPath); err != nil {
+		log.Errorf("Failed to write %s: %v", configPath, err)
+		return fmt.Errorf("failed to write %s: %w", configPath, err)
+	}
+	log.Debugf("Set %s=%s in %s", key, value, configPath)
+	return nil
+}
+
+// configWithVar replaces the KEY= line of a shell style configuration, or appends one
+func configWithVar(content, key, value string) string {
+	line := fmt.Sprintf("%s=\"%s\"", key, value)
+	keyRe := regexp.MustCompile(`(?m)^\s*` + regexp.QuoteMeta(key) + `=.*$`)
+	if keyRe.MatchString(content) {
+		replaced := false
+		return keyRe.ReplaceAllStringFunc(content, func(string) string {
+			if replaced {
+				return ""
+			}
+			replaced = true
+			return line
+		})
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + line + "\n"
+}
+
+// updateImageTime points the installed NTP clients at the template time servers
+// and records whether the hardware clock keeps UTC or local time
+func updateImageTime(installRoot string, template *config.ImageTemplate) error {
+	timeConfig := template.SystemConfig.Time
+	if len(timeConfig.NTP) == 0 && timeConfig.RTC == "" {
+		return nil
+	}
+	log.Infof("Configuring time settings...")
+
+	if len(timeConfig.NTP) > 0 {
+		configured := false
+		for _, chronyConfig := range chronyConfigFiles {
+			chronyPath := filepath.Join(installRoot, chronyConfig)
+			if _, err := os.Stat(chronyPath); err != nil {
+				continue
+			}
+			content, err := file.Read(chronyPath)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", chronyPath, err)
+			}
+			if err := file.Write(chronyConfigWithServers(content, timeConfig.NTP), chronyPath); err != nil {
+				return fmt.Errorf("failed to write %s: %w", chronyPath, err)
+			}
+			log.Debugf("Set NTP servers in %s", chronyPath)
+			configured = true
+		}
+		if unitFileExists(installRoot, "systemd-timesyncd.service") {
+			dropInPath := filepath.Join(installRoot, timesyncdDropIn)
+			if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(dropInPath), true, shell.HostPath, nil); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(dropInPath), err)
+			}
+			dropIn := timeStamp + "[Time]\nNTP=" + strings.Join(timeConfig.NTP, " ") + "\n"
+			if err := file.Write(dropIn, dropInPath); err != nil {
+				return fmt.Errorf("failed to write %s: %w", dropInPath, err)
+			}
+			log.Debugf("Set NTP servers in %s", dropInPath)
+			configured = true
+		}
+		if !configured {
+			log.Errorf("No NTP client found in image")
+			return fmt.Errorf("no NTP client found in image, install chrony or systemd-timesyncd")
+		}
+	}
+
+	if timeConfig.RTC != "" {
+		adjtimePath := filepath.Join(installRoot, adjtimeFile)
+		if err := file.Write(renderAdjtime(timeConfig.RTC), adjtimePath); err != nil {
+			log.Errorf("Failed to write %s: %v", adjtimePath, err)
+			return fmt.Errorf("failed to write %s: %w", adjtimePath, err)
+		}
+	}
+	return nil
+}
+
+// chronyConfigWithServers comments out the server and pool lines of a chrony
+// configuration and appends the template time servers
+func chronyConfigWithServers(content string, servers []string) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+	for i, line := range lines {
+		if chronySourceRe.MatchString(line) {
+			lines[i] = "#" + line
+		}
+	}
+	lines = append(lines, strings.TrimSuffix(timeStamp, "\n"))
+	for _, server := range servers {
+		lines = append(lines, "server "+server+" iburst")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// renderAdjtime returns the /etc/adjtime content for a hardware clock in UTC or
+// local time, as written by timedatectl set-local-rtc
+func renderAdjtime(rtc string) string {
+	mode := "UTC"
+	if rtc == "local" {
+		mode = "LOCAL"
+	}
+	return "0.0 0 0.0\n0\n" + mode + "\n"
+}
@@ -0,0 +1,197 @@
+package imageos
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		locale  string
+		input   string
+		charmap string
+		wantErr bool
+	}{
+		{"en_US.UTF-8", "en_US", "UTF-8", false},
+		{"ja_JP.utf8", "ja_JP", "UTF-8", false},
+		{"de_DE.ISO-8859-15@euro", "de_DE@euro", "ISO-8859-15", false},
+		{"en_US", "", "", true},
+		{"en_US.", "", "", true},
+	}
+	for _, tt := range tests {
+		input, charmap, err := parseLocale(tt.locale)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLocale(%q) error = %v, wantErr %v", tt.locale, err, tt.wantErr)
+			continue
+		}
+		if input != tt.input || charmap != tt.charmap {
+			t.Errorf("parseLocale(%q) = %q, %q, want %q, %q", tt.locale, input, charmap, tt.input, tt.charmap)
+		}
+	}
+}
+
+func TestLocalesToGenerate(t *testing.T) {
+	localization := config.LocalizationConfig{
+		Locales: []string{"en_US.UTF-8", "C.UTF-8", "zh_SG.UTF-8"},
+		Lang:    "en_SG.UTF-8",
+	}
+	expected := []string{"en_US.UTF-8", "zh_SG.UTF-8", "en_SG.UTF-8"}
+	if got := localesToGenerate(localization); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	localization.Lang = "en_US.UTF-8"
+	if got := localesToGenerate(localization); len(got) != 2 {
+		t.Errorf("expected LANG not to be generated twice, got %v", got)
+	}
+	if got := localesToGenerate(config.LocalizationConfig{Lang: "C.UTF-8"}); len(got) != 0 {
+		t.Errorf("expected no locales for a builtin LANG, got %v", got)
+	}
+}
+
+func TestRenderLocaleGen(t *testing.T) {
+	content, err := renderLocaleGen([]string{"en_US.UTF-8", "de_DE.ISO-8859-15@euro"})
+	if err != nil {
+		t.Fatalf("renderLocaleGen failed: %v", err)
+	}
+	if !strings.Contains(content, "\nen_US.UTF-8 UTF-8\nde_DE.ISO-8859-15@euro ISO-8859-15\n") {
+		t.Errorf("unexpected locale.gen:\n%s", content)
+	}
+	if _, err := renderLocaleGen([]string{"en_US"}); err == nil {
+		t.Error("expected an error for a locale without charset")
+	}
+}
+
+func TestConsoleKeymap(t *testing.T) {
+	tests := map[string]string{"us": "us", "de": "de", "gb": "uk", "jp": "jp106"}
+	for layout, expected := range tests {
+		if got := consoleKeymap(layout); got != expected {
+			t.Errorf("consoleKeymap(%q) = %q, expected %q", layout, got, expected)
+		}
+	}
+}
+
+func TestConfigWithVar(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"empty", "", "KEYMAP=\"de\"\n"},
+		{"append", "FONT=eurlatgr", "FONT=eurlatgr\nKEYMAP=\"de\"\n"},
+		{"replace", "KEYMAP=us\nFONT=eurlatgr\n", "KEYMAP=\"de\"\nFONT=eurlatgr\n"},
+		{"other key with the same suffix", "XKEYMAP=us\n", "XKEYMAP=us\nKEYMAP=\"de\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := configWithVar(tt.content, "KEYMAP", "de"); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestChronyConfigWithServers(t *testing.T) {
+	content := "pool 2.azurelinux.pool.ntp.org iburst\nserver 10.0.0.1\ndriftfile /var/lib/chrony/drift\n"
+	got := chronyConfigWithServers(content, []string{"ntp1.example.com", "10.1.1.1"})
+
+	for _, line := range []string{
+		"#pool 2.azurelinux.pool.ntp.org iburst", "#server 10.0.0.1", "driftfile /var/lib/chrony/drift",
+		"server ntp1.example.com iburst", "server 10.1.1.1 iburst",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("expected %q in chrony config, got:\n%s", line, got)
+		}
+	}
+}
+
+func TestRenderAdjtime(t *testing.T) {
+	if got := renderAdjtime("local"); !strings.HasSuffix(got, "\nLOCAL\n") {
+		t.Errorf("expected a local time adjtime, got %q", got)
+	}
+	if got := renderAdjtime("utc"); !strings.HasSuffix(got, "\nUTC\n") {
+		t.Errorf("expected a UTC adjtime, got %q", got)
+	}
+}
+
+func TestUpdateImageLocalization(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No localization configured is a no-op
+	if err := updateImageLocalization(installRoot, "deb", template); err != nil {
+		t.Errorf("expected no error without localization, got: %v", err)
+	}
+
+	template.SystemConfig.Localization = config.LocalizationConfig{
+		Locales: []string{"en_SG.UTF-8"},
+		Lang:    "en_SG.UTF-8",
+		Keymap:  "us",
+	}
+	if err := updateImageLocalization(installRoot, "unknown", template); err == nil {
+		t.Error("expected an error for an unsupported package type")
+	}
+	err := updateImageLocalization(installRoot, "rpm", template)
+	if err == nil || !strings.Contains(err.Error(), "en_SG.UTF-8") {
+		t.Errorf("expected an error naming the locale missing its sources, got: %v", err)
+	}
+
+	sourceDir := filepath.Join(installRoot, localeSourceDir)
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("failed to create locale source directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "en_SG"), []byte("LC_IDENTIFICATION\n"), 0644); err != nil {
+		t.Fatalf("failed to write locale source: %v", err)
+	}
+	for _, pkgType := range []string{"deb", "rpm"} {
+		if err := updateImageLocalization(installRoot, pkgType, template); err != nil {
+			t.Errorf("updateImageLocalization failed for %s: %v", pkgType, err)
+		}
+	}
+}
+
+func TestUpdateImageTime(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No time settings configured is a no-op
+	if err := updateImageTime(installRoot, template); err != nil {
+		t.Errorf("expected no error without time settings, got: %v", err)
+	}
+
+	template.SystemConfig.Time = config.TimeConfig{NTP: []string{"ntp.example.com"}, RTC: "local"}
+	if err := updateImageTime(installRoot, template); err == nil || !strings.Contains(err.Error(), "no NTP client") {
+		t.Errorf("expected an error without an NTP client, got: %v", err)
+	}
+
+	unitDir := filepath.Join(installRoot, "usr", "lib", "systemd", "system")
+	if err := os.MkdirAll(unitDir, 0755); err != nil {
+		t.Fatalf("failed to create unit directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(unitDir, "systemd-timesyncd.service"), []byte("[Unit]\n"), 0644); err != nil {
+		t.Fatalf("failed to write unit: %v", err)
+	}
+	if err := updateImageTime(installRoot, template); err != nil {
+		t.Errorf("updateImageTime failed: %v", err)
+	}
+}
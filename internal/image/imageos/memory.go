@@ -0,0 +1,361 @@
+package imageos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+)
+
+const (
+	defaultSwapFilePath   = "/swapfile"
+	swapFileCreateBuild   = "build"
+	swapFileCreateBoot    = "firstboot"
+	swapFileScript        = "/usr/local/sbin/create-swapfile.sh"
+	swapFileService       = "create-swapfile.service"
+	zramGeneratorConfig   = "/etc/systemd/zram-generator.conf"
+	defaultZramSize       = "50%"
+	defaultZramCompressor = "zstd"
+	tmpfsMountPoint       = "/tmp"
+	defaultTmpfsSize      = "50%"
+	memoryStamp           = "# Generated by OS Image Composer from systemConfig.memory\n"
+)
+
+// zramGenerators are the zram-generator paths of the supported distributions
+var zramGenerators = []string{
+	"/usr/lib/systemd/system-generators/zram-generator",
+	"/lib/systemd/system-generators/zram-generator",
+}
+
+// zramCompressors are the compression algorithms of the zram kernel driver
+var zramCompressors = []string{"lzo", "lzo-rle", "lz4", "lz4hc", "zstd", "842", "deflate"}
+
+// isMemoryConfigEmpty reports whether the template sets no swap file, zram or tmpfs
+func isMemoryConfigEmpty(memory config.MemoryConfig) bool {
+	return memory.SwapFile.Size == "" && !memory.Zram.Enabled && !memory.Tmpfs.Enabled
+}
+
+// updateImageMemory creates the swap file, or the first boot service creating
+// it, and writes the zram-generator configuration. The swap file and /tmp fstab
+// entries are written by updateImageFstab.
+func updateImageMemory(installRoot string, template *config.ImageTemplate) error {
+	memory := template.SystemConfig.Memory
+	if isMemoryConfigEmpty(memory) {
+		return nil
+	}
+	log.Infof("Configuring swap and tmpfs...")
+
+	if err := checkMemoryConfig(template); err != nil {
+		return err
+	}
+
+	if memory.SwapFile.Size != "" {
+		swapFile := memory.SwapFile
+		if swapFile.CreateAt == swapFileCreateBoot {
+			if err := addSwapFileService(installRoot, swapFile); err != nil {
+				return err
+			}
+		} else if err := createSwapFile(installRoot, swapFile); err != nil {
+			return err
+		}
+	}
+
+	if memory.Zram.Enabled {
+		if !zramGeneratorInstalled(installRoot) {
+			return fmt.Errorf("zram needs zram-generator in the image, is the zram-generator package installed")
+		}
+		if err := writeImageFile(installRoot, zramGeneratorConfig, renderZramGeneratorConfig(memory.Zram)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMemoryConfig validates the memory settings against each other and the disk layout
+func checkMemoryConfig(template *config.ImageTemplate) error {
+	memory := template.SystemConfig.Memory
+	diskConfig := template.GetDiskConfig()
+
+	if memory.Tmpfs.Enabled {
+		if _, _, ok := imagedisc.FindPartitionMount(diskConfig, tmpfsMountPoint); ok {
+			return fmt.Errorf("/tmp cannot be a tmpfs, a partition is mounted there")
+		}
+		if memory.Tmpfs.Size != "" {
+			if _, err := tmpfsSizeOption(memory.Tmpfs.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	if memory.Zram.Enabled {
+		if _, err := zramSizeExpression(memory.Zram.Size); err != nil {
+			return err
+		}
+		if memory.Zram.Compression != "" && !slice.Contains(zramCompressors, memory.Zram.Compression) {
+			return fmt.Errorf("unknown zram compression %q, use one of %s", memory.Zram.Compression, strings.Join(zramCompressors, ", "))
+		}
+	}
+
+	swapFile := memory.SwapFile
+	if swapFile.Size == "" {
+		if swapFile.Path != "" || swapFile.CreateAt != "" {
+			return fmt.Errorf("swap file %s needs a size", swapFilePath(swapFile))
+		}
+		return nil
+	}
+	if bytes, err := imagedisc.TranslateSizeStrToBytes(swapFile.Size); err != nil || bytes == 0 {
+		return fmt.Errorf("invalid swap file size %s", swapFile.Size)
+	}
+	if swapFile.CreateAt != "" && swapFile.CreateAt != swapFileCreateBuild && swapFile.CreateAt != swapFileCreateBoot {
+		return fmt.Errorf("invalid swap file createAt %q, use %q or %q", swapFile.CreateAt, swapFileCreateBuild, swapFileCreateBoot)
+	}
+	path := swapFilePath(swapFile)
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path || path == "/" {
+		return fmt.Errorf("swap file path %s must be an absolute file path", path)
+	}
+	if memory.Tmpfs.Enabled && isPathUnder(path, tmpfsMountPoint) {
+		return fmt.Errorf("swap file %s cannot be on the /tmp tmpfs", path)
+	}
+
+	partition, partitionMount, ok := partitionMountOf(diskConfig, path)
+	if !ok {
+		return fmt.Errorf("no partition is mounted for swap file %s", path)
+	}
+	if partitionMount.MountPoint == "/" && template.IsImmutabilityEnabled() {
+		return fmt.Errorf("swap file %s is on the read-only root file system of an immutable image, place it on a writable partition", path)
+	}
+	if partition.FsType == imagedisc.FsTypeBtrfs && partition.Snapshot != "" && partitionMount.MountPoint == partition.MountPoint {
+		return fmt.Errorf("swap file %s is on the snapshotted subvolume %s, place it on its own subvolume", path, partitionMount.Subvolume)
+	}
+	return nil
+}
+
+// swapFilePath returns the swap file path, defaulting to /swapfile
+func swapFilePath(swapFile config.SwapFileConfig) string {
+	if swapFile.Path == "" {
+		return defaultSwapFilePath
+	}
+	return swapFile.Path
+}
+
+// isPathUnder reports whether path is dir or inside it
+func isPathUnder(path, dir string) bool {
+	return path == dir || dir == "/" || strings.HasPrefix(path, dir+"/")
+}
+
+// partitionMountOf returns the partition mount holding path, the one with the longest mount point
+func partitionMountOf(diskConfig config.DiskConfig, path string) (config.PartitionInfo, imagedisc.PartitionMount, bool) {
+	var found config.PartitionInfo
+	var foundMount imagedisc.PartitionMount
+	ok := false
+	for _, partition := range diskConfig.Partitions {
+		for _, partitionMount := range imagedisc.PartitionMounts(partition) {
+			if !strings.HasPrefix(partitionMount.MountPoint, "/") || !isPathUnder(path, partitionMount.MountPoint) {
+				continue
+			}
+			if !ok || len(partitionMount.MountPoint) > len(foundMount.MountPoint) {
+				found, foundMount, ok = partition, partitionMount, true
+			}
+		}
+	}
+	return found, foundMount, ok
+}
+
+// createSwapFile creates and formats the swap file in the image. Copy on write
+// is disabled first, as btrfs requires for swap files.
+func createSwapFile(installRoot string, swapFile config.SwapFileConfig) error {
+	filePath := filepath.Join(installRoot, swapFilePath(swapFile))
+	log.Infof("Creating %s swap file %s", swapFile.Size, swapFilePath(swapFile))
+
+	if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(filePath), true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(filePath), err)
+	}
+	if _, err := shell.ExecCmd("truncate -s 0 "+filePath, true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to create swap file %s: %w", filePath, err)
+	}
+	if _, err := shell.ExecCmd("chattr +C "+filePath, true, shell.HostPath, nil); err != nil {
+		log.Debugf("Copy on write not disabled for %s: %v", filePath, err)
+	}
+	cmds := []string{
+		"fallocate -l " + swapFile.Size + " " + filePath,
+		"chmod 600 " + filePath,
+		"mkswap " + filePath,
+	}
+	for _, cmd := range cmds {
+		if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to create swap file %s: %v", filePath, err)
+			return fmt.Errorf("failed to create swap file %s: %w", filePath, err)
+		}
+	}
+	return nil
+}
+
+// addSwapFileService installs a first boot service creating the swap file
+// before it is activated from fstab
+func addSwapFileService(installRoot string, swapFile config.SwapFileConfig) error {
+	log.Infof("Adding first boot creation of swap file %s", swapFilePath(swapFile))
+	if err := writeImageFile(installRoot, swapFileScript, renderSwapFileScript(swapFile)); err != nil {
+		return err
+	}
+	scriptPath := filepath.Join(installRoot, swapFileScript)
+	if _, err := shell.ExecCmd("chmod 755 "+scriptPath, true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", scriptPath, err)
+	}
+
+	unitFile := filepath.Join("/etc/systemd/system", swapFileService)
+	if err := writeImageFile(installRoot, unitFile, renderSwapFileUnit(swapFile)); err != nil {
+		return err
+	}
+	cmd := "systemctl --root=\"" + installRoot + "\" enable " + swapFileService
+	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to enable %s: %v", swapFileService, err)
+		return fmt.Errorf("failed to enable %s: %w", swapFileService, err)
+	}
+	return nil
+}
+
+// renderSwapFileScript returns a script creating the swap file
+func renderSwapFileScript(swapFile config.SwapFileConfig) string {
+	lines := []string{
+		"#!/bin/sh",
+		"# Generated by os-image-composer: creates the swap file",
+		"set -e",
+		"swap_file=" + shellQuote(swapFilePath(swapFile)),
+		`truncate -s 0 "$swap_file"`,
+		`chattr +C "$swap_file" 2>/dev/null || true`,
+		"fallocate -l " + swapFile.Size + ` "$swap_file"`,
+		`chmod 600 "$swap_file"`,
+		`mkswap "$swap_file"`,
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func renderSwapFileUnit(swapFile config.SwapFileConfig) string {
+	path := swapFilePath(swapFile)
+	lines := []string{
+		"[Unit]",
+		"Description=Create the swap file " + path,
+		"DefaultDependencies=no",
+		"RequiresMountsFor=" + filepath.Dir(path),
+		"After=systemd-remount-fs.service",
+		"Before=" + systemdEscapePath(path) + ".swap swap.target shutdown.target",
+		"Conflicts=shutdown.target",
+		"ConditionPathExists=!" + path,
+		"",
+		"[Service]",
+		"Type=oneshot",
+		"ExecStart=" + swapFileScript,
+		"",
+		"[Install]",
+		"WantedBy=swap.target",
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// systemdEscapePath returns the unit name prefix of path, as systemd-escape --path does
+func systemdEscapePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "-"
+	}
+	var escaped strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '/':
+			escaped.WriteByte('-')
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.' && i > 0:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, `\x%02x`, c)
+		}
+	}
+	return escaped.String()
+}
+
+// zramGeneratorInstalled reports whether the image has zram-generator
+func zramGeneratorInstalled(installRoot string) bool {
+	for _, generator := range zramGenerators {
+		if _, err := os.Stat(filepath.Join(installRoot, generator)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// zramSizeExpression returns the zram-generator size expression of a percentage of RAM
+func zramSizeExpression(size string) (string, error) {
+	if size == "" {
+		size = defaultZramSize
+	}
+	percent, ok := strings.CutSuffix(size, "%")
+	value, err := strconv.Atoi(percent)
+	if !ok || err != nil || value < 1 || value > 100 {
+		return "", fmt.Errorf("invalid zram size %s, use a percentage of RAM from 1%% to 100%%", size)
+	}
+	if value == 100 {
+		return "ram", nil
+	}
+	return fmt.Sprintf("ram * %d / 100", value), nil
+}
+
+func renderZramGeneratorConfig(zram config.ZramConfig) string {
+	size, _ := zramSizeExpression(zram.Size)
+	compression := zram.Compression
+	if compression == "" {
+		compression = defaultZramCompressor
+	}
+	return memoryStamp + "[zram0]\n" +
+		"zram-size = " + size + "\n" +
+		"compression-algorithm = " + compression + "\n"
+}
+
+// tmpfsSizeOption returns the tmpfs size mount option value of a size or a percentage of RAM
+func tmpfsSizeOption(size string) (string, error) {
+	if size == "" {
+		size = defaultTmpfsSize
+	}
+	if percent, ok := strings.CutSuffix(size, "%"); ok {
+		value, err := strconv.Atoi(percent)
+		if err != nil || value < 1 || value > 100 {
+			return "", fmt.Errorf("invalid tmpfs size %s", size)
+		}
+		return size, nil
+	}
+	bytes, err := imagedisc.TranslateSizeStrToBytes(size)
+	if err != nil || bytes == 0 {
+		return "", fmt.Errorf("invalid tmpfs size %s", size)
+	}
+	// tmpfs takes binary sizes with a k, m or g suffix
+	for _, unit := range []struct {
+		suffix string
+		bytes  uint64
+	}{{"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}} {
+		if bytes%unit.bytes == 0 {
+			return fmt.Sprintf("%d%s", bytes/unit.bytes, unit.suffix), nil
+		}
+	}
+	return strconv.FormatUint(bytes, 10), nil
+}
+
+// memoryFstabEntries returns the fstab entries of the swap file and the /tmp tmpfs
+func memoryFstabEntries(memory config.MemoryConfig) ([]string, error) {
+	var entries []string
+	if memory.SwapFile.Size != "" {
+		entries = append(entries, fmt.Sprintf("%s none swap defaults 0 0", swapFilePath(memory.SwapFile)))
+	}
+	if memory.Tmpfs.Enabled {
+		size, err := tmpfsSizeOption(memory.Tmpfs.Size)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fmt.Sprintf("tmpfs %s tmpfs defaults,nosuid,nodev,mode=1777,size=%s 0 0", tmpfsMountPoint, size))
+	}
+	return entries, nil
+}
@@ -0,0 +1,154 @@
+package imageos
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func memoryTemplate() *config.ImageTemplate {
+	return &config.ImageTemplate{
+		Disk: config.DiskConfig{
+			Partitions: []config.PartitionInfo{
+				{ID: "boot", FsType: "vfat", MountPoint: "/boot/efi"},
+				{ID: "rootfs", FsType: "ext4", MountPoint: "/"},
+				{ID: "data", FsType: "ext4", MountPoint: "/var/lib/data"},
+			},
+		},
+		SystemConfig: config.SystemConfig{
+			Memory: config.MemoryConfig{
+				SwapFile: config.SwapFileConfig{Size: "1GiB"},
+				Zram:     config.ZramConfig{Enabled: true, Size: "25%", Compression: "lz4"},
+				Tmpfs:    config.TmpfsConfig{Enabled: true, Size: "512MiB"},
+			},
+		},
+	}
+}
+
+func TestCheckMemoryConfig(t *testing.T) {
+	if err := checkMemoryConfig(memoryTemplate()); err != nil {
+		t.Fatalf("expected the memory settings to be valid, got: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(template *config.ImageTemplate)
+		want   string
+	}{
+		{"swap file on immutable root", func(t *config.ImageTemplate) { t.SystemConfig.Immutability.Enabled = true }, "read-only root"},
+		{"swap file on tmpfs", func(t *config.ImageTemplate) { t.SystemConfig.Memory.SwapFile.Path = "/tmp/swap" }, "tmpfs"},
+		{"relative swap file", func(t *config.ImageTemplate) { t.SystemConfig.Memory.SwapFile.Path = "swapfile" }, "absolute"},
+		{"swap file without size", func(t *config.ImageTemplate) {
+			t.SystemConfig.Memory.SwapFile = config.SwapFileConfig{Path: "/swapfile"}
+		}, "needs a size"},
+		{"invalid swap size", func(t *config.ImageTemplate) { t.SystemConfig.Memory.SwapFile.Size = "1T" }, "invalid swap file size"},
+		{"unknown createAt", func(t *config.ImageTemplate) { t.SystemConfig.Memory.SwapFile.CreateAt = "later" }, "createAt"},
+		{"tmp partition", func(t *config.ImageTemplate) {
+			t.Disk.Partitions = append(t.Disk.Partitions, config.PartitionInfo{ID: "tmp", FsType: "ext4", MountPoint: "/tmp"})
+		}, "a partition is mounted"},
+		{"zram size", func(t *config.ImageTemplate) { t.SystemConfig.Memory.Zram.Size = "150%" }, "invalid zram size"},
+		{"zram compression", func(t *config.ImageTemplate) { t.SystemConfig.Memory.Zram.Compression = "gzip" }, "unknown zram compression"},
+		{"tmpfs size", func(t *config.ImageTemplate) { t.SystemConfig.Memory.Tmpfs.Size = "lots" }, "invalid tmpfs size"},
+	}
+	for _, tt := range tests {
+		template := memoryTemplate()
+		tt.modify(template)
+		if err := checkMemoryConfig(template); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got: %v", tt.name, tt.want, err)
+		}
+	}
+
+	// A swap file on a writable partition of an immutable image is fine
+	template := memoryTemplate()
+	template.SystemConfig.Immutability.Enabled = true
+	template.SystemConfig.Memory.SwapFile.Path = "/var/lib/data/swapfile"
+	if err := checkMemoryConfig(template); err != nil {
+		t.Errorf("expected a swap file on a writable partition to be valid, got: %v", err)
+	}
+}
+
+func TestMemoryFstabEntries(t *testing.T) {
+	entries, err := memoryFstabEntries(memoryTemplate().SystemConfig.Memory)
+	if err != nil {
+		t.Fatalf("memoryFstabEntries failed: %v", err)
+	}
+	expected := []string{
+		"/swapfile none swap defaults 0 0",
+		"tmpfs /tmp tmpfs defaults,nosuid,nodev,mode=1777,size=512m 0 0",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected fstab entries %v, got %v", expected, entries)
+	}
+
+	if entries, _ := memoryFstabEntries(config.MemoryConfig{Tmpfs: config.TmpfsConfig{Enabled: true}}); entries[0] != "tmpfs /tmp tmpfs defaults,nosuid,nodev,mode=1777,size=50% 0 0" {
+		t.Errorf("expected the default tmpfs size, got %v", entries)
+	}
+	if entries, _ := memoryFstabEntries(config.MemoryConfig{}); len(entries) != 0 {
+		t.Errorf("expected no fstab entries, got %v", entries)
+	}
+}
+
+func TestRenderZramGeneratorConfig(t *testing.T) {
+	content := renderZramGeneratorConfig(config.ZramConfig{Enabled: true, Size: "25%", Compression: "lz4"})
+	if !strings.Contains(content, "[zram0]\nzram-size = ram * 25 / 100\ncompression-algorithm = lz4\n") {
+		t.Errorf("unexpected zram-generator configuration:\n%s", content)
+	}
+	content = renderZramGeneratorConfig(config.ZramConfig{Enabled: true})
+	if !strings.Contains(content, "zram-size = ram * 50 / 100\ncompression-algorithm = zstd\n") {
+		t.Errorf("expected the default zram size and compression, got:\n%s", content)
+	}
+}
+
+func TestRenderSwapFileUnit(t *testing.T) {
+	swapFile := config.SwapFileConfig{Size: "2GiB", Path: "/var/swap-file"}
+	unit := renderSwapFileUnit(swapFile)
+	for _, want := range []string{`Before=var-swap\x2dfile.swap swap.target`, "ConditionPathExists=!/var/swap-file", "RequiresMountsFor=/var"} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("expected %q in unit:\n%s", want, unit)
+		}
+	}
+	script := renderSwapFileScript(swapFile)
+	if !strings.Contains(script, `fallocate -l 2GiB "$swap_file"`) || !strings.Contains(script, "swap_file='/var/swap-file'") {
+		t.Errorf("unexpected swap file script:\n%s", script)
+	}
+}
+
+func TestUpdateImageMemory(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	if err := updateImageMemory(installRoot, createTestImageTemplate()); err != nil {
+		t.Errorf("expected no error without memory settings, got: %v", err)
+	}
+
+	template := memoryTemplate()
+	if err := updateImageMemory(installRoot, template); err == nil || !strings.Contains(err.Error(), "zram-generator") {
+		t.Errorf("expected an error without zram-generator, got: %v", err)
+	}
+
+	generator := filepath.Join(installRoot, zramGenerators[0])
+	if err := os.MkdirAll(filepath.Dir(generator), 0755); err != nil {
+		t.Fatalf("failed to create generator directory: %v", err)
+	}
+	if err := os.WriteFile(generator, nil, 0755); err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	if err := updateImageMemory(installRoot, template); err != nil {
+		t.Errorf("updateImageMemory failed: %v", err)
+	}
+
+	template.SystemConfig.Memory.SwapFile.CreateAt = "firstboot"
+	if err := updateImageMemory(installRoot, template); err != nil {
+		t.Errorf("updateImageMemory with a first boot swap file failed: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package imageos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+	"github.com/ulikunitz/xz"
+)
+
+// kernelModuleDirs hold the modules of the installed kernels, relative to the
+// install root; /lib is a symlink to /usr/lib on merged /usr systems
+var kernelModuleDirs = []string{"usr/lib/modules", "lib/modules"}
+
+// kernelModuleSuffixes are the file suffixes of compressed and plain modules
+var kernelModuleSuffixes = []string{".ko", ".ko.xz", ".ko.zst", ".ko.gz"}
+
+// signImageKernelModules signs the kernel modules without a signature, such as
+// out-of-tree drivers, so that they load under Secure Boot lockdown. Modules
+// that already carry a signature are left as they are.
+func signImageKernelModules(installRoot string, template *config.ImageTemplate) error {
+	kernelConfig := template.GetKernel()
+	if kernelConfig.Lockdown != "" && !template.IsSecureBootEnabled() {
+		return fmt.Errorf("kernel lockdown %s needs secure boot to sign the kernel modules", kernelConfig.Lockdown)
+	}
+	if !template.IsSecureBootEnabled() {
+		return nil
+	}
+	// Keys in the firmware DB end up on the .platform keyring, which the
+	// kernel does not use to verify modules
+	if kernelConfig.Lockdown != "" && template.GetSecureBootMode() == config.SecureBootModeDB {
+		log.Warnf("Kernel lockdown %s with secureBootMode db: modules signed here only load when "+
+			"the key is enrolled as a MOK or built into the kernel", kernelConfig.Lockdown)
+	}
+
+	modules, err := findKernelModules(installRoot)
+	if err != nil {
+		return err
+	}
+	required := make(map[string]bool)
+	for _, module := range strings.Fields(kernelConfig.EnableExtraModules) {
+		required[config.NormalizeModuleName(module)] = true
+	}
+	if err := checkRequiredKernelModules(installRoot, modules, kernelConfig.EnableExtraModules); err != nil {
+		return err
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+
+	signer, err := imagesign.NewSigner(template)
+	if err != nil {
+		return fmt.Errorf("failed to get kernel module signer: %w", err)
+	}
+
+	log.Infof("Signing unsigned kernel modules...")
+	var signed int
+	for _, modulePath := range modules {
+		wasSigned, err := signKernelModule(signer, modulePath)
+		if err != nil {
+			relPath, _ := filepath.Rel(installRoot, modulePath)
+			if required[kernelModuleName(modulePath)] {
+				log.Errorf("Failed to sign required kernel module %s: %v", relPath, err)
+				return fmt.Errorf("failed to sign required kernel module %s: %w", relPath, err)
+			}
+			log.Warnf("Failed to sign kernel module %s, it will not load under lockdown: %v", relPath, err)
+			continue
+		}
+		if wasSigned {
+			signed++
+		}
+	}
+	log.Infof("Signed %d kernel modules", signed)
+
+	// The initramfs holds copies of the modules; the UKI build regenerates it later
+	if signed > 0 && template.GetBootloaderConfig().Provider != systemdBootProviderName {
+		return regenerateInitramfs(installRoot)
+	}
+	return nil
+}
+
+// findKernelModules returns the module files of all installed kernels
+func findKernelModules(installRoot string) ([]string, error) {
+	var modules []string
+	for _, dir := range kernelModuleDirs {
+		modulesDir := filepath.Join(installRoot, dir)
+		if info, err := os.Lstat(modulesDir); err != nil || !info.IsDir() {
+			continue
+		}
+		err := filepath.WalkDir(modulesDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.Type().IsRegular() && kernelModuleSuffix(path) != "" {
+				modules = append(modules, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list kernel modules in %s: %w", dir, err)
+		}
+		break
+	}
+	return modules, nil
+}
+
+// checkRequiredKernelModules fails when a module listed in enableExtraModules
+// is neither a module file of the image nor built into its kernels
+func checkRequiredKernelModules(installRoot string, modules []string, extraModules string) error {
+	present := make(map[string]bool)
+	for _, modulePath := range modules {
+		present[kernelModuleName(modulePath)] = true
+	}
+	for _, dir := range kernelModuleDirs {
+		builtinLists, _ := filepath.Glob(filepath.Join(installRoot, dir, "*", "modules.builtin"))
+		for _, builtinList := range builtinLists {
+			content, err := os.ReadFile(builtinList)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", builtinList, err)
+			}
+			for _, line := range strings.Fields(string(content)) {
+				present[kernelModuleName(line)] = true
+			}
+		}
+	}
+
+	var missing []string
+	for _, module := range strings.Fields(extraModules) {
+		if name := config.NormalizeModuleName(module); !present[name] && !slice.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		log.Errorf("Required kernel modules not found in the image: %s", strings.Join(missing, ", "))
+		return fmt.Errorf("required kernel modules not found in the image: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func kernelModuleSuffix(path string) string {
+	for _, suffix := range kernelModuleSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// kernelModuleName returns the normalized module name of a module file
+func kernelModuleName(modulePath string) string {
+	return config.NormalizeModuleName(strings.TrimSuffix(filepath.Base(modulePath), kernelModuleSuffix(modulePath)))
+}
+
+// signKernelModule signs a module file that has no signature yet, and reports
+// whether it did
+func signKernelModule(signer imagesign.Signer, modulePath string) (bool, error) {
+	suffix := kernelModuleSuffix(modulePath)
+	module, err := readKernelModule(modulePath, suffix)
+	if err != nil {
+		return false, err
+	}
+	if imagesign.IsModuleSigned(module) {
+		return false, nil
+	}
+
+	tempFile, err := os.CreateTemp(config.TempDir(), "module-*.ko")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath)
+	_, err = tempFile.Write(module)
+	tempFile.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := signer.SignKernelModule(tempPath); err != nil {
+		return false, err
+	}
+	signedModule, err := os.ReadFile(tempPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read signed module: %w", err)
+	}
+	content, err := compressKernelModule(signedModule, suffix)
+	if err != nil {
+		return false, err
+	}
+	if err := file.Write(string(content), modulePath); err != nil {
+		return false, fmt.Errorf("failed to write signed module: %w", err)
+	}
+	return true, nil
+}
+
+// readKernelModule returns the uncompressed content of a module file
+func readKernelModule(modulePath, suffix string) ([]byte, error) {
+	content, err := os.ReadFile(modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel module: %w", err)
+	}
+	var reader io.Reader
+	switch suffix {
+	case ".ko.xz":
+		reader, err = xz.NewReader(bytes.NewReader(content))
+	case ".ko.zst":
+		var decoder *zstd.Decoder
+		decoder, err = zstd.NewReader(bytes.NewReader(content))
+		if err == nil {
+			defer decoder.Close()
+			reader = decoder
+		}
+	case ".ko.gz":
+		reader, err = gzip.NewReader(bytes.NewReader(content))
+	default:
+		return content, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress kernel module: %w", err)
+	}
+	module, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress kernel module: %w", err)
+	}
+	return module, nil
+}
+
+// compressKernelModule compresses a module for a file with the given suffix.
+// xz modules use CRC32 checks, the only ones the in-kernel decompressor reads.
+func compressKernelModule(module []byte, suffix string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+	switch suffix {
+	case ".ko.xz":
+		writer, err = xz.WriterConfig{CheckSum: xz.CRC32}.NewWriter(&buffer)
+	case ".ko.zst":
+		writer, err = zstd.NewWriter(&buffer)
+	case ".ko.gz":
+		writer = gzip.NewWriter(&buffer)
+	default:
+		return module, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress kernel module: %w", err)
+	}
+	if _, err := writer.Write(module); err != nil {
+		return nil, fmt.Errorf("failed to compress kernel module: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress kernel module: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,169 @@
+package imageos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+// withModuleSignature appends a PKCS#7 signature record to a module
+func withModuleSignature(module []byte) []byte {
+	moduleSignature := make([]byte, 12)
+	moduleSignature[2] = 2
+	binary.BigEndian.PutUint32(moduleSignature[8:], uint32(len("signature")))
+	signed := append(append([]byte{}, module...), "signature"...)
+	return append(append(signed, moduleSignature...), imagesign.ModuleSignatureMagic...)
+}
+
+// moduleSigner appends a signature record to the modules it signs and records them
+type moduleSigner struct {
+	modules []string
+}
+
+func (s *moduleSigner) SignEfiFile(path string) error { return nil }
+
+func (s *moduleSigner) SignData(data []byte) ([]byte, error) { return nil, nil }
+
+func (s *moduleSigner) SignKernelModule(modulePath string) error {
+	module, err := os.ReadFile(modulePath)
+	if err != nil {
+		return err
+	}
+	s.modules = append(s.modules, string(module))
+	return os.WriteFile(modulePath, withModuleSignature(module), 0644)
+}
+
+func writeKernelModule(t *testing.T, installRoot, name string, module []byte) string {
+	t.Helper()
+	modulePath := filepath.Join(installRoot, "usr/lib/modules/6.8.0/extra", name)
+	content, err := compressKernelModule(module, kernelModuleSuffix(name))
+	if err != nil {
+		t.Fatalf("failed to compress %s: %v", name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(modulePath), 0755); err != nil {
+		t.Fatalf("failed to create module directory: %v", err)
+	}
+	if err := os.WriteFile(modulePath, content, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return modulePath
+}
+
+func TestKernelModuleCompression(t *testing.T) {
+	for _, suffix := range kernelModuleSuffixes {
+		modulePath := writeKernelModule(t, t.TempDir(), "driver"+suffix, []byte("module"))
+		module, err := readKernelModule(modulePath, suffix)
+		if err != nil {
+			t.Errorf("failed to read %s module: %v", suffix, err)
+			continue
+		}
+		if string(module) != "module" {
+			t.Errorf("expected the %s module to round trip, got %q", suffix, module)
+		}
+	}
+
+	if name := kernelModuleName("/lib/modules/6.8.0/extra/my-driver.ko.zst"); name != "my_driver" {
+		t.Errorf("expected module name my_driver, got %q", name)
+	}
+}
+
+func TestSignKernelModule(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	signer := &moduleSigner{}
+	unsigned := writeKernelModule(t, installRoot, "driver.ko.zst", []byte("unsigned"))
+	signed := writeKernelModule(t, installRoot, "signed.ko", withModuleSignature([]byte("signed")))
+
+	modules, err := findKernelModules(installRoot)
+	if err != nil || len(modules) != 2 {
+		t.Fatalf("expected two modules, got %v, %v", modules, err)
+	}
+
+	if wasSigned, err := signKernelModule(signer, unsigned); err != nil || !wasSigned {
+		t.Errorf("expected the unsigned module to be signed, got %v, %v", wasSigned, err)
+	}
+	if wasSigned, err := signKernelModule(signer, signed); err != nil || wasSigned {
+		t.Errorf("expected the signed module to be left alone, got %v, %v", wasSigned, err)
+	}
+	if len(signer.modules) != 1 || signer.modules[0] != "unsigned" {
+		t.Errorf("expected only the decompressed unsigned module to be signed, got %q", signer.modules)
+	}
+}
+
+func TestSignImageKernelModules(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	writeKernelModule(t, installRoot, "my-driver.ko", []byte("unsigned"))
+	scriptPath := filepath.Join(t.TempDir(), "sign.sh")
+	if err := os.WriteFile(scriptPath, nil, 0755); err != nil {
+		t.Fatalf("failed to create signing script: %v", err)
+	}
+
+	template := createTestImageTemplate()
+	template.SystemConfig.Kernel.Lockdown = "integrity"
+	if err := signImageKernelModules(installRoot, template); err == nil || !strings.Contains(err.Error(), "needs secure boot") {
+		t.Errorf("expected lockdown without secure boot to fail, got: %v", err)
+	}
+
+	template.SystemConfig.Immutability = config.ImmutabilityConfig{
+		SecureBoot:      true,
+		SecureBootDBKey: "exec:" + scriptPath,
+		SecureBootDBCrt: "/keys/DB.crt",
+		SecureBootDBCer: "/keys/DB.cer",
+	}
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "sign.sh", Output: "", Error: fmt.Errorf("signing service unavailable")},
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+
+	logPath := filepath.Join(t.TempDir(), "build.log")
+	_, cleanup, err := logger.InitWithConfig(logger.Config{Level: "info", FilePath: logPath, FileLevel: "debug"})
+	if err != nil {
+		t.Fatalf("failed to configure log file: %v", err)
+	}
+
+	// Modules that fail to sign only fail the build when they are required
+	if err := signImageKernelModules(installRoot, template); err != nil {
+		t.Errorf("expected an optional module failure to be skipped, got: %v", err)
+	}
+	cleanup()
+	if data, err := os.ReadFile(logPath); err != nil || !strings.Contains(string(data), "secureBootMode db") {
+		t.Errorf("expected a warning that db keys do not verify modules under lockdown, got %s, %v", data, err)
+	}
+	template.SystemConfig.Kernel.EnableExtraModules = "my_driver"
+	if err := signImageKernelModules(installRoot, template); err == nil || !strings.Contains(err.Error(), "my-driver.ko") {
+		t.Errorf("expected a required module failure to fail the build, got: %v", err)
+	}
+
+	// Required modules must be in the image, as a module file or built in
+	template.SystemConfig.Kernel.EnableExtraModules = "my-driver missing-driver"
+	if err := signImageKernelModules(installRoot, template); err == nil || !strings.Contains(err.Error(), "not found") ||
+		!strings.Contains(err.Error(), "missing_driver") || strings.Contains(err.Error(), "my_driver") {
+		t.Errorf("expected the missing required module to fail the build, got: %v", err)
+	}
+	builtinPath := filepath.Join(installRoot, "usr/lib/modules/6.8.0/modules.builtin")
+	if err := os.WriteFile(builtinPath, []byte("kernel/fs/ext4/ext4.ko\n"), 0644); err != nil {
+		t.Fatalf("failed to write modules.builtin: %v", err)
+	}
+	template.SystemConfig.Kernel.EnableExtraModules = "ext4"
+	if err := signImageKernelModules(installRoot, template); err != nil {
+		t.Errorf("expected a built-in required module to be accepted, got: %v", err)
+	}
+}
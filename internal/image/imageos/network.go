@@ -0,0 +1,594 @@
+package imageos
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	networkBackendNetworkd       = "networkd"
+	networkBackendNetplan        = "netplan"
+	networkBackendNetworkManager = "networkmanager"
+
+	// networkdFilePrefix sorts before the distribution dhcp.network files so the template interfaces win
+	networkdFilePrefix  = "/etc/systemd/network/10-os-image-composer-"
+	netplanFile         = "/etc/netplan/90-os-image-composer.yaml"
+	networkManagerDir   = "/etc/NetworkManager/system-connections"
+	networkConfigHeader = "# Generated by OS Image Composer from systemConfig.network\n"
+)
+
+// networkBackendPaths are the files that show a network backend is installed,
+// in order of preference when the template does not name one
+var networkBackendPaths = []struct {
+	backend string
+	paths   []string
+}{
+	{networkBackendNetplan, []string{"/usr/sbin/netplan", "/sbin/netplan"}},
+	{networkBackendNetworkManager, []string{"/usr/sbin/NetworkManager", "/sbin/NetworkManager"}},
+	{networkBackendNetworkd, []string{
+		"/etc/systemd/system/systemd-networkd.service",
+		"/usr/lib/systemd/system/systemd-networkd.service",
+		"/lib/systemd/system/systemd-networkd.service",
+	}},
+}
+
+// networkFile is a rendered network configuration file, relative to the install root
+type networkFile struct {
+	path    string
+	content string
+	secret  bool
+}
+
+// updateImageNetworkConfig renders the systemConfig.network interfaces for the
+// network backend installed in the image
+func updateImageNetworkConfig(installRoot string, template *config.ImageTemplate) error {
+	network := template.SystemConfig.Network
+	if len(network.Interfaces) == 0 {
+		return nil
+	}
+	log.Infof("Configuring network interfaces...")
+
+	if err := checkNetworkInterfaces(network.Interfaces); err != nil {
+		return err
+	}
+
+	backend := network.Backend
+	if backend == "" {
+		backend = detectNetworkBackend(installRoot)
+		if backend == "" {
+			log.Errorf("No network backend found in image")
+			return fmt.Errorf("no network backend found in image, install systemd-networkd, netplan or NetworkManager")
+		}
+		log.Debugf("Detected network backend %s", backend)
+	} else if !networkBackendInstalled(installRoot, backend) {
+		log.Errorf("Network backend %s is not installed in image", backend)
+		return fmt.Errorf("network backend %s is not installed in image", backend)
+	}
+
+	psks := make(map[string]string)
+	for _, iface := range network.Interfaces {
+		if iface.Type != "wifi" {
+			continue
+		}
+		psk, err := template.GetNetworkPSK(iface)
+		if err != nil {
+			return err
+		}
+		psks[iface.Name] = psk
+	}
+
+	var files []networkFile
+	var err error
+	switch backend {
+	case networkBackendNetworkd:
+		files, err = renderNetworkdFiles(network.Interfaces)
+	case networkBackendNetplan:
+		files, err = renderNetplanFiles(network.Interfaces, psks)
+	case networkBackendNetworkManager:
+		files, err = renderNetworkManagerFiles(network.Interfaces, psks)
+	default:
+		err = fmt.Errorf("unsupported network backend: %s", backend)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, networkFile := range files {
+		filePath := filepath.Join(installRoot, networkFile.path)
+		if _, err := shell.ExecCmd("mkdir -p "+filepath.Dir(filePath), true, shell.HostPath, nil); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(filePath), err)
+		}
+		if err := file.Write(networkFile.content, filePath); err != nil {
+			log.Errorf("Failed to write network config %s: %v", filePath, err)
+			return fmt.Errorf("failed to write network config %s: %w", filePath, err)
+		}
+		mode := "0644"
+		if networkFile.secret {
+			mode = "0600"
+		}
+		if _, err := shell.ExecCmd("chmod "+mode+" "+filePath, true, shell.HostPath, nil); err != nil {
+			return fmt.Errorf("failed to set permissions for network config %s: %w", filePath, err)
+		}
+		log.Debugf("Wrote network config %s", filePath)
+	}
+	return nil
+}
+
+// detectNetworkBackend returns the preferred network backend installed in the image, or ""
+func detectNetworkBackend(installRoot string) string {
+	for _, candidate := range networkBackendPaths {
+		if networkBackendInstalled(installRoot, candidate.backend) {
+			return candidate.backend
+		}
+	}
+	return ""
+}
+
+// networkBackendInstalled reports whether the files of the network backend exist in the install root
+func networkBackendInstalled(installRoot, backend string) bool {
+	for _, candidate := range networkBackendPaths {
+		if candidate.backend != backend {
+			continue
+		}
+		for _, path := range candidate.paths {
+			if _, err := os.Stat(filepath.Join(installRoot, path)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkNetworkInterfaces rejects duplicate names, malformed addresses and
+// vlans, bonds or wifi connections missing their required settings
+func checkNetworkInterfaces(ifaces []config.NetworkInterface) error {
+	names := make(map[string]bool)
+	for _, iface := range ifaces {
+		if iface.Name == "" {
+			return fmt.Errorf("network interface without a name")
+		}
+		if names[iface.Name] {
+			return fmt.Errorf("network interface %s is defined more than once", iface.Name)
+		}
+		names[iface.Name] = true
+
+		switch interfaceType(iface) {
+		case "ethernet":
+		case "vlan":
+			if iface.VLANID == 0 || iface.Link == "" {
+				return fmt.Errorf("vlan %s needs vlanId and link", iface.Name)
+			}
+		case "bond":
+			if len(iface.Members) == 0 {
+				return fmt.Errorf("bond %s needs members", iface.Name)
+			}
+		case "wifi":
+			if iface.SSID == "" {
+				return fmt.Errorf("wifi interface %s needs an ssid", iface.Name)
+			}
+		default:
+			return fmt.Errorf("network interface %s has unsupported type %s", iface.Name, iface.Type)
+		}
+
+		for _, address := range iface.Addresses {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				return fmt.Errorf("network interface %s has invalid address %s, expected CIDR notation", iface.Name, address)
+			}
+		}
+		ips := append([]string{}, iface.DNS...)
+		if iface.Gateway != "" {
+			ips = append(ips, iface.Gateway)
+		}
+		for _, route := range iface.Routes {
+			ips = append(ips, route.Via)
+			if route.To != "default" {
+				if _, _, err := net.ParseCIDR(route.To); err != nil {
+					return fmt.Errorf("network interface %s has invalid route destination %s", iface.Name, route.To)
+				}
+			}
+		}
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("network interface %s has invalid IP address %s", iface.Name, ip)
+			}
+		}
+	}
+
+	// A vlan is attached through its parent interface, so the parent must be defined
+	for _, iface := range ifaces {
+		if interfaceType(iface) == "vlan" && !names[iface.Link] {
+			return fmt.Errorf("vlan %s links to %s, which is not a defined network interface", iface.Name, iface.Link)
+		}
+	}
+	return nil
+}
+
+// interfaceType returns the interface type, defaulting to ethernet
+func interfaceType(iface config.NetworkInterface) string {
+	if iface.Type == "" {
+		return "ethernet"
+	}
+	return iface.Type
+}
+
+// bondOf returns the bond the interface is a member of, or ""
+func bondOf(ifaces []config.NetworkInterface, name string) string {
+	for _, iface := range ifaces {
+		if interfaceType(iface) == "bond" && slice.Contains(iface.Members, name) {
+			return iface.Name
+		}
+	}
+	return ""
+}
+
+// undefinedBondMembers returns the bond members not defined as interfaces, with their bond
+func undefinedBondMembers(ifaces []config.NetworkInterface) [][2]string {
+	defined := make(map[string]bool)
+	for _, iface := range ifaces {
+		defined[iface.Name] = true
+	}
+	var members [][2]string
+	for _, iface := range ifaces {
+		if interfaceType(iface) != "bond" {
+			continue
+		}
+		for _, member := range iface.Members {
+			if !defined[member] {
+				members = append(members, [2]string{member, iface.Name})
+			}
+		}
+	}
+	return members
+}
+
+func isIPv6(address string) bool {
+	if ip, _, err := net.ParseCIDR(address); err == nil {
+		return ip.To4() == nil
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
+
+// renderNetworkdFiles returns the systemd-networkd .network and .netdev files of the interfaces
+func renderNetworkdFiles(ifaces []config.NetworkInterface) ([]networkFile, error) {
+	var files []networkFile
+	for _, iface := range ifaces {
+		ifaceType := interfaceType(iface)
+		if ifaceType == "wifi" {
+			return nil, fmt.Errorf("wifi interface %s is not supported by the networkd backend, use netplan or networkmanager", iface.Name)
+		}
+
+		if ifaceType == "vlan" || ifaceType == "bond" {
+			var netdev strings.Builder
+			netdev.WriteString(networkConfigHeader)
+			fmt.Fprintf(&netdev, "[NetDev]\nName=%s\nKind=%s\n", iface.Name, ifaceType)
+			if iface.MTU != 0 {
+				fmt.Fprintf(&netdev, "MTUBytes=%d\n", iface.MTU)
+			}
+			if ifaceType == "vlan" {
+				fmt.Fprintf(&netdev, "\n[VLAN]\nId=%d\n", iface.VLANID)
+			} else if iface.BondMode != "" {
+				fmt.Fprintf(&netdev, "\n[Bond]\nMode=%s\n", iface.BondMode)
+			}
+			files = append(files, networkFile{path: networkdFilePrefix + iface.Name + ".netdev", content: netdev.String()})
+		}
+
+		var network strings.Builder
+		network.WriteString(networkConfigHeader)
+		network.WriteString("[Match]\n")
+		if ifaceType == "ethernet" && (iface.Match.Name != "" || iface.Match.MACAddress != "") {
+			if iface.Match.Name != "" {
+				fmt.Fprintf(&network, "Name=%s\n", iface.Match.Name)
+			}
+			if iface.Match.MACAddress != "" {
+				fmt.Fprintf(&network, "MACAddress=%s\n", iface.Match.MACAddress)
+			}
+		} else {
+			fmt.Fprintf(&network, "Name=%s\n", iface.Name)
+		}
+		if iface.MTU != 0 && ifaceType == "ethernet" {
+			fmt.Fprintf(&network, "\n[Link]\nMTUBytes=%d\n", iface.MTU)
+		}
+
+		network.WriteString("\n[Network]\n")
+		if bond := bondOf(ifaces, iface.Name); bond != "" {
+			fmt.Fprintf(&network, "Bond=%s\n", bond)
+		} else {
+			switch {
+			case iface.DHCP4 && iface.DHCP6:
+				network.WriteString("DHCP=yes\n")
+			case iface.DHCP4:
+				network.WriteString("DHCP=ipv4\n")
+			case iface.DHCP6:
+				network.WriteString("DHCP=ipv6\n")
+			}
+			for _, address := range iface.Addresses {
+				fmt.Fprintf(&network, "Address=%s\n", address)
+			}
+			if iface.Gateway != "" {
+				fmt.Fprintf(&network, "Gateway=%s\n", iface.Gateway)
+			}
+			for _, dns := range iface.DNS {
+				fmt.Fprintf(&network, "DNS=%s\n", dns)
+			}
+			if len(iface.Domains) > 0 {
+				fmt.Fprintf(&network, "Domains=%s\n", strings.Join(iface.Domains, " "))
+			}
+		}
+		for _, vlan := range ifaces {
+			if interfaceType(vlan) == "vlan" && vlan.Link == iface.Name {
+				fmt.Fprintf(&network, "VLAN=%s\n", vlan.Name)
+			}
+		}
+		for _, route := range iface.Routes {
+			network.WriteString("\n[Route]\n")
+			if route.To != "default" {
+				fmt.Fprintf(&network, "Destination=%s\n", route.To)
+			}
+			fmt.Fprintf(&network, "Gateway=%s\n", route.Via)
+			if route.Metric != 0 {
+				fmt.Fprintf(&network, "Metric=%d\n", route.Metric)
+			}
+		}
+		files = append(files, networkFile{path: networkdFilePrefix + iface.Name + ".network", content: network.String()})
+	}
+
+	for _, member := range undefinedBondMembers(ifaces) {
+		content := fmt.Sprintf("%s[Match]\nName=%s\n\n[Network]\nBond=%s\n", networkConfigHeader, member[0], member[1])
+		files = append(files, networkFile{path: networkdFilePrefix + member[0] + ".network", content: content})
+	}
+	return files, nil
+}
+
+// netplanMatch is the match section of a netplan device
+type netplanMatch struct {
+	Name       string `yaml:"name,omitempty"`
+	MACAddress string `yaml:"macaddress,omitempty"`
+}
+
+// netplanRoute is a netplan route
+type netplanRoute struct {
+	To     string `yaml:"to"`
+	Via    string `yaml:"via"`
+	Metric int    `yaml:"metric,omitempty"`
+}
+
+// netplanNameservers is the nameservers section of a netplan device
+type netplanNameservers struct {
+	Addresses []string `yaml:"addresses,omitempty"`
+	Search    []string `yaml:"search,omitempty"`
+}
+
+// netplanAccessPoint is a netplan wifi access point
+type netplanAccessPoint struct {
+	Password string `yaml:"password,omitempty"`
+}
+
+// netplanDevice holds the netplan settings shared by ethernets, vlans, bonds and wifis
+type netplanDevice struct {
+	Match        *netplanMatch                 `yaml:"match,omitempty"`
+	Interfaces   []string                      `yaml:"interfaces,omitempty"`
+	Parameters   map[string]string             `yaml:"parameters,omitempty"`
+	ID           int                           `yaml:"id,omitempty"`
+	Link         string                        `yaml:"link,omitempty"`
+	AccessPoints map[string]netplanAccessPoint `yaml:"access-points,omitempty"`
+	DHCP4        bool                          `yaml:"dhcp4,omitempty"`
+	DHCP6        bool                          `yaml:"dhcp6,omitempty"`
+	Addresses    []string                      `yaml:"addresses,omitempty"`
+	Routes       []netplanRoute                `yaml:"routes,omitempty"`
+	Nameservers  *netplanNameservers           `yaml:"nameservers,omitempty"`
+	MTU          int                           `yaml:"mtu,omitempty"`
+}
+
+// netplanNetwork is the network section of a netplan file
+type netplanNetwork struct {
+	Version   int                      `yaml:"version"`
+	Ethernets map[string]netplanDevice `yaml:"ethernets,omitempty"`
+	VLANs     map[string]netplanDevice `yaml:"vlans,omitempty"`
+	Bonds     map[string]netplanDevice `yaml:"bonds,omitempty"`
+	WiFis     map[string]netplanDevice `yaml:"wifis,omitempty"`
+}
+
+// renderNetplanFiles returns the netplan file of the interfaces
+func renderNetplanFiles(ifaces []config.NetworkInterface, psks map[string]string) ([]networkFile, error) {
+	network := netplanNetwork{Version: 2}
+	add := func(devices *map[string]netplanDevice, name string, device netplanDevice) {
+		if *devices == nil {
+			*devices = make(map[string]netplanDevice)
+		}
+		(*devices)[name] = device
+	}
+
+	for _, iface := range ifaces {
+		device := netplanDevice{
+			DHCP4:     iface.DHCP4,
+			DHCP6:     iface.DHCP6,
+			Addresses: iface.Addresses,
+			MTU:       iface.MTU,
+		}
+		if iface.Match.Name != "" || iface.Match.MACAddress != "" {
+			device.Match = &netplanMatch{Name: iface.Match.Name, MACAddress: iface.Match.MACAddress}
+		}
+		if iface.Gateway != "" {
+			device.Routes = append(device.Routes, netplanRoute{To: "default", Via: iface.Gateway})
+		}
+		for _, route := range iface.Routes {
+			device.Routes = append(device.Routes, netplanRoute{To: route.To, Via: route.Via, Metric: route.Metric})
+		}
+		if len(iface.DNS) > 0 || len(iface.Domains) > 0 {
+			device.Nameservers = &netplanNameservers{Addresses: iface.DNS, Search: iface.Domains}
+		}
+
+		switch interfaceType(iface) {
+		case "ethernet":
+			add(&network.Ethernets, iface.Name, device)
+		case "vlan":
+			device.ID = iface.VLANID
+			device.Link = iface.Link
+			add(&network.VLANs, iface.Name, device)
+		case "bond":
+			device.Interfaces = iface.Members
+			if iface.BondMode != "" {
+				device.Parameters = map[string]string{"mode": iface.BondMode}
+			}
+			add(&network.Bonds, iface.Name, device)
+		case "wifi":
+			device.AccessPoints = map[string]netplanAccessPoint{iface.SSID: {Password: psks[iface.Name]}}
+			add(&network.WiFis, iface.Name, device)
+		}
+	}
+	for _, member := range undefinedBondMembers(ifaces) {
+		add(&network.Ethernets, member[0], netplanDevice{})
+	}
+
+	data, err := yaml.Marshal(map[string]netplanNetwork{"network": network})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render netplan config: %w", err)
+	}
+	// netplan warns about world-readable files, they may hold wifi passphrases
+	return []networkFile{{path: netplanFile, content: networkConfigHeader + string(data), secret: true}}, nil
+}
+
+// renderNetworkManagerFiles returns the NetworkManager keyfiles of the interfaces
+func renderNetworkManagerFiles(ifaces []config.NetworkInterface, psks map[string]string) ([]networkFile, error) {
+	var files []networkFile
+	for _, iface := range ifaces {
+		ifaceType := interfaceType(iface)
+		var keyfile strings.Builder
+		keyfile.WriteString(networkConfigHeader)
+		fmt.Fprintf(&keyfile, "[connection]\nid=%s\ntype=%s\n", iface.Name, ifaceType)
+		matched := ifaceType == "ethernet" && (iface.Match.Name != "" || iface.Match.MACAddress != "")
+		if !matched {
+			fmt.Fprintf(&keyfile, "interface-name=%s\n", iface.Name)
+		}
+		bond := bondOf(ifaces, iface.Name)
+		if bond != "" {
+			fmt.Fprintf(&keyfile, "master=%s\nslave-type=bond\n", bond)
+		}
+		if iface.Match.Name != "" && matched {
+			fmt.Fprintf(&keyfile, "\n[match]\ninterface-name=%s\n", iface.Match.Name)
+		}
+
+		switch ifaceType {
+		case "ethernet", "vlan", "bond":
+			if iface.Match.MACAddress != "" || iface.MTU != 0 {
+				keyfile.WriteString("\n[ethernet]\n")
+				if iface.Match.MACAddress != "" && matched {
+					fmt.Fprintf(&keyfile, "mac-address=%s\n", iface.Match.MACAddress)
+				}
+				if iface.MTU != 0 {
+					fmt.Fprintf(&keyfile, "mtu=%d\n", iface.MTU)
+				}
+			}
+		case "wifi":
+			fmt.Fprintf(&keyfile, "\n[wifi]\nmode=infrastructure\nssid=%s\n", keyfileEscape(iface.SSID))
+			if iface.MTU != 0 {
+				fmt.Fprintf(&keyfile, "mtu=%d\n", iface.MTU)
+			}
+			if psk := psks[iface.Name]; psk != "" {
+				fmt.Fprintf(&keyfile, "\n[wifi-security]\nkey-mgmt=wpa-psk\npsk=%s\n", keyfileEscape(psk))
+			}
+		}
+		if ifaceType == "vlan" {
+			fmt.Fprintf(&keyfile, "\n[vlan]\nid=%d\nparent=%s\n", iface.VLANID, iface.Link)
+		}
+		if ifaceType == "bond" && iface.BondMode != "" {
+			fmt.Fprintf(&keyfile, "\n[bond]\nmode=%s\n", iface.BondMode)
+		}
+
+		if bond == "" {
+			keyfile.WriteString(renderNetworkManagerIP(iface, false))
+			keyfile.WriteString(renderNetworkManagerIP(iface, true))
+		}
+		files = append(files, networkFile{
+			path:    filepath.Join(networkManagerDir, iface.Name+".nmconnection"),
+			content: keyfile.String(),
+			secret:  true,
+		})
+	}
+
+	for _, member := range undefinedBondMembers(ifaces) {
+		content := fmt.Sprintf("%s[connection]\nid=%s-%s\ntype=ethernet\ninterface-name=%s\nmaster=%s\nslave-type=bond\n",
+			networkConfigHeader, member[1], member[0], member[0], member[1])
+		files = append(files, networkFile{
+			path:    filepath.Join(networkManagerDir, member[1]+"-"+member[0]+".nmconnection"),
+			content: content,
+			secret:  true,
+		})
+	}
+	return files, nil
+}
+
+// renderNetworkManagerIP returns the [ipv4] or [ipv6] section of a NetworkManager keyfile
+func renderNetworkManagerIP(iface config.NetworkInterface, ipv6 bool) string {
+	section, dhcp, defaultDest := "ipv4", iface.DHCP4, "0.0.0.0/0"
+	if ipv6 {
+		section, dhcp, defaultDest = "ipv6", iface.DHCP6, "::/0"
+	}
+
+	var addresses, dns []string
+	for _, address := range iface.Addresses {
+		if isIPv6(address) == ipv6 {
+			addresses = append(addresses, address)
+		}
+	}
+	for _, server := range iface.DNS {
+		if isIPv6(server) == ipv6 {
+			dns = append(dns, server)
+		}
+	}
+
+	var lines []string
+	switch {
+	case dhcp:
+		lines = append(lines, "method=auto")
+	case len(addresses) > 0:
+		lines = append(lines, "method=manual")
+	default:
+		lines = append(lines, "method=disabled")
+	}
+	for i, address := range addresses {
+		lines = append(lines, fmt.Sprintf("address%d=%s", i+1, address))
+	}
+	if iface.Gateway != "" && isIPv6(iface.Gateway) == ipv6 {
+		lines = append(lines, "gateway="+iface.Gateway)
+	}
+	if len(dns) > 0 {
+		lines = append(lines, "dns="+strings.Join(dns, ";")+";")
+	}
+	if len(iface.Domains) > 0 && (dhcp || len(addresses) > 0) {
+		lines = append(lines, "dns-search="+strings.Join(iface.Domains, ";")+";")
+	}
+	routeIndex := 1
+	for _, route := range iface.Routes {
+		if isIPv6(route.Via) != ipv6 {
+			continue
+		}
+		dest := route.To
+		if dest == "default" {
+			dest = defaultDest
+		}
+		value := dest + "," + route.Via
+		if route.Metric != 0 {
+			value += "," + strconv.Itoa(route.Metric)
+		}
+		lines = append(lines, fmt.Sprintf("route%d=%s", routeIndex, value))
+		routeIndex++
+	}
+	return "\n[" + section + "]\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// keyfileEscape escapes a keyfile string value
+func keyfileEscape(value string) string {
+	return strings.ReplaceAll(value, `\`, `\\`)
+}
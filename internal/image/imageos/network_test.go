@@ -0,0 +1,283 @@
+package imageos
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"gopkg.in/yaml.v3"
+)
+
+// testNetworkInterfaces returns a static ethernet carrying a vlan, and a bond of two ports
+func testNetworkInterfaces() []config.NetworkInterface {
+	return []config.NetworkInterface{
+		{
+			Name:      "lan",
+			Match:     config.NetworkMatch{MACAddress: "52:54:00:12:34:56"},
+			Addresses: []string{"192.168.1.10/24", "fd00::10/64"},
+			Gateway:   "192.168.1.1",
+			DNS:       []string{"192.168.1.1", "fd00::1"},
+			Domains:   []string{"example.com"},
+			Routes:    []config.NetworkRoute{{To: "10.0.0.0/8", Via: "192.168.1.254", Metric: 100}},
+			MTU:       9000,
+		},
+		{Name: "lan.100", Type: "vlan", VLANID: 100, Link: "lan", DHCP4: true},
+		{Name: "bond0", Type: "bond", Members: []string{"eno1", "eno2"}, BondMode: "active-backup", DHCP4: true, DHCP6: true},
+		{Name: "eno1"},
+	}
+}
+
+// findNetworkFile returns the content of the rendered file with the path, failing the test if it is missing
+func findNetworkFile(t *testing.T, files []networkFile, path string) networkFile {
+	t.Helper()
+	for _, networkFile := range files {
+		if networkFile.path == path {
+			return networkFile
+		}
+	}
+	t.Fatalf("expected network file %s to be rendered", path)
+	return networkFile{}
+}
+
+func TestCheckNetworkInterfaces(t *testing.T) {
+	if err := checkNetworkInterfaces(testNetworkInterfaces()); err != nil {
+		t.Errorf("expected valid interfaces, got: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ifaces []config.NetworkInterface
+		errMsg string
+	}{
+		{"duplicate", []config.NetworkInterface{{Name: "lan"}, {Name: "lan"}}, "more than once"},
+		{"vlan without link", []config.NetworkInterface{{Name: "v", Type: "vlan", VLANID: 10}}, "vlanId and link"},
+		{"vlan with unknown link", []config.NetworkInterface{{Name: "lan"}, {Name: "v", Type: "vlan", VLANID: 10, Link: "eth9"}}, "not a defined network interface"},
+		{"bond without members", []config.NetworkInterface{{Name: "bond0", Type: "bond"}}, "needs members"},
+		{"wifi without ssid", []config.NetworkInterface{{Name: "wlan0", Type: "wifi"}}, "ssid"},
+		{"unknown type", []config.NetworkInterface{{Name: "x", Type: "bridge"}}, "unsupported type"},
+		{"address without prefix", []config.NetworkInterface{{Name: "lan", Addresses: []string{"192.168.1.10"}}}, "CIDR"},
+		{"bad gateway", []config.NetworkInterface{{Name: "lan", Gateway: "router"}}, "invalid IP address"},
+		{"bad route", []config.NetworkInterface{{Name: "lan", Routes: []config.NetworkRoute{{To: "10.0.0.0", Via: "10.0.0.1"}}}}, "route destination"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkNetworkInterfaces(tt.ifaces)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got: %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestRenderNetworkdFiles(t *testing.T) {
+	files, err := renderNetworkdFiles(testNetworkInterfaces())
+	if err != nil {
+		t.Fatalf("renderNetworkdFiles failed: %v", err)
+	}
+	if len(files) != 7 {
+		t.Errorf("expected 7 files, got %d", len(files))
+	}
+
+	lan := findNetworkFile(t, files, networkdFilePrefix+"lan.network").content
+	for _, line := range []string{
+		"MACAddress=52:54:00:12:34:56", "MTUBytes=9000", "Address=192.168.1.10/24", "Address=fd00::10/64",
+		"Gateway=192.168.1.1", "DNS=fd00::1", "Domains=example.com", "VLAN=lan.100",
+		"[Route]\nDestination=10.0.0.0/8\nGateway=192.168.1.254\nMetric=100",
+	} {
+		if !strings.Contains(lan, line) {
+			t.Errorf("expected %q in lan.network, got:\n%s", line, lan)
+		}
+	}
+	if strings.Contains(lan, "DHCP=") {
+		t.Errorf("expected no DHCP for a static interface, got:\n%s", lan)
+	}
+
+	vlan := findNetworkFile(t, files, networkdFilePrefix+"lan.100.netdev").content
+	if !strings.Contains(vlan, "Kind=vlan") || !strings.Contains(vlan, "[VLAN]\nId=100") {
+		t.Errorf("unexpected vlan netdev:\n%s", vlan)
+	}
+	bond := findNetworkFile(t, files, networkdFilePrefix+"bond0.netdev").content
+	if !strings.Contains(bond, "Kind=bond") || !strings.Contains(bond, "Mode=active-backup") {
+		t.Errorf("unexpected bond netdev:\n%s", bond)
+	}
+	if content := findNetworkFile(t, files, networkdFilePrefix+"bond0.network").content; !strings.Contains(content, "DHCP=yes") {
+		t.Errorf("expected DHCP on the bond, got:\n%s", content)
+	}
+
+	// A defined member gets Bond= instead of addressing, an undefined one gets its own file
+	for _, member := range []string{"eno1", "eno2"} {
+		content := findNetworkFile(t, files, networkdFilePrefix+member+".network").content
+		if !strings.Contains(content, "Name="+member) || !strings.Contains(content, "Bond=bond0") {
+			t.Errorf("unexpected bond member %s:\n%s", member, content)
+		}
+	}
+
+	if _, err := renderNetworkdFiles([]config.NetworkInterface{{Name: "wlan0", Type: "wifi", SSID: "office"}}); err == nil {
+		t.Error("expected an error for wifi with the networkd backend")
+	}
+}
+
+func TestRenderNetplanFiles(t *testing.T) {
+	ifaces := append(testNetworkInterfaces(), config.NetworkInterface{
+		Name: "wlan0", Type: "wifi", SSID: "office", DHCP4: true,
+	})
+	files, err := renderNetplanFiles(ifaces, map[string]string{"wlan0": "correct horse"})
+	if err != nil {
+		t.Fatalf("renderNetplanFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].path != netplanFile || !files[0].secret {
+		t.Fatalf("expected a single secret netplan file, got %+v", files)
+	}
+
+	var parsed map[string]netplanNetwork
+	if err := yaml.Unmarshal([]byte(files[0].content), &parsed); err != nil {
+		t.Fatalf("rendered netplan is not valid YAML: %v", err)
+	}
+	network := parsed["network"]
+	if network.Version != 2 {
+		t.Errorf("expected netplan version 2, got %d", network.Version)
+	}
+
+	lan := network.Ethernets["lan"]
+	if lan.Match == nil || lan.Match.MACAddress != "52:54:00:12:34:56" {
+		t.Errorf("expected a MAC match on lan, got %+v", lan.Match)
+	}
+	if len(lan.Routes) != 2 || lan.Routes[0].To != "default" || lan.Routes[0].Via != "192.168.1.1" {
+		t.Errorf("expected the gateway as the default route, got %+v", lan.Routes)
+	}
+	if lan.Nameservers == nil || len(lan.Nameservers.Addresses) != 2 || lan.Nameservers.Search[0] != "example.com" {
+		t.Errorf("unexpected nameservers %+v", lan.Nameservers)
+	}
+	if _, ok := network.Ethernets["eno2"]; !ok {
+		t.Error("expected the undefined bond member eno2 to be declared as an ethernet")
+	}
+	if vlan := network.VLANs["lan.100"]; vlan.ID != 100 || vlan.Link != "lan" {
+		t.Errorf("unexpected vlan %+v", vlan)
+	}
+	if bond := network.Bonds["bond0"]; len(bond.Interfaces) != 2 || bond.Parameters["mode"] != "active-backup" {
+		t.Errorf("unexpected bond %+v", bond)
+	}
+	if wifi := network.WiFis["wlan0"]; wifi.AccessPoints["office"].Password != "correct horse" {
+		t.Errorf("unexpected wifi %+v", wifi)
+	}
+}
+
+func TestRenderNetworkManagerFiles(t *testing.T) {
+	ifaces := append(testNetworkInterfaces(), config.NetworkInterface{
+		Name: "wlan0", Type: "wifi", SSID: "office", DHCP4: true,
+	})
+	files, err := renderNetworkManagerFiles(ifaces, map[string]string{"wlan0": `correct\horse`})
+	if err != nil {
+		t.Fatalf("renderNetworkManagerFiles failed: %v", err)
+	}
+	for _, networkFile := range files {
+		if !networkFile.secret {
+			t.Errorf("expected keyfile %s to be readable by root only", networkFile.path)
+		}
+	}
+
+	lan := findNetworkFile(t, files, filepath.Join(networkManagerDir, "lan.nmconnection")).content
+	for _, line := range []string{
+		"type=ethernet", "mac-address=52:54:00:12:34:56", "mtu=9000",
+		"[ipv4]\nmethod=manual\naddress1=192.168.1.10/24\ngateway=192.168.1.1\ndns=192.168.1.1;",
+		"route1=10.0.0.0/8,192.168.1.254,100",
+		"[ipv6]\nmethod=manual\naddress1=fd00::10/64\ndns=fd00::1;",
+	} {
+		if !strings.Contains(lan, line) {
+			t.Errorf("expected %q in lan keyfile, got:\n%s", line, lan)
+		}
+	}
+	if strings.Contains(lan, "interface-name=lan") {
+		t.Errorf("expected a MAC matched interface to have no interface-name, got:\n%s", lan)
+	}
+
+	vlan := findNetworkFile(t, files, filepath.Join(networkManagerDir, "lan.100.nmconnection")).content
+	if !strings.Contains(vlan, "[vlan]\nid=100\nparent=lan") || !strings.Contains(vlan, "[ipv6]\nmethod=disabled") {
+		t.Errorf("unexpected vlan keyfile:\n%s", vlan)
+	}
+
+	member := findNetworkFile(t, files, filepath.Join(networkManagerDir, "eno1.nmconnection")).content
+	if !strings.Contains(member, "master=bond0\nslave-type=bond") || strings.Contains(member, "[ipv4]") {
+		t.Errorf("expected a bond port without addressing, got:\n%s", member)
+	}
+	findNetworkFile(t, files, filepath.Join(networkManagerDir, "bond0-eno2.nmconnection"))
+
+	wifi := findNetworkFile(t, files, filepath.Join(networkManagerDir, "wlan0.nmconnection")).content
+	if !strings.Contains(wifi, "ssid=office") || !strings.Contains(wifi, "key-mgmt=wpa-psk\npsk=correct\\\\horse") {
+		t.Errorf("unexpected wifi keyfile:\n%s", wifi)
+	}
+}
+
+func TestDetectNetworkBackend(t *testing.T) {
+	installRoot := t.TempDir()
+	if backend := detectNetworkBackend(installRoot); backend != "" {
+		t.Errorf("expected no backend in an empty root, got %q", backend)
+	}
+
+	for _, path := range []string{"lib/systemd/system/systemd-networkd.service", "usr/sbin/NetworkManager", "usr/sbin/netplan"} {
+		fullPath := filepath.Join(installRoot, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(fullPath), err)
+		}
+		if err := os.WriteFile(fullPath, nil, 0755); err != nil {
+			t.Fatalf("failed to write %s: %v", fullPath, err)
+		}
+		backend := detectNetworkBackend(installRoot)
+		expected := map[string]string{
+			"lib/systemd/system/systemd-networkd.service": networkBackendNetworkd,
+			"usr/sbin/NetworkManager":                     networkBackendNetworkManager,
+			"usr/sbin/netplan":                            networkBackendNetplan,
+		}[path]
+		if backend != expected {
+			t.Errorf("after adding %s expected backend %s, got %q", path, expected, backend)
+		}
+	}
+	if !networkBackendInstalled(installRoot, networkBackendNetworkd) {
+		t.Error("expected networkd to be reported as installed")
+	}
+}
+
+func TestUpdateImageNetworkConfig(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: ".*", Output: "", Error: nil},
+	})
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No interfaces configured is a no-op
+	if err := updateImageNetworkConfig(installRoot, template); err != nil {
+		t.Errorf("expected no error without interfaces, got: %v", err)
+	}
+
+	template.SystemConfig.Network = config.NetworkConfig{Interfaces: testNetworkInterfaces()}
+	if err := updateImageNetworkConfig(installRoot, template); err == nil || !strings.Contains(err.Error(), "no network backend") {
+		t.Errorf("expected an error without a network backend, got: %v", err)
+	}
+
+	template.SystemConfig.Network.Backend = networkBackendNetplan
+	if err := updateImageNetworkConfig(installRoot, template); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("expected an error for a backend missing from the image, got: %v", err)
+	}
+
+	unitPath := filepath.Join(installRoot, "usr", "lib", "systemd", "system", "systemd-networkd.service")
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		t.Fatalf("failed to create unit directory: %v", err)
+	}
+	if err := os.WriteFile(unitPath, []byte("[Unit]\n"), 0644); err != nil {
+		t.Fatalf("failed to write unit: %v", err)
+	}
+	template.SystemConfig.Network.Backend = ""
+	if err := updateImageNetworkConfig(installRoot, template); err != nil {
+		t.Errorf("updateImageNetworkConfig failed: %v", err)
+	}
+}
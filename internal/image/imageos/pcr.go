@@ -0,0 +1,216 @@
+package imageos
+
+import (
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"debug/pe"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imageboot"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/security"
+)
+
+const (
+	// PCRPredictionFile is staged in the temp directory like the size report
+	// and copied next to the image artifacts
+	PCRPredictionFile = "pcr_prediction.json"
+
+	// ukiPCR is the PCR systemd-stub measures the UKI sections into
+	ukiPCR = 11
+)
+
+// ukiMeasuredSections are the UKI sections systemd-stub measures, in the
+// order it measures them
+var ukiMeasuredSections = []string{
+	".linux", ".osrel", ".cmdline", ".initrd", ".ucode", ".splash", ".dtb", ".uname", ".sbat", ".pcrpkey",
+}
+
+// ukiBootPhases are the boot phases systemd-pcrphase measures; the policy is
+// signed for the PCR value at each of them
+var ukiBootPhases = []string{"enter-initrd", "leave-initrd", "sysinit", "ready"}
+
+var pcrBankHashes = map[string]crypto.Hash{
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// PCRValue is a predicted PCR value at a boot phase, as systemd-measure
+// reports it
+type PCRValue struct {
+	Phase string `json:"phase"`
+	PCR   int    `json:"pcr"`
+	Hash  string `json:"hash"`
+}
+
+// ukiPCRSigningArgs returns the ukify options that sign the PCR policy of the
+// UKI. The keys are host paths: ukify runs on the host when it signs, so the
+// private key never enters the image.
+func ukiPCRSigningArgs(template *config.ImageTemplate) (string, error) {
+	pcrSigning := template.GetKernel().PCRSigning
+	if !pcrSigning.IsEnabled() {
+		return "", nil
+	}
+
+	publicKey := pcrSigning.PublicKey
+	if publicKey == "" {
+		publicKeyPEM, err := imagesign.PublicKeyPEM(pcrSigning.PrivateKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to derive PCR public key: %w", err)
+		}
+		publicKey = filepath.Join(config.TempDir(), "pcr-public-key.pem")
+		if err := security.SafeWriteFile(publicKey, publicKeyPEM, 0644, security.RejectSymlinks); err != nil {
+			return "", fmt.Errorf("failed to write PCR public key: %w", err)
+		}
+	}
+
+	return fmt.Sprintf(" --pcr-private-key \"%s\" --pcr-public-key \"%s\" --pcr-banks %s",
+		pcrSigning.PrivateKey, publicKey, strings.Join(pcrSigning.GetBanks(), ",")), nil
+}
+
+// imageUKIStub returns the host path of the systemd-stub installed in the
+// image, or an empty string when there is none
+func imageUKIStub(installRoot string) string {
+	stubs, _ := filepath.Glob(filepath.Join(installRoot, "usr/lib/systemd/boot/efi/linux*.efi.stub"))
+	if len(stubs) == 0 {
+		return ""
+	}
+	return stubs[0]
+}
+
+// pcrPredictionFileName returns the prediction file of a boot entry's UKI; the
+// default entry's goes to PCRPredictionFile
+func pcrPredictionFileName(entry imageboot.BootEntry) string {
+	if entry.Default {
+		return PCRPredictionFile
+	}
+	return strings.TrimSuffix(PCRPredictionFile, ".json") + "-" + entry.Name + ".json"
+}
+
+// writePCRPrediction stages the PCR 11 values the UKI produces at each boot
+// phase, for TPM2 policies and remote attestation
+func writePCRPrediction(ukiPath, predictionFile string, template *config.ImageTemplate) error {
+	sections, err := readUKISections(ukiPath)
+	if err != nil {
+		return err
+	}
+	prediction, err := predictUKIPCRs(sections, template.GetKernel().PCRSigning.GetBanks())
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(prediction, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode PCR prediction: %w", err)
+	}
+
+	predictionPath := filepath.Join(config.TempDir(), predictionFile)
+	if err := security.SafeWriteFile(predictionPath, append(data, '\n'), 0644, security.RejectSymlinks); err != nil {
+		log.Errorf("Failed to write PCR prediction: %v", err)
+		return fmt.Errorf("failed to write PCR prediction: %w", err)
+	}
+	log.Infof("PCR prediction written to %s", predictionPath)
+	return nil
+}
+
+// readUKISections returns the measured sections of a UKI, each cut to its
+// virtual size as the stub measures it
+func readUKISections(ukiPath string) (map[string][]byte, error) {
+	ukiFile, err := pe.Open(ukiPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UKI %s: %w", ukiPath, err)
+	}
+	defer ukiFile.Close()
+
+	sections := make(map[string][]byte)
+	for _, name := range ukiMeasuredSections {
+		section := ukiFile.Section(name)
+		if section == nil {
+			continue
+		}
+		data, err := section.Data()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read UKI section %s: %w", name, err)
+		}
+		if uint32(len(data)) > section.VirtualSize {
+			data = data[:section.VirtualSize]
+		}
+		sections[name] = data
+	}
+	if sections[".linux"] == nil {
+		return nil, fmt.Errorf("UKI %s has no .linux section", ukiPath)
+	}
+	return sections, nil
+}
+
+// predictUKIPCRs computes PCR 11 by bank and boot phase: systemd-stub extends
+// it with the name and the content of every section, then systemd-pcrphase
+// with each boot phase
+func predictUKIPCRs(sections map[string][]byte, banks []string) (map[string][]PCRValue, error) {
+	prediction := make(map[string][]PCRValue)
+	for _, bank := range banks {
+		hash, ok := pcrBankHashes[bank]
+		if !ok {
+			return nil, fmt.Errorf("unsupported PCR bank %s", bank)
+		}
+		pcr := make([]byte, hash.Size())
+		for _, name := range ukiMeasuredSections {
+			data, ok := sections[name]
+			if !ok {
+				continue
+			}
+			pcr = extendPCR(hash, pcr, append([]byte(name), 0))
+			pcr = extendPCR(hash, pcr, data)
+		}
+		for i, phase := range ukiBootPhases {
+			pcr = extendPCR(hash, pcr, []byte(phase))
+			prediction[bank] = append(prediction[bank], PCRValue{
+				Phase: strings.Join(ukiBootPhases[:i+1], ":"),
+				PCR:   ukiPCR,
+				Hash:  hex.EncodeToString(pcr),
+			})
+		}
+	}
+	return prediction, nil
+}
+
+// extendPCR returns the PCR value after extending it with the digest of data
+func extendPCR(hash crypto.Hash, pcr, data []byte) []byte {
+	digest := hash.New()
+	digest.Write(data)
+	extend := hash.New()
+	extend.Write(pcr)
+	extend.Write(digest.Sum(nil))
+	return extend.Sum(nil)
+}
+
+// CopyPCRPredictionToImageBuildDir copies the PCR predictions from the temp
+// directory next to the image artifacts, if the build produced any
+func CopyPCRPredictionToImageBuildDir(imageBuildDir string) error {
+	pattern := strings.TrimSuffix(PCRPredictionFile, ".json") + "*.json"
+	predictions, err := filepath.Glob(filepath.Join(config.TempDir(), pattern))
+	if err != nil {
+		return fmt.Errorf("failed to list PCR predictions: %w", err)
+	}
+	for _, srcPrediction := range predictions {
+		data, err := security.SafeReadFile(srcPrediction, security.RejectSymlinks)
+		if err != nil {
+			log.Errorf("Failed to read PCR prediction: %v", err)
+			return fmt.Errorf("failed to read PCR prediction: %w", err)
+		}
+		dstPrediction := filepath.Join(imageBuildDir, filepath.Base(srcPrediction))
+		if err := security.SafeWriteFile(dstPrediction, data, 0644, security.RejectSymlinks); err != nil {
+			log.Errorf("Failed to write PCR prediction to image build directory: %v", err)
+			return fmt.Errorf("failed to write PCR prediction to image build directory: %w", err)
+		}
+	}
+	return nil
+}
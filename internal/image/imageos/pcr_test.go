@@ -0,0 +1,220 @@
+package imageos
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+// writeTestUKI writes a minimal PE file with the given sections, each padded
+// to a raw size larger than its virtual size
+func writeTestUKI(t *testing.T, names []string, contents []string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	dosHeader := make([]byte, 64)
+	copy(dosHeader, "MZ")
+	binary.LittleEndian.PutUint32(dosHeader[0x3c:], 64)
+	buf.Write(dosHeader)
+	buf.WriteString("PE\x00\x00")
+	binary.Write(&buf, binary.LittleEndian, pe.FileHeader{Machine: pe.IMAGE_FILE_MACHINE_AMD64, NumberOfSections: uint16(len(names))})
+
+	dataOffset := uint32(buf.Len() + 40*len(names))
+	for i, name := range names {
+		header := pe.SectionHeader32{
+			VirtualSize:      uint32(len(contents[i])),
+			VirtualAddress:   uint32(0x1000 * (i + 1)),
+			SizeOfRawData:    uint32(len(contents[i]) + 16),
+			PointerToRawData: dataOffset,
+		}
+		copy(header.Name[:], name)
+		binary.Write(&buf, binary.LittleEndian, header)
+		dataOffset += header.SizeOfRawData
+	}
+	for _, content := range contents {
+		buf.WriteString(content)
+		buf.Write(make([]byte, 16))
+	}
+
+	ukiPath := filepath.Join(t.TempDir(), "linux.efi")
+	if err := os.WriteFile(ukiPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write UKI: %v", err)
+	}
+	return ukiPath
+}
+
+func TestPredictUKIPCRs(t *testing.T) {
+	// Expected values from systemd-measure calculate for the same sections
+	sections := map[string][]byte{
+		".linux":   []byte("LINUX"),
+		".osrel":   []byte("ID=test\n"),
+		".cmdline": []byte("quiet"),
+		".initrd":  []byte("INITRD"),
+		".pcrpkey": []byte("PUB"),
+	}
+	prediction, err := predictUKIPCRs(sections, []string{"sha256", "sha1"})
+	if err != nil {
+		t.Fatalf("predictUKIPCRs failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"sha256": {
+			"c0f6dc55b578289ab30da5dc1eda1efff8f0ae5e5a0e0000f357b96af6c48a8c",
+			"49b5354ef8f1af339f0245e8527ace7c53691d88b8b5df823962c5f45c5dd3dd",
+			"80e260f92f89709c60540ea56f7abb9d5795231a4ddfa54469f851c2d6c38892",
+			"717202c3f77e3e947bf2cfa34b309af5ff199ed23e1fcc20902a7789392c40a9",
+		},
+		"sha1": {
+			"6e7a5e8a62066036867308ba8bbd29ed6f0a9ae7",
+			"22430a4eaa7552f6241ad458f8fbfdbc1c4a402b",
+			"1ba6103eb1ce68767ccf6db148c312cb7b906e3f",
+			"1575ad4bb4d924d989763d9a33550e4618832dbd",
+		},
+	}
+	for bank, hashes := range expected {
+		if len(prediction[bank]) != len(hashes) {
+			t.Fatalf("expected %d %s values, got %v", len(hashes), bank, prediction[bank])
+		}
+		for i, hash := range hashes {
+			value := prediction[bank][i]
+			if value.Hash != hash || value.PCR != ukiPCR {
+				t.Errorf("%s phase %s: expected PCR 11 %s, got %+v", bank, value.Phase, hash, value)
+			}
+		}
+	}
+	if phase := prediction["sha256"][3].Phase; phase != "enter-initrd:leave-initrd:sysinit:ready" {
+		t.Errorf("unexpected last phase %q", phase)
+	}
+
+	if _, err := predictUKIPCRs(sections, []string{"md5"}); err == nil {
+		t.Error("expected an unsupported bank to fail")
+	}
+}
+
+func TestWritePCRPrediction(t *testing.T) {
+	useTestTempDir(t)
+
+	ukiPath := writeTestUKI(t,
+		[]string{".text", ".osrel", ".cmdline", ".linux", ".initrd", ".pcrpkey"},
+		[]string{"stub", "ID=test\n", "quiet", "LINUX", "INITRD", "PUB"})
+	sections, err := readUKISections(ukiPath)
+	if err != nil {
+		t.Fatalf("readUKISections failed: %v", err)
+	}
+	if len(sections) != 5 || string(sections[".linux"]) != "LINUX" || string(sections[".osrel"]) != "ID=test\n" {
+		t.Errorf("expected the measured sections cut to their virtual size, got %q", sections)
+	}
+
+	template := createTestImageTemplate()
+	template.SystemConfig.Kernel.PCRSigning = config.PCRSigning{PrivateKey: "/keys/pcr.key"}
+	if err := writePCRPrediction(ukiPath, PCRPredictionFile, template); err != nil {
+		t.Fatalf("writePCRPrediction failed: %v", err)
+	}
+	imageBuildDir := t.TempDir()
+	if err := CopyPCRPredictionToImageBuildDir(imageBuildDir); err != nil {
+		t.Fatalf("CopyPCRPredictionToImageBuildDir failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(imageBuildDir, PCRPredictionFile))
+	if err != nil {
+		t.Fatalf("failed to read PCR prediction: %v", err)
+	}
+	if !strings.Contains(string(data), "717202c3f77e3e947bf2cfa34b309af5ff199ed23e1fcc20902a7789392c40a9") {
+		t.Errorf("expected the predicted sha256 value in the prediction, got %s", data)
+	}
+
+	if _, err := readUKISections(writeTestUKI(t, []string{".text"}, []string{"stub"})); err == nil {
+		t.Error("expected a UKI without kernel to fail")
+	}
+}
+
+func TestUKIPCRSigningArgs(t *testing.T) {
+	useTestTempDir(t)
+
+	template := createTestImageTemplate()
+	if args, err := ukiPCRSigningArgs(template); err != nil || args != "" {
+		t.Errorf("expected no options without PCR signing, got %q, %v", args, err)
+	}
+
+	keyPath := writeTestPCRKey(t)
+	template.SystemConfig.Kernel.PCRSigning = config.PCRSigning{PrivateKey: keyPath, Banks: []string{"sha256", "sha384"}}
+
+	publicKey := filepath.Join(config.TempDir(), "pcr-public-key.pem")
+	args, err := ukiPCRSigningArgs(template)
+	if err != nil {
+		t.Fatalf("ukiPCRSigningArgs failed: %v", err)
+	}
+	expected := ` --pcr-private-key "` + keyPath + `" --pcr-public-key "` + publicKey + `" --pcr-banks sha256,sha384`
+	if args != expected {
+		t.Errorf("expected options %q, got %q", expected, args)
+	}
+	if data, err := os.ReadFile(publicKey); err != nil || !strings.Contains(string(data), "PUBLIC KEY") {
+		t.Errorf("expected the derived public key, got %q, %v", data, err)
+	}
+}
+
+// writeTestPCRKey writes an RSA private key and returns its path
+func writeTestPCRKey(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "pcr.key")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return keyPath
+}
+
+func TestBuildUKIPCRSigningOnHost(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	useTestTempDir(t)
+
+	installRoot := t.TempDir()
+	stubDir := filepath.Join(installRoot, "usr/lib/systemd/boot/efi")
+	if err := os.MkdirAll(stubDir, 0755); err != nil {
+		t.Fatalf("failed to create stub dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stubDir, "linuxx64.efi.stub"), []byte("stub"), 0644); err != nil {
+		t.Fatalf("failed to create stub: %v", err)
+	}
+
+	keyPath := writeTestPCRKey(t)
+	template := createTestImageTemplate()
+	template.SystemConfig.Kernel.PCRSigning = config.PCRSigning{PrivateKey: keyPath}
+
+	// ukify is installed in the image, but only the host run is allowed to sign
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "command -v ukify", Output: "/usr/bin/ukify", Error: nil},
+		{Pattern: "cat .*cmdline.conf", Output: "root=/dev/sda2 ro", Error: nil},
+		{Pattern: "chroot .*ukify build", Output: "", Error: fmt.Errorf("ukify ran in the chroot")},
+		{Pattern: `ukify build .*--stub "` + stubDir + `/linuxx64.efi.stub" --pcr-private-key "` + keyPath + `"`, Output: "", Error: nil},
+		{Pattern: ".*", Output: "", Error: fmt.Errorf("unexpected command")},
+	})
+	if err := buildUKI(installRoot, "/boot/vmlinuz", "/boot/initrd.img", "/cmdline.conf", "/boot/efi/EFI/Linux/linux.efi", template); err != nil {
+		t.Fatalf("buildUKI failed: %v", err)
+	}
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "chroot .*command -v ukify", Output: "/usr/bin/ukify", Error: nil},
+		{Pattern: "cat .*cmdline.conf", Output: "root=/dev/sda2 ro", Error: nil},
+		{Pattern: ".*", Output: "", Error: fmt.Errorf("not found")},
+	})
+	err := buildUKI(installRoot, "/boot/vmlinuz", "/boot/initrd.img", "/cmdline.conf", "/boot/efi/EFI/Linux/linux.efi", template)
+	if err == nil || !strings.Contains(err.Error(), "ukify on the build host") {
+		t.Errorf("expected PCR signing without host ukify to fail, got: %v", err)
+	}
+}
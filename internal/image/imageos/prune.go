@@ -0,0 +1,394 @@
+package imageos
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/slice"
+)
+
+const (
+	dpkgPruneFile   = "/etc/dpkg/dpkg.cfg.d/90-os-image-composer-prune"
+	rpmPruneMacros  = "/etc/rpm/macros.os-image-composer"
+	pruneStamp      = "# Generated by OS Image Composer from systemConfig.prune\n"
+	pruneBatchSize  = 100
+	pruneUnsafeChar = "'\"\\;|&$`\n"
+)
+
+// docPrunePaths are pruned when prune.docs is set. Copyright files stay for
+// license compliance
+var docPrunePaths = []string{"/usr/share/doc/*/!(copyright)", "/usr/share/man/*", "/usr/share/info/*"}
+
+// aptPurgeRe matches the packages an apt-get purge simulation removes
+var aptPurgeRe = regexp.MustCompile(`(?m)^Purg (\S+)`)
+
+// prunePaths returns the prune rules of the template, including the docs rules
+func prunePaths(template *config.ImageTemplate) []string {
+	var paths []string
+	if template.SystemConfig.Prune.Docs {
+		paths = append(paths, docPrunePaths...)
+	}
+	for _, path := range template.SystemConfig.Prune.Paths {
+		if !slice.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// parsePruneRule splits a prune rule into its path elements. The rule must be
+// absolute, start with a literal directory, and hold at most one !(...) element
+func parsePruneRule(rule string) ([]string, error) {
+	if !strings.HasPrefix(rule, "/") || filepath.Clean(rule) != rule {
+		return nil, fmt.Errorf("prune path %s must be a clean absolute path", rule)
+	}
+	elements := strings.Split(strings.TrimPrefix(rule, "/"), "/")
+	if len(elements) < 2 || strings.ContainsAny(elements[0], "*?[!(") {
+		return nil, fmt.Errorf("prune path %s must start with a literal directory", rule)
+	}
+	negations := 0
+	for _, element := range elements {
+		if element == ".." {
+			return nil, fmt.Errorf("prune path %s must not contain ..", rule)
+		}
+		pattern := element
+		if alternatives, ok := pruneNegation(element); ok {
+			negations++
+			pattern = strings.Join(alternatives, "")
+		} else if strings.Contains(element, "!(") {
+			return nil, fmt.Errorf("prune path %s: !(...) must be a whole path element", rule)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("prune path %s: %w", rule, err)
+		}
+	}
+	if negations > 1 {
+		return nil, fmt.Errorf("prune path %s holds more than one !(...) element", rule)
+	}
+	return elements, nil
+}
+
+// pruneNegation returns the alternatives of a !(a|b) path element
+func pruneNegation(element string) ([]string, bool) {
+	if !strings.HasPrefix(element, "!(") || !strings.HasSuffix(element, ")") {
+		return nil, false
+	}
+	return strings.Split(element[2:len(element)-1], "|"), true
+}
+
+// matchPruneElement reports whether a directory entry name matches a path element
+func matchPruneElement(element, name string) bool {
+	if alternatives, ok := pruneNegation(element); ok {
+		for _, alternative := range alternatives {
+			if matched, _ := filepath.Match(alternative, name); matched {
+				return false
+			}
+		}
+		return true
+	}
+	matched, _ := filepath.Match(element, name)
+	return matched
+}
+
+// matchPrunePaths returns the image paths a prune rule matches. Symbolic links
+// to directories are not followed, so matches stay inside the image
+func matchPrunePaths(installRoot string, elements []string) []string {
+	current := []string{installRoot}
+	for i, element := range elements {
+		last := i == len(elements)-1
+		var next []string
+		for _, dir := range current {
+			if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+				continue
+			}
+			if !strings.ContainsAny(element, "*?[") && !strings.HasPrefix(element, "!(") {
+				if _, err := os.Lstat(filepath.Join(dir, element)); err == nil {
+					next = append(next, filepath.Join(dir, element))
+				}
+				continue
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if !matchPruneElement(element, entry.Name()) {
+					continue
+				}
+				if !last && !entry.IsDir() {
+					continue
+				}
+				next = append(next, filepath.Join(dir, entry.Name()))
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// renderDpkgPathExcludes returns the dpkg configuration that keeps the files of
+// the prune rules from being unpacked. A !(a|b) element becomes a path-exclude
+// with * followed by a path-include for each alternative
+func renderDpkgPathExcludes(rules []string) (string, error) {
+	content := pruneStamp
+	for _, rule := range rules {
+		elements, err := parsePruneRule(rule)
+		if err != nil {
+			return "", err
+		}
+		negation := -1
+		for i, element := range elements {
+			if _, ok := pruneNegation(element); ok {
+				negation = i
+			}
+		}
+		if negation < 0 {
+			content += "path-exclude=" + rule + "\n"
+			continue
+		}
+		alternatives, _ := pruneNegation(elements[negation])
+		excluded := append([]string{}, elements...)
+		excluded[negation] = "*"
+		content += "path-exclude=/" + strings.Join(excluded, "/") + "\n"
+		for _, alternative := range alternatives {
+			included := append([]string{}, elements...)
+			included[negation] = alternative
+			content += "path-include=/" + strings.Join(included, "/") + "\n"
+		}
+	}
+	return content, nil
+}
+
+// preparePkgPrune configures the package manager of the image to leave out the
+// pruned files, before the image packages are installed
+func preparePkgPrune(installRoot, pkgType string, template *config.ImageTemplate) error {
+	rules := prunePaths(template)
+	if len(rules) == 0 {
+		return nil
+	}
+	switch pkgType {
+	case "deb":
+		content, err := renderDpkgPathExcludes(rules)
+		if err != nil {
+			return err
+		}
+		return writeImageFile(installRoot, dpkgPruneFile, content)
+	case "rpm":
+		if template.SystemConfig.Prune.Docs {
+			return writeImageFile(installRoot, rpmPruneMacros, pruneStamp+"%_excludedocs 1\n")
+		}
+	}
+	return nil
+}
+
+// pruneImageFiles removes the files matching the prune rules from the image
+func pruneImageFiles(installRoot string, template *config.ImageTemplate) error {
+	rules := prunePaths(template)
+	if len(rules) == 0 {
+		return nil
+	}
+	log.Infof("Pruning image files...")
+
+	var matches []string
+	for _, rule := range rules {
+		elements, err := parsePruneRule(rule)
+		if err != nil {
+			log.Errorf("Invalid prune path: %v", err)
+			return err
+		}
+		for _, match := range matchPrunePaths(installRoot, elements) {
+			if strings.ContainsAny(match, pruneUnsafeChar) {
+				log.Warnf("Skipping prune of %s, its name holds shell characters", match)
+				continue
+			}
+			if !slice.Contains(matches, match) {
+				matches = append(matches, match)
+			}
+		}
+	}
+
+	var size int64
+	for _, match := range matches {
+		size += pathSize(match)
+	}
+	for start := 0; start < len(matches); start += pruneBatchSize {
+		end := min(start+pruneBatchSize, len(matches))
+		cmd := "rm -rf --"
+		for _, match := range matches[start:end] {
+			cmd += " '" + match + "'"
+		}
+		if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to prune image files: %v", err)
+			return fmt.Errorf("failed to prune image files: %w", err)
+		}
+	}
+	log.Infof("Pruned %d paths, %d MiB", len(matches), size/(1024*1024))
+	return nil
+}
+
+// pathSize returns the size of the regular files at or below a path
+func pathSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// checkRemovePackages rejects removing packages the template installs
+func checkRemovePackages(template *config.ImageTemplate) error {
+	var conflicts []string
+	installed := template.GetPackages()
+	for _, pkg := range template.SystemConfig.Remove {
+		if slice.Contains(installed, pkg) {
+			conflicts = append(conflicts, pkg)
+		}
+	}
+	if len(conflicts) > 0 {
+		log.Errorf("Packages both installed and removed: %s", strings.Join(conflicts, ", "))
+		return fmt.Errorf("packages both installed and removed by the template: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// removeImagePkgs purges the packages of systemConfig.remove that got installed
+// as dependencies, refusing removals that would take other packages along, and
+// drops them from the SBOM
+func (imageOs *ImageOs) removeImagePkgs(installRoot, pkgType string, template *config.ImageTemplate) error {
+	if len(template.SystemConfig.Remove) == 0 {
+		return nil
+	}
+	if err := checkRemovePackages(template); err != nil {
+		return err
+	}
+
+	var removed []string
+	var err error
+	switch pkgType {
+	case "deb":
+		removed, err = removeDebPkgs(installRoot, template.SystemConfig.Remove)
+	case "rpm":
+		removed, err = imageOs.removeRpmPkgs(installRoot, template.SystemConfig.Remove)
+	default:
+		err = fmt.Errorf("unsupported package type: %s", pkgType)
+	}
+	if err != nil {
+		return err
+	}
+	return manifest.RemovePackagesFromSPDX(removed)
+}
+
+// installedOf returns the packages of the list that the package query lists
+func installedOf(pkgs []string, queryOutput string) []string {
+	listed := strings.Fields(queryOutput)
+	var installed []string
+	for _, pkg := range pkgs {
+		if slice.Contains(listed, pkg) {
+			installed = append(installed, pkg)
+		} else {
+			log.Warnf("Package %s to remove is not installed in image", pkg)
+		}
+	}
+	return installed
+}
+
+func removeDebPkgs(installRoot string, pkgs []string) ([]string, error) {
+	output, err := shell.ExecCmdSilent("dpkg-query -W -f='${db:Status-Status} ${Package}\\n'", true, installRoot, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image packages: %w", err)
+	}
+	var installedList []string
+	for _, line := range strings.Split(output, "\n") {
+		if status, name, ok := strings.Cut(strings.TrimSpace(line), " "); ok && status == "installed" {
+			installedList = append(installedList, name)
+		}
+	}
+	remove := installedOf(pkgs, strings.Join(installedList, " "))
+	if len(remove) == 0 {
+		return nil, nil
+	}
+
+	envVars := []string{
+		"DEBIAN_FRONTEND=noninteractive",
+		"DEBCONF_NONINTERACTIVE_SEEN=true",
+		"DEBCONF_NOWARNINGS=yes",
+	}
+	simulation, err := shell.ExecCmd("apt-get -s purge "+strings.Join(remove, " "), true, installRoot, envVars)
+	if err != nil {
+		log.Errorf("Failed to simulate package removal: %v", err)
+		return nil, fmt.Errorf("failed to simulate package removal: %w", err)
+	}
+	if dependents := removalDependents(simulation, remove); len(dependents) > 0 {
+		log.Errorf("Removing %s would also remove %s", strings.Join(remove, ", "), strings.Join(dependents, ", "))
+		return nil, fmt.Errorf("removing %s would also remove %s, which depend on them",
+			strings.Join(remove, ", "), strings.Join(dependents, ", "))
+	}
+
+	log.Infof("Removing packages: %s", strings.Join(remove, ", "))
+	if _, err := shell.ExecCmdWithStream("apt-get purge -y "+strings.Join(remove, " "), true, installRoot, envVars); err != nil {
+		log.Errorf("Failed to remove packages: %v", err)
+		return nil, fmt.Errorf("failed to remove packages: %w", err)
+	}
+	return remove, nil
+}
+
+// removalDependents returns the packages an apt-get purge simulation removes
+// beyond the requested ones
+func removalDependents(simulation string, requested []string) []string {
+	var dependents []string
+	for _, match := range aptPurgeRe.FindAllStringSubmatch(simulation, -1) {
+		name, _, _ := strings.Cut(match[1], ":")
+		if !slice.Contains(requested, name) && !slice.Contains(dependents, name) {
+			dependents = append(dependents, name)
+		}
+	}
+	return dependents
+}
+
+func (imageOs *ImageOs) removeRpmPkgs(installRoot string, pkgs []string) ([]string, error) {
+	chrootInstallRoot, err := imageOs.chrootEnv.GetChrootEnvPath(installRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chroot environment path for install root %s: %w", installRoot, err)
+	}
+	chrootEnvRoot := imageOs.chrootEnv.GetChrootEnvRoot()
+
+	output, err := shell.ExecCmdSilent("rpm -qa --qf '%{NAME}\\n' --root "+chrootInstallRoot, true, chrootEnvRoot, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image packages: %w", err)
+	}
+	remove := installedOf(pkgs, output)
+	if len(remove) == 0 {
+		return nil, nil
+	}
+
+	// rpm refuses to erase packages other installed packages depend on
+	if output, err := shell.ExecCmd("rpm -e --test --root "+chrootInstallRoot+" "+strings.Join(remove, " "),
+		true, chrootEnvRoot, nil); err != nil {
+		log.Errorf("Removing %s would break dependencies: %s", strings.Join(remove, ", "), output)
+		return nil, fmt.Errorf("removing %s would break the packages that depend on them: %w",
+			strings.Join(remove, ", "), err)
+	}
+
+	log.Infof("Removing packages: %s", strings.Join(remove, ", "))
+	if _, err := shell.ExecCmd("rpm -e --root "+chrootInstallRoot+" "+strings.Join(remove, " "),
+		true, chrootEnvRoot, nil); err != nil {
+		log.Errorf("Failed to remove packages: %v", err)
+		return nil, fmt.Errorf("failed to remove packages: %w", err)
+	}
+	return remove, nil
+}
@@ -0,0 +1,200 @@
+package imageos
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func TestParsePruneRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{"/usr/share/doc/*", false},
+		{"/usr/share/locale/!(en*|locale.alias)", false},
+		{"/usr/share/doc/*/!(copyright)", false},
+		{"usr/share/doc", true},
+		{"/*", true},
+		{"/*/share", true},
+		{"/usr/share/../lib", true},
+		{"/usr/share/doc/", true},
+		{"/usr/share/x!(y)", true},
+		{"/usr/!(share)/!(doc)", true},
+		{"/usr/share/[a", true},
+	}
+	for _, tt := range tests {
+		if _, err := parsePruneRule(tt.rule); (err != nil) != tt.wantErr {
+			t.Errorf("parsePruneRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchPrunePaths(t *testing.T) {
+	installRoot := t.TempDir()
+	for _, dir := range []string{
+		"usr/share/locale/en_US/LC_MESSAGES", "usr/share/locale/de/LC_MESSAGES",
+		"usr/share/locale/fr", "usr/share/doc/bash/examples", "etc",
+	} {
+		if err := os.MkdirAll(filepath.Join(installRoot, dir), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	for _, path := range []string{
+		"usr/share/locale/locale.alias", "usr/share/doc/bash/copyright", "usr/share/doc/bash/README",
+	} {
+		if err := os.WriteFile(filepath.Join(installRoot, path), []byte("x"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	// A link out of the image must not be followed
+	if err := os.Symlink("/etc", filepath.Join(installRoot, "usr/share/doc/link")); err != nil {
+		t.Fatalf("failed to create link: %v", err)
+	}
+
+	tests := []struct {
+		rule     string
+		expected []string
+	}{
+		{"/usr/share/locale/!(en*|locale.alias)", []string{"usr/share/locale/de", "usr/share/locale/fr"}},
+		{"/usr/share/doc/*/!(copyright)", []string{"usr/share/doc/bash/README", "usr/share/doc/bash/examples"}},
+		{"/usr/share/locale/*/LC_MESSAGES", []string{"usr/share/locale/de/LC_MESSAGES", "usr/share/locale/en_US/LC_MESSAGES"}},
+		{"/usr/share/man/*", nil},
+	}
+	for _, tt := range tests {
+		elements, err := parsePruneRule(tt.rule)
+		if err != nil {
+			t.Fatalf("parsePruneRule(%q) failed: %v", tt.rule, err)
+		}
+		var got []string
+		for _, match := range matchPrunePaths(installRoot, elements) {
+			rel, _ := filepath.Rel(installRoot, match)
+			got = append(got, rel)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.rule, tt.expected, got)
+		}
+	}
+}
+
+func TestRenderDpkgPathExcludes(t *testing.T) {
+	content, err := renderDpkgPathExcludes([]string{"/usr/share/man/*", "/usr/share/locale/!(en*|locale.alias)"})
+	if err != nil {
+		t.Fatalf("renderDpkgPathExcludes failed: %v", err)
+	}
+	expected := pruneStamp +
+		"path-exclude=/usr/share/man/*\n" +
+		"path-exclude=/usr/share/locale/*\n" +
+		"path-include=/usr/share/locale/en*\n" +
+		"path-include=/usr/share/locale/locale.alias\n"
+	if content != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	if _, err := renderDpkgPathExcludes([]string{"/*"}); err == nil {
+		t.Error("expected an error for an invalid rule")
+	}
+}
+
+func TestPrunePaths(t *testing.T) {
+	template := createTestImageTemplate()
+	template.SystemConfig.Prune.Paths = []string{"/usr/share/man/*", "/usr/share/locale/!(en*)"}
+	template.SystemConfig.Prune.Docs = true
+
+	got := prunePaths(template)
+	expected := append(append([]string{}, docPrunePaths...), "/usr/share/locale/!(en*)")
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestPruneImageFiles(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "rm -rf", Output: "", Error: nil},
+	})
+
+	installRoot := t.TempDir()
+	template := createTestImageTemplate()
+
+	// No prune rules is a no-op
+	if err := pruneImageFiles(installRoot, template); err != nil {
+		t.Errorf("expected no error without prune rules, got: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(installRoot, "usr/share/man/man1"), 0755); err != nil {
+		t.Fatalf("failed to create man directory: %v", err)
+	}
+	template.SystemConfig.Prune.Docs = true
+	if err := pruneImageFiles(installRoot, template); err != nil {
+		t.Errorf("pruneImageFiles failed: %v", err)
+	}
+
+	template.SystemConfig.Prune.Paths = []string{"/*"}
+	if err := pruneImageFiles(installRoot, template); err == nil {
+		t.Error("expected an error for an invalid prune path")
+	}
+}
+
+func TestCheckRemovePackages(t *testing.T) {
+	template := createTestImageTemplate()
+	template.SystemConfig.Packages = []string{"openssh-server"}
+	template.SystemConfig.Remove = []string{"man-db"}
+	if err := checkRemovePackages(template); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	template.SystemConfig.Remove = []string{"man-db", "openssh-server"}
+	if err := checkRemovePackages(template); err == nil || !strings.Contains(err.Error(), "openssh-server") {
+		t.Errorf("expected an error naming the installed package, got: %v", err)
+	}
+}
+
+func TestRemovalDependents(t *testing.T) {
+	simulation := "The following packages will be REMOVED:\n" +
+		"  man-db* groff-base* apt-listchanges*\n" +
+		"Purg man-db [2.11.2-2]\n" +
+		"Purg groff-base [1.22.4-10]\n" +
+		"Purg apt-listchanges:amd64 [3.24]\n"
+	got := removalDependents(simulation, []string{"man-db", "groff-base"})
+	if !reflect.DeepEqual(got, []string{"apt-listchanges"}) {
+		t.Errorf("expected apt-listchanges to be reported, got %v", got)
+	}
+	if got := removalDependents("Purg man-db [2.11.2-2]\n", []string{"man-db"}); len(got) != 0 {
+		t.Errorf("expected no dependents, got %v", got)
+	}
+}
+
+func TestRemoveDebPkgs(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	installRoot := t.TempDir()
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "dpkg-query", Output: "installed man-db\ninstalled groff-base\nconfig-files nano\n", Error: nil},
+		{Pattern: "apt-get -s purge", Output: "Purg man-db [2.11.2-2]\n", Error: nil},
+		{Pattern: "apt-get purge", Output: "", Error: nil},
+	})
+	removed, err := removeDebPkgs(installRoot, []string{"man-db", "nano"})
+	if err != nil {
+		t.Fatalf("removeDebPkgs failed: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"man-db"}) {
+		t.Errorf("expected only the installed package to be removed, got %v", removed)
+	}
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "dpkg-query", Output: "installed groff-base\ninstalled man-db\n", Error: nil},
+		{Pattern: "apt-get -s purge", Output: "Purg groff-base [1.22.4-10]\nPurg man-db [2.11.2-2]\n", Error: nil},
+	})
+	if _, err := removeDebPkgs(installRoot, []string{"groff-base"}); err == nil || !strings.Contains(err.Error(), "man-db") {
+		t.Errorf("expected an error naming the dependent package, got: %v", err)
+	}
+}
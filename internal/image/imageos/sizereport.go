@@ -0,0 +1,378 @@
+package imageos
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/security"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	// SizeReportFile is staged in the temp directory like the SBOM and copied
+	// next to the image artifacts
+	SizeReportFile = "size_report.txt"
+
+	sizeReportTopDirs     = 20
+	sizeReportDirDepth    = 3
+	sizeReportLogPackages = 10
+)
+
+// ErrSizeBudgetExceeded is wrapped by the install error when the image is over
+// its size budget, the size report is still copied to the artifacts then
+var ErrSizeBudgetExceeded = errors.New("image size budget exceeded")
+
+// sizeReportSkipDirs are API file systems mounted into the install root during the build
+var sizeReportSkipDirs = []string{"proc", "sys", "dev", "run"}
+
+// PackageSize is the installed size of a package
+type PackageSize struct {
+	Name  string
+	Bytes uint64
+}
+
+// PathSize is the disk usage of a directory of the image
+type PathSize struct {
+	Path  string
+	Bytes uint64
+}
+
+// PartitionUsage is the file system usage of an image partition
+type PartitionUsage struct {
+	ID         string
+	MountPoint string
+	SizeBytes  uint64
+	UsedBytes  uint64
+}
+
+// SizeReport describes where the space of an image goes
+type SizeReport struct {
+	ImageName   string
+	RootfsBytes uint64
+	Packages    []PackageSize
+	Directories []PathSize
+	Partitions  []PartitionUsage
+}
+
+// usedBytes returns the space used across all partitions
+func (r *SizeReport) usedBytes() uint64 {
+	var used uint64
+	for _, partition := range r.Partitions {
+		used += partition.UsedBytes
+	}
+	return used
+}
+
+// String renders the report as text
+func (r *SizeReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Size report for %s\n\n", r.ImageName)
+	fmt.Fprintf(&b, "Root file system content: %s\n", imagedisc.TranslateBytesToSizeStr(r.RootfsBytes))
+
+	if len(r.Partitions) > 0 {
+		fmt.Fprintf(&b, "\nPartitions (%s used):\n", imagedisc.TranslateBytesToSizeStr(r.usedBytes()))
+		fmt.Fprintf(&b, "  %-12s %-20s %12s %12s %5s\n", "ID", "MOUNT POINT", "SIZE", "USED", "USE%")
+		for _, partition := range r.Partitions {
+			percent := uint64(0)
+			if partition.SizeBytes > 0 {
+				percent = partition.UsedBytes * 100 / partition.SizeBytes
+			}
+			fmt.Fprintf(&b, "  %-12s %-20s %12s %12s %4d%%\n", partition.ID, partition.MountPoint,
+				imagedisc.TranslateBytesToSizeStr(partition.SizeBytes),
+				imagedisc.TranslateBytesToSizeStr(partition.UsedBytes), percent)
+		}
+	}
+
+	if len(r.Directories) > 0 {
+		fmt.Fprintf(&b, "\nTop directories:\n")
+		for _, dir := range r.Directories {
+			fmt.Fprintf(&b, "  %12s  %s\n", imagedisc.TranslateBytesToSizeStr(dir.Bytes), dir.Path)
+		}
+	}
+
+	if len(r.Packages) > 0 {
+		var total uint64
+		for _, pkg := range r.Packages {
+			total += pkg.Bytes
+		}
+		fmt.Fprintf(&b, "\nPackages (%d, %s installed):\n", len(r.Packages), imagedisc.TranslateBytesToSizeStr(total))
+		for _, pkg := range r.Packages {
+			fmt.Fprintf(&b, "  %12s  %s\n", imagedisc.TranslateBytesToSizeStr(pkg.Bytes), pkg.Name)
+		}
+	}
+	return b.String()
+}
+
+// reportImageSize writes the size report of the image and fails when the
+// template size budgets are exceeded
+func (imageOs *ImageOs) reportImageSize(installRoot, pkgType string, mountPointInfoList []map[string]string) error {
+	template := imageOs.template
+	log.Infof("Generating image size report...")
+
+	report := &SizeReport{ImageName: template.GetImageName()}
+	report.RootfsBytes, report.Directories = measureDirectories(installRoot, rootfsExcludedPaths(installRoot, template, mountPointInfoList))
+	report.Partitions = partitionUsage(installRoot, mountPointInfoList)
+
+	packages, err := imageOs.packageSizes(installRoot, pkgType)
+	if err != nil {
+		log.Warnf("Failed to get installed package sizes: %v", err)
+	}
+	report.Packages = packages
+
+	reportPath := filepath.Join(config.TempDir(), SizeReportFile)
+	if err := security.SafeWriteFile(reportPath, []byte(report.String()), 0644, security.RejectSymlinks); err != nil {
+		log.Errorf("Failed to write size report: %v", err)
+		return fmt.Errorf("failed to write size report: %w", err)
+	}
+
+	log.Infof("Root file system content: %s", imagedisc.TranslateBytesToSizeStr(report.RootfsBytes))
+	for i, pkg := range report.Packages {
+		if i == sizeReportLogPackages {
+			break
+		}
+		log.Infof("  %s: %s", pkg.Name, imagedisc.TranslateBytesToSizeStr(pkg.Bytes))
+	}
+	log.Infof("Size report written to %s", reportPath)
+
+	return checkSizeBudgets(report, template)
+}
+
+// checkSizeBudgets compares the report with disk.sizeBudget and
+// systemConfig.maxRootfsSize, logging the report when one is exceeded
+func checkSizeBudgets(report *SizeReport, template *config.ImageTemplate) error {
+	var exceeded []string
+	if template.SystemConfig.MaxRootfsSize != "" {
+		limit, err := imagedisc.TranslateSizeStrToBytes(template.SystemConfig.MaxRootfsSize)
+		if err != nil {
+			return fmt.Errorf("invalid maxRootfsSize %s: %w", template.SystemConfig.MaxRootfsSize, err)
+		}
+		if report.RootfsBytes > limit {
+			exceeded = append(exceeded, fmt.Sprintf("root file system content %s exceeds maxRootfsSize %s",
+				imagedisc.TranslateBytesToSizeStr(report.RootfsBytes), template.SystemConfig.MaxRootfsSize))
+		}
+	}
+	if template.Disk.SizeBudget != "" && len(report.Partitions) > 0 {
+		limit, err := imagedisc.TranslateSizeStrToBytes(template.Disk.SizeBudget)
+		if err != nil {
+			return fmt.Errorf("invalid disk sizeBudget %s: %w", template.Disk.SizeBudget, err)
+		}
+		if used := report.usedBytes(); used > limit {
+			exceeded = append(exceeded, fmt.Sprintf("partitions use %s, exceeding sizeBudget %s",
+				imagedisc.TranslateBytesToSizeStr(used), template.Disk.SizeBudget))
+		}
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n") {
+		log.Error(line)
+	}
+	log.Errorf("Image size budget exceeded: %s", strings.Join(exceeded, "; "))
+	return fmt.Errorf("%w: %s", ErrSizeBudgetExceeded, strings.Join(exceeded, "; "))
+}
+
+// rootfsExcludedPaths returns the paths of the install root that are not on the
+// root partition: the other partitions mounted below it and the swap file
+func rootfsExcludedPaths(installRoot string, template *config.ImageTemplate, mountPointInfoList []map[string]string) []string {
+	var paths []string
+	for _, mountPointInfo := range mountPointInfoList {
+		if mountPoint := filepath.Clean(mountPointInfo["MountPoint"]); mountPoint != filepath.Clean(installRoot) {
+			paths = append(paths, mountPoint)
+		}
+	}
+	if swapFile := template.SystemConfig.Memory.SwapFile; swapFile.Size != "" {
+		paths = append(paths, filepath.Join(installRoot, swapFilePath(swapFile)))
+	}
+	return paths
+}
+
+// measureDirectories returns the disk usage of the install root and of its
+// largest directories, counting hard linked files once and leaving out the
+// excluded paths
+func measureDirectories(installRoot string, excludedPaths []string) (uint64, []PathSize) {
+	var total uint64
+	dirSizes := make(map[string]uint64)
+	seen := make(map[[2]uint64]bool)
+	excluded := make(map[string]bool)
+	for _, path := range excludedPaths {
+		excluded[filepath.Clean(path)] = true
+	}
+
+	_ = filepath.WalkDir(installRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if excluded[path] {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(installRoot, path)
+		if entry.IsDir() {
+			for _, skip := range sizeReportSkipDirs {
+				if rel == skip {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		size := uint64(info.Size())
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			key := [2]uint64{uint64(stat.Dev), stat.Ino}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+			size = uint64(stat.Blocks) * 512
+		}
+		total += size
+
+		elements := strings.Split(filepath.Dir(rel), string(filepath.Separator))
+		for depth := 1; depth <= len(elements) && depth <= sizeReportDirDepth; depth++ {
+			if elements[0] == "." {
+				break
+			}
+			dirSizes["/"+strings.Join(elements[:depth], "/")] += size
+		}
+		return nil
+	})
+
+	dirs := make([]PathSize, 0, len(dirSizes))
+	for path, size := range dirSizes {
+		dirs = append(dirs, PathSize{Path: path, Bytes: size})
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Bytes != dirs[j].Bytes {
+			return dirs[i].Bytes > dirs[j].Bytes
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	if len(dirs) > sizeReportTopDirs {
+		dirs = dirs[:sizeReportTopDirs]
+	}
+	return total, dirs
+}
+
+// partitionUsage returns the file system usage of the mounted image partitions.
+// A partition mounted more than once, as btrfs subvolumes are, is reported once.
+func partitionUsage(installRoot string, mountPointInfoList []map[string]string) []PartitionUsage {
+	var partitions []PartitionUsage
+	reported := make(map[string]bool)
+	for _, mountPointInfo := range mountPointInfoList {
+		if reported[mountPointInfo["Id"]] {
+			continue
+		}
+		reported[mountPointInfo["Id"]] = true
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(mountPointInfo["MountPoint"], &stat); err != nil {
+			log.Warnf("Failed to get file system usage of %s: %v", mountPointInfo["MountPoint"], err)
+			continue
+		}
+		mountPoint, _ := filepath.Rel(installRoot, mountPointInfo["MountPoint"])
+		partitions = append(partitions, PartitionUsage{
+			ID:         mountPointInfo["Id"],
+			MountPoint: filepath.Join("/", mountPoint),
+			SizeBytes:  stat.Blocks * uint64(stat.Bsize),
+			UsedBytes:  (stat.Blocks - stat.Bfree) * uint64(stat.Bsize),
+		})
+	}
+	return partitions
+}
+
+// packageSizes returns the installed size of every package, largest first
+func (imageOs *ImageOs) packageSizes(installRoot, pkgType string) ([]PackageSize, error) {
+	switch pkgType {
+	case "deb":
+		output, err := shell.ExecCmdSilent("dpkg-query -W -f='${db:Status-Status}\\t${Installed-Size}\\t${Package}\\n'",
+			true, installRoot, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query dpkg status: %w", err)
+		}
+		return parsePackageSizes(output, 1024), nil
+	case "rpm":
+		chrootInstallRoot, err := imageOs.chrootEnv.GetChrootEnvPath(installRoot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chroot environment path for install root %s: %w", installRoot, err)
+		}
+		output, err := shell.ExecCmdSilent("rpm -qa --qf 'installed\\t%{SIZE}\\t%{NAME}\\n' --root "+chrootInstallRoot,
+			true, imageOs.chrootEnv.GetChrootEnvRoot(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query rpm database: %w", err)
+		}
+		return parsePackageSizes(output, 1), nil
+	default:
+		return nil, fmt.Errorf("unsupported package type: %s", pkgType)
+	}
+}
+
+// parsePackageSizes parses "status<TAB>size<TAB>name" lines of installed
+// packages, with sizes in units of unit bytes
+func parsePackageSizes(output string, unit uint64) []PackageSize {
+	var packages []PackageSize
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 || fields[0] != "installed" {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			size = 0
+		}
+		packages = append(packages, PackageSize{Name: fields[2], Bytes: size * unit})
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Bytes != packages[j].Bytes {
+			return packages[i].Bytes > packages[j].Bytes
+		}
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+// CopySizeReportOnBudgetError copies the size report next to the image
+// artifacts when err is a size budget failure, so the report of the failed
+// build is kept
+func CopySizeReportOnBudgetError(err error, imageBuildDir string) {
+	if !errors.Is(err, ErrSizeBudgetExceeded) {
+		return
+	}
+	if copyErr := CopySizeReportToImageBuildDir(imageBuildDir); copyErr != nil {
+		log.Warnf("Failed to copy size report to image build directory: %v", copyErr)
+	}
+}
+
+// CopySizeReportToImageBuildDir copies the size report from the temp directory
+// next to the image artifacts
+func CopySizeReportToImageBuildDir(imageBuildDir string) error {
+	srcReport := filepath.Join(config.TempDir(), SizeReportFile)
+	if _, err := os.Stat(srcReport); os.IsNotExist(err) {
+		log.Warnf("Size report not found at %s, skipping copy", srcReport)
+		return nil
+	}
+	data, err := security.SafeReadFile(srcReport, security.RejectSymlinks)
+	if err != nil {
+		log.Errorf("Failed to read size report: %v", err)
+		return fmt.Errorf("failed to read size report: %w", err)
+	}
+	dstReport := filepath.Join(imageBuildDir, SizeReportFile)
+	if err := security.SafeWriteFile(dstReport, data, 0644, security.RejectSymlinks); err != nil {
+		log.Errorf("Failed to write size report to image build directory: %v", err)
+		return fmt.Errorf("failed to write size report to image build directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package imageos
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+)
+
+func TestParsePackageSizes(t *testing.T) {
+	output := "installed\t120\tbash\n" +
+		"config-files\t50\tnano\n" +
+		"installed\t4000\tlinux-image-amd64\n" +
+		"installed\t\tlibfoo\n" +
+		"garbage line\n"
+	got := parsePackageSizes(output, 1024)
+	expected := []PackageSize{
+		{Name: "linux-image-amd64", Bytes: 4000 * 1024},
+		{Name: "bash", Bytes: 120 * 1024},
+		{Name: "libfoo", Bytes: 0},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestMeasureDirectories(t *testing.T) {
+	installRoot := t.TempDir()
+	files := map[string]int{
+		"usr/share/doc/bash/README": 10000,
+		"usr/bin/bash":              50000,
+		"etc/hostname":              10,
+		"proc/cpuinfo":              90000,
+		"vmlinuz":                   20000,
+	}
+	for path, size := range files {
+		fullPath := filepath.Join(installRoot, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, make([]byte, size), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	// A hard link is counted once
+	if err := os.Link(filepath.Join(installRoot, "usr/bin/bash"), filepath.Join(installRoot, "usr/bin/sh")); err != nil {
+		t.Fatalf("failed to create hard link: %v", err)
+	}
+
+	// Other partitions and the swap file are not on the root file system
+	for path, size := range map[string]int{"boot/efi/EFI/BOOT/BOOTX64.EFI": 900000, "swapfile": 900000} {
+		fullPath := filepath.Join(installRoot, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, make([]byte, size), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	template := &config.ImageTemplate{}
+	template.SystemConfig.Memory.SwapFile.Size = "1MiB"
+	mountPointInfoList := []map[string]string{
+		{"Id": "rootfs", "MountPoint": installRoot},
+		{"Id": "boot", "MountPoint": filepath.Join(installRoot, "boot/efi")},
+	}
+
+	total, dirs := measureDirectories(installRoot, rootfsExcludedPaths(installRoot, template, mountPointInfoList))
+	if total < 80000 || total >= 170000 {
+		t.Errorf("expected the total to skip /proc, /boot/efi and the swap file and count the hard link once, got %d", total)
+	}
+	if len(dirs) == 0 || dirs[0].Path != "/usr" {
+		t.Fatalf("expected /usr to be the largest directory, got %v", dirs)
+	}
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, dir.Path)
+	}
+	for _, want := range []string{"/usr/bin", "/usr/share/doc", "/etc"} {
+		found := false
+		for _, path := range paths {
+			found = found || path == want
+		}
+		if !found {
+			t.Errorf("expected %s in %v", want, paths)
+		}
+	}
+	for _, path := range paths {
+		if strings.HasPrefix(path, "/proc") || path == "/usr/share/doc/bash" {
+			t.Errorf("unexpected directory %s in %v", path, paths)
+		}
+	}
+}
+
+func TestPartitionUsage(t *testing.T) {
+	installRoot := t.TempDir()
+	partitions := partitionUsage(installRoot, []map[string]string{
+		{"Id": "rootfs", "MountPoint": installRoot},
+		{"Id": "missing", "MountPoint": filepath.Join(installRoot, "missing")},
+	})
+	if len(partitions) != 1 {
+		t.Fatalf("expected one partition, got %v", partitions)
+	}
+	if partitions[0].ID != "rootfs" || partitions[0].MountPoint != "/" || partitions[0].SizeBytes == 0 {
+		t.Errorf("unexpected partition usage %+v", partitions[0])
+	}
+}
+
+func TestCheckSizeBudgets(t *testing.T) {
+	report := &SizeReport{
+		ImageName:   "test",
+		RootfsBytes: 600 * 1024 * 1024,
+		Packages:    []PackageSize{{Name: "bash", Bytes: 1024 * 1024}},
+		Partitions: []PartitionUsage{
+			{ID: "boot", MountPoint: "/boot/efi", SizeBytes: 128 * 1024 * 1024, UsedBytes: 100 * 1024 * 1024},
+			{ID: "rootfs", MountPoint: "/", SizeBytes: 2048 * 1024 * 1024, UsedBytes: 700 * 1024 * 1024},
+		},
+	}
+	template := createTestImageTemplate()
+
+	if err := checkSizeBudgets(report, template); err != nil {
+		t.Errorf("expected no error without budgets, got: %v", err)
+	}
+
+	template.SystemConfig.MaxRootfsSize = "1GiB"
+	template.Disk.SizeBudget = "1GiB"
+	if err := checkSizeBudgets(report, template); err != nil {
+		t.Errorf("expected the budgets to hold, got: %v", err)
+	}
+
+	template.SystemConfig.MaxRootfsSize = "512MiB"
+	err := checkSizeBudgets(report, template)
+	if err == nil || !strings.Contains(err.Error(), "maxRootfsSize 512MiB") {
+		t.Errorf("expected maxRootfsSize to be exceeded, got: %v", err)
+	}
+	if !errors.Is(err, ErrSizeBudgetExceeded) {
+		t.Errorf("expected the error to wrap ErrSizeBudgetExceeded, got: %v", err)
+	}
+
+	template.SystemConfig.MaxRootfsSize = ""
+	template.Disk.SizeBudget = "768MiB"
+	err = checkSizeBudgets(report, template)
+	if err == nil || !strings.Contains(err.Error(), "sizeBudget 768MiB") {
+		t.Errorf("expected the disk budget to be exceeded, got: %v", err)
+	}
+
+	template.Disk.SizeBudget = "lots"
+	if err := checkSizeBudgets(report, template); err == nil {
+		t.Error("expected an error for an invalid budget")
+	}
+}
+
+func TestSizeReportString(t *testing.T) {
+	report := &SizeReport{
+		ImageName:   "minimal",
+		RootfsBytes: 2 * 1024 * 1024,
+		Packages:    []PackageSize{{Name: "bash", Bytes: 1024 * 1024}},
+		Directories: []PathSize{{Path: "/usr", Bytes: 1024 * 1024}},
+		Partitions:  []PartitionUsage{{ID: "rootfs", MountPoint: "/", SizeBytes: 4096, UsedBytes: 1024}},
+	}
+	got := report.String()
+	for _, want := range []string{"Size report for minimal", "Partitions", "rootfs", "25%", "Top directories", "/usr", "Packages (1,", "bash"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in report:\n%s", want, got)
+		}
+	}
+}
+
+func TestCopySizeReportToImageBuildDir(t *testing.T) {
+	tempDir := t.TempDir()
+	buildDir := t.TempDir()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = tempDir
+	config.SetGlobal(newGlobal)
+
+	// A missing report is skipped
+	if err := CopySizeReportToImageBuildDir(buildDir); err != nil {
+		t.Fatalf("expected no error without a report, got: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, SizeReportFile), []byte("report"), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	if err := CopySizeReportToImageBuildDir(buildDir); err != nil {
+		t.Fatalf("CopySizeReportToImageBuildDir failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(buildDir, SizeReportFile)); err != nil || string(data) != "report" {
+		t.Errorf("expected the report to be copied, got %q, %v", data, err)
+	}
+}
+
+func TestCopySizeReportOnBudgetError(t *testing.T) {
+	tempDir := t.TempDir()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = tempDir
+	config.SetGlobal(newGlobal)
+
+	if err := os.WriteFile(filepath.Join(tempDir, SizeReportFile), []byte("report"), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	// Other install errors leave the artifacts directory alone
+	buildDir := t.TempDir()
+	CopySizeReportOnBudgetError(errors.New("package install failed"), buildDir)
+	if _, err := os.Stat(filepath.Join(buildDir, SizeReportFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no size report for an unrelated error, got: %v", err)
+	}
+
+	budgetErr := fmt.Errorf("failed to install OS: %w", ErrSizeBudgetExceeded)
+	CopySizeReportOnBudgetError(budgetErr, buildDir)
+	if data, err := os.ReadFile(filepath.Join(buildDir, SizeReportFile)); err != nil || string(data) != "report" {
+		t.Errorf("expected the report to be copied on a budget error, got %q, %v", data, err)
+	}
+}
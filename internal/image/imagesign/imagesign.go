@@ -7,70 +7,266 @@ import (
 	"path/filepath"
 
 	"github.com/open-edge-platform/os-image-composer/internal/config"
-	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/system"
 )
 
+// shimPaths are where distributions install the Microsoft signed shim, relative
+// to the root file system
+var shimPaths = []string{
+	"usr/lib/shim/shimx64.efi.signed",
+	"usr/lib/shim/shimx64.efi.signed.latest",
+	"usr/share/shim-signed/shimx64.efi",
+	"usr/lib/shim/shimx64.efi",
+}
+
+// mokManagerPaths are where distributions install MokManager, relative to the
+// root file system
+var mokManagerPaths = []string{
+	"usr/lib/shim/mmx64.efi.signed",
+	"usr/share/shim-signed/mmx64.efi",
+	"usr/lib/shim/mmx64.efi",
+}
+
+// MokCertFileName is the certificate copied next to shim for enrollment from
+// MokManager on the first boot
+const MokCertFileName = "ENROLL_THIS_KEY_IN_MOKMANAGER.cer"
+
+// GrubLockdownModules are built into GRUB EFI images signed for Secure Boot,
+// since a locked down GRUB does not load modules from disk
+var GrubLockdownModules = []string{
+	"all_video", "boot", "btrfs", "cat", "configfile", "echo", "efifwsetup",
+	"ext2", "fat", "font", "gettext", "gfxterm", "gzio", "halt", "iso9660",
+	"linux", "loadenv", "ls", "minicmd", "normal", "part_gpt", "part_msdos",
+	"reboot", "regexp", "search", "search_fs_file", "search_fs_uuid",
+	"search_label", "sleep", "test", "true", "video", "xfs",
+}
+
+// signingKeys are the signer of the Secure Boot DB key and its DER certificate
+type signingKeys struct {
+	signer Signer
+	cer    string
+}
+
+// getSigningKeys returns the signer of the template after checking that the
+// certificate files exist
+func getSigningKeys(template *config.ImageTemplate) (signingKeys, error) {
+	crt := template.GetSecureBootDBCrtPath()
+	keys := signingKeys{cer: template.GetSecureBootDBCerPath()}
+	if template.GetSecureBootDBKeyPath() == "" || crt == "" || keys.cer == "" {
+		return keys, fmt.Errorf("secure boot needs secureBootDBKey, secureBootDBCrt and secureBootDBCer")
+	}
+
+	signer, err := NewSigner(template)
+	if err != nil {
+		return keys, err
+	}
+	keys.signer = signer
+
+	// Check if the certificate files exist
+	if _, err := os.Stat(crt); err != nil {
+		return keys, fmt.Errorf("secure boot certificate file not found at %s: %w", crt, err)
+	}
+	if _, err := os.Stat(keys.cer); err != nil {
+		return keys, fmt.Errorf("secure boot UEFI certificate file not found at %s: %w", keys.cer, err)
+	}
+	return keys, nil
+}
+
+// signEfiFile signs an EFI binary in place
+func signEfiFile(keys signingKeys, filePath string) error {
+	return keys.signer.SignEfiFile(filePath)
+}
+
 func SignImage(installRoot string, template *config.ImageTemplate) error {
 
-	// If immutability is not enabled, skip signing
-	if !template.IsImmutabilityEnabled() {
+	// Skip signing unless secure boot is requested, or immutability is
+	// enabled with the secure boot keys
+	if !template.IsSecureBootEnabled() {
 		return nil
 	}
 
-	// Check if secure boot keys are provided
-	// If not, skip signing
-	if template.GetSecureBootDBKeyPath() == "" ||
-		template.GetSecureBootDBCrtPath() == "" ||
-		template.GetSecureBootDBCerPath() == "" {
-		return nil
+	keys, err := getSigningKeys(template)
+	if err != nil {
+		return err
 	}
 
-	pbKeyPath := template.GetSecureBootDBKeyPath()
-	prKeyPath := template.GetSecureBootDBCrtPath()
-	prCerPath := template.GetSecureBootDBCerPath()
+	espDir := filepath.Join(installRoot, "boot", "efi")
+	if template.GetBootloaderConfig().Provider == "grub" {
+		if err := signGrubBootChain(installRoot, espDir, keys, template.GetSecureBootMode()); err != nil {
+			return err
+		}
+	} else {
+		if err := signUKIBootChain(espDir, keys); err != nil {
+			return err
+		}
+	}
 
-	// Check if the key and certificate files exist
-	if _, err := os.Stat(pbKeyPath); err != nil {
-		return fmt.Errorf("secure boot key file not found at %s: %w", pbKeyPath, err)
+	return copyCertToImageBuildDir(template, keys.cer)
+}
+
+// signUKIBootChain signs the systemd-boot loader and the UKIs it boots, one
+// for each boot entry
+func signUKIBootChain(espDir string, keys signingKeys) error {
+	// Sign the UKIs (Unified Kernel Images)
+	ukiPaths, err := filepath.Glob(filepath.Join(espDir, "EFI", "Linux", "*.efi"))
+	if err != nil {
+		return fmt.Errorf("failed to list UKIs: %w", err)
 	}
-	if _, err := os.Stat(prKeyPath); err != nil {
-		return fmt.Errorf("secure boot certificate file not found at %s: %w", prKeyPath, err)
+	if len(ukiPaths) == 0 {
+		return fmt.Errorf("no UKI found in %s", filepath.Join(espDir, "EFI", "Linux"))
 	}
-	if _, err := os.Stat(prCerPath); err != nil {
-		return fmt.Errorf("secure boot UEFI certificate file not found at %s: %w", prCerPath, err)
+	for _, ukiPath := range ukiPaths {
+		if err := signEfiFile(keys, ukiPath); err != nil {
+			return fmt.Errorf("failed to sign UKI: %w", err)
+		}
 	}
 
-	espDir := filepath.Join(installRoot, "boot", "efi")
-	ukiPath := filepath.Join(espDir, "EFI", "Linux", "linux.efi")
+	// Sign the bootloader
 	bootloaderPath := filepath.Join(espDir, "EFI", "BOOT", "BOOTX64.EFI")
+	if err := signEfiFile(keys, bootloaderPath); err != nil {
+		return fmt.Errorf("failed to sign bootloader: %w", err)
+	}
+	return nil
+}
 
-	// Sign the UKI (Unified Kernel Image) - create signed file then replace original
-	ukiSignedPath := filepath.Join(espDir, "EFI", "Linux", "linux.efi.signed")
-	cmd := fmt.Sprintf("sbsign --key %s --cert %s --output %s %s",
-		pbKeyPath, prKeyPath, ukiSignedPath, ukiPath)
-	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
-		return fmt.Errorf("failed to sign UKI: %w", err)
+// signGrubBootChain signs GRUB on the ESP and the kernels it boots
+func signGrubBootChain(installRoot, espDir string, keys signingKeys, mode string) error {
+	bootDir := filepath.Join(espDir, "EFI", "BOOT")
+	if err := signGrubEfiDir(bootDir, installRoot, keys, mode); err != nil {
+		return err
 	}
 
-	// Replace original with signed version
-	if err := os.Rename(ukiSignedPath, ukiPath); err != nil {
-		return fmt.Errorf("failed to replace UKI with signed version: %w", err)
+	kernels, err := filepath.Glob(filepath.Join(installRoot, "boot", "vmlinuz-*"))
+	if err != nil {
+		return fmt.Errorf("failed to list kernels: %w", err)
+	}
+	for _, kernel := range kernels {
+		// Kernel symlinks point to a kernel signed in this loop
+		if info, err := os.Lstat(kernel); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := signEfiFile(keys, kernel); err != nil {
+			return fmt.Errorf("failed to sign kernel %s: %w", filepath.Base(kernel), err)
+		}
 	}
+	if len(kernels) == 0 {
+		return fmt.Errorf("no kernel found in /boot to sign")
+	}
+	return nil
+}
+
+// signGrubEfiDir signs the GRUB boot files of an EFI/BOOT directory. In "db"
+// mode BOOTX64.EFI and the GRUB behind it are signed with the DB key. In "mok"
+// mode BOOTX64.EFI is the Microsoft signed shim from shimRoot, GRUB moves to
+// grubx64.efi and is signed with the key that shim trusts once it is enrolled
+// with MokManager.
+func signGrubEfiDir(bootDir, shimRoot string, keys signingKeys, mode string) error {
+	bootloaderPath := filepath.Join(bootDir, "BOOTX64.EFI")
+	grubPath := filepath.Join(bootDir, "grubx64.efi")
+	if _, err := os.Stat(bootloaderPath); err != nil {
+		return fmt.Errorf("failed to find bootloader %s: %w", bootloaderPath, err)
+	}
+	_, err := os.Stat(grubPath)
+	shimInstalled := err == nil
 
-	// Sign the bootloader - create signed file then replace original
-	bootloaderSignedPath := filepath.Join(espDir, "EFI", "BOOT", "BOOTX64.EFI.signed")
-	cmd = fmt.Sprintf("sbsign --key %s --cert %s --output %s %s",
-		pbKeyPath, prKeyPath, bootloaderSignedPath, bootloaderPath)
-	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+	if mode == config.SecureBootModeMOK {
+		if !shimInstalled {
+			if err := installShim(bootDir, shimRoot); err != nil {
+				return err
+			}
+		}
+		if err := signEfiFile(keys, grubPath); err != nil {
+			return fmt.Errorf("failed to sign GRUB: %w", err)
+		}
+		if err := file.CopyFile(keys.cer, filepath.Join(bootDir, MokCertFileName), "", true); err != nil {
+			return fmt.Errorf("failed to copy certificate for MOK enrollment: %w", err)
+		}
+		return nil
+	}
+
+	if shimInstalled {
+		if err := signEfiFile(keys, grubPath); err != nil {
+			return fmt.Errorf("failed to sign GRUB: %w", err)
+		}
+	}
+	if err := signEfiFile(keys, bootloaderPath); err != nil {
 		return fmt.Errorf("failed to sign bootloader: %w", err)
 	}
+	return nil
+}
+
+// installShim moves GRUB to grubx64.efi, where shim loads it from, and
+// installs shim and MokManager from shimRoot in its place
+func installShim(bootDir, shimRoot string) error {
+	shimPath := findRootFile(shimRoot, shimPaths)
+	mokManagerPath := findRootFile(shimRoot, mokManagerPaths)
+	if shimPath == "" || mokManagerPath == "" {
+		return fmt.Errorf("secure boot mode mok needs shim and MokManager, add the shim-signed package")
+	}
+
+	bootloaderPath := filepath.Join(bootDir, "BOOTX64.EFI")
+	if err := os.Rename(bootloaderPath, filepath.Join(bootDir, "grubx64.efi")); err != nil {
+		return fmt.Errorf("failed to move GRUB to grubx64.efi: %w", err)
+	}
+	if err := file.CopyFile(shimPath, bootloaderPath, "", true); err != nil {
+		return fmt.Errorf("failed to install shim: %w", err)
+	}
+	if err := file.CopyFile(mokManagerPath, filepath.Join(bootDir, "mmx64.efi"), "", true); err != nil {
+		return fmt.Errorf("failed to install MokManager: %w", err)
+	}
+	return nil
+}
+
+// findRootFile returns the first of paths that exists below root
+func findRootFile(root string, paths []string) string {
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(root, path)); err == nil {
+			return filepath.Join(root, path)
+		}
+	}
+	return ""
+}
+
+// SignIsoBootFiles signs the EFI boot files of an ISO image: the GRUB image
+// in efiBootDir and the kernel it boots. shimRoot is the root file system
+// shim is taken from in "mok" mode.
+func SignIsoBootFiles(template *config.ImageTemplate, efiBootDir, kernelPath, shimRoot string) error {
+	if !template.IsSecureBootEnabled() {
+		return nil
+	}
+	keys, err := getSigningKeys(template)
+	if err != nil {
+		return err
+	}
+	if err := signGrubEfiDir(efiBootDir, shimRoot, keys, template.GetSecureBootMode()); err != nil {
+		return err
+	}
+	if err := signEfiFile(keys, kernelPath); err != nil {
+		return fmt.Errorf("failed to sign kernel: %w", err)
+	}
+	return nil
+}
 
-	// Replace original with signed version
-	if err := os.Rename(bootloaderSignedPath, bootloaderPath); err != nil {
-		return fmt.Errorf("failed to replace bootloader with signed version: %w", err)
+// SignUKI signs a UKI the firmware boots directly, such as the UKI of a
+// netboot bundle
+func SignUKI(template *config.ImageTemplate, ukiPath string) error {
+	if !template.IsSecureBootEnabled() {
+		return nil
+	}
+	keys, err := getSigningKeys(template)
+	if err != nil {
+		return err
 	}
+	if err := signEfiFile(keys, ukiPath); err != nil {
+		return fmt.Errorf("failed to sign UKI: %w", err)
+	}
+	return nil
+}
 
+// copyCertToImageBuildDir copies the DER certificate next to the built image
+// for enrollment in the firmware
+func copyCertToImageBuildDir(template *config.ImageTemplate, prCerPath string) error {
 	// Getting image build directory
 	globalWorkDir, err := config.WorkDir()
 	if err != nil {
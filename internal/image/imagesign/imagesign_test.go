@@ -775,3 +775,162 @@ func TestSignImage_PartialSecureBootConfig(t *testing.T) {
 		})
 	}
 }
+
+// writeGrubTestFiles creates key files, a GRUB ESP and a kernel with a symlink
+func writeGrubTestFiles(t *testing.T, installRoot string) config.ImmutabilityConfig {
+	t.Helper()
+	files := map[string]string{
+		"test.key":                      "test key",
+		"test.crt":                      "test cert",
+		"test.cer":                      "test cer",
+		"boot/efi/EFI/BOOT/BOOTX64.EFI": "grub",
+		"boot/vmlinuz-6.8.0":            "kernel",
+	}
+	for name, content := range files {
+		path := filepath.Join(installRoot, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	if err := os.Symlink("vmlinuz-6.8.0", filepath.Join(installRoot, "boot", "vmlinuz")); err != nil {
+		t.Fatalf("Failed to create kernel symlink: %v", err)
+	}
+	return config.ImmutabilityConfig{
+		SecureBoot:      true,
+		SecureBootDBKey: filepath.Join(installRoot, "test.key"),
+		SecureBootDBCrt: filepath.Join(installRoot, "test.crt"),
+		SecureBootDBCer: filepath.Join(installRoot, "test.cer"),
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(content)
+}
+
+func TestSignImage_GrubDBMode(t *testing.T) {
+	installRoot := t.TempDir()
+
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = &CustomMockExecutor{
+		mockCommands: []shell.MockCommand{{Pattern: `sbsign --key`, Output: "Signing successful"}},
+		t:            t,
+	}
+
+	template := &config.ImageTemplate{
+		SystemConfig: config.SystemConfig{
+			Name:         "test-config",
+			Immutability: writeGrubTestFiles(t, installRoot),
+			Bootloader:   config.Bootloader{BootType: "efi", Provider: "grub"},
+		},
+	}
+
+	if err := imagesign.SignImage(installRoot, template); err != nil {
+		t.Fatalf("SignImage should sign the GRUB boot chain without immutability, got: %v", err)
+	}
+	if content := readTestFile(t, filepath.Join(installRoot, "boot", "efi", "EFI", "BOOT", "BOOTX64.EFI")); content != "signed content" {
+		t.Errorf("expected GRUB to be signed, got %q", content)
+	}
+	if content := readTestFile(t, filepath.Join(installRoot, "boot", "vmlinuz-6.8.0")); content != "signed content" {
+		t.Errorf("expected the kernel to be signed, got %q", content)
+	}
+	if info, err := os.Lstat(filepath.Join(installRoot, "boot", "vmlinuz")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected the kernel symlink to be left alone, got: %v", err)
+	}
+}
+
+func TestSignImage_GrubMOKMode(t *testing.T) {
+	installRoot := t.TempDir()
+
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = &CustomMockExecutor{
+		mockCommands: []shell.MockCommand{{Pattern: `sbsign --key`, Output: "Signing successful"}},
+		t:            t,
+	}
+
+	immutability := writeGrubTestFiles(t, installRoot)
+	immutability.SecureBootMode = config.SecureBootModeMOK
+	template := &config.ImageTemplate{
+		SystemConfig: config.SystemConfig{
+			Name:         "test-config",
+			Immutability: immutability,
+			Bootloader:   config.Bootloader{BootType: "efi", Provider: "grub"},
+		},
+	}
+
+	err := imagesign.SignImage(installRoot, template)
+	if err == nil || !strings.Contains(err.Error(), "shim-signed") {
+		t.Fatalf("expected an error without shim, got: %v", err)
+	}
+
+	shimDir := filepath.Join(installRoot, "usr", "lib", "shim")
+	if err := os.MkdirAll(shimDir, 0755); err != nil {
+		t.Fatalf("Failed to create shim directory: %v", err)
+	}
+	for _, name := range []string{"shimx64.efi.signed", "mmx64.efi.signed"} {
+		if err := os.WriteFile(filepath.Join(shimDir, name), []byte("shim"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	if err := imagesign.SignImage(installRoot, template); err != nil {
+		t.Fatalf("SignImage should install shim and sign GRUB, got: %v", err)
+	}
+	if content := readTestFile(t, filepath.Join(installRoot, "boot", "efi", "EFI", "BOOT", "grubx64.efi")); content != "signed content" {
+		t.Errorf("expected GRUB to move to grubx64.efi and be signed, got %q", content)
+	}
+}
+
+func TestSignImage_SecureBootWithoutKeys(t *testing.T) {
+	template := &config.ImageTemplate{
+		SystemConfig: config.SystemConfig{
+			Immutability: config.ImmutabilityConfig{SecureBoot: true},
+		},
+	}
+
+	err := imagesign.SignImage(t.TempDir(), template)
+	if err == nil || !strings.Contains(err.Error(), "secureBootDBKey") {
+		t.Errorf("SignImage should fail when secure boot is requested without keys, got: %v", err)
+	}
+}
+
+func TestSignUKI(t *testing.T) {
+	installRoot := t.TempDir()
+
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = &CustomMockExecutor{
+		mockCommands: []shell.MockCommand{{Pattern: `sbsign --key`, Output: "Signing successful"}},
+		t:            t,
+	}
+
+	ukiPath := filepath.Join(installRoot, "netboot.efi")
+	if err := os.WriteFile(ukiPath, []byte("uki"), 0644); err != nil {
+		t.Fatalf("Failed to create UKI: %v", err)
+	}
+
+	template := &config.ImageTemplate{}
+	if err := imagesign.SignUKI(template, ukiPath); err != nil {
+		t.Fatalf("SignUKI should skip signing without secure boot, got: %v", err)
+	}
+	if content := readTestFile(t, ukiPath); content != "uki" {
+		t.Errorf("expected the UKI to be left alone, got %q", content)
+	}
+
+	template.SystemConfig.Immutability = writeGrubTestFiles(t, installRoot)
+	if err := imagesign.SignUKI(template, ukiPath); err != nil {
+		t.Fatalf("SignUKI should sign the UKI, got: %v", err)
+	}
+	if content := readTestFile(t, ukiPath); content != "signed content" {
+		t.Errorf("expected the UKI to be signed, got %q", content)
+	}
+}
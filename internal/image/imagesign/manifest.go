@@ -0,0 +1,81 @@
+package imagesign
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
+)
+
+// ManifestSuffix names the signed manifest written next to an image artifact
+const ManifestSuffix = ".manifest.json"
+
+// manifestSchemaVersion is the schema version of the image manifest
+const manifestSchemaVersion = "1.0"
+
+// WriteArtifactManifests writes a signed manifest next to each artifact named
+// after basePath, such as image.raw, image.qcow2 or image-gce.tar.gz. The
+// manifest records the size and SHA-256 hash of the artifact and is signed
+// with the Secure Boot DB key of the template.
+func WriteArtifactManifests(template *config.ImageTemplate, basePath, imageVersion string) error {
+	signer, err := NewSigner(template)
+	if err != nil {
+		return fmt.Errorf("failed to get the manifest signer: %w", err)
+	}
+	var artifacts []string
+	for _, pattern := range []string{basePath + ".*", basePath + "-*"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("failed to list image artifacts: %w", err)
+		}
+		artifacts = append(artifacts, matches...)
+	}
+	for _, artifactPath := range artifacts {
+		if strings.HasSuffix(artifactPath, ManifestSuffix) {
+			continue
+		}
+		if info, err := os.Stat(artifactPath); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := writeArtifactManifest(signer, template, artifactPath, imageVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArtifactManifest writes the signed manifest of one artifact
+func writeArtifactManifest(signer Signer, template *config.ImageTemplate, artifactPath, imageVersion string) error {
+	artifact, err := os.Open(artifactPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", artifactPath, err)
+	}
+	defer artifact.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, artifact)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", artifactPath, err)
+	}
+
+	imageManifest := manifest.SoftwarePackageManifest{
+		SchemaVersion: manifestSchemaVersion,
+		ImageVersion:  imageVersion,
+		BuiltAt:       time.Now().UTC().Format(time.RFC3339),
+		Arch:          template.Target.Arch,
+		SizeBytes:     size,
+		Hash:          hex.EncodeToString(hash.Sum(nil)),
+		HashAlg:       "sha256",
+	}
+	if err := manifest.SignManifest(&imageManifest, signer.SignData); err != nil {
+		return err
+	}
+	return manifest.WriteManifestToFile(imageManifest, artifactPath+ManifestSuffix)
+}
@@ -0,0 +1,84 @@
+package imagesign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
+)
+
+func TestWriteArtifactManifests(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "DB.key")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	template := signerTemplate(keyPath)
+	template.Target.Arch = "x86_64"
+
+	buildDir := t.TempDir()
+	basePath := filepath.Join(buildDir, "minimal-1.0")
+	for name, content := range map[string]string{
+		"minimal-1.0.raw":        "raw image",
+		"minimal-1.0.qcow2":      "qcow2 image",
+		"minimal-1.0-gce.tar.gz": "gce image",
+		"minimal-2.0.raw":        "other version",
+		"size_report.txt":        "report",
+	} {
+		if err := os.WriteFile(filepath.Join(buildDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	if err := WriteArtifactManifests(template, basePath, "1.0"); err != nil {
+		t.Fatalf("WriteArtifactManifests failed: %v", err)
+	}
+	// Manifests of a previous run are replaced, not signed themselves
+	if err := WriteArtifactManifests(template, basePath, "1.0"); err != nil {
+		t.Fatalf("WriteArtifactManifests failed on rerun: %v", err)
+	}
+
+	manifests, _ := filepath.Glob(filepath.Join(buildDir, "*"+ManifestSuffix))
+	if len(manifests) != 3 {
+		t.Fatalf("expected manifests for the raw, qcow2 and gce images, got %v", manifests)
+	}
+
+	data, err := os.ReadFile(basePath + ".raw" + ManifestSuffix)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var got manifest.SoftwarePackageManifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	imageDigest := sha256.Sum256([]byte("raw image"))
+	if got.Hash != hex.EncodeToString(imageDigest[:]) || got.SizeBytes != int64(len("raw image")) ||
+		got.ImageVersion != "1.0" || got.Arch != "x86_64" {
+		t.Errorf("unexpected manifest %+v", got)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(got.Signature)
+	if err != nil {
+		t.Fatalf("failed to decode signature: %v", err)
+	}
+	got.Signature = ""
+	payload, _ := json.Marshal(got)
+	digest := sha256.Sum256(payload)
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("manifest signature does not verify: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package imagesign
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// ModuleSignatureMagic ends every signed kernel module
+const ModuleSignatureMagic = "~Module signature appended~\n"
+
+// pkeyIDPKCS7 is the module signature id type of a PKCS#7 (CMS) signature
+const pkeyIDPKCS7 = 2
+
+// moduleSignatureSize is the size of struct module_signature
+const moduleSignatureSize = 12
+
+// IsModuleSigned returns whether the uncompressed kernel module carries an
+// appended PKCS#7 signature record: the signature, struct module_signature
+// describing it and the magic string
+func IsModuleSigned(module []byte) bool {
+	if !bytes.HasSuffix(module, []byte(ModuleSignatureMagic)) {
+		return false
+	}
+	body := module[:len(module)-len(ModuleSignatureMagic)]
+	if len(body) < moduleSignatureSize {
+		return false
+	}
+	// struct module_signature { u8 algo, hash, id_type, signer_len, key_id_len; u8 __pad[3]; __be32 sig_len; }
+	moduleSignature := body[len(body)-moduleSignatureSize:]
+	if moduleSignature[2] != pkeyIDPKCS7 {
+		return false
+	}
+	// The kernel rejects PKCS#7 records with any other field set
+	for i, field := range moduleSignature[:8] {
+		if i != 2 && field != 0 {
+			return false
+		}
+	}
+	sigLen := binary.BigEndian.Uint32(moduleSignature[8:])
+	return sigLen > 0 && uint64(sigLen) <= uint64(len(body)-moduleSignatureSize)
+}
+
+// appendModuleSignature appends a detached CMS signature to a kernel module
+// the way the kernel's sign-file does: the signature, struct module_signature
+// and the magic string
+func appendModuleSignature(modulePath string, signature []byte) error {
+	module, err := os.ReadFile(modulePath)
+	if err != nil {
+		return fmt.Errorf("failed to read kernel module %s: %w", modulePath, err)
+	}
+
+	moduleSignature := make([]byte, moduleSignatureSize)
+	moduleSignature[2] = pkeyIDPKCS7
+	binary.BigEndian.PutUint32(moduleSignature[8:], uint32(len(signature)))
+
+	signed := make([]byte, 0, len(module)+len(signature)+len(moduleSignature)+len(ModuleSignatureMagic))
+	signed = append(signed, module...)
+	signed = append(signed, signature...)
+	signed = append(signed, moduleSignature...)
+	signed = append(signed, ModuleSignatureMagic...)
+	if err := os.WriteFile(modulePath, signed, 0644); err != nil {
+		return fmt.Errorf("failed to write signed kernel module %s: %w", modulePath, err)
+	}
+	return nil
+}
+
+// cmsSignArgs are the openssl cms options producing the detached signature
+// that sign-file appends to modules
+const cmsSignArgs = "cms -sign -binary -noattr -nosmimecap -nocerts -outform DER -md sha256"
@@ -0,0 +1,111 @@
+package imagesign
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeModuleFile(t *testing.T) string {
+	t.Helper()
+	modulePath := filepath.Join(t.TempDir(), "driver.ko")
+	if err := os.WriteFile(modulePath, []byte("module"), 0644); err != nil {
+		t.Fatalf("failed to create module: %v", err)
+	}
+	return modulePath
+}
+
+func TestAppendModuleSignature(t *testing.T) {
+	modulePath := writeModuleFile(t)
+	if err := appendModuleSignature(modulePath, []byte("signature")); err != nil {
+		t.Fatalf("appendModuleSignature failed: %v", err)
+	}
+
+	module, err := os.ReadFile(modulePath)
+	if err != nil {
+		t.Fatalf("failed to read module: %v", err)
+	}
+	if !IsModuleSigned(module) {
+		t.Fatalf("expected the module to be signed, got %q", module)
+	}
+	trailer := module[len(module)-len(ModuleSignatureMagic)-12 : len(module)-len(ModuleSignatureMagic)]
+	if trailer[2] != pkeyIDPKCS7 {
+		t.Errorf("expected id type %d, got %d", pkeyIDPKCS7, trailer[2])
+	}
+	if sigLen := binary.BigEndian.Uint32(trailer[8:]); sigLen != uint32(len("signature")) {
+		t.Errorf("expected signature length %d, got %d", len("signature"), sigLen)
+	}
+	if !strings.HasPrefix(string(module), "modulesignature") {
+		t.Errorf("expected the signature after the module, got %q", module)
+	}
+	if IsModuleSigned([]byte("module")) {
+		t.Error("expected an unsigned module to be reported as unsigned")
+	}
+}
+
+func TestIsModuleSigned(t *testing.T) {
+	record := func(idType byte, sigLen uint32) []byte {
+		moduleSignature := make([]byte, moduleSignatureSize)
+		moduleSignature[2] = idType
+		binary.BigEndian.PutUint32(moduleSignature[8:], sigLen)
+		return append(moduleSignature, ModuleSignatureMagic...)
+	}
+
+	tests := []struct {
+		name   string
+		module []byte
+		signed bool
+	}{
+		{"signed", append([]byte("modulesignature"), record(pkeyIDPKCS7, 9)...), true},
+		{"magic only", []byte("module" + ModuleSignatureMagic), false},
+		{"truncated record", []byte("\x02" + ModuleSignatureMagic), false},
+		{"not pkcs7", append([]byte("modulesignature"), record(1, 9)...), false},
+		{"empty signature", append([]byte("module"), record(pkeyIDPKCS7, 0)...), false},
+		{"signature longer than module", append([]byte("module"), record(pkeyIDPKCS7, 64)...), false},
+	}
+	for _, tt := range tests {
+		if signed := IsModuleSigned(tt.module); signed != tt.signed {
+			t.Errorf("%s: expected IsModuleSigned %v, got %v", tt.name, tt.signed, signed)
+		}
+	}
+
+	module := append([]byte("modulesignature"), record(pkeyIDPKCS7, 9)...)
+	module[len("modulesignature")+3] = 1
+	if IsModuleSigned(module) {
+		t.Error("expected a record with a signer length to be rejected")
+	}
+}
+
+func TestSignKernelModule(t *testing.T) {
+	executor := useSigningExecutor(t)
+
+	tests := []struct {
+		name    string
+		signer  Signer
+		command string
+	}{
+		{"file", &fileSigner{key: "/keys/DB.key", crt: "/keys/DB.crt"}, "openssl " + cmsSignArgs + " -signer /keys/DB.crt -inkey /keys/DB.key"},
+		{"pkcs11", &pkcs11Signer{uri: "pkcs11:token=sb;object=db", crt: "/keys/DB.crt"}, "-engine pkcs11 -keyform engine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor.commands = nil
+			modulePath := writeModuleFile(t)
+			if err := tt.signer.SignKernelModule(modulePath); err != nil {
+				t.Fatalf("SignKernelModule failed: %v", err)
+			}
+			module, _ := os.ReadFile(modulePath)
+			if !IsModuleSigned(module) || !strings.HasPrefix(string(module), "modulesigned") {
+				t.Errorf("expected the signature to be appended to the module, got %q", module)
+			}
+			if len(executor.commands) != 1 || !strings.Contains(executor.commands[0], tt.command) {
+				t.Errorf("expected a command with %q, got %v", tt.command, executor.commands)
+			}
+			if _, err := os.Stat(modulePath + ".p7s"); !os.IsNotExist(err) {
+				t.Errorf("expected the detached signature to be removed, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package imagesign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/network"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+// Key URI schemes selecting the signer backend. A key without a scheme is a
+// private key file.
+const (
+	KeySchemePKCS11 = "pkcs11:"
+	KeySchemeExec   = "exec:"
+)
+
+// SignerTokenEnv names the environment variable holding the bearer token sent
+// to an HTTP signing service
+const SignerTokenEnv = "IMAGE_COMPOSER_SIGNER_TOKEN"
+
+// signerTimeout bounds a request to an HTTP signing service
+const signerTimeout = 5 * time.Minute
+
+// Signer signs with the Secure Boot DB key, wherever the key is kept. The
+// private key never has to be readable on the build machine, except for the
+// local key file backend.
+type Signer interface {
+	// SignEfiFile signs an EFI binary in place
+	SignEfiFile(filePath string) error
+	// SignData returns a detached SHA-256 signature of data
+	SignData(data []byte) ([]byte, error)
+	// SignKernelModule appends a signature to an uncompressed kernel module
+	SignKernelModule(modulePath string) error
+}
+
+// NewSigner returns the signer for the secure boot key of the template. The
+// key is a private key file, a PKCS#11 URI (pkcs11:token=...;object=...), a
+// signing script (exec:/path/to/script) or the URL of a signing service.
+func NewSigner(template *config.ImageTemplate) (Signer, error) {
+	keyURI := template.GetSecureBootDBKeyPath()
+	crtPath := template.GetSecureBootDBCrtPath()
+	if keyURI == "" {
+		return nil, fmt.Errorf("no secure boot key configured")
+	}
+
+	switch {
+	case strings.HasPrefix(keyURI, KeySchemePKCS11):
+		if strings.ContainsAny(keyURI, "'\n") {
+			return nil, fmt.Errorf("invalid PKCS#11 key URI")
+		}
+		return &pkcs11Signer{uri: keyURI, crt: crtPath}, nil
+	case strings.HasPrefix(keyURI, KeySchemeExec):
+		script := strings.TrimPrefix(keyURI, KeySchemeExec)
+		if strings.ContainsAny(script, "'\n") {
+			return nil, fmt.Errorf("invalid signing script path")
+		}
+		if _, err := os.Stat(script); err != nil {
+			return nil, fmt.Errorf("signing script not found at %s: %w", script, err)
+		}
+		return &execSigner{script: script, crt: crtPath}, nil
+	case strings.HasPrefix(keyURI, "https://") || strings.HasPrefix(keyURI, "http://"):
+		if _, err := url.Parse(keyURI); err != nil {
+			return nil, fmt.Errorf("invalid secure boot signing service URL")
+		}
+		return &httpSigner{url: keyURI}, nil
+	default:
+		if _, err := os.Stat(keyURI); err != nil {
+			return nil, fmt.Errorf("secure boot key file not found at %s: %w", keyURI, err)
+		}
+		return &fileSigner{key: keyURI, crt: crtPath}, nil
+	}
+}
+
+// replaceWithSigned replaces filePath with the signed file written next to it
+func replaceWithSigned(signedPath, filePath string) error {
+	if err := os.Rename(signedPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace %s with signed version: %w", filePath, err)
+	}
+	return nil
+}
+
+// signDataWithFiles writes data to a temporary file and returns the signature
+// that sign writes for it
+func signDataWithFiles(data []byte, sign func(dataPath, sigPath string) error) ([]byte, error) {
+	tempDir, err := os.MkdirTemp("", "imagesign-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dataPath := filepath.Join(tempDir, "data")
+	sigPath := filepath.Join(tempDir, "data.sig")
+	if err := os.WriteFile(dataPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write data to sign: %w", err)
+	}
+	if err := sign(dataPath, sigPath); err != nil {
+		return nil, err
+	}
+	signature, err := os.ReadFile(sigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+	return signature, nil
+}
+
+// fileSigner signs with a private key file on the build machine
+type fileSigner struct {
+	key string
+	crt string
+}
+
+func (s *fileSigner) SignEfiFile(filePath string) error {
+	signedPath := filePath + ".signed"
+	cmd := fmt.Sprintf("sbsign --key %s --cert %s --output %s %s", s.key, s.crt, signedPath, filePath)
+	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+		return err
+	}
+	return replaceWithSigned(signedPath, filePath)
+}
+
+func (s *fileSigner) SignData(data []byte) ([]byte, error) {
+	signer, err := readPrivateKey(s.key)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// readPrivateKey reads a PEM encoded PKCS#8, PKCS#1 or EC private key
+func readPrivateKey(keyPath string) (crypto.Signer, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("private key %s is not PEM encoded", keyPath)
+	}
+	var key any
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("failed to parse private key %s: %w", keyPath, err)
+			}
+		}
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key %s cannot sign", keyPath)
+	}
+	return signer, nil
+}
+
+// PublicKeyPEM returns the PEM encoded public key of a private key file
+func PublicKeyPEM(keyPath string) ([]byte, error) {
+	signer, err := readPrivateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key of %s: %w", keyPath, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func (s *fileSigner) SignKernelModule(modulePath string) error {
+	sigPath := modulePath + ".p7s"
+	defer os.Remove(sigPath)
+	cmd := fmt.Sprintf("openssl %s -signer %s -inkey %s -in %s -out %s", cmsSignArgs, s.crt, s.key, modulePath, sigPath)
+	if _, err := shell.ExecCmd(cmd, true, shell.HostPath, nil); err != nil {
+		return fmt.Errorf("failed to sign kernel module: %w", err)
+	}
+	return appendModuleSignatureFile(modulePath, sigPath)
+}
+
+// appendModuleSignatureFile appends the signature written to sigPath
+func appendModuleSignatureFile(modulePath, sigPath string) error {
+	signature, err := os.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("failed to read kernel module signature: %w", err)
+	}
+	return appendModuleSignature(modulePath, signature)
+}
+
+// pkcs11Signer signs with a key kept in an HSM, through the OpenSSL pkcs11
+// engine. The PKCS#11 module is found through p11-kit, and the URI may carry
+// the PIN as pin-source or pin-value.
+type pkcs11Signer struct {
+	uri string
+	crt string
+}
+
+// pinValueRe matches the pin-value attribute of a PKCS#11 URI
+var pinValueRe = regexp.MustCompile(`([;?&])pin-value=([^;&]*)`)
+
+// run runs a signing command with the key URI in SIGN_KEY, since PKCS#11
+// URIs separate their attributes with semicolons. A pin-value is moved to a
+// PIN file passed as pin-source, because failed commands are logged with
+// their environment.
+func (s *pkcs11Signer) run(cmd string) error {
+	keyURI := s.uri
+	if match := pinValueRe.FindStringSubmatch(keyURI); match != nil {
+		pin, err := url.PathUnescape(match[2])
+		if err != nil {
+			return fmt.Errorf("invalid pin-value in PKCS#11 key URI")
+		}
+		pinFile, err := os.CreateTemp("", "imagesign-pin-")
+		if err != nil {
+			return fmt.Errorf("failed to create PIN file: %w", err)
+		}
+		defer os.Remove(pinFile.Name())
+		_, err = pinFile.WriteString(pin)
+		if closeErr := pinFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write PIN file: %w", err)
+		}
+		keyURI = pinValueRe.ReplaceAllLiteralString(keyURI, match[1]+"pin-source=file:"+pinFile.Name())
+	}
+	_, err := shell.ExecCmd(cmd, true, shell.HostPath, []string{fmt.Sprintf("SIGN_KEY='%s'", keyURI)})
+	return err
+}
+
+func (s *pkcs11Signer) SignEfiFile(filePath string) error {
+	signedPath := filePath + ".signed"
+	cmd := fmt.Sprintf("sh -c 'sbsign --engine pkcs11 --key \"$SIGN_KEY\" --cert %s --output %s %s'",
+		s.crt, signedPath, filePath)
+	if err := s.run(cmd); err != nil {
+		return err
+	}
+	return replaceWithSigned(signedPath, filePath)
+}
+
+func (s *pkcs11Signer) SignData(data []byte) ([]byte, error) {
+	return signDataWithFiles(data, func(dataPath, sigPath string) error {
+		cmd := fmt.Sprintf("sh -c 'openssl dgst -sha256 -engine pkcs11 -keyform engine -sign \"$SIGN_KEY\" -out %s %s'",
+			sigPath, dataPath)
+		if err := s.run(cmd); err != nil {
+			return fmt.Errorf("failed to sign data with PKCS#11 key: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *pkcs11Signer) SignKernelModule(modulePath string) error {
+	sigPath := modulePath + ".p7s"
+	defer os.Remove(sigPath)
+	cmd := fmt.Sprintf("sh -c 'openssl %s -engine pkcs11 -keyform engine -signer %s -inkey \"$SIGN_KEY\" -in %s -out %s'",
+		cmsSignArgs, s.crt, modulePath, sigPath)
+	if err := s.run(cmd); err != nil {
+		return fmt.Errorf("failed to sign kernel module with PKCS#11 key: %w", err)
+	}
+	return appendModuleSignatureFile(modulePath, sigPath)
+}
+
+// execSigner hands signing to a script. The script gets SIGN_KIND ("efi",
+// "module" or "data"), SIGN_INPUT, SIGN_OUTPUT and SIGN_CERT in its
+// environment, and writes the signed EFI binary, the signed kernel module or
+// the detached signature to SIGN_OUTPUT.
+type execSigner struct {
+	script string
+	crt    string
+}
+
+func (s *execSigner) run(kind, input, output string) error {
+	env := []string{
+		"SIGN_KIND=" + kind,
+		"SIGN_INPUT=" + input,
+		"SIGN_OUTPUT=" + output,
+		"SIGN_CERT=" + s.crt,
+	}
+	if _, err := shell.ExecCmd("sh '"+s.script+"'", true, shell.HostPath, env); err != nil {
+		return fmt.Errorf("signing script %s failed: %w", s.script, err)
+	}
+	return nil
+}
+
+func (s *execSigner) SignEfiFile(filePath string) error {
+	signedPath := filePath + ".signed"
+	if err := s.run("efi", filePath, signedPath); err != nil {
+		return err
+	}
+	return replaceWithSigned(signedPath, filePath)
+}
+
+func (s *execSigner) SignKernelModule(modulePath string) error {
+	signedPath := modulePath + ".signed"
+	if err := s.run("module", modulePath, signedPath); err != nil {
+		return err
+	}
+	return replaceWithSigned(signedPath, modulePath)
+}
+
+func (s *execSigner) SignData(data []byte) ([]byte, error) {
+	return signDataWithFiles(data, func(dataPath, sigPath string) error {
+		return s.run("data", dataPath, sigPath)
+	})
+}
+
+// httpSigner posts what is signed to a signing service, with the kind ("efi",
+// "module" or "data") as query parameter, and reads the signed EFI binary, the
+// signed kernel module or the detached signature from the response body
+type httpSigner struct {
+	url string
+}
+
+func (s *httpSigner) post(kind string, content []byte) ([]byte, error) {
+	requestURL, err := url.Parse(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing service URL: %w", err)
+	}
+	query := requestURL.Query()
+	query.Set("kind", kind)
+	requestURL.RawQuery = query.Encode()
+
+	request, err := http.NewRequest(http.MethodPost, requestURL.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signing request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+	if token := os.Getenv(SignerTokenEnv); token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := *network.GetSecureHTTPClient()
+	client.Timeout = signerTimeout
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("signing service request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing service response: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signing service returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("signing service returned an empty response")
+	}
+	return body, nil
+}
+
+func (s *httpSigner) SignEfiFile(filePath string) error {
+	return s.signFile("efi", filePath)
+}
+
+func (s *httpSigner) SignKernelModule(modulePath string) error {
+	return s.signFile("module", modulePath)
+}
+
+// signFile replaces filePath with the signed file returned by the service
+func (s *httpSigner) signFile(kind, filePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	signed, err := s.post(kind, content)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+	signedPath := filePath + ".signed"
+	if err := os.WriteFile(signedPath, signed, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write signed %s: %w", filePath, err)
+	}
+	return replaceWithSigned(signedPath, filePath)
+}
+
+func (s *httpSigner) SignData(data []byte) ([]byte, error) {
+	return s.post("data", data)
+}
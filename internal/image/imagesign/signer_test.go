@@ -0,0 +1,267 @@
+package imagesign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+// signingExecutor records commands and writes the output file a signing
+// command names, like sbsign, openssl or a signing script would
+type signingExecutor struct {
+	commands []string
+	// inspect, when set, sees the environment of each command while it runs
+	inspect func(envVal []string)
+}
+
+func (e *signingExecutor) ExecCmd(cmdStr string, sudo bool, chrootPath string, envVal []string) (string, error) {
+	e.commands = append(e.commands, strings.Join(append(envVal, cmdStr), " "))
+	if e.inspect != nil {
+		e.inspect(envVal)
+	}
+	var output string
+	fields := strings.Fields(cmdStr)
+	for i, field := range fields {
+		if (field == "--output" || field == "-out") && i+1 < len(fields) {
+			output = strings.Trim(fields[i+1], "'")
+		}
+	}
+	for _, env := range envVal {
+		if value, ok := strings.CutPrefix(env, "SIGN_OUTPUT="); ok {
+			output = value
+		}
+	}
+	if output != "" {
+		if err := os.WriteFile(output, []byte("signed"), 0644); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func (e *signingExecutor) ExecCmdSilent(cmdStr string, sudo bool, chrootPath string, envVal []string) (string, error) {
+	return e.ExecCmd(cmdStr, sudo, chrootPath, envVal)
+}
+
+func (e *signingExecutor) ExecCmdWithStream(cmdStr string, sudo bool, chrootPath string, envVal []string) (string, error) {
+	return e.ExecCmd(cmdStr, sudo, chrootPath, envVal)
+}
+
+func (e *signingExecutor) ExecCmdWithInput(inputStr string, cmdStr string, sudo bool, chrootPath string, envVal []string) (string, error) {
+	return e.ExecCmd(cmdStr, sudo, chrootPath, envVal)
+}
+
+func useSigningExecutor(t *testing.T) *signingExecutor {
+	originalExecutor := shell.Default
+	t.Cleanup(func() { shell.Default = originalExecutor })
+	executor := &signingExecutor{}
+	shell.Default = executor
+	return executor
+}
+
+func signerTemplate(keyURI string) *config.ImageTemplate {
+	return &config.ImageTemplate{
+		SystemConfig: config.SystemConfig{
+			Immutability: config.ImmutabilityConfig{
+				SecureBoot:      true,
+				SecureBootDBKey: keyURI,
+				SecureBootDBCrt: "/keys/DB.crt",
+				SecureBootDBCer: "/keys/DB.cer",
+			},
+		},
+	}
+}
+
+func writeEfiFile(t *testing.T) string {
+	t.Helper()
+	efiPath := filepath.Join(t.TempDir(), "BOOTX64.EFI")
+	if err := os.WriteFile(efiPath, []byte("unsigned"), 0644); err != nil {
+		t.Fatalf("failed to create EFI file: %v", err)
+	}
+	return efiPath
+}
+
+func TestNewSigner(t *testing.T) {
+	tempDir := t.TempDir()
+	keyPath := filepath.Join(tempDir, "DB.key")
+	scriptPath := filepath.Join(tempDir, "sign.sh")
+	for _, path := range []string{keyPath, scriptPath} {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		keyURI string
+		want   Signer
+	}{
+		{keyPath, &fileSigner{key: keyPath, crt: "/keys/DB.crt"}},
+		{"pkcs11:token=sb;object=db", &pkcs11Signer{uri: "pkcs11:token=sb;object=db", crt: "/keys/DB.crt"}},
+		{"exec:" + scriptPath, &execSigner{script: scriptPath, crt: "/keys/DB.crt"}},
+		{"https://signer.example.com/sign", &httpSigner{url: "https://signer.example.com/sign"}},
+	}
+	for _, tt := range tests {
+		signer, err := NewSigner(signerTemplate(tt.keyURI))
+		if err != nil {
+			t.Errorf("NewSigner(%q) failed: %v", tt.keyURI, err)
+			continue
+		}
+		if !reflect.DeepEqual(signer, tt.want) {
+			t.Errorf("NewSigner(%q) returned %+v, expected %+v", tt.keyURI, signer, tt.want)
+		}
+	}
+
+	for keyURI, want := range map[string]string{
+		"":                       "no secure boot key",
+		"/missing/DB.key":        "key file not found",
+		"exec:/missing/sign.sh":  "signing script not found",
+		"pkcs11:object=it's-bad": "invalid PKCS#11 key URI",
+		"exec:/opt/it's/sign.sh": "invalid signing script path",
+	} {
+		if _, err := NewSigner(signerTemplate(keyURI)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("NewSigner(%q): expected an error containing %q, got: %v", keyURI, want, err)
+		}
+	}
+}
+
+func TestFileSignerSignData(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "DB.key")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	signer := &fileSigner{key: keyPath}
+	signature, err := signer.SignData([]byte("manifest"))
+	if err != nil {
+		t.Fatalf("SignData failed: %v", err)
+	}
+	digest := sha256.Sum256([]byte("manifest"))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestPKCS11Signer(t *testing.T) {
+	executor := useSigningExecutor(t)
+	signer := &pkcs11Signer{uri: "pkcs11:token=sb;object=db", crt: "/keys/DB.crt"}
+
+	efiPath := writeEfiFile(t)
+	if err := signer.SignEfiFile(efiPath); err != nil {
+		t.Fatalf("SignEfiFile failed: %v", err)
+	}
+	if content, _ := os.ReadFile(efiPath); string(content) != "signed" {
+		t.Errorf("expected the EFI file to be replaced with the signed one, got %q", content)
+	}
+	if _, err := signer.SignData([]byte("manifest")); err != nil {
+		t.Fatalf("SignData failed: %v", err)
+	}
+
+	if len(executor.commands) != 2 {
+		t.Fatalf("expected two commands, got %v", executor.commands)
+	}
+	for i, want := range []string{"sbsign --engine pkcs11 --key \"$SIGN_KEY\"", "openssl dgst -sha256 -engine pkcs11"} {
+		if !strings.HasPrefix(executor.commands[i], "SIGN_KEY='pkcs11:token=sb;object=db' sh -c") || !strings.Contains(executor.commands[i], want) {
+			t.Errorf("expected a command with %q, got %q", want, executor.commands[i])
+		}
+	}
+}
+
+func TestPKCS11SignerPinValue(t *testing.T) {
+	executor := useSigningExecutor(t)
+	signer := &pkcs11Signer{uri: "pkcs11:token=sb;object=db?pin-value=12%2534", crt: "/keys/DB.crt"}
+
+	var pinFile string
+	executor.inspect = func(envVal []string) {
+		match := regexp.MustCompile(`^SIGN_KEY='pkcs11:token=sb;object=db\?pin-source=file:([^']+)'$`).FindStringSubmatch(envVal[0])
+		if match == nil {
+			t.Errorf("expected the PIN to be passed as pin-source, got %q", envVal[0])
+			return
+		}
+		pinFile = match[1]
+		if pin, err := os.ReadFile(pinFile); err != nil || string(pin) != "12%34" {
+			t.Errorf("expected the PIN file to hold the decoded PIN, got %q, %v", pin, err)
+		}
+	}
+
+	if _, err := signer.SignData([]byte("manifest")); err != nil {
+		t.Fatalf("SignData failed: %v", err)
+	}
+	if strings.Contains(executor.commands[0], "12%2534") {
+		t.Errorf("expected the PIN to be kept out of the command, got %q", executor.commands[0])
+	}
+	if _, err := os.Stat(pinFile); pinFile == "" || !os.IsNotExist(err) {
+		t.Errorf("expected the PIN file %q to be removed, got: %v", pinFile, err)
+	}
+}
+
+func TestExecSigner(t *testing.T) {
+	executor := useSigningExecutor(t)
+	signer := &execSigner{script: "/opt/signer/sign.sh", crt: "/keys/DB.crt"}
+
+	efiPath := writeEfiFile(t)
+	if err := signer.SignEfiFile(efiPath); err != nil {
+		t.Fatalf("SignEfiFile failed: %v", err)
+	}
+	signature, err := signer.SignData([]byte("manifest"))
+	if err != nil {
+		t.Fatalf("SignData failed: %v", err)
+	}
+	if string(signature) != "signed" {
+		t.Errorf("expected the signature written by the script, got %q", signature)
+	}
+	want := "SIGN_KIND=efi SIGN_INPUT=" + efiPath + " SIGN_OUTPUT=" + efiPath + ".signed SIGN_CERT=/keys/DB.crt sh '/opt/signer/sign.sh'"
+	if executor.commands[0] != want {
+		t.Errorf("expected command %q, got %q", want, executor.commands[0])
+	}
+}
+
+func TestHTTPSigner(t *testing.T) {
+	t.Setenv(SignerTokenEnv, "build-token")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer build-token" {
+			http.Error(w, "not allowed", http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.URL.Query().Get("kind") + ":" + string(body)))
+	}))
+	defer server.Close()
+
+	signer := &httpSigner{url: server.URL + "/sign"}
+	efiPath := writeEfiFile(t)
+	if err := signer.SignEfiFile(efiPath); err != nil {
+		t.Fatalf("SignEfiFile failed: %v", err)
+	}
+	if content, _ := os.ReadFile(efiPath); string(content) != "efi:unsigned" {
+		t.Errorf("expected the binary returned by the signing service, got %q", content)
+	}
+	signature, err := signer.SignData([]byte("manifest"))
+	if err != nil || string(signature) != "data:manifest" {
+		t.Errorf("expected the signature returned by the signing service, got %q, %v", signature, err)
+	}
+
+	t.Setenv(SignerTokenEnv, "")
+	if _, err := signer.SignData([]byte("manifest")); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected the signing service to refuse the request, got: %v", err)
+	}
+}
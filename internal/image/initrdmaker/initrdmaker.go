@@ -141,6 +141,7 @@ func (initrdMaker *InitrdMaker) BuildInitrdImage() (err error) {
 		if cleanErr := initrdMaker.CleanInitrdRootfs(); cleanErr != nil {
 			log.Errorf("Failed to clean initrd rootfs after install failure: %v", cleanErr)
 		}
+		imageos.CopySizeReportOnBudgetError(err, initrdMaker.ImageBuildDir)
 		return fmt.Errorf("failed to install initrd: %w", err)
 	}
 
@@ -171,6 +172,10 @@ func (initrdMaker *InitrdMaker) BuildInitrdImage() (err error) {
 		// Don't fail the build if SBOM copy fails, just log warning
 	}
 
+	if err := imageos.CopySizeReportToImageBuildDir(initrdMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy size report to image build directory: %v", err)
+	}
+
 	return nil
 }
 
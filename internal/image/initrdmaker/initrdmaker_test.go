@@ -195,6 +195,14 @@ func (m *mockImageOs) InstallImageOs(diskPathIdMap map[string]string) (versionIn
 	return m.versionInfo, m.err
 }
 
+func (m *mockImageOs) InstallLiveOs() (string, error) {
+	return m.versionInfo, m.err
+}
+
+func (m *mockImageOs) InstallContainerOs() (string, error) {
+	return m.versionInfo, m.err
+}
+
 func TestNewInitrdMaker(t *testing.T) {
 	tests := []struct {
 		name        string
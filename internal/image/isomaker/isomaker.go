@@ -10,6 +10,7 @@ import (
 	"github.com/open-edge-platform/os-image-composer/internal/config"
 	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
 	"github.com/open-edge-platform/os-image-composer/internal/image/imageos"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
 	"github.com/open-edge-platform/os-image-composer/internal/image/initrdmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
@@ -18,8 +19,9 @@ import (
 )
 
 type IsoMakerInterface interface {
-	Init() error          // Initialize with stored template
-	BuildIsoImage() error // Build ISO image using stored template
+	Init() error              // Initialize with stored template
+	BuildIsoImage() error     // Build ISO image using stored template
+	BuildLiveIsoImage() error // Build live ISO image using stored template
 }
 
 type IsoMaker struct {
@@ -85,6 +87,7 @@ func (isoMaker *IsoMaker) BuildIsoImage() (err error) {
 	log.Infof("Building ISO image for: %s", isoMaker.template.GetImageName())
 
 	if err := isoMaker.buildInitrd(isoMaker.template); err != nil {
+		imageos.CopySizeReportOnBudgetError(err, isoMaker.ImageBuildDir)
 		return fmt.Errorf("failed to build initrd image: %w", err)
 	}
 	defer func() {
@@ -103,12 +106,22 @@ func (isoMaker *IsoMaker) BuildIsoImage() (err error) {
 		return fmt.Errorf("failed to create ISO image: %w", err)
 	}
 
+	if isoMaker.template.IsSecureBootEnabled() {
+		if err := imagesign.WriteArtifactManifests(isoMaker.template, filepath.Join(isoMaker.ImageBuildDir, ImageName), versionInfo); err != nil {
+			return fmt.Errorf("failed to write signed image manifests: %w", err)
+		}
+	}
+
 	// Copy SBOM to image build directory
 	if err := manifest.CopySBOMToImageBuildDir(isoMaker.ImageBuildDir); err != nil {
 		log.Warnf("Failed to copy SBOM to image build directory: %v", err)
 		// Don't fail the build if SBOM copy fails, just log warning
 	}
 
+	if err := imageos.CopySizeReportToImageBuildDir(isoMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy size report to image build directory: %v", err)
+	}
+
 	log.Infof("ISO image build completed successfully: %s", isoFilePath)
 
 	return nil
@@ -198,6 +211,35 @@ func (isoMaker *IsoMaker) copyConfigFilesToIso(template *config.ImageTemplate, i
 	}
 	template.SystemConfig.AdditionalFiles = PathUpdatedList
 
+	// Inline SSH key files, their host paths do not exist on the ISO
+	for i, user := range template.SystemConfig.Users {
+		sshKeys, err := template.GetUserSSHKeys(user)
+		if err != nil {
+			return fmt.Errorf("failed to collect SSH keys for user %s: %w", user.Name, err)
+		}
+		template.SystemConfig.Users[i].SSHKeys = sshKeys
+		template.SystemConfig.Users[i].SSHKeyFiles = nil
+	}
+
+	// Copy wifi PSK files to ISO and update path info. Rock Ridge makes every
+	// file on the ISO world-readable, so the PSK ships in plaintext on the media.
+	for i, iface := range template.SystemConfig.Network.Interfaces {
+		if iface.PSKFile == "" {
+			continue
+		}
+		psk, err := template.GetNetworkPSK(iface)
+		if err != nil {
+			return fmt.Errorf("failed to read PSK for interface %s: %w", iface.Name, err)
+		}
+		pskFileName := fmt.Sprintf("network-%s.psk", iface.Name)
+		dstFile := filepath.Join(osvConfigDestDir, "imageconfigs", "additionalfiles", pskFileName)
+		if err := file.Write(psk+"\n", dstFile); err != nil {
+			log.Errorf("Failed to copy PSK file for interface %s to iso root: %v", iface.Name, err)
+			return fmt.Errorf("failed to copy PSK file for interface %s to iso root: %w", iface.Name, err)
+		}
+		template.SystemConfig.Network.Interfaces[i].PSKFile = fmt.Sprintf("../additionalfiles/%s", pskFileName)
+	}
+
 	// Dump updated template to ISO
 	templateDumpFilePath := filepath.Join(isoMaker.ImageBuildDir, "template-dump.yaml")
 	if err := template.SaveUpdatedConfigFile(templateDumpFilePath); err != nil {
@@ -286,10 +328,14 @@ func (isoMaker *IsoMaker) createIso(template *config.ImageTemplate, initrdRootfs
 		return fmt.Errorf("failed to copy config files to ISO: %w", err)
 	}
 
-	// Copy image packages to ISO
-	log.Infof("Copying image packages to ISO...")
-	if err := isoMaker.copyImagePkgsToIso(template, installRoot); err != nil {
-		return fmt.Errorf("failed to copy image packages to ISO: %w", err)
+	// Copy image packages to ISO, unless the installer fetches them from the network
+	if template.Target.InstallRepo != "" {
+		log.Infof("Netinstall ISO: packages will be fetched from %s at install time", config.RedactURLCredentials(template.Target.InstallRepo))
+	} else {
+		log.Infof("Copying image packages to ISO...")
+		if err := isoMaker.copyImagePkgsToIso(template, installRoot); err != nil {
+			return fmt.Errorf("failed to copy image packages to ISO: %w", err)
+		}
 	}
 
 	// Create GRUB config for EFI boot
@@ -308,7 +354,6 @@ func (isoMaker *IsoMaker) createIso(template *config.ImageTemplate, initrdRootfs
 	if err != nil {
 		return fmt.Errorf("failed to create EFI FAT image: %w", err)
 	}
-	efiFatImgRelPath := strings.TrimPrefix(efiFatImgPath, installRoot)
 
 	log.Infof("Creating image for Bios boot...")
 	biosImgRelPath, err := createBiosImage(template, initrdRootfsPath, installRoot)
@@ -316,8 +361,24 @@ func (isoMaker *IsoMaker) createIso(template *config.ImageTemplate, initrdRootfs
 		return fmt.Errorf("failed to create BIOS image: %w", err)
 	}
 
-	// Create ISO image with xorriso
+	if err := makeIsoImage(installRoot, isoFilePath, IsoLabel, efiFatImgPath, biosImgRelPath, ""); err != nil {
+		return err
+	}
+
+	if err := cleanIsoInstallRoot(installRoot); err != nil {
+		return fmt.Errorf("failed to clean up ISO install root: %w", err)
+	}
+
+	log.Infof("ISO creation completed successfully")
+	return nil
+}
+
+// makeIsoImage writes the ISO image of the ISO root with xorriso, bootable
+// from CD and USB media. A non-empty partImgPath is appended as a partition
+// the system can write to once the image is on a USB stick.
+func makeIsoImage(isoRoot, isoFilePath, volumeID, efiFatImgPath, biosImgRelPath, partImgPath string) error {
 	log.Infof("Creating ISO image with xorriso...")
+	efiFatImgRelPath := strings.TrimPrefix(efiFatImgPath, isoRoot)
 	var xorrisoCmd string
 	if biosImgRelPath != "" {
 		// Support both BIOS and UEFI boot mode
@@ -325,32 +386,32 @@ func (isoMaker *IsoMaker) createIso(template *config.ImageTemplate, initrdRootfs
 		biosImgRelDir := filepath.Dir(biosImgRelPath)
 		xorrisoCmd = fmt.Sprintf("xorriso -as mkisofs -graft-points -r -J -l -b %s", biosImgRelPath)
 		xorrisoCmd += " -no-emul-boot -boot-load-size 4 -boot-info-table --grub2-boot-info"
-		xorrisoCmd += fmt.Sprintf(" --grub2-mbr %s", filepath.Join(installRoot, biosImgRelDir, "boot_hybrid.img"))
+		xorrisoCmd += fmt.Sprintf(" --grub2-mbr %s", filepath.Join(isoRoot, biosImgRelDir, "boot_hybrid.img"))
 		xorrisoCmd += fmt.Sprintf(" -eltorito-alt-boot -e %s -no-emul-boot", efiFatImgRelPath)
 		xorrisoCmd += fmt.Sprintf(" -append_partition 2 0xef %s -appended_part_as_gpt", efiFatImgPath)
-		xorrisoCmd += fmt.Sprintf(" -r %s --sort-weight 0 / --sort-weight 1 /boot", installRoot)
+		if partImgPath != "" {
+			xorrisoCmd += fmt.Sprintf(" -append_partition 3 0x83 %s", partImgPath)
+		}
+		xorrisoCmd += fmt.Sprintf(" -r %s --sort-weight 0 / --sort-weight 1 /boot", isoRoot)
 		xorrisoCmd += fmt.Sprintf(" -volid \"%s\" --protective-msdos-label -o \"%s\" \"%s\"",
-			IsoLabel, isoFilePath, installRoot)
+			volumeID, isoFilePath, isoRoot)
 	} else {
 		// Support only UEFI boot mode
 		log.Infof("Creating ISO for UEFI boot mode only...")
 		xorrisoCmd = fmt.Sprintf("xorriso -as mkisofs -graft-points -r -J -l --efi-boot %s", efiFatImgPath)
 		xorrisoCmd += " -efi-boot-part --efi-boot-image --protective-msdos-label"
-		xorrisoCmd += fmt.Sprintf(" -r %s --sort-weight 0 / --sort-weight 1 /boot", installRoot)
+		if partImgPath != "" {
+			xorrisoCmd += fmt.Sprintf(" -append_partition 3 0x83 %s", partImgPath)
+		}
+		xorrisoCmd += fmt.Sprintf(" -r %s --sort-weight 0 / --sort-weight 1 /boot", isoRoot)
 		xorrisoCmd += fmt.Sprintf(" -volid \"%s\" -o \"%s\" \"%s\"",
-			IsoLabel, isoFilePath, installRoot)
+			volumeID, isoFilePath, isoRoot)
 	}
 
 	if _, err := shell.ExecCmdWithStream(xorrisoCmd, true, shell.HostPath, nil); err != nil {
 		log.Errorf("Failed to create ISO image: %v", err)
 		return fmt.Errorf("failed to create ISO image: %w", err)
 	}
-
-	if err := cleanIsoInstallRoot(installRoot); err != nil {
-		return fmt.Errorf("failed to clean up ISO install root: %w", err)
-	}
-
-	log.Infof("ISO creation completed successfully")
 	return nil
 }
 
@@ -572,12 +633,22 @@ func createEfiFatImage(template *config.ImageTemplate, initrdRootfsPath, install
 		grubmkCmd := fmt.Sprintf("grub-mkimage --format=%s --output=%s", format, efiImgPath)
 		grubmkCmd += fmt.Sprintf(" --config=%s --directory=%s --prefix=%s", loadCfgSrc, grubLibDir, prefixDir)
 		grubmkCmd += " part_gpt part_msdos fat ext2 ntfs search iso9660"
+		if template.IsSecureBootEnabled() {
+			// GRUB is locked down under Secure Boot and cannot load modules from disk
+			grubmkCmd += " " + strings.Join(imagesign.GrubLockdownModules, " ")
+		}
 
 		if _, err := shell.ExecCmd(grubmkCmd, true, shell.HostPath, nil); err != nil {
 			log.Errorf("Failed to create EFI image: %v", err)
 			return efiFatImgPath, fmt.Errorf("failed to create EFI image: %w", err)
 		}
 
+		kernelPath := filepath.Join(installRoot, "images", "vmlinuz")
+		if err := imagesign.SignIsoBootFiles(template, filepath.Join(efiDirPath, "BOOT"), kernelPath, initrdRootfsPath); err != nil {
+			log.Errorf("Failed to sign EFI boot files: %v", err)
+			return efiFatImgPath, fmt.Errorf("failed to sign EFI boot files: %w", err)
+		}
+
 		cmdStr = fmt.Sprintf("mcopy -s -i %s %s ::/.", efiFatImgPath, efiDirPath)
 		if _, err := shell.ExecCmd(cmdStr, true, shell.HostPath, nil); err != nil {
 			log.Errorf("Failed to copy EFI files to FAT image: %v", err)
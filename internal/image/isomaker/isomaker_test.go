@@ -1163,6 +1163,14 @@ func (m *MockImageOs) InstallImageOs(diskPathIdMap map[string]string) (string, e
 	return "", nil
 }
 
+func (m *MockImageOs) InstallLiveOs() (string, error) {
+	return "", nil
+}
+
+func (m *MockImageOs) InstallContainerOs() (string, error) {
+	return "", nil
+}
+
 func TestIsoMaker_BuildIsoImage_Success(t *testing.T) {
 	originalExecutor := shell.Default
 	defer func() { shell.Default = originalExecutor }()
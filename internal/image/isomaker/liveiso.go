@@ -0,0 +1,261 @@
+package isomaker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imageos"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+const (
+	// LiveIsoLabel is the volume ID the live initramfs finds the boot media by
+	LiveIsoLabel = "OIC_LIVE"
+
+	// liveSquashfsPath is where the dracut dmsquash-live module looks for the
+	// root file system on the boot media
+	liveSquashfsPath = "LiveOS/squashfs.img"
+
+	// liveOverlayDir holds the persistent overlayfs layer on the persistence
+	// partition, and liveOverlayWorkDir the overlayfs work directory next to it
+	liveOverlayDir     = "/LiveOS/overlay"
+	liveOverlayWorkDir = "/LiveOS/ovlwork"
+
+	// liveMenuTimeout is the boot menu timeout when the template sets none, so
+	// that the writable layer can be chosen
+	liveMenuTimeout = 5
+)
+
+// BuildLiveIsoImage builds an ISO image that runs the image from the boot
+// media, with the root file system in a squashfs and an overlayfs writable
+// layer in RAM or on a persistence partition
+func (isoMaker *IsoMaker) BuildLiveIsoImage() (err error) {
+	template := isoMaker.template
+	log.Infof("Building live ISO image for: %s", template.GetImageName())
+
+	versionInfo, err := isoMaker.ImageOs.InstallLiveOs()
+	if err != nil {
+		imageos.CopySizeReportOnBudgetError(err, isoMaker.ImageBuildDir)
+		return fmt.Errorf("failed to install live image OS: %w", err)
+	}
+	rootfsPath := isoMaker.ImageOs.GetInstallRoot()
+	defer func() {
+		if cleanErr := cleanIsoInstallRoot(rootfsPath); cleanErr != nil && err == nil {
+			err = fmt.Errorf("failed to clean live image rootfs: %w", cleanErr)
+		}
+	}()
+
+	imageName := fmt.Sprintf("%s-%s", template.GetImageName(), versionInfo)
+	isoFilePath := filepath.Join(isoMaker.ImageBuildDir, fmt.Sprintf("%s.iso", imageName))
+	if err := createLiveIso(template, rootfsPath, isoFilePath); err != nil {
+		return fmt.Errorf("failed to create live ISO image: %w", err)
+	}
+
+	if template.IsSecureBootEnabled() {
+		if err := imagesign.WriteArtifactManifests(template, filepath.Join(isoMaker.ImageBuildDir, imageName), versionInfo); err != nil {
+			return fmt.Errorf("failed to write signed image manifests: %w", err)
+		}
+	}
+
+	if err := manifest.CopySBOMToImageBuildDir(isoMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy SBOM to image build directory: %v", err)
+	}
+
+	if err := imageos.CopySizeReportToImageBuildDir(isoMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy size report to image build directory: %v", err)
+	}
+
+	log.Infof("Live ISO image build completed successfully: %s", isoFilePath)
+	return nil
+}
+
+// createLiveIso lays out the ISO root next to the image rootfs and writes the
+// ISO image: GRUB, the kernel and the live initramfs, and the squashfs
+func createLiveIso(template *config.ImageTemplate, rootfsPath, isoFilePath string) (err error) {
+	isoRoot := rootfsPath + "-iso"
+	log.Infof("Creating live ISO image: %s", isoFilePath)
+
+	isoImagesPath := filepath.Join(isoRoot, "images")
+	dirs := []string{
+		filepath.Join(isoRoot, "boot", "grub"),
+		filepath.Join(isoRoot, "EFI", "BOOT"),
+		isoImagesPath,
+		filepath.Join(isoRoot, filepath.Dir(liveSquashfsPath)),
+	}
+	for _, dir := range dirs {
+		if _, err := shell.ExecCmd("mkdir -p "+dir, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to create directory %s: %v", dir, err)
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	defer func() {
+		if cleanErr := cleanIsoInstallRoot(isoRoot); cleanErr != nil && err == nil {
+			err = fmt.Errorf("failed to clean up live ISO root: %w", cleanErr)
+		}
+	}()
+
+	log.Infof("Copying kernel and live initrd files...")
+	kernelPath, initrdPath, err := imageos.GetLiveBootFiles(rootfsPath)
+	if err != nil {
+		return fmt.Errorf("failed to find live boot files: %w", err)
+	}
+	if err := file.CopyFile(kernelPath, filepath.Join(isoImagesPath, "vmlinuz"), "--preserve=mode", true); err != nil {
+		log.Errorf("Failed to copy kernel to iso image path: %v", err)
+		return fmt.Errorf("failed to copy kernel to iso image path: %w", err)
+	}
+	if err := copyInitrdToIsoImagesPath(initrdPath, isoImagesPath); err != nil {
+		return fmt.Errorf("failed to copy initrd to iso image path: %w", err)
+	}
+
+	if err := imageos.CreateLiveSquashfs(template, rootfsPath, filepath.Join(isoRoot, liveSquashfsPath)); err != nil {
+		return err
+	}
+
+	grubCfg := renderLiveGrubCfg(template)
+	for _, grubCfgPath := range []string{
+		filepath.Join(isoRoot, "boot", "grub", "grub.cfg"),
+		filepath.Join(isoRoot, "EFI", "BOOT", "grub.cfg"),
+	} {
+		if err := file.Write(grubCfg, grubCfgPath); err != nil {
+			log.Errorf("Failed to write live GRUB configuration: %v", err)
+			return fmt.Errorf("failed to write live GRUB configuration: %w", err)
+		}
+	}
+
+	log.Infof("Copying GRUB files to ISO boot path...")
+	if err := copyGrubFilesToGrubPath(rootfsPath, isoRoot); err != nil {
+		return fmt.Errorf("failed to copy GRUB files to ISO boot path: %w", err)
+	}
+
+	log.Infof("Creating EFI FAT image...")
+	efiFatImgPath, err := createEfiFatImage(template, rootfsPath, isoRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create EFI FAT image: %w", err)
+	}
+
+	log.Infof("Creating image for Bios boot...")
+	biosImgRelPath, err := createBiosImage(template, rootfsPath, isoRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create BIOS image: %w", err)
+	}
+
+	persistenceImgPath := ""
+	persistence := template.SystemConfig.Live.Persistence
+	if persistence.Size != "" {
+		persistenceImgPath = rootfsPath + "-persistence.img"
+		if err := createPersistenceImage(persistence, persistenceImgPath); err != nil {
+			return err
+		}
+		defer func() {
+			if _, rmErr := shell.ExecCmd("rm -f "+persistenceImgPath, true, shell.HostPath, nil); rmErr != nil {
+				log.Warnf("Failed to remove persistence partition image %s: %v", persistenceImgPath, rmErr)
+			}
+		}()
+	}
+
+	if err := makeIsoImage(isoRoot, isoFilePath, LiveIsoLabel, efiFatImgPath, biosImgRelPath, persistenceImgPath); err != nil {
+		return err
+	}
+
+	log.Infof("Live ISO creation completed successfully")
+	return nil
+}
+
+// createPersistenceImage writes the ext4 file system of the persistence
+// partition appended to the ISO, with the overlayfs directories the live
+// initramfs expects
+func createPersistenceImage(persistence config.LivePersistence, imgPath string) error {
+	size, err := imagedisc.TranslateSizeStrToBytes(persistence.Size)
+	if err != nil {
+		return fmt.Errorf("invalid persistence partition size %s: %w", persistence.Size, err)
+	}
+	log.Infof("Creating %s persistence partition %s...", persistence.Size, persistence.GetLabel())
+
+	skelDir, err := os.MkdirTemp(config.TempDir(), "live-persistence-")
+	if err != nil {
+		return fmt.Errorf("failed to create persistence partition content: %w", err)
+	}
+	defer os.RemoveAll(skelDir)
+	for _, dir := range []string{liveOverlayDir, liveOverlayWorkDir} {
+		if err := os.MkdirAll(filepath.Join(skelDir, dir), 0755); err != nil {
+			return fmt.Errorf("failed to create persistence partition content: %w", err)
+		}
+	}
+
+	if _, err := shell.ExecCmd(fmt.Sprintf("truncate -s %d %s", size, imgPath), true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create persistence partition image: %v", err)
+		return fmt.Errorf("failed to create persistence partition image: %w", err)
+	}
+	cmdStr := fmt.Sprintf("mkfs -t ext4 -F -L %s -E root_owner=0:0 -d %s %s", persistence.GetLabel(), skelDir, imgPath)
+	if _, err := shell.ExecCmd(cmdStr, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to format persistence partition image: %v", err)
+		return fmt.Errorf("failed to format persistence partition image: %w", err)
+	}
+	return nil
+}
+
+// liveKernelCmdline returns the kernel command line of the live system,
+// with the writable layer in RAM or on the persistence partition
+func liveKernelCmdline(template *config.ImageTemplate, persistent bool) string {
+	args := []string{
+		"root=live:CDLABEL=" + LiveIsoLabel,
+		"rd.live.image",
+		"rd.live.overlay.overlayfs=1",
+	}
+	if persistent {
+		label := template.SystemConfig.Live.Persistence.GetLabel()
+		args = append(args, fmt.Sprintf("rd.live.overlay=LABEL=%s:%s", label, liveOverlayDir))
+	}
+	return imageos.LiveKernelCmdline(template, args...)
+}
+
+// renderLiveGrubCfg returns the GRUB configuration of the live ISO. It boots
+// the persistent writable layer first when persistence is enabled.
+func renderLiveGrubCfg(template *config.ImageTemplate) string {
+	timeout := liveMenuTimeout
+	if bootloader := template.GetBootloaderConfig(); bootloader.Timeout != nil {
+		timeout = *bootloader.Timeout
+	}
+	imageName := template.GetImageName()
+
+	var grubCfg strings.Builder
+	fmt.Fprintf(&grubCfg, `set timeout=%d
+set default=0
+
+insmod all_video
+
+# Search for the boot media - more robust than hardcoding (cd0)
+search --no-floppy --set=root --file /images/vmlinuz
+`, timeout)
+
+	entries := []struct {
+		title      string
+		persistent bool
+	}{
+		{imageName, false},
+		{imageName + " (persistent)", true},
+	}
+	if template.SystemConfig.Live.Persistence.Enabled {
+		entries[0], entries[1] = entries[1], entries[0]
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(&grubCfg, `
+menuentry "%s" {
+  linux /images/vmlinuz %s
+  initrd /images/initrd.img
+}
+`, entry.title, liveKernelCmdline(template, entry.persistent))
+	}
+	grubCfg.WriteString(`menuentry "Boot from local disk" {
+  exit
+}
+`)
+	return grubCfg.String()
+}
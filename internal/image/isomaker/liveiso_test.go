@@ -0,0 +1,125 @@
+package isomaker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+func createLiveTemplate() *config.ImageTemplate {
+	return &config.ImageTemplate{
+		Image:  config.ImageInfo{Name: "live-test"},
+		Target: config.TargetInfo{OS: "ubuntu", Dist: "ubuntu24", Arch: "x86_64", ImageType: "live-iso"},
+		SystemConfig: config.SystemConfig{
+			Name:   "live-system",
+			Kernel: config.KernelConfig{Cmdline: "console=ttyS0"},
+		},
+	}
+}
+
+func TestLiveKernelCmdline(t *testing.T) {
+	template := createLiveTemplate()
+
+	cmdline := liveKernelCmdline(template, false)
+	expected := "root=live:CDLABEL=OIC_LIVE rd.live.image rd.live.overlay.overlayfs=1 console=ttyS0"
+	if cmdline != expected {
+		t.Errorf("expected %q, got %q", expected, cmdline)
+	}
+
+	template.SystemConfig.Live.Persistence.Label = "DEMO"
+	template.SystemConfig.Kernel.Lockdown = "integrity"
+	cmdline = liveKernelCmdline(template, true)
+	if !strings.Contains(cmdline, "rd.live.overlay=LABEL=DEMO:/LiveOS/overlay") {
+		t.Errorf("expected persistence overlay in %q", cmdline)
+	}
+	if !strings.HasSuffix(cmdline, "lockdown=integrity") {
+		t.Errorf("expected lockdown in %q", cmdline)
+	}
+
+	template.SystemConfig.Kernel.Cmdline = "lockdown=confidentiality"
+	cmdline = liveKernelCmdline(template, false)
+	if strings.Count(cmdline, "lockdown=") != 1 {
+		t.Errorf("expected the template lockdown only in %q", cmdline)
+	}
+}
+
+func TestRenderLiveGrubCfg(t *testing.T) {
+	t.Run("RamOverlayFirst", func(t *testing.T) {
+		grubCfg := renderLiveGrubCfg(createLiveTemplate())
+		if !strings.Contains(grubCfg, fmt.Sprintf("set timeout=%d\n", liveMenuTimeout)) {
+			t.Errorf("expected default menu timeout in:\n%s", grubCfg)
+		}
+		ram := strings.Index(grubCfg, `menuentry "live-test" {`)
+		persistent := strings.Index(grubCfg, `menuentry "live-test (persistent)" {`)
+		if ram < 0 || persistent < 0 || ram > persistent {
+			t.Errorf("expected the RAM overlay entry first in:\n%s", grubCfg)
+		}
+		if !strings.Contains(grubCfg, `menuentry "Boot from local disk"`) {
+			t.Errorf("expected local disk entry in:\n%s", grubCfg)
+		}
+	})
+
+	t.Run("PersistentFirst", func(t *testing.T) {
+		template := createLiveTemplate()
+		timeout := 10
+		template.SystemConfig.Bootloader.Timeout = &timeout
+		template.SystemConfig.Live.Persistence.Enabled = true
+		grubCfg := renderLiveGrubCfg(template)
+		if !strings.Contains(grubCfg, "set timeout=10\n") {
+			t.Errorf("expected template menu timeout in:\n%s", grubCfg)
+		}
+		timeout = 0
+		if grubCfg := renderLiveGrubCfg(template); !strings.Contains(grubCfg, "set timeout=0\n") {
+			t.Errorf("expected an explicit timeout of 0 to be kept in:\n%s", grubCfg)
+		}
+		ram := strings.Index(grubCfg, `menuentry "live-test" {`)
+		persistent := strings.Index(grubCfg, `menuentry "live-test (persistent)" {`)
+		if ram < 0 || persistent < 0 || persistent > ram {
+			t.Errorf("expected the persistent entry first in:\n%s", grubCfg)
+		}
+	})
+}
+
+func TestCreatePersistenceImage(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = t.TempDir()
+	config.SetGlobal(newGlobal)
+
+	t.Run("Success", func(t *testing.T) {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: `truncate -s 1073741824 /tmp/persist\.img$`},
+			{Pattern: `mkfs -t ext4 -F -L OIC_PERSIST -E root_owner=0:0 -d \S+ /tmp/persist\.img$`},
+			{Pattern: "truncate|mkfs", Error: fmt.Errorf("unexpected command")},
+		})
+		persistence := config.LivePersistence{Enabled: true, Size: "1GiB"}
+		if err := createPersistenceImage(persistence, "/tmp/persist.img"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("InvalidSize", func(t *testing.T) {
+		persistence := config.LivePersistence{Size: "lots"}
+		if err := createPersistenceImage(persistence, "/tmp/persist.img"); err == nil {
+			t.Error("expected invalid size error")
+		}
+	})
+
+	t.Run("MkfsFailure", func(t *testing.T) {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "truncate"},
+			{Pattern: "mkfs", Error: fmt.Errorf("mkfs failed")},
+		})
+		persistence := config.LivePersistence{Size: "512MiB"}
+		if err := createPersistenceImage(persistence, "/tmp/persist.img"); err == nil {
+			t.Error("expected mkfs error")
+		}
+	})
+}
@@ -0,0 +1,319 @@
+package netbootmaker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-edge-platform/os-image-composer/internal/chroot"
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imageos"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/file"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/system"
+)
+
+const (
+	// Files of the netboot bundle, served from one directory
+	kernelFileName   = "vmlinuz"
+	initrdFileName   = "initrd.img"
+	squashfsFileName = "squashfs.img"
+	ipxeFileName     = "boot.ipxe"
+	grubCfgFileName  = "grub.cfg"
+	grubEfiFileName  = "BOOTX64.EFI"
+	ukiFileName      = "netboot.efi"
+
+	// ukiBuildPath is where the UKI is built in the image rootfs
+	ukiBuildPath = "/boot/" + ukiFileName
+
+	// defaultIpxeBaseURL serves the bundle from the root of the TFTP server
+	// that iPXE was loaded from, as with the QEMU built-in TFTP server
+	defaultIpxeBaseURL = "tftp://${next-server}"
+)
+
+// grubNetModules are built into the GRUB EFI image, which loads grub.cfg
+// over the network from where the firmware loaded it
+var grubNetModules = []string{
+	"efinet", "net", "tftp", "http", "linux", "normal", "configfile", "echo",
+	"regexp", "test", "all_video", "gzio",
+}
+
+type NetbootMakerInterface interface {
+	Init() error              // Initialize with stored template
+	BuildNetbootImage() error // Build netboot bundle using stored template
+}
+
+type NetbootMaker struct {
+	template      *config.ImageTemplate
+	ImageBuildDir string
+	ChrootEnv     chroot.ChrootEnvInterface
+	ImageOs       imageos.ImageOsInterface
+}
+
+var log = logger.Logger()
+
+func NewNetbootMaker(chrootEnv chroot.ChrootEnvInterface, template *config.ImageTemplate) (*NetbootMaker, error) {
+	// nil checking is done one in constructor only to avoid repetitive checks
+	// in every method and schema check is done during template load making
+	// sure internal structure is valid
+	if template == nil {
+		return nil, fmt.Errorf("image template cannot be nil")
+	}
+	if chrootEnv == nil {
+		return nil, fmt.Errorf("chroot environment cannot be nil")
+	}
+
+	imageOs, err := imageos.NewImageOs(chrootEnv, template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image OS: %w", err)
+	}
+
+	return &NetbootMaker{
+		template:  template, // Store template
+		ChrootEnv: chrootEnv,
+		ImageOs:   imageOs, // Already template-aware
+	}, nil
+}
+
+func (netbootMaker *NetbootMaker) Init() error {
+	globalWorkDir, err := config.WorkDir()
+	if err != nil {
+		return fmt.Errorf("failed to get work directory: %w", err)
+	}
+
+	providerId := system.GetProviderId(
+		netbootMaker.template.Target.OS,
+		netbootMaker.template.Target.Dist,
+		netbootMaker.template.Target.Arch,
+	)
+
+	netbootMaker.ImageBuildDir = filepath.Join(
+		globalWorkDir,
+		providerId,
+		"imagebuild",
+		netbootMaker.template.GetSystemConfigName(),
+	)
+
+	return os.MkdirAll(netbootMaker.ImageBuildDir, 0700)
+}
+
+// BuildNetbootImage builds a directory with everything a TFTP or HTTP server
+// needs to boot the image over the network: the kernel, the live initramfs,
+// the squashfs root file system, an iPXE script, GRUB and its configuration,
+// and optionally a UKI for UEFI HTTP Boot
+func (netbootMaker *NetbootMaker) BuildNetbootImage() (err error) {
+	template := netbootMaker.template
+	log.Infof("Building netboot bundle for: %s", template.GetImageName())
+
+	if err := checkNetbootConfig(template); err != nil {
+		return err
+	}
+
+	versionInfo, err := netbootMaker.ImageOs.InstallLiveOs()
+	if err != nil {
+		imageos.CopySizeReportOnBudgetError(err, netbootMaker.ImageBuildDir)
+		return fmt.Errorf("failed to install netboot image OS: %w", err)
+	}
+	rootfsPath := netbootMaker.ImageOs.GetInstallRoot()
+	defer func() {
+		if _, rmErr := shell.ExecCmd("rm -rf "+rootfsPath, true, shell.HostPath, nil); rmErr != nil && err == nil {
+			err = fmt.Errorf("failed to clean netboot image rootfs: %w", rmErr)
+		}
+	}()
+
+	bundleDir := filepath.Join(netbootMaker.ImageBuildDir,
+		fmt.Sprintf("%s-%s-netboot", template.GetImageName(), versionInfo))
+	if err := createNetbootBundle(template, rootfsPath, bundleDir); err != nil {
+		return fmt.Errorf("failed to create netboot bundle: %w", err)
+	}
+
+	if err := manifest.CopySBOMToImageBuildDir(netbootMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy SBOM to image build directory: %v", err)
+	}
+
+	if err := imageos.CopySizeReportToImageBuildDir(netbootMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy size report to image build directory: %v", err)
+	}
+
+	log.Infof("Netboot bundle build completed successfully: %s", bundleDir)
+	return nil
+}
+
+// checkNetbootConfig rejects the netboot settings the bundle cannot be built
+// with, before the image is installed
+func checkNetbootConfig(template *config.ImageTemplate) error {
+	if template.Target.Arch != "x86_64" {
+		return fmt.Errorf("unsupported architecture for netboot bundle: %s", template.Target.Arch)
+	}
+	if template.SystemConfig.Netboot.UKI && template.SystemConfig.Netboot.BaseURL == "" {
+		return fmt.Errorf("netboot UKI needs netboot.baseUrl, its kernel command line is built in")
+	}
+	return nil
+}
+
+// createNetbootBundle writes the netboot bundle into bundleDir, replacing the
+// bundle of an earlier build
+func createNetbootBundle(template *config.ImageTemplate, rootfsPath, bundleDir string) error {
+	log.Infof("Creating netboot bundle: %s", bundleDir)
+	for _, cmdStr := range []string{"rm -rf " + bundleDir, "mkdir -p " + bundleDir} {
+		if _, err := shell.ExecCmd(cmdStr, true, shell.HostPath, nil); err != nil {
+			log.Errorf("Failed to create netboot bundle directory %s: %v", bundleDir, err)
+			return fmt.Errorf("failed to create netboot bundle directory %s: %w", bundleDir, err)
+		}
+	}
+
+	log.Infof("Copying kernel and live initrd files...")
+	kernelPath, initrdPath, err := imageos.GetLiveBootFiles(rootfsPath)
+	if err != nil {
+		return fmt.Errorf("failed to find live boot files: %w", err)
+	}
+	if err := file.CopyFile(kernelPath, filepath.Join(bundleDir, kernelFileName), "--preserve=mode", true); err != nil {
+		log.Errorf("Failed to copy kernel to netboot bundle: %v", err)
+		return fmt.Errorf("failed to copy kernel to netboot bundle: %w", err)
+	}
+	if err := file.CopyFile(initrdPath, filepath.Join(bundleDir, initrdFileName), "--preserve=mode", true); err != nil {
+		log.Errorf("Failed to copy initrd to netboot bundle: %v", err)
+		return fmt.Errorf("failed to copy initrd to netboot bundle: %w", err)
+	}
+
+	if err := imageos.CreateLiveSquashfs(template, rootfsPath, filepath.Join(bundleDir, squashfsFileName)); err != nil {
+		return err
+	}
+
+	bootConfigs := map[string]string{
+		ipxeFileName:    renderIpxeScript(template),
+		grubCfgFileName: renderNetbootGrubCfg(template),
+	}
+	for fileName, content := range bootConfigs {
+		if err := file.Write(content, filepath.Join(bundleDir, fileName)); err != nil {
+			log.Errorf("Failed to write %s: %v", fileName, err)
+			return fmt.Errorf("failed to write %s: %w", fileName, err)
+		}
+	}
+
+	if err := createGrubNetImage(template, rootfsPath, bundleDir); err != nil {
+		return err
+	}
+
+	if template.SystemConfig.Netboot.UKI {
+		if err := createNetbootUKI(template, rootfsPath, bundleDir); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Netboot bundle creation completed successfully")
+	return nil
+}
+
+// createGrubNetImage builds the GRUB EFI image of the bundle from the GRUB
+// modules of the image. Without a prefix GRUB reads grub.cfg from the
+// directory it was loaded from, over TFTP or HTTP.
+func createGrubNetImage(template *config.ImageTemplate, rootfsPath, bundleDir string) error {
+	format := "x86_64-efi"
+	grubLibDir := filepath.Join(rootfsPath, "usr", "lib", "grub", format)
+	if _, err := os.Stat(grubLibDir); os.IsNotExist(err) {
+		log.Errorf("GRUB modules directory does not exist: %s", grubLibDir)
+		return fmt.Errorf("GRUB modules directory does not exist: %s, is the grub-efi-amd64-bin package installed", grubLibDir)
+	}
+
+	grubEfiPath := filepath.Join(bundleDir, grubEfiFileName)
+	grubmkCmd := fmt.Sprintf("grub-mkimage --format=%s --output=%s --directory=%s --prefix=",
+		format, grubEfiPath, grubLibDir)
+	grubmkCmd += " " + strings.Join(grubNetModules, " ")
+	if template.IsSecureBootEnabled() {
+		// GRUB is locked down under Secure Boot and cannot load modules from disk
+		grubmkCmd += " " + strings.Join(imagesign.GrubLockdownModules, " ")
+	}
+	if _, err := shell.ExecCmd(grubmkCmd, true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to create GRUB network image: %v", err)
+		return fmt.Errorf("failed to create GRUB network image: %w", err)
+	}
+
+	kernelPath := filepath.Join(bundleDir, kernelFileName)
+	if err := imagesign.SignIsoBootFiles(template, bundleDir, kernelPath, rootfsPath); err != nil {
+		log.Errorf("Failed to sign netboot EFI files: %v", err)
+		return fmt.Errorf("failed to sign netboot EFI files: %w", err)
+	}
+	return nil
+}
+
+// createNetbootUKI builds the UKI of the bundle, with the kernel command line
+// of the configured base URL built in
+func createNetbootUKI(template *config.ImageTemplate, rootfsPath, bundleDir string) error {
+	log.Infof("Creating netboot UKI...")
+	cmdline := netbootKernelCmdline(template, strings.TrimSuffix(template.SystemConfig.Netboot.BaseURL, "/"))
+	if err := imageos.BuildLiveUKI(rootfsPath, cmdline, ukiBuildPath, template); err != nil {
+		return err
+	}
+
+	ukiPath := filepath.Join(bundleDir, ukiFileName)
+	if err := file.CopyFile(filepath.Join(rootfsPath, ukiBuildPath), ukiPath, "--preserve=mode", true); err != nil {
+		log.Errorf("Failed to copy UKI to netboot bundle: %v", err)
+		return fmt.Errorf("failed to copy UKI to netboot bundle: %w", err)
+	}
+	if err := imagesign.SignUKI(template, ukiPath); err != nil {
+		log.Errorf("Failed to sign netboot UKI: %v", err)
+		return fmt.Errorf("failed to sign netboot UKI: %w", err)
+	}
+	return nil
+}
+
+// netbootKernelCmdline returns the kernel command line that brings up the
+// network in the initramfs and downloads the squashfs from baseURL
+func netbootKernelCmdline(template *config.ImageTemplate, baseURL string) string {
+	return imageos.LiveKernelCmdline(template,
+		fmt.Sprintf("root=live:%s/%s", baseURL, squashfsFileName),
+		"rd.live.image",
+		"rd.live.overlay.overlayfs=1",
+		"ip=dhcp",
+		"rd.neednet=1",
+	)
+}
+
+// renderIpxeScript returns the iPXE script of the bundle. A base-url set
+// before the script is chained, for example by the DHCP server, takes
+// precedence over the configured one.
+func renderIpxeScript(template *config.ImageTemplate) string {
+	baseURL := strings.TrimSuffix(template.SystemConfig.Netboot.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultIpxeBaseURL
+	}
+	return fmt.Sprintf(`#!ipxe
+
+isset ${base-url} || set base-url %s
+kernel ${base-url}/%s initrd=%s %s
+initrd ${base-url}/%s
+boot
+`, baseURL, kernelFileName, initrdFileName,
+		netbootKernelCmdline(template, "${base-url}"), initrdFileName)
+}
+
+// renderNetbootGrubCfg returns the GRUB configuration of the bundle. GRUB
+// loads the kernel and initrd from its prefix, the network location it was
+// loaded from; without a configured base URL the squashfs is downloaded from
+// there too.
+func renderNetbootGrubCfg(template *config.ImageTemplate) string {
+	var grubCfg strings.Builder
+	fmt.Fprintf(&grubCfg, "set timeout=%d\nset default=0\n\n", template.GetBootloaderConfig().GetTimeout())
+
+	if baseURL := strings.TrimSuffix(template.SystemConfig.Netboot.BaseURL, "/"); baseURL != "" {
+		fmt.Fprintf(&grubCfg, "set base_url=\"%s\"\n", baseURL)
+	} else {
+		grubCfg.WriteString(`# $prefix is (tftp,<server>)/<dir> or (http,<server>)/<dir>
+regexp --set=1:net_proto --set=2:net_server --set=3:net_dir '^\(([a-z]+),([^)]+)\)(.*)$' "$prefix"
+set base_url="${net_proto}://${net_server}${net_dir}"
+`)
+	}
+
+	fmt.Fprintf(&grubCfg, `
+menuentry "%s" {
+  linux $prefix/%s %s
+  initrd $prefix/%s
+}
+`, template.GetImageName(), kernelFileName, netbootKernelCmdline(template, "${base_url}"), initrdFileName)
+	return grubCfg.String()
+}
@@ -0,0 +1,228 @@
+package netbootmaker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-edge-platform/os-image-composer/internal/config"
+	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
+)
+
+type mockImageOs struct {
+	installRoot string
+	versionInfo string
+	err         error
+	liveCalled  bool
+}
+
+func (m *mockImageOs) GetInstallRoot() string {
+	return m.installRoot
+}
+
+func (m *mockImageOs) InstallInitrd() (string, string, error) {
+	return m.installRoot, m.versionInfo, m.err
+}
+
+func (m *mockImageOs) InstallImageOs(diskPathIdMap map[string]string) (string, error) {
+	return m.versionInfo, m.err
+}
+
+func (m *mockImageOs) InstallLiveOs() (string, error) {
+	m.liveCalled = true
+	return m.versionInfo, m.err
+}
+
+func (m *mockImageOs) InstallContainerOs() (string, error) {
+	return m.versionInfo, m.err
+}
+
+func writeBootFiles(t *testing.T, installRoot string) {
+	t.Helper()
+	bootDir := filepath.Join(installRoot, "boot")
+	if err := os.MkdirAll(bootDir, 0755); err != nil {
+		t.Fatalf("Failed to create boot directory: %v", err)
+	}
+	for _, name := range []string{"vmlinuz-6.8.0", "initramfs-6.8.0.img"} {
+		if err := os.WriteFile(filepath.Join(bootDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+}
+
+func createNetbootTemplate() *config.ImageTemplate {
+	return &config.ImageTemplate{
+		Image:  config.ImageInfo{Name: "netboot-test"},
+		Target: config.TargetInfo{OS: "ubuntu", Dist: "ubuntu24", Arch: "x86_64", ImageType: "netboot"},
+		SystemConfig: config.SystemConfig{
+			Name:   "netboot-system",
+			Kernel: config.KernelConfig{Cmdline: "console=ttyS0"},
+		},
+	}
+}
+
+func TestCheckNetbootConfig(t *testing.T) {
+	template := createNetbootTemplate()
+	if err := checkNetbootConfig(template); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	template.SystemConfig.Netboot.UKI = true
+	if err := checkNetbootConfig(template); err == nil || !strings.Contains(err.Error(), "baseUrl") {
+		t.Errorf("expected missing base URL error, got %v", err)
+	}
+
+	template.SystemConfig.Netboot.BaseURL = "http://10.0.0.1/netboot"
+	if err := checkNetbootConfig(template); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	template.Target.Arch = "aarch64"
+	if err := checkNetbootConfig(template); err == nil {
+		t.Error("expected unsupported architecture error")
+	}
+}
+
+func TestNetbootKernelCmdline(t *testing.T) {
+	template := createNetbootTemplate()
+	template.SystemConfig.Kernel.Lockdown = "integrity"
+
+	cmdline := netbootKernelCmdline(template, "http://10.0.0.1/netboot")
+	expected := "root=live:http://10.0.0.1/netboot/squashfs.img rd.live.image rd.live.overlay.overlayfs=1 ip=dhcp rd.neednet=1 console=ttyS0 lockdown=integrity"
+	if cmdline != expected {
+		t.Errorf("expected %q, got %q", expected, cmdline)
+	}
+}
+
+func TestRenderIpxeScript(t *testing.T) {
+	template := createNetbootTemplate()
+
+	script := renderIpxeScript(template)
+	if !strings.HasPrefix(script, "#!ipxe\n") {
+		t.Errorf("expected iPXE shebang in:\n%s", script)
+	}
+	for _, want := range []string{
+		"isset ${base-url} || set base-url tftp://${next-server}\n",
+		"kernel ${base-url}/vmlinuz initrd=initrd.img root=live:${base-url}/squashfs.img ",
+		"initrd ${base-url}/initrd.img\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected %q in:\n%s", want, script)
+		}
+	}
+
+	template.SystemConfig.Netboot.BaseURL = "http://10.0.0.1/netboot/"
+	script = renderIpxeScript(template)
+	if !strings.Contains(script, "set base-url http://10.0.0.1/netboot\n") {
+		t.Errorf("expected configured base URL in:\n%s", script)
+	}
+}
+
+func TestRenderNetbootGrubCfg(t *testing.T) {
+	template := createNetbootTemplate()
+	timeout := 3
+	template.SystemConfig.Bootloader.Timeout = &timeout
+
+	grubCfg := renderNetbootGrubCfg(template)
+	for _, want := range []string{
+		"set timeout=3\n",
+		"regexp --set=1:net_proto --set=2:net_server --set=3:net_dir",
+		`menuentry "netboot-test" {`,
+		"linux $prefix/vmlinuz root=live:${base_url}/squashfs.img ",
+		"initrd $prefix/initrd.img\n",
+	} {
+		if !strings.Contains(grubCfg, want) {
+			t.Errorf("expected %q in:\n%s", want, grubCfg)
+		}
+	}
+
+	template.SystemConfig.Netboot.BaseURL = "tftp://10.0.2.2"
+	grubCfg = renderNetbootGrubCfg(template)
+	if !strings.Contains(grubCfg, `set base_url="tftp://10.0.2.2"`) || strings.Contains(grubCfg, "regexp") {
+		t.Errorf("expected configured base URL in:\n%s", grubCfg)
+	}
+}
+
+func TestBuildNetbootImage(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	originalGlobal := config.Global()
+	defer config.SetGlobal(originalGlobal)
+	newGlobal := config.DefaultGlobalConfig()
+	newGlobal.TempDir = t.TempDir()
+	config.SetGlobal(newGlobal)
+
+	tempDir := t.TempDir()
+	installRoot := filepath.Join(tempDir, "rootfs")
+	imageBuildDir := filepath.Join(tempDir, "imagebuild")
+	if err := os.MkdirAll(filepath.Join(installRoot, "usr", "lib", "grub", "x86_64-efi"), 0755); err != nil {
+		t.Fatalf("Failed to create GRUB modules directory: %v", err)
+	}
+	writeBootFiles(t, installRoot)
+
+	t.Run("Success", func(t *testing.T) {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "ls ", Output: "initramfs-6.8.0.img\nvmlinuz-6.8.0\n"},
+			{Pattern: "mkdir"},
+			{Pattern: "rm "},
+			{Pattern: "cp "},
+			{Pattern: `mksquashfs \S+ \S+/netboot-test-1\.0-netboot/squashfs\.img -noappend -comp xz$`},
+			{Pattern: `grub-mkimage --format=x86_64-efi --output=\S+/BOOTX64\.EFI .*--prefix= efinet`},
+			{Pattern: "mksquashfs|grub-mkimage", Error: fmt.Errorf("unexpected command")},
+		})
+		imageOs := &mockImageOs{installRoot: installRoot, versionInfo: "1.0"}
+		netbootMaker := &NetbootMaker{
+			template:      createNetbootTemplate(),
+			ImageBuildDir: imageBuildDir,
+			ImageOs:       imageOs,
+		}
+		if err := netbootMaker.BuildNetbootImage(); err != nil {
+			t.Fatalf("BuildNetbootImage failed: %v", err)
+		}
+		if !imageOs.liveCalled {
+			t.Error("expected the live OS to be installed")
+		}
+	})
+
+	t.Run("UKIWithoutBaseURL", func(t *testing.T) {
+		template := createNetbootTemplate()
+		template.SystemConfig.Netboot.UKI = true
+		imageOs := &mockImageOs{installRoot: installRoot}
+		netbootMaker := &NetbootMaker{template: template, ImageBuildDir: imageBuildDir, ImageOs: imageOs}
+		if err := netbootMaker.BuildNetbootImage(); err == nil {
+			t.Error("expected missing base URL error")
+		}
+		if imageOs.liveCalled {
+			t.Error("expected the configuration to be checked before the install")
+		}
+	})
+
+	t.Run("InstallFailure", func(t *testing.T) {
+		imageOs := &mockImageOs{installRoot: installRoot, err: fmt.Errorf("install failed")}
+		netbootMaker := &NetbootMaker{template: createNetbootTemplate(), ImageBuildDir: imageBuildDir, ImageOs: imageOs}
+		if err := netbootMaker.BuildNetbootImage(); err == nil || !strings.Contains(err.Error(), "install failed") {
+			t.Errorf("expected install error, got %v", err)
+		}
+	})
+
+	t.Run("MissingGrubModules", func(t *testing.T) {
+		shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+			{Pattern: "ls ", Output: "initramfs-6.8.0.img\nvmlinuz-6.8.0\n"},
+			{Pattern: "mkdir"},
+			{Pattern: "rm "},
+			{Pattern: "cp "},
+			{Pattern: "mksquashfs"},
+		})
+		emptyRoot := t.TempDir()
+		writeBootFiles(t, emptyRoot)
+		imageOs := &mockImageOs{installRoot: emptyRoot, versionInfo: "1.0"}
+		netbootMaker := &NetbootMaker{template: createNetbootTemplate(), ImageBuildDir: imageBuildDir, ImageOs: imageOs}
+		err := netbootMaker.BuildNetbootImage()
+		if err == nil || !strings.Contains(err.Error(), "grub-efi-amd64-bin") {
+			t.Errorf("expected missing GRUB modules error, got %v", err)
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/open-edge-platform/os-image-composer/internal/image/imageconvert"
 	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
 	"github.com/open-edge-platform/os-image-composer/internal/image/imageos"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/logger"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/system"
@@ -111,7 +112,82 @@ func (rawMaker *RawMaker) renameImageFile(currentPath, imageName, versionInfo st
 	return newFilePath, nil
 }
 
+// buildContainerArtifacts installs the container root file system and writes
+// the rootfs tarball and container image artifacts from it. The install root
+// is emptied afterwards so a disk image can be installed into it.
+func (rawMaker *RawMaker) buildContainerArtifacts() error {
+	imageName := rawMaker.template.GetImageName()
+	installRoot := rawMaker.ImageOs.GetInstallRoot()
+
+	versionInfo, err := rawMaker.ImageOs.InstallContainerOs()
+	if err != nil {
+		imageos.CopySizeReportOnBudgetError(err, rawMaker.ImageBuildDir)
+		return fmt.Errorf("failed to install container OS: %w", err)
+	}
+
+	imageBasePath := filepath.Join(rawMaker.ImageBuildDir, fmt.Sprintf("%s-%s", imageName, versionInfo))
+	if err := rawMaker.ImageConvert.ConvertRootfs(installRoot, imageBasePath, rawMaker.template); err != nil {
+		return fmt.Errorf("failed to create container artifacts: %w", err)
+	}
+	if err := manifest.CopyContainerSBOMToImageBuildDir(rawMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy container SBOM to image build directory: %v", err)
+	}
+
+	log.Infof("Container artifacts build completed successfully: %s", imageBasePath)
+
+	if _, err := shell.ExecCmd(fmt.Sprintf("rm -rf %s", installRoot), true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to remove container install root %s: %v", installRoot, err)
+		return fmt.Errorf("failed to remove container install root: %w", err)
+	}
+	if _, err := shell.ExecCmd(fmt.Sprintf("mkdir -p %s", installRoot), true, shell.HostPath, nil); err != nil {
+		log.Errorf("Failed to recreate install root %s: %v", installRoot, err)
+		return fmt.Errorf("failed to recreate install root: %w", err)
+	}
+	return nil
+}
+
+// copyBuildReports copies the SBOM, size report and PCR prediction to the
+// image build directory
+func (rawMaker *RawMaker) copyBuildReports() {
+	if err := manifest.CopySBOMToImageBuildDir(rawMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy SBOM to image build directory: %v", err)
+		// Don't fail the build if SBOM copy fails, just log warning
+	}
+
+	if err := imageos.CopySizeReportToImageBuildDir(rawMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy size report to image build directory: %v", err)
+	}
+
+	if err := imageos.CopyPCRPredictionToImageBuildDir(rawMaker.ImageBuildDir); err != nil {
+		log.Warnf("Failed to copy PCR prediction to image build directory: %v", err)
+	}
+}
+
 func (rawMaker *RawMaker) BuildRawImage() error {
+	if err := imageconvert.CheckCloudProfiles(rawMaker.template); err != nil {
+		return fmt.Errorf("cloud profile check failed: %w", err)
+	}
+	if rawMaker.template.SystemConfig.CloudInit.HasSeed() {
+		if _, err := rawMaker.template.GetCloudInitSeed(); err != nil {
+			return fmt.Errorf("invalid cloud-init seed: %w", err)
+		}
+	}
+	// The cidata ISO is written next to the disk artifacts, which a container
+	// only build does not have
+	if rawMaker.template.SystemConfig.CloudInit.HasIsoSeed() && !rawMaker.template.HasDiskArtifacts() {
+		return fmt.Errorf("invalid cloud-init seed: seed iso needs a disk artifact")
+	}
+
+	if rawMaker.template.HasContainerArtifacts() {
+		if err := rawMaker.buildContainerArtifacts(); err != nil {
+			return err
+		}
+		if !rawMaker.template.HasDiskArtifacts() {
+			rawMaker.copyBuildReports()
+			return nil
+		}
+	}
+
 	imageName := rawMaker.template.GetImageName()
 	imageFile := filepath.Join(rawMaker.ImageBuildDir, imageName+".raw")
 
@@ -142,11 +218,32 @@ func (rawMaker *RawMaker) BuildRawImage() error {
 		// Loop device will be cleaned up by defer
 		// Image file cleanup handled separately if needed
 		rawMaker.cleanupImageFileOnError(imageFile)
+		imageos.CopySizeReportOnBudgetError(err, rawMaker.ImageBuildDir)
 		return fmt.Errorf("failed to install OS: %w", err)
 	}
 
 	log.Infof("OS installation completed with version: %s", versionInfo)
 
+	// Shrink an auto-sized image to its content
+	diskInfo := rawMaker.template.GetDiskConfig()
+	if imagedisc.IsDiskSizeAuto(diskInfo) {
+		diskSize, err := imagedisc.ShrinkDiskToFit(loopDevPath, diskPathIdMap, diskInfo)
+		if err != nil {
+			rawMaker.cleanupImageFileOnError(imageFile)
+			return fmt.Errorf("failed to shrink image: %w", err)
+		}
+		// The loop device is released before the file is truncated
+		if err := rawMaker.LoopDev.LoopSetupDelete(loopDevPath); err != nil {
+			rawMaker.cleanupImageFileOnError(imageFile)
+			return fmt.Errorf("failed to detach loop device: %w", err)
+		}
+		loopDevPath = ""
+		if err := imagedisc.TruncateRawFile(imageFile, diskSize, diskInfo.PartitionTableType); err != nil {
+			rawMaker.cleanupImageFileOnError(imageFile)
+			return fmt.Errorf("failed to shrink image file: %w", err)
+		}
+	}
+
 	// File renaming
 	finalImagePath, err := rawMaker.renameImageFile(imageFile, imageName, versionInfo)
 	if err != nil {
@@ -162,11 +259,21 @@ func (rawMaker *RawMaker) BuildRawImage() error {
 		return fmt.Errorf("failed to convert image file: %w", err)
 	}
 
-	// Copy SBOM to image build directory
-	if err := manifest.CopySBOMToImageBuildDir(rawMaker.ImageBuildDir); err != nil {
-		log.Warnf("Failed to copy SBOM to image build directory: %v", err)
-		// Don't fail the build if SBOM copy fails, just log warning
+	if rawMaker.template.SystemConfig.CloudInit.HasIsoSeed() {
+		seedIsoPath := filepath.Join(rawMaker.ImageBuildDir, fmt.Sprintf("%s-%s-cidata.iso", imageName, versionInfo))
+		if err := imageos.CreateCloudInitSeedIso(rawMaker.template, seedIsoPath); err != nil {
+			return fmt.Errorf("failed to create cloud-init seed ISO: %w", err)
+		}
 	}
 
+	if rawMaker.template.IsSecureBootEnabled() {
+		basePath := filepath.Join(rawMaker.ImageBuildDir, fmt.Sprintf("%s-%s", imageName, versionInfo))
+		if err := imagesign.WriteArtifactManifests(rawMaker.template, basePath, versionInfo); err != nil {
+			return fmt.Errorf("failed to write signed image manifests: %w", err)
+		}
+	}
+
+	rawMaker.copyBuildReports()
+
 	return nil
 }
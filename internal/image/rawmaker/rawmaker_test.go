@@ -1,6 +1,10 @@
 package rawmaker_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,6 +16,7 @@ import (
 	"github.com/open-edge-platform/os-image-composer/internal/image/imageconvert"
 	"github.com/open-edge-platform/os-image-composer/internal/image/imagedisc"
 	"github.com/open-edge-platform/os-image-composer/internal/image/imageos"
+	"github.com/open-edge-platform/os-image-composer/internal/image/imagesign"
 	"github.com/open-edge-platform/os-image-composer/internal/image/rawmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/utils/shell"
 )
@@ -143,6 +148,7 @@ type mockImageOs struct {
 	installRoot       string
 	shouldFailInstall bool
 	versionInfo       string
+	containerCalled   bool
 }
 
 func (m *mockImageOs) GetInstallRoot() string {
@@ -163,8 +169,25 @@ func (m *mockImageOs) InstallImageOs(diskPathIdMap map[string]string) (versionIn
 	return m.versionInfo, nil
 }
 
+func (m *mockImageOs) InstallLiveOs() (string, error) {
+	if m.shouldFailInstall {
+		return "", fmt.Errorf("mock install image OS failure")
+	}
+	return m.versionInfo, nil
+}
+
+func (m *mockImageOs) InstallContainerOs() (string, error) {
+	m.containerCalled = true
+	if m.shouldFailInstall {
+		return "", fmt.Errorf("mock install container OS failure")
+	}
+	return m.versionInfo, nil
+}
+
 type mockImageConvert struct {
 	shouldFailConvert bool
+	rootfsPath        string
+	imageBasePath     string
 }
 
 func (m *mockImageConvert) ConvertImageFile(filePath string, template *config.ImageTemplate) error {
@@ -174,6 +197,15 @@ func (m *mockImageConvert) ConvertImageFile(filePath string, template *config.Im
 	return nil
 }
 
+func (m *mockImageConvert) ConvertRootfs(rootfsPath, imageBasePath string, template *config.ImageTemplate) error {
+	if m.shouldFailConvert {
+		return fmt.Errorf("mock rootfs conversion failure")
+	}
+	m.rootfsPath = rootfsPath
+	m.imageBasePath = imageBasePath
+	return nil
+}
+
 func TestNewRawMaker(t *testing.T) {
 	originalExecutor := shell.Default
 	defer func() { shell.Default = originalExecutor }()
@@ -448,6 +480,78 @@ func TestRawMaker_BuildRawImage_Success(t *testing.T) {
 	}
 }
 
+func TestRawMaker_BuildRawImage_SignedManifest(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "mkdir", Output: "", Error: nil},
+		{Pattern: "mv", Output: "", Error: nil},
+	})
+
+	tempDir := t.TempDir()
+	chrootEnv := &mockChrootEnv{
+		pkgType:           "deb",
+		chrootEnvRoot:     tempDir,
+		chrootPkgCacheDir: filepath.Join(tempDir, "cache"),
+	}
+	if err := os.MkdirAll(chrootEnv.GetChrootImageBuildDir(), 0700); err != nil {
+		t.Fatalf("Failed to create chroot image build dir: %v", err)
+	}
+	os.Setenv("IMAGE_COMPOSER_WORK_DIR", tempDir)
+	defer os.Unsetenv("IMAGE_COMPOSER_WORK_DIR")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyPath := filepath.Join(tempDir, "DB.key")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	template := &config.ImageTemplate{
+		Target: config.TargetInfo{OS: "ubuntu", Dist: "jammy", Arch: "x86_64"},
+		Image:  config.ImageInfo{Name: "test-image"},
+		SystemConfig: config.SystemConfig{
+			Name: "test-config",
+			Immutability: config.ImmutabilityConfig{
+				SecureBoot:      true,
+				SecureBootDBKey: keyPath,
+			},
+		},
+	}
+
+	rawMaker, err := rawmaker.NewRawMaker(chrootEnv, template)
+	if err != nil {
+		t.Fatalf("Failed to create RawMaker: %v", err)
+	}
+	if err := rawMaker.Init(); err != nil {
+		t.Fatalf("Failed to init RawMaker: %v", err)
+	}
+	rawMaker.LoopDev = &mockLoopDev{loopDevPath: "/dev/loop0"}
+	rawMaker.ImageOs = &mockImageOs{installRoot: tempDir, versionInfo: "1.0.0"}
+	rawMaker.ImageConvert = &mockImageConvert{}
+
+	// The image is moved into place by the mocked mv
+	imagePath := filepath.Join(rawMaker.ImageBuildDir, "test-image-1.0.0.raw")
+	if err := os.WriteFile(imagePath, []byte("raw image"), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	if err := rawMaker.BuildRawImage(); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	data, err := os.ReadFile(imagePath + imagesign.ManifestSuffix)
+	if err != nil {
+		t.Fatalf("Expected a signed manifest next to the image: %v", err)
+	}
+	if !strings.Contains(string(data), `"sig_alg": "sha256"`) || strings.Contains(string(data), `"signature": ""`) {
+		t.Errorf("Expected the manifest to be signed, got %s", data)
+	}
+}
+
 func TestRawMaker_BuildRawImage_LoopDevCreationFailure(t *testing.T) {
 	originalExecutor := shell.Default
 	defer func() { shell.Default = originalExecutor }()
@@ -816,6 +920,283 @@ func TestRawMaker_BuildRawImage_LoopDevDeleteFailure(t *testing.T) {
 	}
 }
 
+func TestRawMaker_BuildRawImage_AutoSize(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	partitionTable := `{"partitiontable": {"label": "gpt", "sectorsize": 512, "partitions": [
+		{"node": "/dev/loop0p1", "start": 2048, "size": 1048576},
+		{"node": "/dev/loop0p2", "start": 1050624, "size": 67108864}]}}`
+	mockCommands := []shell.MockCommand{
+		{Pattern: "mkdir", Output: "", Error: nil},
+		{Pattern: "mv", Output: "", Error: nil},
+		{Pattern: "e2fsck", Output: "", Error: nil},
+		{Pattern: "tune2fs -l", Output: "Block size:               4096\n", Error: nil},
+		{Pattern: "resize2fs -P", Output: "Estimated minimum size of the filesystem: 262144\n", Error: nil},
+		{Pattern: "sfdisk --json", Output: partitionTable, Error: nil},
+		{Pattern: "resize2fs", Output: "", Error: nil},
+		{Pattern: "sfdisk", Output: "", Error: nil},
+		{Pattern: "truncate", Output: "", Error: nil},
+	}
+	shell.Default = shell.NewMockExecutor(mockCommands)
+
+	tempDir := t.TempDir()
+	chrootEnv := &mockChrootEnv{
+		pkgType:           "deb",
+		chrootEnvRoot:     tempDir,
+		chrootPkgCacheDir: filepath.Join(tempDir, "cache"),
+	}
+
+	if err := os.MkdirAll(chrootEnv.GetChrootImageBuildDir(), 0700); err != nil {
+		t.Fatalf("Failed to create chroot image build dir: %v", err)
+	}
+
+	os.Setenv("IMAGE_COMPOSER_WORK_DIR", tempDir)
+	defer os.Unsetenv("IMAGE_COMPOSER_WORK_DIR")
+
+	template := &config.ImageTemplate{
+		Target: config.TargetInfo{
+			OS:   "ubuntu",
+			Dist: "jammy",
+			Arch: "x86_64",
+		},
+		Image: config.ImageInfo{
+			Name: "test-image",
+		},
+		Disk: config.DiskConfig{
+			Name:               "auto",
+			Size:               imagedisc.SizeAuto,
+			PartitionTableType: "gpt",
+			Partitions: []config.PartitionInfo{
+				{ID: "boot", FsType: "vfat", Start: "1MiB", End: "513MiB"},
+				{ID: "root", FsType: "ext4", Start: "513MiB", Size: imagedisc.SizeAuto},
+			},
+		},
+		SystemConfig: config.SystemConfig{
+			Name: "test-config",
+		},
+	}
+
+	rawMaker, err := rawmaker.NewRawMaker(chrootEnv, template)
+	if err != nil {
+		t.Fatalf("Failed to create RawMaker: %v", err)
+	}
+	mockLoopDev := &mockLoopDev{
+		loopDevPath: "/dev/loop0",
+	}
+	rawMaker.LoopDev = mockLoopDev
+	rawMaker.ImageOs = &mockImageOs{
+		installRoot: tempDir,
+		versionInfo: "1.0.0",
+	}
+	rawMaker.ImageConvert = &mockImageConvert{}
+
+	if err := rawMaker.Init(); err != nil {
+		t.Fatalf("Failed to initialize RawMaker: %v", err)
+	}
+	if err := rawMaker.BuildRawImage(); err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+
+	// The loop device must be released before the image file is truncated
+	mockLoopDev.shouldFailDelete = true
+	err = rawMaker.BuildRawImage()
+	if err == nil || !strings.Contains(err.Error(), "failed to detach loop device") {
+		t.Errorf("Expected a loop device detach error, but got: %v", err)
+	}
+}
+
+func TestRawMaker_BuildRawImage_ContainerArtifacts(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "mkdir", Output: "", Error: nil},
+		{Pattern: "rm -rf", Output: "", Error: nil},
+		{Pattern: "mv", Output: "", Error: nil},
+	})
+
+	tempDir := t.TempDir()
+	chrootEnv := &mockChrootEnv{
+		pkgType:           "deb",
+		chrootEnvRoot:     tempDir,
+		chrootPkgCacheDir: filepath.Join(tempDir, "cache"),
+	}
+	if err := os.MkdirAll(chrootEnv.GetChrootImageBuildDir(), 0700); err != nil {
+		t.Fatalf("Failed to create chroot image build dir: %v", err)
+	}
+
+	os.Setenv("IMAGE_COMPOSER_WORK_DIR", tempDir)
+	defer os.Unsetenv("IMAGE_COMPOSER_WORK_DIR")
+
+	tests := []struct {
+		name              string
+		artifacts         []config.ArtifactInfo
+		shouldFailInstall bool
+		expectDiskBuild   bool
+		expectError       string
+	}{
+		{
+			name:      "ContainerOnly",
+			artifacts: []config.ArtifactInfo{{Type: "tar"}, {Type: "oci", Compression: "gz"}},
+		},
+		{
+			name:            "ContainerAndDisk",
+			artifacts:       []config.ArtifactInfo{{Type: "docker"}, {Type: "raw"}},
+			expectDiskBuild: true,
+		},
+		{
+			name:              "InstallFailure",
+			artifacts:         []config.ArtifactInfo{{Type: "oci"}},
+			shouldFailInstall: true,
+			expectError:       "failed to install container OS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &config.ImageTemplate{
+				Target: config.TargetInfo{OS: "ubuntu", Dist: "jammy", Arch: "x86_64"},
+				Image:  config.ImageInfo{Name: "test-image"},
+				SystemConfig: config.SystemConfig{
+					Name: "test-config",
+				},
+				Disk: config.DiskConfig{Artifacts: tt.artifacts},
+			}
+
+			rawMaker, err := rawmaker.NewRawMaker(chrootEnv, template)
+			if err != nil {
+				t.Fatalf("Failed to create RawMaker: %v", err)
+			}
+			installRoot := filepath.Join(tempDir, "rootfs")
+			mockImageOs := &mockImageOs{
+				installRoot:       installRoot,
+				versionInfo:       "1.0.0",
+				shouldFailInstall: tt.shouldFailInstall,
+			}
+			mockImageConvert := &mockImageConvert{}
+			// The disk build fails at the loop device so it shows when it runs
+			rawMaker.LoopDev = &mockLoopDev{shouldFailCreate: true}
+			rawMaker.ImageOs = mockImageOs
+			rawMaker.ImageConvert = mockImageConvert
+			if err := rawMaker.Init(); err != nil {
+				t.Fatalf("Failed to init RawMaker: %v", err)
+			}
+
+			err = rawMaker.BuildRawImage()
+			if !mockImageOs.containerCalled {
+				t.Error("Expected the container OS to be installed")
+			}
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if tt.expectDiskBuild {
+				if err == nil || !strings.Contains(err.Error(), "failed to create loop device") {
+					t.Errorf("Expected the disk image build to follow, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error, but got: %v", err)
+			}
+
+			if mockImageConvert.rootfsPath != installRoot {
+				t.Errorf("Expected rootfs path %s, got %s", installRoot, mockImageConvert.rootfsPath)
+			}
+			expectedBase := filepath.Join(rawMaker.ImageBuildDir, "test-image-1.0.0")
+			if mockImageConvert.imageBasePath != expectedBase {
+				t.Errorf("Expected image base path %s, got %s", expectedBase, mockImageConvert.imageBasePath)
+			}
+		})
+	}
+}
+
+func TestRawMaker_BuildRawImage_CloudProfileCheck(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "mkdir", Output: "", Error: nil},
+	})
+
+	tempDir := t.TempDir()
+	chrootEnv := &mockChrootEnv{
+		pkgType:           "deb",
+		chrootEnvRoot:     tempDir,
+		chrootPkgCacheDir: filepath.Join(tempDir, "cache"),
+	}
+	if err := os.MkdirAll(chrootEnv.GetChrootImageBuildDir(), 0700); err != nil {
+		t.Fatalf("Failed to create chroot image build dir: %v", err)
+	}
+
+	template := &config.ImageTemplate{
+		Target: config.TargetInfo{OS: "wind-river-elxr", Dist: "elxr12", Arch: "x86_64"},
+		Image:  config.ImageInfo{Name: "test-image"},
+		SystemConfig: config.SystemConfig{
+			Name:   "test-config",
+			Kernel: config.KernelConfig{Cmdline: "console=tty0"},
+		},
+		Disk: config.DiskConfig{Artifacts: []config.ArtifactInfo{{Type: "vhd", Profile: "azure"}}},
+	}
+
+	rawMaker, err := rawmaker.NewRawMaker(chrootEnv, template)
+	if err != nil {
+		t.Fatalf("Failed to create RawMaker: %v", err)
+	}
+	mockImageOs := &mockImageOs{installRoot: tempDir, versionInfo: "1.0.0"}
+	rawMaker.LoopDev = &mockLoopDev{shouldFailCreate: true}
+	rawMaker.ImageOs = mockImageOs
+	rawMaker.ImageConvert = &mockImageConvert{}
+
+	err = rawMaker.BuildRawImage()
+	if err == nil || !strings.Contains(err.Error(), "cloud profile check failed") {
+		t.Errorf("Expected cloud profile check error, got %v", err)
+	}
+}
+
+func TestRawMaker_BuildRawImage_IsoSeedWithoutDiskArtifact(t *testing.T) {
+	originalExecutor := shell.Default
+	defer func() { shell.Default = originalExecutor }()
+
+	shell.Default = shell.NewMockExecutor([]shell.MockCommand{
+		{Pattern: "mkdir", Output: "", Error: nil},
+	})
+
+	tempDir := t.TempDir()
+	chrootEnv := &mockChrootEnv{
+		pkgType:           "deb",
+		chrootEnvRoot:     tempDir,
+		chrootPkgCacheDir: filepath.Join(tempDir, "cache"),
+	}
+	if err := os.MkdirAll(chrootEnv.GetChrootImageBuildDir(), 0700); err != nil {
+		t.Fatalf("Failed to create chroot image build dir: %v", err)
+	}
+
+	template := &config.ImageTemplate{
+		Target: config.TargetInfo{OS: "wind-river-elxr", Dist: "elxr12", Arch: "x86_64"},
+		Image:  config.ImageInfo{Name: "test-image"},
+		SystemConfig: config.SystemConfig{
+			Name:      "test-config",
+			CloudInit: config.CloudInitConfig{UserData: "#cloud-config\n", Seed: "iso"},
+		},
+		Disk: config.DiskConfig{Artifacts: []config.ArtifactInfo{{Type: "oci"}}},
+	}
+
+	rawMaker, err := rawmaker.NewRawMaker(chrootEnv, template)
+	if err != nil {
+		t.Fatalf("Failed to create RawMaker: %v", err)
+	}
+	rawMaker.LoopDev = &mockLoopDev{shouldFailCreate: true}
+	rawMaker.ImageOs = &mockImageOs{installRoot: tempDir, versionInfo: "1.0.0"}
+	rawMaker.ImageConvert = &mockImageConvert{}
+
+	err = rawMaker.BuildRawImage()
+	if err == nil || !strings.Contains(err.Error(), "seed iso needs a disk artifact") {
+		t.Errorf("Expected iso seed error, got %v", err)
+	}
+}
+
 func TestRawMaker_CleanupOnSuccess(t *testing.T) {
 	originalExecutor := shell.Default
 	defer func() { shell.Default = originalExecutor }()
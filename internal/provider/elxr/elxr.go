@@ -9,6 +9,7 @@ import (
 	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
 	"github.com/open-edge-platform/os-image-composer/internal/image/initrdmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/image/isomaker"
+	"github.com/open-edge-platform/os-image-composer/internal/image/netbootmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/image/rawmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/ospackage/debutils"
 	"github.com/open-edge-platform/os-image-composer/internal/provider"
@@ -105,6 +106,10 @@ func (p *eLxr) BuildImage(template *config.ImageTemplate) error {
 		return p.buildInitrdImage(template)
 	case "iso":
 		return p.buildIsoImage(template)
+	case "live-iso":
+		return p.buildLiveIsoImage(template)
+	case "netboot":
+		return p.buildNetbootImage(template)
 	default:
 		return fmt.Errorf("unsupported image type: %s", template.Target.ImageType)
 	}
@@ -191,6 +196,66 @@ func (p *eLxr) buildIsoImage(template *config.ImageTemplate) error {
 	return nil
 }
 
+func (p *eLxr) buildLiveIsoImage(template *config.ImageTemplate) error {
+	// Create IsoMaker with template (dependency injection)
+	isoMaker, err := isomaker.NewIsoMaker(p.chrootEnv, template)
+	if err != nil {
+		return fmt.Errorf("failed to create iso maker: %w", err)
+	}
+
+	// Use the maker
+	if err := isoMaker.Init(); err != nil {
+		return fmt.Errorf("failed to initialize iso maker: %w", err)
+	}
+
+	if err := isoMaker.BuildLiveIsoImage(); err != nil {
+		return err
+	}
+
+	// Display summary after build completes
+	// Construct the actual image build directory path (on host, not in chroot)
+	globalWorkDir, err := config.WorkDir()
+	if err != nil {
+		return fmt.Errorf("failed to get work directory: %w", err)
+	}
+	providerId := system.GetProviderId(template.Target.OS, template.Target.Dist, template.Target.Arch)
+	imageBuildDir := filepath.Join(globalWorkDir, providerId, "imagebuild", template.GetSystemConfigName())
+
+	displayImageArtifacts(imageBuildDir, "LIVE ISO")
+
+	return nil
+}
+
+func (p *eLxr) buildNetbootImage(template *config.ImageTemplate) error {
+	// Create NetbootMaker with template (dependency injection)
+	netbootMaker, err := netbootmaker.NewNetbootMaker(p.chrootEnv, template)
+	if err != nil {
+		return fmt.Errorf("failed to create netboot maker: %w", err)
+	}
+
+	// Use the maker
+	if err := netbootMaker.Init(); err != nil {
+		return fmt.Errorf("failed to initialize netboot maker: %w", err)
+	}
+
+	if err := netbootMaker.BuildNetbootImage(); err != nil {
+		return err
+	}
+
+	// Display summary after build completes
+	// Construct the actual image build directory path (on host, not in chroot)
+	globalWorkDir, err := config.WorkDir()
+	if err != nil {
+		return fmt.Errorf("failed to get work directory: %w", err)
+	}
+	providerId := system.GetProviderId(template.Target.OS, template.Target.Dist, template.Target.Arch)
+	imageBuildDir := filepath.Join(globalWorkDir, providerId, "imagebuild", template.GetSystemConfigName())
+
+	displayImageArtifacts(imageBuildDir, "NETBOOT")
+
+	return nil
+}
+
 func (p *eLxr) PostProcess(template *config.ImageTemplate, err error) error {
 	if err := p.chrootEnv.CleanupChrootEnv(template.Target.OS,
 		template.Target.Dist, template.Target.Arch); err != nil {
@@ -201,15 +266,16 @@ func (p *eLxr) PostProcess(template *config.ImageTemplate, err error) error {
 
 func (p *eLxr) installHostDependency() error {
 	var dependencyInfo = map[string]string{
-		"mmdebstrap":   "mmdebstrap",    // For the chroot env build
-		"mkfs.fat":     "dosfstools",    // For the FAT32 boot partition creation
-		"mformat":      "mtools",        // For writing files to FAT32 partition
-		"xorriso":      "xorriso",       // For ISO image creation
-		"qemu-img":     "qemu-utils",    // For image file format conversion
-		"ukify":        "systemd-ukify", // For the UKI image creation
-		"grub-mkimage": "grub-common",   // For ISO image UEFI Grub binary creation
-		"veritysetup":  "cryptsetup",    // For the veritysetup command
-		"sbsign":       "sbsigntool",    // For the UKI image creation
+		"mmdebstrap":   "mmdebstrap",     // For the chroot env build
+		"mkfs.fat":     "dosfstools",     // For the FAT32 boot partition creation
+		"mformat":      "mtools",         // For writing files to FAT32 partition
+		"xorriso":      "xorriso",        // For ISO image creation
+		"mksquashfs":   "squashfs-tools", // For the live ISO root file system
+		"qemu-img":     "qemu-utils",     // For image file format conversion
+		"ukify":        "systemd-ukify",  // For the UKI image creation
+		"grub-mkimage": "grub-common",    // For ISO image UEFI Grub binary creation
+		"veritysetup":  "cryptsetup",     // For the veritysetup command
+		"sbsign":       "sbsigntool",     // For the UKI image creation
 	}
 	hostPkgManager, err := system.GetHostOsPkgManager()
 	if err != nil {
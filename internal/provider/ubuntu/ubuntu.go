@@ -9,6 +9,7 @@ import (
 	"github.com/open-edge-platform/os-image-composer/internal/config/manifest"
 	"github.com/open-edge-platform/os-image-composer/internal/image/initrdmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/image/isomaker"
+	"github.com/open-edge-platform/os-image-composer/internal/image/netbootmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/image/rawmaker"
 	"github.com/open-edge-platform/os-image-composer/internal/ospackage/debutils"
 	"github.com/open-edge-platform/os-image-composer/internal/provider"
@@ -102,6 +103,10 @@ func (p *ubuntu) BuildImage(template *config.ImageTemplate) error {
 		return p.buildInitrdImage(template)
 	case "iso":
 		return p.buildIsoImage(template)
+	case "live-iso":
+		return p.buildLiveIsoImage(template)
+	case "netboot":
+		return p.buildNetbootImage(template)
 	default:
 		return fmt.Errorf("unsupported image type: %s", template.Target.ImageType)
 	}
@@ -158,6 +163,36 @@ func (p *ubuntu) buildIsoImage(template *config.ImageTemplate) error {
 	return isoMaker.BuildIsoImage()
 }
 
+func (p *ubuntu) buildLiveIsoImage(template *config.ImageTemplate) error {
+	// Create IsoMaker with template (dependency injection)
+	isoMaker, err := isomaker.NewIsoMaker(p.chrootEnv, template)
+	if err != nil {
+		return fmt.Errorf("failed to create iso maker: %w", err)
+	}
+
+	// Use the maker
+	if err := isoMaker.Init(); err != nil {
+		return fmt.Errorf("failed to initialize iso maker: %w", err)
+	}
+
+	return isoMaker.BuildLiveIsoImage()
+}
+
+func (p *ubuntu) buildNetbootImage(template *config.ImageTemplate) error {
+	// Create NetbootMaker with template (dependency injection)
+	netbootMaker, err := netbootmaker.NewNetbootMaker(p.chrootEnv, template)
+	if err != nil {
+		return fmt.Errorf("failed to create netboot maker: %w", err)
+	}
+
+	// Use the maker
+	if err := netbootMaker.Init(); err != nil {
+		return fmt.Errorf("failed to initialize netboot maker: %w", err)
+	}
+
+	return netbootMaker.BuildNetbootImage()
+}
+
 func (p *ubuntu) PostProcess(template *config.ImageTemplate, err error) error {
 	if err := p.chrootEnv.CleanupChrootEnv(template.Target.OS,
 		template.Target.Dist, template.Target.Arch); err != nil {
@@ -172,6 +207,7 @@ func (p *ubuntu) installHostDependency() error {
 		"mkfs.fat":         "dosfstools",       // For the FAT32 boot partition creation
 		"mformat":          "mtools",           // For writing files to FAT32 partition
 		"xorriso":          "xorriso",          // For ISO image creation
+		"mksquashfs":       "squashfs-tools",   // For the live ISO root file system
 		"qemu-img":         "qemu-utils",       // For image file format conversion
 		"ukify":            "systemd-ukify",    // For the UKI image creation
 		"grub-mkimage":     "grub-common",      // For ISO image UEFI Grub binary creation
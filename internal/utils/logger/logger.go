@@ -7,14 +7,16 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 type Config struct {
-	Level    string
-	FilePath string
+	Level     string
+	FilePath  string
+	FileLevel string // FileLevel: level of the file output, defaults to Level
 }
 
 type nopSyncer struct {
@@ -35,6 +37,40 @@ func (n *nopSyncer) Sync() error {
 	return nil // no-op
 }
 
+// swapCore forwards to the core of the current configuration, so loggers taken
+// before a reconfiguration, such as package-level loggers, write to its outputs
+type swapCore struct {
+	fields []zapcore.Field
+}
+
+func (c *swapCore) core() zapcore.Core {
+	core := *activeCore.Load()
+	if len(c.fields) > 0 {
+		return core.With(c.fields)
+	}
+	return core
+}
+
+func (c *swapCore) Enabled(level zapcore.Level) bool {
+	return c.core().Enabled(level)
+}
+
+func (c *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	return &swapCore{fields: append(append([]zapcore.Field{}, c.fields...), fields...)}
+}
+
+func (c *swapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.core().Check(entry, checked)
+}
+
+func (c *swapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.core().Write(entry, fields)
+}
+
+func (c *swapCore) Sync() error {
+	return c.core().Sync()
+}
+
 type StatusWriter struct {
 	Status chan string
 }
@@ -53,6 +89,7 @@ var (
 	logFile       *os.File
 	currentConfig Config
 	stderrSyncer  = &nopSyncer{writer: os.Stderr}
+	activeCore    atomic.Pointer[zapcore.Core]
 )
 
 func initLogger() {
@@ -91,7 +128,11 @@ func applyConfig(cfg Config) error {
 
 	filePath := strings.TrimSpace(cfg.FilePath)
 	if filePath != "" {
-		fileCore, handle, err := buildFileCore(encoderCfg, filePath)
+		var fileLevel zapcore.LevelEnabler = atomicLevel
+		if cfg.FileLevel != "" {
+			fileLevel = parseLevel(cfg.FileLevel)
+		}
+		fileCore, handle, err := buildFileCore(encoderCfg, filePath, fileLevel)
 		if err != nil {
 			return err
 		}
@@ -107,6 +148,7 @@ func applyConfig(cfg Config) error {
 	}
 
 	core := zapcore.NewTee(cores...)
+	activeCore.Store(&core)
 
 	options := []zap.Option{
 		zap.AddCaller(),
@@ -114,7 +156,7 @@ func applyConfig(cfg Config) error {
 		zap.AddStacktrace(zapcore.ErrorLevel),
 	}
 
-	newLogger := zap.New(core, options...)
+	newLogger := zap.New(&swapCore{}, options...)
 	sugar := newLogger.Sugar()
 
 	baseLogger = newLogger
@@ -122,12 +164,12 @@ func applyConfig(cfg Config) error {
 
 	zap.ReplaceGlobals(baseLogger)
 
-	currentConfig = Config{Level: level.String(), FilePath: filePath}
+	currentConfig = Config{Level: level.String(), FilePath: filePath, FileLevel: cfg.FileLevel}
 
 	return nil
 }
 
-func buildFileCore(encoderCfg zapcore.EncoderConfig, path string) (zapcore.Core, *os.File, error) {
+func buildFileCore(encoderCfg zapcore.EncoderConfig, path string, level zapcore.LevelEnabler) (zapcore.Core, *os.File, error) {
 	cleanedPath := filepath.Clean(path)
 	dir := filepath.Dir(cleanedPath)
 	if dir != "." && dir != "" {
@@ -145,7 +187,7 @@ func buildFileCore(encoderCfg zapcore.EncoderConfig, path string) (zapcore.Core,
 	fileEncoderCfg.EncodeLevel = zapcore.CapitalLevelEncoder
 
 	fileEncoder := zapcore.NewConsoleEncoder(fileEncoderCfg)
-	core := zapcore.NewCore(fileEncoder, zapcore.AddSync(file), atomicLevel)
+	core := zapcore.NewCore(fileEncoder, zapcore.AddSync(file), level)
 
 	return core, file, nil
 }
@@ -153,7 +195,7 @@ func buildFileCore(encoderCfg zapcore.EncoderConfig, path string) (zapcore.Core,
 func InitWithConfig(cfg Config) (*zap.SugaredLogger, func(), error) {
 	initializedHere := false
 	var initErr error
-	requested := Config{Level: parseLevel(cfg.Level).String(), FilePath: strings.TrimSpace(cfg.FilePath)}
+	requested := Config{Level: parseLevel(cfg.Level).String(), FilePath: strings.TrimSpace(cfg.FilePath), FileLevel: cfg.FileLevel}
 
 	once.Do(func() {
 		initErr = applyConfig(cfg)
@@ -230,7 +272,6 @@ func createCleanupFunc() func() {
 
 	return func() {
 		mu.Lock()
-		defer mu.Unlock()
 
 		if baseLogger != nil {
 			if err := baseLogger.Sync(); err != nil {
@@ -238,12 +279,23 @@ func createCleanupFunc() func() {
 			}
 		}
 
+		closedActive := false
 		if currentFile != nil {
 			if err := currentFile.Close(); err != nil {
 				fmt.Fprintf(os.Stderr, "error closing log file: %v\n", err)
 			}
 			if logFile == currentFile {
 				logFile = nil
+				closedActive = true
+			}
+		}
+		level := currentConfig.Level
+		mu.Unlock()
+
+		// Loggers outlive the cleanup, so keep them writing to the console only
+		if closedActive {
+			if err := applyConfig(Config{Level: level}); err != nil {
+				fmt.Fprintf(os.Stderr, "error resetting logger: %v\n", err)
 			}
 		}
 	}
@@ -202,6 +202,69 @@ func TestInitWithConfigFile(t *testing.T) {
 	}
 }
 
+func TestInitWithConfigFileLevel(t *testing.T) {
+	resetLogger()
+
+	logPath := filepath.Join(t.TempDir(), "debug.log")
+
+	var console bytes.Buffer
+	origWriter := ReplaceStderrWriter(&console)
+	defer ReplaceStderrWriter(origWriter)
+
+	sugar, cleanup, err := InitWithConfig(Config{Level: "info", FilePath: logPath, FileLevel: "debug"})
+	if err != nil {
+		t.Fatalf("InitWithConfig returned error: %v", err)
+	}
+
+	sugar.Debug("file only debug message")
+	cleanup()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "file only debug message") {
+		t.Errorf("log file does not contain debug message: %s", data)
+	}
+	if strings.Contains(console.String(), "file only debug message") {
+		t.Errorf("console should not contain debug message: %s", console.String())
+	}
+}
+
+func TestInitWithConfigEarlyLogger(t *testing.T) {
+	resetLogger()
+
+	// Package-level loggers are taken before main configures the log file
+	early := Logger()
+	logPath := filepath.Join(t.TempDir(), "early.log")
+
+	var console bytes.Buffer
+	origWriter := ReplaceStderrWriter(&console)
+	defer ReplaceStderrWriter(origWriter)
+
+	_, cleanup, err := InitWithConfig(Config{Level: "info", FilePath: logPath, FileLevel: "debug"})
+	if err != nil {
+		t.Fatalf("InitWithConfig returned error: %v", err)
+	}
+
+	early.Debug("early logger debug message")
+	early.With("stage", "install").Info("early logger info message")
+	cleanup()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	for _, want := range []string{"early logger debug message", "early logger info message", "install"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("log file does not contain %q: %s", want, data)
+		}
+	}
+	if strings.Contains(console.String(), "early logger debug message") {
+		t.Errorf("console should not contain debug message: %s", console.String())
+	}
+}
+
 func TestLogger(t *testing.T) {
 	resetLogger()
 
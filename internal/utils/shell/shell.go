@@ -26,11 +26,14 @@ var commandMap = map[string][]string{
 	"bash":               {"/usr/bin/bash"},
 	"blkid":              {"/usr/sbin/blkid"},
 	"bootctl":            {"/usr/bin/bootctl"},
+	"btrfs":              {"/usr/bin/btrfs", "/bin/btrfs", "/usr/sbin/btrfs", "/sbin/btrfs"},
 	"bunzip2":            {"/usr/bin/bunzip2"},
 	"cat":                {"/bin/cat"},
 	"cd":                 {"cd"}, // 'cd' is a shell builtin, not a standalone command
 	"chroot":             {"/usr/sbin/chroot"},
 	"chmod":              {"/usr/bin/chmod"},
+	"chown":              {"/usr/bin/chown", "/bin/chown"},
+	"chattr":             {"/usr/bin/chattr", "/bin/chattr"},
 	"command":            {"command"}, // 'command' is a shell builtin
 	"cp":                 {"/bin/cp", "/usr/bin/cp"},
 	"createrepo_c":       {"/usr/bin/createrepo_c"},
@@ -40,6 +43,7 @@ var commandMap = map[string][]string{
 	"dirname":            {"/usr/bin/dirname"},
 	"dnf":                {"/usr/bin/dnf"},
 	"dpkg":               {"/usr/bin/dpkg"},
+	"dpkg-query":         {"/usr/bin/dpkg-query"},
 	"dpkg-scanpackages":  {"/usr/bin/dpkg-scanpackages"},
 	"echo":               {"/bin/echo", "/usr/bin/echo"},
 	"e2fsck":             {"/usr/sbin/e2fsck"},
@@ -61,6 +65,8 @@ var commandMap = map[string][]string{
 	"gzip":               {"/usr/bin/gzip"},
 	"head":               {"/usr/bin/head"},
 	"ln":                 {"/usr/bin/ln"},
+	"locale-gen":         {"/usr/sbin/locale-gen"},
+	"localedef":          {"/usr/bin/localedef"},
 	"ls":                 {"/bin/ls", "/usr/bin/ls"},
 	"lsof":               {"/usr/bin/lsof"},
 	"lsb_release":        {"/usr/bin/lsb_release"},
@@ -72,15 +78,18 @@ var commandMap = map[string][]string{
 	"mmdebstrap":         {"/usr/bin/mmdebstrap"},
 	"mkdir":              {"/bin/mkdir"},
 	"mkfs":               {"/usr/sbin/mkfs"},
+	"mksquashfs":         {"/usr/bin/mksquashfs"},
 	"mkswap":             {"/usr/sbin/mkswap"},
 	"mktemp":             {"/usr/bin/mktemp"},
 	"mount":              {"/usr/bin/mount"},
+	"openssl":            {"/usr/bin/openssl"},
 	"opkg":               {"/usr/bin/opkg"},
 	"parted":             {"/usr/sbin/parted"},
 	"partx":              {"/usr/bin/partx", "/sbin/partx"},
 	"pvcreate":           {"/usr/sbin/pvcreate"},
 	"qemu-img":           {"/usr/bin/qemu-img"},
 	"qemu-system-x86_64": {"/usr/bin/qemu-system-x86_64"},
+	"resize2fs":          {"/usr/sbin/resize2fs", "/sbin/resize2fs"},
 	"rm":                 {"/bin/rm"},
 	"rpm":                {"/usr/bin/rpm"},
 	"run":                {"/usr/bin/run"},
@@ -104,6 +113,7 @@ var commandMap = map[string][]string{
 	"umount":             {"/usr/bin/umount"},
 	"uname":              {"/usr/bin/uname"},
 	"uniq":               {"/usr/bin/uniq"},
+	"update-initramfs":   {"/usr/sbin/update-initramfs"},
 	"veritysetup":        {"/usr/sbin/veritysetup"},
 	"vgcreate":           {"/usr/sbin/vgcreate"},
 	"wipefs":             {"/usr/sbin/wipefs"},
@@ -111,7 +121,7 @@ var commandMap = map[string][]string{
 	"xz":                 {"/usr/bin/xz"},
 	"yum":                {"/usr/bin/yum"},
 	"zstd":               {"/usr/bin/zstd"},
-	"dracut":             {"/usr/bin/dracut"},
+	"dracut":             {"/usr/bin/dracut", "/usr/sbin/dracut"},
 	"useradd":            {"/usr/sbin/useradd"},
 	"usermod":            {"/usr/sbin/usermod"},
 	"groups":             {"/usr/bin/groups"},
@@ -511,7 +521,8 @@ func (d *DefaultExecutor) ExecCmdWithInput(inputStr string, cmdStr string, sudo
 		if outputStr != "" {
 			log.Infof(outputStr)
 		}
-		return outputStr, fmt.Errorf("failed to exec %s with input %s: %w", fullCmdStr, inputStr, err)
+		// The input may hold secrets such as passwords, so it is left out of the error
+		return outputStr, fmt.Errorf("failed to exec %s: %w", fullCmdStr, err)
 	} else {
 		if outputStr != "" {
 			log.Debugf(outputStr)